@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
+	"io"
+	"os"
+)
+
+const (
+	// a check rule or a lint failed, or the config could not be loaded
+	cExitFailed = 1
+	// check could not run
+	cExitError = 2
+)
+
+// exitError makes main exit with code so that CI pipelines can tell failures
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// check classifies logPath against the baseline and writes the result to outDir or stdout
+func check() error {
+	if err := runCheck(); err != nil {
+		if _, ok := err.(*exitError); ok {
+			return err
+		}
+		return &exitError{cExitError, err}
+	}
+	return nil
+}
+
+func runCheck() error {
+	if baselineDir == "" || logPath == "" {
+		return fmt.Errorf("baseline and logPath are mandatory for check")
+	}
+	if checkFormat != logan.CheckFormatJson && checkFormat != logan.CheckFormatJunit {
+		return fmt.Errorf("unknown format %s. %s or %s", checkFormat, logan.CheckFormatJson, logan.CheckFormatJunit)
+	}
+	logan.SetLockTimeout(lockTimeout)
+	a, err := logan.OpenWith(&logan.Config{DataDir: baselineDir}, logan.Options{ReadOnly: true, Debug: debug})
+	if err != nil {
+		return fmt.Errorf("failed to load the baseline %s: %w", baselineDir, err)
+	}
+	defer a.Close()
+
+	r, err := a.Check(logPath, maxShareDiff, checkMinLines)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if outDir != "" {
+		f, err := os.Create(outDir)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := r.Write(w, checkFormat); err != nil {
+		return err
+	}
+	if !r.Passed {
+		return &exitError{cExitFailed, fmt.Errorf("check failed: %d new groups, %d share changes in %s",
+			len(r.NewGroups), len(r.ShareChanges), logPath)}
+	}
+	return nil
+}
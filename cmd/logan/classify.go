@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"goLogAnalyzer/pkg/logan"
+	"io"
+	"os"
+)
+
+// classify writes the lines of inputPath, or stdin, with their log groups as NDJSON to outDir or stdout
+func classify(a *logan.Analyzer) error {
+	var r io.Reader = os.Stdin
+	if inputPath != "" && inputPath != "-" {
+		f, err := os.Open(inputPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	var w io.Writer = os.Stdout
+	if outDir != "" {
+		f, err := os.Create(outDir)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return a.Classify(context.Background(), r, w)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+feed registers the logs and shows the statistics of the lines.
+SIGINT or SIGTERM makes it stop at the next line and commit what it has registered,
+so that the next feed goes on from there. A second one kills logan as usual.
+*/
+func feed(a *logan.Analyzer) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-sigs:
+			signal.Stop(sigs)
+			logrus.Warnf("got %v. stopping feed at the next line", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if !silent {
+		a.ShowProgress(os.Stderr)
+	}
+	// the lines registered until the signal are committed
+	if err := a.Feed(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	printFeedStats(a.FeedStats())
+	return nil
+}
+
+// printFeedStats shows what became of the lines of each file fed
+func printFeedStats(stats []logan.FeedStats) {
+	if silent || len(stats) == 0 {
+		return
+	}
+	fmt.Printf("%-10s %-10s %-10s %-15s %-13s %-10s %s\n",
+		"Read", "Grouped", "Filtered", "FormatMismatch", "BadTimestamp", "Late", "File")
+	total := logan.FeedStats{File: "total"}
+	for _, s := range stats {
+		printFeedStatsRow(s)
+		total.Read += s.Read
+		total.Grouped += s.Grouped
+		total.Filtered += s.Filtered
+		total.FormatMismatch += s.FormatMismatch
+		total.BadTimestamp += s.BadTimestamp
+		total.Late += s.Late
+	}
+	if len(stats) > 1 {
+		printFeedStatsRow(total)
+	}
+}
+
+func printFeedStatsRow(s logan.FeedStats) {
+	fmt.Printf("%-10d %-10d %-10d %-15d %-13d %-10d %s\n",
+		s.Read, s.Grouped, s.Filtered, s.FormatMismatch, s.BadTimestamp, s.Late, s.File)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
+	"goLogAnalyzer/pkg/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+showGroups prints the groups, or the history of groupId,
+or writes them to CSV files in outDir with the history of all the groups selected.
+*/
+func showGroups(a *logan.Analyzer, history bool) error {
+	q := logan.Query{
+		Limit:        N,
+		Search:       searchString,
+		Exclude:      excludeString,
+		UpdatedSince: unixTime(minLastUpdate),
+		From:         unixTime(fromEpoch),
+		To:           unixTime(toEpoch),
+		MinCount:     minLogCount,
+		MaxCount:     maxLogCount,
+		Asc:          ascOrder,
+	}
+	by := groupBy
+	if history {
+		by = ""
+	}
+	if outDir != "" {
+		return a.WriteGroups(outDir, q, history, by)
+	}
+
+	if by != "" && by != logan.GroupBySource {
+		return fmt.Errorf("unknown dimension %s to break down groups by", by)
+	}
+	if history {
+		if groupId <= 0 {
+			return fmt.Errorf("you need to specify a groupId for history")
+		}
+		h, err := a.History(groupId, q.From, q.To)
+		if err != nil {
+			return err
+		}
+		printLogGroupHistory(h)
+		return nil
+	}
+	groups, err := a.Groups(q)
+	if err != nil {
+		return err
+	}
+	if by == logan.GroupBySource {
+		// the groups have counts by source if the dataDir has them
+		if len(groups) > 0 && groups[0].Sources == nil {
+			return fmt.Errorf("%s has no source counts. set sourceFrom and feed again", dataDir)
+		}
+		printLogGroupsBySource(groups)
+	} else {
+		printLogGroups(groups)
+	}
+	return nil
+}
+
+// the zero time for 0, no limit in logan.Query
+func unixTime(epoch int64) time.Time {
+	if epoch <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(epoch, 0)
+}
+
+func printLogGroups(groups []logan.Group) {
+	// Print header for log groups
+	fmt.Println("Log Groups")
+	fmt.Println("==========")
+	fmt.Printf("%-10s %-10s %-s\n", "groupId", "Count", "Text")
+	for _, g := range groups {
+		fmt.Printf("%-10d %-10d %s\n", g.GroupId, g.Count, g.DisplayString)
+		// counts by host of merged dataDirs
+		if g.Sources != nil {
+			fmt.Printf("%-10s %-10s %s\n", "", "", strings.Join(formatSources(g.Sources), " "))
+		}
+	}
+	fmt.Println()
+}
+
+// counts of the groups in columns by source
+func printLogGroupsBySource(groups []logan.Group) {
+	totals := make(map[string]int)
+	for _, g := range groups {
+		for source, count := range g.Sources {
+			totals[source] += count
+		}
+	}
+	sources := sortSources(totals)
+	widths := make([]int, len(sources))
+
+	fmt.Println("Log Groups by source")
+	fmt.Println("====================")
+	fmt.Printf("%-10s %-10s ", "groupId", "Count")
+	for i, source := range sources {
+		widths[i] = max(len(source), 6)
+		fmt.Printf("%*s ", widths[i], source)
+	}
+	fmt.Println("Text")
+	for _, g := range groups {
+		fmt.Printf("%-10d %-10d ", g.GroupId, g.Count)
+		for i, source := range sources {
+			fmt.Printf("%*d ", widths[i], g.Sources[source])
+		}
+		fmt.Println(g.DisplayString)
+	}
+	fmt.Println()
+}
+
+func printLogGroupHistory(h *logan.History) {
+	// Print header for log group history
+	fmt.Printf("History for Log Group %d\n", h.GroupId)
+	fmt.Println("=======================")
+	fmt.Printf("%-20s %-10s\n", "Timestamp", "Value")
+	for _, p := range h.Points {
+		fmt.Printf("%-20s %-10d\n", utils.EpochToString(p.Time.Unix()), p.Count)
+	}
+	fmt.Println()
+}
+
+// the sources of counts in descending order of the counts
+func sortSources(counts map[string]int) []string {
+	sources := make([]string, 0, len(counts))
+	for source := range counts {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if counts[sources[i]] == counts[sources[j]] {
+			return sources[i] < sources[j]
+		}
+		return counts[sources[i]] > counts[sources[j]]
+	})
+	return sources
+}
+
+// "source=count" in descending order of counts
+func formatSources(counts map[string]int) []string {
+	sources := sortSources(counts)
+	for i, source := range sources {
+		sources[i] = fmt.Sprintf("%s=%d", source, counts[source])
+	}
+	return sources
+}
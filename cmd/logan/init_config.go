@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// a starter config file for the log format detected in logPath
+func newStarterConfig(d *logan.DetectedLogFormat, logPath, dataDir string) ([]byte, error) {
+	if dataDir == "" {
+		dataDir = fmt.Sprintf("{{ HOME }}/logan/%s", getLogName(logPath))
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	add := func(key, value, tag, comment string) *yaml.Node {
+		k := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		root.Content = append(root.Content, k,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, LineComment: comment})
+		return k
+	}
+
+	first := add("dataDir", dataDir, "!!str", "")
+	first.HeadComment = fmt.Sprintf("generated by logan init from %s", logPath)
+	add("logPath", logPath, "!!str", "")
+	if d.Name == "" {
+		add("separators", d.Separators, "!!str", "").HeadComment =
+			fmt.Sprintf("no known timestamp format matched the %d sample lines.\n"+
+				"the whole line is grouped and the time of the file is used. add logFormat and timestampLayout", d.Sampled)
+	} else {
+		add("logFormat", d.LogFormat, "!!str", "").HeadComment =
+			fmt.Sprintf("%s: %d of %d sample lines matched", d.Name, d.Matched, d.Sampled)
+		add("timestampLayout", d.TimestampLayout, "!!str", "")
+		if d.UseUtcTime {
+			add("useUtcTime", "true", "!!bool", "the time zone is in the timestamps")
+		}
+		add("separators", d.Separators, "!!str", "")
+	}
+	add("unitSecs", strconv.FormatInt(utils.GetUnitsecs(utils.CFreqDay), 10), "!!int", "daily")
+	add("keepPeriod", strconv.Itoa(logan.DefaultKeepPeriod), "!!int", "units to keep the analyzed data")
+
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(sb.String()), nil
+}
+
+// syslog of /var/log/syslog*
+func getLogName(logPath string) string {
+	name := filepath.Base(logPath)
+	if fields := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	}); len(fields) > 0 {
+		return fields[0]
+	}
+	return "logs"
+}
+
+/*
+initConfig detects the log format of logPath and writes a starter config file to outDir,
+or to stdout without -o. The written file is linted with the sample lines.
+*/
+func initConfig() error {
+	if logPath == "" {
+		return fmt.Errorf("logPath is mandatory for init")
+	}
+	if outDir != "" && utils.PathExist(outDir) {
+		return fmt.Errorf("%s already exists", outDir)
+	}
+	d, err := logan.DetectLogFormat(logPath, logan.DefaultDetectSampleLines)
+	if err != nil {
+		return err
+	}
+	data, err := newStarterConfig(d, logPath, dataDir)
+	if err != nil {
+		return err
+	}
+	if outDir == "" {
+		fmt.Print(string(data))
+		return nil
+	}
+	if err := os.WriteFile(outDir, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s written\n", outDir)
+
+	problems, err := lintConfig(outDir, logan.DefaultLintSampleLines)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	fmt.Printf("check a line with:\nlogan test -c %s -line '%s'\n", outDir, strings.ReplaceAll(d.FirstLine, "'", `'\''`))
+	return nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/logan"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	reEnvVar     = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+	reProblemKey = regexp.MustCompile(`^(\w+)(?:\[(\d+)\])?`)
+)
+
+// a problem of the config file with the line of its key. line is 0 for keys not in the file
+type lintProblem struct {
+	logan.ConfigProblem
+	path string
+	line int
+}
+
+func (p lintProblem) String() string {
+	pos := p.path
+	if p.line > 0 {
+		pos = fmt.Sprintf("%s:%d", p.path, p.line)
+	}
+	return fmt.Sprintf("%-5s %s %s: %s", p.Severity, pos, p.Key, p.Message)
+}
+
+// readConfig parses the YAML at path replacing {{ VAR }} with environment variables.
+// doc keeps the lines of the keys
+func readConfig(path string) (*config, *yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c := new(config)
+	if len(doc.Content) == 0 {
+		return c, &doc, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s: line %d: the config must be a YAML mapping", path, root.Line)
+	}
+	replaceEnvVarsInNode(root)
+	if err := root.Decode(c); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, &doc, nil
+}
+
+// replaceEnvVarsInNode replaces {{ VAR }} placeholders with environment variables recursively in mappings
+func replaceEnvVarsInNode(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(node.Content); i += 2 {
+		value := node.Content[i]
+		switch {
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!str":
+			value.Value = reEnvVar.ReplaceAllStringFunc(value.Value, func(placeholder string) string {
+				return os.Getenv(reEnvVar.FindStringSubmatch(placeholder)[1])
+			})
+		case value.Kind == yaml.MappingNode:
+			replaceEnvVarsInNode(value)
+		}
+	}
+}
+
+/*
+lintConfig checks the config file at path.
+
+  - keys the config struct does not know, with the closest known key
+  - the values by logan.LintConfig, sampling sampleLines lines of logPath
+  - patternDetectionMode
+
+Errors are returned for files that are not YAML at all.
+*/
+func lintConfig(path string, sampleLines int) ([]lintProblem, error) {
+	c, doc, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	var problems []lintProblem
+	if len(doc.Content) > 0 {
+		for _, p := range unknownKeys(doc.Content[0], reflect.TypeOf(*c), "") {
+			p.path = path
+			problems = append(problems, p)
+		}
+	}
+
+	conf := c.toConfig()
+	found := logan.LintConfig(conf, sampleLines)
+	switch c.PatternDetectionMode {
+	case "", "firstMatch", "relations":
+	default:
+		found = append(found, logan.ConfigProblem{Severity: csvdb.CSeverityError, Key: "patternDetectionMode",
+			Message: fmt.Sprintf("unknown mode %s. firstMatch or relations", c.PatternDetectionMode)})
+	}
+	if len(c.PatternKeyRegexes) > 0 && c.PatternDetectionMode == "" {
+		found = append(found, logan.ConfigProblem{Severity: csvdb.CSeverityWarning, Key: "patternDetectionMode",
+			Message: "empty. patterns needs firstMatch or relations"})
+	}
+	for _, p := range found {
+		problems = append(problems, lintProblem{ConfigProblem: p, path: path, line: findKeyLine(doc, p.Key)})
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].line < problems[j].line })
+	return problems, nil
+}
+
+// keys of mapping not in the yaml tags of t. slices of structs are checked item by item
+func unknownKeys(mapping *yaml.Node, t reflect.Type, prefix string) []lintProblem {
+	if mapping.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make(map[string]reflect.Type)
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = t.Field(i).Type
+		names = append(names, name)
+	}
+
+	var problems []lintProblem
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		ft, ok := fields[key.Value]
+		if !ok {
+			msg := "unknown key. it is ignored"
+			if guess := closestKey(key.Value, names); guess != "" {
+				msg = fmt.Sprintf("unknown key. did you mean %s?", guess)
+			}
+			problems = append(problems, lintProblem{
+				ConfigProblem: logan.ConfigProblem{Severity: csvdb.CSeverityWarning, Key: prefix + key.Value, Message: msg},
+				line:          key.Line,
+			})
+			continue
+		}
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct && value.Kind == yaml.SequenceNode {
+			for j, item := range value.Content {
+				problems = append(problems, unknownKeys(item, ft.Elem(), fmt.Sprintf("%s%s[%d].", prefix, key.Value, j))...)
+			}
+		}
+	}
+	return problems
+}
+
+// the closest known key containing key or within an edit distance of a third of its length
+func closestKey(key string, names []string) string {
+	key = strings.ToLower(key)
+	limit := len(key)/3 + 1
+	best, bestDist := "", 0
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		d := editDistance(key, lower)
+		if d >= limit && !strings.Contains(lower, key) {
+			continue
+		}
+		if best == "" || d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// line of key like patternKeyRegexes[0] or retention in doc. 0 if not found
+func findKeyLine(doc *yaml.Node, key string) int {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return 0
+	}
+	ma := reProblemKey.FindStringSubmatch(key)
+	if ma == nil {
+		return 0
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != ma[1] {
+			continue
+		}
+		value := root.Content[i+1]
+		if ma[2] != "" && value.Kind == yaml.SequenceNode {
+			if j, err := strconv.Atoi(ma[2]); err == nil && j < len(value.Content) {
+				return value.Content[j].Line
+			}
+		}
+		return root.Content[i].Line
+	}
+	return 0
+}
+
+// configLint prints the problems of configPath. an error if any of them is an error
+func configLint() error {
+	if configPath == "" {
+		return fmt.Errorf("configPath is mandatory for config lint")
+	}
+	problems, err := lintConfig(configPath, logan.DefaultLintSampleLines)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: no problems found\n", configPath)
+		return nil
+	}
+	errors := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if p.IsError() {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return &exitError{cExitFailed, fmt.Errorf("%d errors found in %s", errors, configPath)}
+	}
+	return nil
+}
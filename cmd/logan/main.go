@@ -2,13 +2,14 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"goLogAnalyzer/internal/logan"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/logan"
 	"goLogAnalyzer/pkg/utils"
-	"io/ioutil"
 	"os"
-	"regexp"
 	"runtime"
 	"strings"
 	"time"
@@ -18,9 +19,15 @@ import (
 )
 
 const (
-	usageStr = "usage: logan feed|history|groups|patterns|clean|test"
+	usageStr = "usage: logan feed|history|groups|patterns|export|clean|test|fsck|migrate|merge|tui|report|config lint|init|check|classify|model export|model import"
 )
 
+// commands of two words like "config lint" and the commands they run
+var subCommands = map[string]map[string]string{
+	"config": {"lint": "lint"},
+	"model":  {"export": "modelExport", "import": "modelImport"},
+}
+
 var (
 	configPath           string
 	debug                bool
@@ -41,6 +48,7 @@ var (
 	blockSize            int
 	keepPeriod           int64
 	unitSecs             int64
+	reorderWindow        int64
 	minMatchRate         float64
 	termCountBorderRate  float64
 	termCountBorder      int
@@ -59,9 +67,9 @@ var (
 	B                    int
 	cmd                  string
 	useUtcTime           bool
+	timezone             string
 	separators           string
 	_flagSet             *flag.FlagSet
-	loaded               bool
 	ascOrder             bool
 	analLogPath          string
 	ignoreNumbers        bool
@@ -72,39 +80,66 @@ var (
 	minOccurrences       float64
 	lastFileEpoch        int64
 	groupId              int64
+	repair               bool
+	lockTimeout          = logan.DefaultLockTimeout // for the commands without -lockTimeout
+	exportFormat         string
+	storage              string
+	retention            []logan.RetentionTier
+	sourceFrom           string
+	maskers              []string
+	rejectFile           string
+	memoryBudget         int
+	groupBy              string
+	_from                string
+	_to                  string
+	fromEpoch            int64
+	toEpoch              int64
+	baselineDir          string
+	checkFormat          string
+	maxShareDiff         float64
+	checkMinLines        int
+	inputPath            string
 )
 
 type config struct {
-	DataDir              string   `yaml:"dataDir"`
-	LogPath              string   `yaml:"logPath"`
-	SearchRegex          []string `yaml:"searchRegex"`
-	ExcludeRegex         []string `yaml:"excludeRegex"`
-	LogFormat            string   `yaml:"logFormat"`
-	MsgFormats           []string `yaml:"msgFormats"`
-	PatternKeyRegexes    []string `yaml:"patternKeyRegexes"`
-	PatternDetectionMode string   `yaml:"patternDetectionMode"`
-	TimestampLayout      string   `yaml:"timestampLayout"`
-	KeepPeriod           int64    `yaml:"keepPeriod"`
-	UnitSecs             int64    `yaml:"unitSecs"`
-	MaxBlocks            int      `yaml:"maxBlocks"`
-	BlockSize            int      `yaml:"blockSize"`
-	MinMatchRate         float64  `yaml:"minMatchRate"`
-	TermCountBorderRate  float64  `yaml:"termCountBorderRate"`
-	TermCountBorder      int      `yaml:"termCountBorder"`
-	Keywords             []string `yaml:"keywords"`
-	Ignorewords          []string `yaml:"ignorewords"`
-	KeyRegexes           []string `yaml:"keyRegexes"`
-	IgnoreRegexes        []string `yaml:"ignoreRegexes"`
-	CustomLogGroups      []string `yaml:"phrases"`
-	UseUtcTime           bool     `yaml:"useUtcTime"`
-	OutDir               string   `yaml:"outDir"`
-	Separators           string   `yaml:"separators"`
-	IgnoreNumbers        bool     `yaml:"ignoreNumbers"`
-	DaysToShow           int64    `yaml:"daysToShow"`
-	MinLogCount          int      `yaml:"minLogCount"`
-	MaxLogCount          int      `yaml:"maxLogCount"`
-	StdThreshold         float64  `yaml:"stdThreshold"`
-	MinOccurrences       float64  `yaml:"minOccurrences"`
+	DataDir              string                `yaml:"dataDir"`
+	LogPath              string                `yaml:"logPath"`
+	SearchRegex          []string              `yaml:"searchRegex"`
+	ExcludeRegex         []string              `yaml:"excludeRegex"`
+	LogFormat            string                `yaml:"logFormat"`
+	MsgFormats           []string              `yaml:"msgFormats"`
+	PatternKeyRegexes    []string              `yaml:"patternKeyRegexes"`
+	PatternDetectionMode string                `yaml:"patternDetectionMode"`
+	TimestampLayout      string                `yaml:"timestampLayout"`
+	KeepPeriod           int64                 `yaml:"keepPeriod"`
+	UnitSecs             int64                 `yaml:"unitSecs"`
+	ReorderWindow        int64                 `yaml:"reorderWindow"`
+	MaxBlocks            int                   `yaml:"maxBlocks"`
+	BlockSize            int                   `yaml:"blockSize"`
+	MinMatchRate         float64               `yaml:"minMatchRate"`
+	TermCountBorderRate  float64               `yaml:"termCountBorderRate"`
+	TermCountBorder      int                   `yaml:"termCountBorder"`
+	Keywords             []string              `yaml:"keywords"`
+	Ignorewords          []string              `yaml:"ignorewords"`
+	KeyRegexes           []string              `yaml:"keyRegexes"`
+	IgnoreRegexes        []string              `yaml:"ignoreRegexes"`
+	CustomLogGroups      []string              `yaml:"phrases"`
+	UseUtcTime           bool                  `yaml:"useUtcTime"`
+	Timezone             string                `yaml:"timezone"`
+	OutDir               string                `yaml:"outDir"`
+	Separators           string                `yaml:"separators"`
+	IgnoreNumbers        bool                  `yaml:"ignoreNumbers"`
+	DaysToShow           int64                 `yaml:"daysToShow"`
+	MinLogCount          int                   `yaml:"minLogCount"`
+	MaxLogCount          int                   `yaml:"maxLogCount"`
+	StdThreshold         float64               `yaml:"stdThreshold"`
+	MinOccurrences       float64               `yaml:"minOccurrences"`
+	Storage              string                `yaml:"storage"`
+	Retention            []logan.RetentionTier `yaml:"retention"`
+	SourceFrom           string                `yaml:"sourceFrom"`
+	Maskers              []string              `yaml:"maskers"`
+	RejectFile           string                `yaml:"rejectFile"`
+	MemoryBudget         int                   `yaml:"memoryBudget"`
 }
 
 func setCommonFlag(fs *flag.FlagSet) {
@@ -131,6 +166,10 @@ func setCommonFlag(fs *flag.FlagSet) {
 	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
 	fs.Int64Var(&lastFileEpoch, "lastEpoch", 0, "last epoch of the log file")
 	fs.Int64Var(&groupId, "groupId", -1, "logGroup id to show the history")
+	fs.DurationVar(&lockTimeout, "lockTimeout", logan.DefaultLockTimeout, "how long to wait for another logan process using the same dataDir")
+	fs.StringVar(&sourceFrom, "sourceFrom", "", "where to pick up the source of lines. filename, regex:<regexp> or field:<n>")
+	fs.StringVar(&rejectFile, "rejectFile", "", "file to write a sample of the lines not matching logFormat or with a bad timestamp to")
+	fs.IntVar(&memoryBudget, "memoryBudget", 0, "MB of memory to keep the terms and log groups in. 0 for no limit on the terms")
 }
 
 func setNonFeedFlag(fs *flag.FlagSet) {
@@ -146,164 +185,324 @@ func setOutFlag(fs *flag.FlagSet) {
 	fs.IntVar(&minLogCount, "minCount", 0, "Minimum of logGroup size")
 	fs.IntVar(&maxLogCount, "maxCount", 0, "Maximum of logGroup size")
 	fs.Int64Var(&minLastUpdate, "lastepoch", 0, "minimum of the last updated epoch to show in output")
+	fs.StringVar(&groupBy, "by", "", "break down logGroup counts. source")
+	fs.StringVar(&_from, "from", "", "count logs from this time. epoch, RFC3339, yyyy-mm-dd or a duration from now like -2h")
+	fs.StringVar(&_to, "to", "", "count logs until this time. same formats as -from")
 }
 
-func setParseLineFlag(fs *flag.FlagSet) {
+func setFsckFlag(fs *flag.FlagSet) {
+	setCommonFlag(fs)
+	fs.BoolVar(&repair, "repair", false, "Drop or rebuild the broken parts of the data directory")
+}
+
+func setExportFlag(fs *flag.FlagSet) {
+	setNonFeedFlag(fs)
+	fs.StringVar(&outDir, "o", "", "Output file for sqlite, output directory for parquet")
+	fs.StringVar(&exportFormat, "format", logan.ExportFormatSqlite, "sqlite or parquet")
+}
+
+func setMigrateFlag(fs *flag.FlagSet) {
+	setCommonFlag(fs)
+	fs.StringVar(&storage, "storage", "", "Storage to migrate the data directory to. csv or bin")
+}
+
+func setMergeFlag(fs *flag.FlagSet) {
 	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
 	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
-	fs.StringVar(&configPath, "c", "", "Path to the configuration file")
-	fs.StringVar(&line, "line", "", "Log line to analyze")
+	fs.StringVar(&outDir, "o", "", "Data directory to create with the merged data")
 	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
-
+	fs.DurationVar(&lockTimeout, "lockTimeout", logan.DefaultLockTimeout, "how long to wait for another logan process using the same dataDir")
 }
 
-func init() {
-	loaded = false
+func setInitFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
+	fs.StringVar(&logPath, "f", "", "Log file to sample")
+	fs.StringVar(&dataDir, "d", "", "Data directory to write in the config file")
+	fs.StringVar(&outDir, "o", "", "Config file to write. stdout if empty")
+	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
 }
 
-func loadConfig(path string) error {
-	logrus.WithField("path", path).Info("Loading configuration")
-
-	// Read the YAML file
-	yamlFile, err := ioutil.ReadFile(path)
-	if err != nil {
-		logrus.Fatalf("Error reading YAML file: %v", err)
-	}
+func setCheckFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
+	fs.StringVar(&baselineDir, "baseline", "", "Data directory fed with known-good logs")
+	fs.StringVar(&logPath, "f", "", "Log file to check")
+	fs.StringVar(&outDir, "o", "", "Output file. stdout if empty")
+	fs.StringVar(&checkFormat, "format", logan.CheckFormatJson, "json or junit")
+	fs.Float64Var(&maxShareDiff, "maxShareDiff", logan.DefaultMaxShareDiff, "fail when the share of lines of a group moves more than this from the baseline")
+	fs.IntVar(&checkMinLines, "minLines", logan.DefaultCheckMinLines, "check the shares of the groups only in logs of this many lines or more")
+	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
+	fs.DurationVar(&lockTimeout, "lockTimeout", logan.DefaultLockTimeout, "how long to wait for another logan process using the same dataDir")
+}
 
-	// Load YAML content into a temporary map to process environment replacements
-	var tempConfig map[string]interface{}
-	if err := yaml.Unmarshal(yamlFile, &tempConfig); err != nil {
-		logrus.Fatalf("Error unmarshalling YAML: %v", err)
-	}
+func setClassifyFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
+	fs.StringVar(&configPath, "c", "", "Path to the configuration file")
+	fs.StringVar(&inputPath, "f", "", "Log file to classify. stdin if empty or -")
+	fs.StringVar(&outDir, "o", "", "Output file. stdout if empty")
+	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
+	fs.DurationVar(&lockTimeout, "lockTimeout", logan.DefaultLockTimeout, "how long to wait for another logan process using the same dataDir")
+}
 
-	// Recursively replace environment variables in the map
-	replaceEnvVarsInMap(tempConfig)
+func setModelExportFlag(fs *flag.FlagSet) {
+	setNonFeedFlag(fs)
+	fs.StringVar(&outDir, "o", "", "Model file to write")
+}
 
-	// Marshal modified map back to YAML to load it into the actual config struct
-	modifiedYaml, err := yaml.Marshal(tempConfig)
-	if err != nil {
-		logrus.Fatalf("Error re-marshalling YAML: %v", err)
-	}
+func setModelImportFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
+	fs.StringVar(&configPath, "c", "", "Path to the configuration file to take dataDir from")
+	fs.StringVar(&dataDir, "d", "", "Data directory to create")
+	fs.StringVar(&inputPath, "f", "", "Model file to import")
+	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
+}
 
-	// Now unmarshal into the actual config struct
-	var c config
-	if err := yaml.Unmarshal(modifiedYaml, &c); err != nil {
-		logrus.Fatalf("Error unmarshalling modified YAML: %v", err)
-	}
+func setLintFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
+	fs.StringVar(&configPath, "c", "", "Path to the configuration file to check")
+	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
+}
 
-	// Set defaults
-	applyConfigValues(&c)
+func setParseLineFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&debug, "debug", false, "Enable debug mode")
+	fs.BoolVar(&silent, "silent", false, "Enable silent mode")
+	fs.StringVar(&configPath, "c", "", "Path to the configuration file")
+	fs.StringVar(&line, "line", "", "Log line to analyze")
+	fs.StringVar(&analLogPath, "anallog", "", "File to output logs of this application")
 
-	return nil
 }
 
-// replaceEnvVarsInMap replaces {{ VAR }} placeholders with environment variables recursively in maps
-func replaceEnvVarsInMap(data map[string]interface{}) {
-	re := regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
-	for key, value := range data {
-		switch v := value.(type) {
-		case string:
-			// Replace environment variables in string values
-			data[key] = re.ReplaceAllStringFunc(v, func(placeholder string) string {
-				varName := re.FindStringSubmatch(placeholder)[1]
-				return os.Getenv(varName)
-			})
-		case map[string]interface{}:
-			// Recursively replace in nested maps
-			replaceEnvVarsInMap(v)
-		}
-	}
+// resetGlobals puts the variables above back to their defaults.
+// main() runs more than once in a process in the tests and the flags and the
+// config values of a run must not leak into the next one
+func resetGlobals() {
+	configPath, dataDir, logPath, searchString, excludeString = "", "", "", "", ""
+	logFormat, patternDetectionMode, timestampLayout, line, outDir = "", "", "", "", ""
+	_keywords, _ignorewords, _keyRegexes, _ignoreRegexes = "", "", "", ""
+	cmd, timezone, separators, analLogPath, exportFormat, storage = "", "", "", "", "", ""
+	sourceFrom, rejectFile, groupBy, _from, _to = "", "", "", "", ""
+	baselineDir, checkFormat, inputPath = "", "", ""
+	searchRegex, excludeRegex, msgFormats, patternKeyRegexes = nil, nil, nil, nil
+	keywords, ignorewords, keyRegexes, ignoRegexes, customLogGroups, maskers = nil, nil, nil, nil, nil, nil
+	retention = nil
+	debug, silent, readOnly, useUtcTime, ascOrder, ignoreNumbers, repair = false, false, false, false, false, false, false
+	maxBlocks, blockSize, termCountBorder, N, B, minLogCount, maxLogCount, memoryBudget = 0, 0, 0, 0, 0, 0, 0, 0
+	checkMinLines = 0
+	keepPeriod, unitSecs, reorderWindow, minLastUpdate, lastFileEpoch, groupId = 0, 0, 0, 0, 0, 0
+	fromEpoch, toEpoch = 0, 0
+	minMatchRate, termCountBorderRate, stdThreshold, minOccurrences, maxShareDiff = 0, 0, 0, 0, 0
+	lockTimeout = logan.DefaultLockTimeout
+	_flagSet = nil
 }
 
-// applyConfigValues applies default values for config fields if they are unset
-func applyConfigValues(c *config) {
-	if dataDir == "" {
-		dataDir = c.DataDir
-	}
-	if logPath == "" {
-		logPath = c.LogPath
-	}
-	if outDir == "" {
-		outDir = c.OutDir
-	}
-	if searchRegex == nil {
-		searchRegex = c.SearchRegex
+// loadConfig reads the config file. warnings of the file are logged and errors are returned
+func loadConfig(path string) (*config, error) {
+	logrus.WithField("path", path).Info("Loading configuration")
+
+	c, _, err := readConfig(path)
+	if err != nil {
+		return nil, err
 	}
-	if excludeRegex == nil {
-		excludeRegex = c.ExcludeRegex
+	problems, err := lintConfig(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	errors := 0
+	for _, p := range problems {
+		if p.IsError() {
+			logrus.Error(p.String())
+			errors++
+		} else {
+			logrus.Warn(p.String())
+		}
 	}
-	if logFormat == "" {
-		logFormat = c.LogFormat
+	if errors > 0 {
+		return nil, fmt.Errorf("%d errors found in %s. run 'logan config lint -c %s' to check the log lines too",
+			errors, path, path)
 	}
-	if len(msgFormats) == 0 {
-		msgFormats = c.MsgFormats
+	return c, nil
+}
+
+// addWordToConfig appends word to the list of key in the YAML file, keeping the comments
+func addWordToConfig(path, key, word string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
 	}
-	if len(patternKeyRegexes) == 0 {
-		patternKeyRegexes = c.PatternKeyRegexes
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
 	}
-	if patternDetectionMode == "" {
-		patternDetectionMode = c.PatternDetectionMode
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a YAML mapping", path)
 	}
+	root := doc.Content[0]
 
-	if timestampLayout == "" {
-		timestampLayout = c.TimestampLayout
+	var list *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			list = root.Content[i+1]
+		}
 	}
-	if keepPeriod == 0 {
-		keepPeriod = c.KeepPeriod
+	if list == nil {
+		list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, list)
 	}
-	if unitSecs == 0 {
-		unitSecs = c.UnitSecs
+	// "key:" without values
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		list.Kind, list.Tag, list.Value = yaml.SequenceNode, "!!seq", ""
 	}
-	if blockSize == 0 {
-		blockSize = c.BlockSize
+	if list.Kind != yaml.SequenceNode {
+		return fmt.Errorf("%s in %s is not a list", key, path)
 	}
-	if maxBlocks == 0 {
-		maxBlocks = c.MaxBlocks
+	for _, n := range list.Content {
+		if n.Value == word {
+			return nil
+		}
 	}
-	if minMatchRate == 0 {
-		minMatchRate = c.MinMatchRate
+	list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: word})
+
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
 	}
-	if termCountBorderRate == 0 {
-		termCountBorderRate = c.TermCountBorderRate
+	if err := enc.Close(); err != nil {
+		return err
 	}
-	if termCountBorder == 0 {
-		termCountBorder = c.TermCountBorder
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
 	}
-	if keywords == nil {
-		keywords = c.Keywords
+	return os.WriteFile(path, []byte(sb.String()), st.Mode().Perm())
+}
+
+/*
+applyConfigValues merges the flags and the config file c. The flags set win and
+the config file fills the rest. Both the variables and c end up with the values,
+so that c.toConfig() is the analyzer config the command runs with.
+*/
+func applyConfigValues(c *config) {
+	if len(c.SearchRegex) == 0 && searchString != "" {
+		searchRegex = []string{searchString}
 	}
-	if ignorewords == nil {
-		ignorewords = c.Ignorewords
+	if len(c.ExcludeRegex) == 0 && excludeString != "" {
+		excludeRegex = []string{excludeString}
 	}
-	if keyRegexes == nil {
-		keyRegexes = c.KeyRegexes
+	if _keywords != "" {
+		keywords = strings.Split(_keywords, ",")
 	}
-	if ignoRegexes == nil {
-		ignoRegexes = c.IgnoreRegexes
+	if _ignorewords != "" {
+		ignorewords = strings.Split(_ignorewords, ",")
 	}
-	ignoreNumbers = c.IgnoreNumbers
-	if customLogGroups == nil {
-		customLogGroups = c.CustomLogGroups
+	if _keyRegexes != "" {
+		keyRegexes = strings.Split(_keyRegexes, ",")
 	}
-	if separators == "" {
-		separators = c.Separators
+	if _ignoreRegexes != "" {
+		ignoRegexes = strings.Split(_ignoreRegexes, ",")
 	}
+
+	mergeValue(&dataDir, &c.DataDir)
+	mergeValue(&logPath, &c.LogPath)
+	mergeValue(&outDir, &c.OutDir)
+	mergeSlice(&retention, &c.Retention)
+	mergeValue(&storage, &c.Storage)
+	mergeValue(&sourceFrom, &c.SourceFrom)
+	mergeSlice(&maskers, &c.Maskers)
+	mergeValue(&rejectFile, &c.RejectFile)
+	mergeValue(&memoryBudget, &c.MemoryBudget)
+	mergeValue(&timezone, &c.Timezone)
+	mergeValue(&useUtcTime, &c.UseUtcTime)
+	mergeSlice(&searchRegex, &c.SearchRegex)
+	mergeSlice(&excludeRegex, &c.ExcludeRegex)
+	mergeValue(&logFormat, &c.LogFormat)
+	mergeSlice(&msgFormats, &c.MsgFormats)
+	mergeSlice(&patternKeyRegexes, &c.PatternKeyRegexes)
+	mergeValue(&patternDetectionMode, &c.PatternDetectionMode)
+	mergeValue(&timestampLayout, &c.TimestampLayout)
+	mergeValue(&keepPeriod, &c.KeepPeriod)
+	mergeValue(&unitSecs, &c.UnitSecs)
+	mergeValue(&reorderWindow, &c.ReorderWindow)
+	mergeValue(&blockSize, &c.BlockSize)
+	mergeValue(&maxBlocks, &c.MaxBlocks)
+	mergeValue(&minMatchRate, &c.MinMatchRate)
+	mergeValue(&termCountBorderRate, &c.TermCountBorderRate)
+	mergeValue(&termCountBorder, &c.TermCountBorder)
+	mergeSlice(&keywords, &c.Keywords)
+	mergeSlice(&ignorewords, &c.Ignorewords)
+	mergeSlice(&keyRegexes, &c.KeyRegexes)
+	mergeSlice(&ignoRegexes, &c.IgnoreRegexes)
+	mergeValue(&ignoreNumbers, &c.IgnoreNumbers)
+	mergeSlice(&customLogGroups, &c.CustomLogGroups)
+	mergeValue(&separators, &c.Separators)
+	mergeValue(&minLogCount, &c.MinLogCount)
+	mergeValue(&maxLogCount, &c.MaxLogCount)
+	mergeValue(&stdThreshold, &c.StdThreshold)
+	mergeValue(&minOccurrences, &c.MinOccurrences)
 	if minLastUpdate == 0 && c.DaysToShow > 0 {
 		minLastUpdate = utils.GetNdaysBefore(int(c.DaysToShow))
 	}
-	if minLogCount == 0 {
-		minLogCount = c.MinLogCount
-	}
-	if maxLogCount == 0 {
-		maxLogCount = c.MaxLogCount
-	}
-	if stdThreshold == 0 {
-		stdThreshold = c.StdThreshold
+}
+
+// the flag if set, the config file otherwise
+func mergeValue[T comparable](flag, file *T) {
+	var zero T
+	if *flag == zero {
+		*flag = *file
+	} else {
+		*file = *flag
 	}
-	if minOccurrences == 0 {
-		minOccurrences = c.MinOccurrences
+}
+
+func mergeSlice[T any](flag, file *[]T) {
+	if len(*flag) == 0 {
+		*flag = *file
+	} else {
+		*file = *flag
 	}
+}
 
+// toConfig maps the config file to the analyzer config as logan.NewWith gets it
+func (c *config) toConfig() *logan.Config {
+	return &logan.Config{
+		DataDir:             c.DataDir,
+		LogPath:             c.LogPath,
+		LogFormat:           c.LogFormat,
+		MsgFormats:          c.MsgFormats,
+		PatternKeyRegexes:   c.PatternKeyRegexes,
+		TimestampLayout:     c.TimestampLayout,
+		UseUtcTime:          c.UseUtcTime,
+		Timezone:            c.Timezone,
+		BlockSize:           c.BlockSize,
+		MaxBlocks:           c.MaxBlocks,
+		KeepPeriod:          c.KeepPeriod,
+		UnitSecs:            c.UnitSecs,
+		ReorderWindow:       c.ReorderWindow,
+		SearchRegex:         c.SearchRegex,
+		ExcludeRegex:        c.ExcludeRegex,
+		TermCountBorderRate: c.TermCountBorderRate,
+		TermCountBorder:     c.TermCountBorder,
+		MinMatchRate:        c.MinMatchRate,
+		Keywords:            c.Keywords,
+		KeyRegexes:          c.KeyRegexes,
+		Ignorewords:         c.Ignorewords,
+		IgnoreRegexes:       c.IgnoreRegexes,
+		CustomLogGroups:     c.CustomLogGroups,
+		Separators:          c.Separators,
+		IgnoreNumbers:       c.IgnoreNumbers,
+		Storage:             c.Storage,
+		Retention:           c.Retention,
+		SourceFrom:          c.SourceFrom,
+		Maskers:             c.Maskers,
+		RejectFile:          c.RejectFile,
+		MemoryBudget:        c.MemoryBudget,
+	}
 }
 
 func clean() {
@@ -329,8 +528,16 @@ func clean() {
 		}
 	}
 
+	// wait for feeds and readers to finish
+	lock, err := csvdb.LockDir(dataDir, true, lockTimeout)
+	if err != nil {
+		fmt.Printf("Failed to lock directory '%s': %v\n", dataDir, err)
+		return
+	}
+	defer lock.Unlock()
+
 	// Remove the directory
-	err := os.RemoveAll(dataDir)
+	err = os.RemoveAll(dataDir)
 	if err != nil {
 		fmt.Printf("Failed to remove directory '%s': %v\n", dataDir, err)
 		return
@@ -339,6 +546,72 @@ func clean() {
 	fmt.Printf("Directory '%s' removed successfully.\n", dataDir)
 }
 
+func fsck() error {
+	if dataDir == "" {
+		return fmt.Errorf("dataDir is mandatory for fsck")
+	}
+	if utils.PathExist(dataDir) {
+		// repairs must not race with feed
+		lock, err := csvdb.LockDir(dataDir, repair, lockTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+	problems, err := logan.Fsck(dataDir)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Printf("%s: no problems found\n", dataDir)
+		return nil
+	}
+
+	unrepaired := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if !repair {
+			continue
+		}
+		if !p.Repairable() {
+			unrepaired++
+			continue
+		}
+		if err := p.Repair(); err != nil {
+			fmt.Printf("      repair failed: %v\n", err)
+			unrepaired++
+		} else {
+			fmt.Printf("      repaired\n")
+		}
+	}
+	if repair && unrepaired == 0 {
+		return nil
+	}
+	if !repair {
+		unrepaired = len(problems)
+	}
+	return fmt.Errorf("%d problems found in %s", unrepaired, dataDir)
+}
+
+// outDir in the config file is a directory
+func getExportPath() string {
+	if exportFormat != logan.ExportFormatSqlite {
+		return outDir
+	}
+	if st, err := os.Stat(outDir); err == nil && st.IsDir() {
+		return fmt.Sprintf("%s/logan.db", outDir)
+	}
+	return outDir
+}
+
+// -o of report is a file or a directory to write report.html in
+func getReportPath() string {
+	if st, err := os.Stat(outDir); err == nil && st.IsDir() {
+		return fmt.Sprintf("%s/report.html", outDir)
+	}
+	return outDir
+}
+
 func checkCommonFlag() string {
 	if logPath == "" {
 		return "logPath is mandatory"
@@ -361,38 +634,60 @@ func run() error {
 	var err error
 	var a *logan.Analyzer
 
+	if cmd == "lint" {
+		return configLint()
+	}
+	if cmd == "init" {
+		return initConfig()
+	}
+	if cmd == "check" {
+		return check()
+	}
+
 	// Load configuration
+	c := new(config)
 	if configPath != "" {
-		if err := loadConfig(configPath); err != nil {
-			logrus.Info(fmt.Sprintf("Failed to load configuration: %s", configPath))
+		// a config that can't be used stops logan with a failure like before it was linted
+		if c, err = loadConfig(configPath); err != nil {
+			return &exitError{cExitFailed, err}
 		}
 	}
+	applyConfigValues(c)
+	logan.SetLockTimeout(lockTimeout)
 	if cmd == "clean" {
 		clean()
 		return nil
 	}
-
-	if len(searchRegex) == 0 && searchString != "" {
-		searchRegex = []string{searchString}
-	}
-	if len(excludeRegex) == 0 && excludeString != "" {
-		excludeRegex = []string{excludeString}
+	if cmd == "fsck" {
+		return fsck()
 	}
-	if _keywords != "" {
-		keywords = strings.Split(_keywords, ",")
+	if cmd == "migrate" {
+		if dataDir == "" || storage == "" {
+			return fmt.Errorf("dataDir and storage are mandatory for migrate")
+		}
+		return logan.Migrate(dataDir, storage)
 	}
-	if _ignorewords != "" {
-		ignorewords = strings.Split(_ignorewords, ",")
+	if cmd == "modelImport" {
+		return logan.ImportModel(inputPath, dataDir)
 	}
-	if _keyRegexes != "" {
-		keyRegexes = strings.Split(_keyRegexes, ",")
-	}
-	if _ignoreRegexes != "" {
-		ignoRegexes = strings.Split(_ignoreRegexes, ",")
+	if cmd == "merge" {
+		// logan merge -o mergedDir dirA dirB ...
+		return logan.Merge(outDir, _flagSet.Args())
 	}
+
 	if minLastUpdate == 0 && B > 0 {
 		minLastUpdate = utils.GetNdaysBefore(B)
 	}
+	now := time.Now()
+	if fromEpoch, err = utils.ParseTimeArg(_from, now); err != nil {
+		return err
+	}
+	if toEpoch, err = utils.ParseTimeArg(_to, now); err != nil {
+		return err
+	}
+	if fromEpoch > 0 && toEpoch > 0 && fromEpoch >= toEpoch {
+		return fmt.Errorf("from %s must be before to %s", utils.EpochToString(fromEpoch), utils.EpochToString(toEpoch))
+	}
 
 	msg := ""
 	testMode := false
@@ -403,6 +698,13 @@ func run() error {
 		msg = checkTestFlag()
 		readOnly = true
 		testMode = true
+	case "modelExport":
+		readOnly = true
+	case "classify":
+		readOnly = true
+		if !utils.PathExist(fmt.Sprintf("%s/config.json", dataDir)) {
+			return fmt.Errorf("no log groups in %s to classify with. feed it first", dataDir)
+		}
 	}
 	if msg != "" {
 		fmt.Printf("%s for '%s' option\n", msg, cmd)
@@ -414,64 +716,57 @@ func run() error {
 		return fmt.Errorf("configPath is mandatory when cmd is not groups")
 	}
 
+	conf := c.toConfig()
+	opts := logan.Options{ReadOnly: readOnly, TestMode: testMode, LastFileEpoch: lastFileEpoch, Debug: debug}
 	tblDir := fmt.Sprintf("%s/config.json", dataDir)
 	if utils.PathExist(tblDir) && !testMode {
 		logrus.Infof("Loading config from %s\n", tblDir)
-		a, err = logan.LoadAnalyzer(dataDir, logPath,
-			termCountBorderRate,
-			termCountBorder,
-			minMatchRate,
-			customLogGroups,
-			readOnly, debug, testMode, ignoreNumbers)
+		a, err = logan.OpenWith(conf, opts)
 	} else {
-		conf := new(logan.AnalConfig)
-		conf.DataDir = dataDir
-		conf.LogPath = logPath
-		conf.SearchRegex = searchRegex
-		conf.ExludeRegex = excludeRegex
-		conf.LogFormat = logFormat
-		conf.MsgFormats = msgFormats
-		conf.PatternKeyRegexes = patternKeyRegexes
-		conf.TimestampLayout = timestampLayout
-		conf.KeepPeriod = keepPeriod
-		conf.UnitSecs = unitSecs
-		conf.MaxBlocks = maxBlocks
-		conf.BlockSize = blockSize
-		conf.TermCountBorderRate = termCountBorderRate
-		conf.TermCountBorder = termCountBorder
-		conf.MinMatchRate = minMatchRate
-		conf.Keywords = keywords
-		conf.Ignorewords = ignorewords
-		conf.KeyRegexes = keyRegexes
-		conf.IgnoreRegexes = ignoRegexes
-		conf.CustomLogGroups = customLogGroups
-		conf.UseUtcTime = useUtcTime
-		conf.Separators = separators
-		conf.IgnoreNumbers = ignoreNumbers
-
-		a, err = logan.NewAnalyzer(conf,
-			lastFileEpoch,
-			readOnly, testMode)
+		a, err = logan.NewWith(conf, opts)
 	}
 	if err != nil {
 		return err
 	}
+	defer a.Close()
 
 	if N == 0 {
-		N = logan.CDefaultN
+		N = logan.DefaultN
+	}
+
+	switch cmd {
+	case "history", "groups", "patterns", "export", "report", "tui":
+		// the outputs include the lines logged since the last feed
+		if err := a.Feed(context.Background()); err != nil {
+			return err
+		}
 	}
 
 	switch cmd {
 	case "feed":
-		err = a.Feed(0)
+		err = feed(a)
 	case "history":
-		err = a.OutputLogGroups(N, outDir, searchString, excludeString, minLastUpdate, minLogCount, maxLogCount, true, ascOrder, groupId)
+		err = showGroups(a, true)
 	case "groups":
-		err = a.OutputLogGroups(N, outDir, searchString, excludeString, minLastUpdate, minLogCount, maxLogCount, false, ascOrder, -1)
+		err = showGroups(a, false)
 	case "patterns":
-		err = a.DetectPatterns(N, patternDetectionMode, outDir)
+		err = showPatterns(a)
+	case "export":
+		err = a.Export(exportFormat, getExportPath())
 	case "test":
-		a.ParseLogLine(line)
+		err = parse(a, line)
+	case "classify":
+		err = classify(a)
+	case "modelExport":
+		err = a.ExportModel(outDir)
+	case "report":
+		err = a.Report(N, getReportPath(), unixTime(fromEpoch), unixTime(toEpoch), stdThreshold, minOccurrences)
+	case "tui":
+		err = a.TUI(logan.TUIOptions{
+			AddWord: func(key, word string) error {
+				return addWordToConfig(configPath, key, word)
+			},
+		})
 	default:
 		println(usageStr)
 		return nil
@@ -483,46 +778,76 @@ func run() error {
 }
 
 func main() {
+	resetGlobals()
 
-	if len(os.Args) < 1 {
+	args := os.Args
+	if len(args) < 2 {
 		println(usageStr)
 		return
 	}
+	if subCmds, ok := subCommands[args[1]]; ok {
+		// logan config lint -c config.yml
+		if len(args) < 3 || subCmds[args[2]] == "" {
+			println(usageStr)
+			return
+		}
+		args = append([]string{args[0], subCmds[args[2]]}, args[3:]...)
+	}
 	flgStartPos := 2
-	cmd = os.Args[1]
+	cmd = args[1]
 	if cmd[:1] == "-" {
 		cmd = "groups"
 		flgStartPos = 1
 	}
 
-	if !loaded {
-		_flagSet = flag.NewFlagSet(fmt.Sprintf("logan %s", cmd), flag.ExitOnError)
-
-		switch cmd {
-		case "clean":
-			setCommonFlag(_flagSet)
-		case "feed":
-			setCommonFlag(_flagSet)
-		case "history":
-			setOutFlag(_flagSet)
-		case "groups", "":
-			setOutFlag(_flagSet)
-		case "patterns":
-			setOutFlag(_flagSet)
-		case "test":
-			setParseLineFlag(_flagSet)
-		default:
-			println(usageStr)
-			return
-		}
-		if len(os.Args) < 3 {
-			_flagSet.Usage()
-			return
-		}
+	_flagSet = flag.NewFlagSet(fmt.Sprintf("logan %s", cmd), flag.ExitOnError)
 
-		loaded = true
+	switch cmd {
+	case "clean":
+		setCommonFlag(_flagSet)
+	case "feed":
+		setCommonFlag(_flagSet)
+	case "history":
+		setOutFlag(_flagSet)
+	case "groups", "":
+		setOutFlag(_flagSet)
+	case "patterns":
+		setOutFlag(_flagSet)
+	case "export":
+		setExportFlag(_flagSet)
+	case "test":
+		setParseLineFlag(_flagSet)
+	case "fsck":
+		setFsckFlag(_flagSet)
+	case "migrate":
+		setMigrateFlag(_flagSet)
+	case "merge":
+		setMergeFlag(_flagSet)
+	case "tui":
+		setNonFeedFlag(_flagSet)
+	case "report":
+		setOutFlag(_flagSet)
+	case "lint":
+		setLintFlag(_flagSet)
+	case "init":
+		setInitFlag(_flagSet)
+	case "classify":
+		setClassifyFlag(_flagSet)
+	case "modelExport":
+		setModelExportFlag(_flagSet)
+	case "modelImport":
+		setModelImportFlag(_flagSet)
+	case "check":
+		setCheckFlag(_flagSet)
+	default:
+		println(usageStr)
+		return
 	}
-	_flagSet.Parse(os.Args[flgStartPos:])
+	if len(args) < 3 {
+		_flagSet.Usage()
+		return
+	}
+	_flagSet.Parse(args[flgStartPos:])
 
 	// log setting
 	logrus.SetFormatter(&logrus.TextFormatter{
@@ -569,6 +894,12 @@ func main() {
 	}()
 
 	if err := run(); err != nil {
+		var ee *exitError
+		if errors.As(err, &ee) {
+			// keep stdout for the results like the json of check
+			fmt.Fprintf(os.Stderr, "%+v\n", err)
+			os.Exit(ee.code)
+		}
 		fmt.Printf("%+v\n", err)
 	} else {
 		logrus.Debug("Finished successfully")
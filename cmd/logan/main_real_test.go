@@ -1,12 +1,21 @@
 package main
 
 import (
+	"goLogAnalyzer/pkg/utils"
 	"os"
 	"testing"
 )
 
+// skipWithoutInput skips a test on real data when its config is not on this host
+func skipWithoutInput(t *testing.T, config string) {
+	if !utils.PathExist(config) {
+		t.Skipf("%s not found", config)
+	}
+}
+
 func Test_real_sbc_gateway(t *testing.T) {
 	config := "/home/ubuntu/tests/sbc/gateway/sbc_gateway.yml.j2"
+	skipWithoutInput(t, config)
 	//os.Args = []string{"logan", "clean", "-c", config, "-silent"}
 	//main()
 
@@ -16,6 +25,7 @@ func Test_real_sbc_gateway(t *testing.T) {
 
 func Test_real_sbc_gateway2(t *testing.T) {
 	config := "/data/Documents/202508_sug5k_noans/loganconfs/gateway.yaml"
+	skipWithoutInput(t, config)
 	//os.Args = []string{"logan", "clean", "-c", config, "-silent"}
 	//main()
 
@@ -25,6 +35,7 @@ func Test_real_sbc_gateway2(t *testing.T) {
 
 func Test_real_sophos(t *testing.T) {
 	config := "/home/ubuntu/tests/sophos/SOPHOS-01.yml"
+	skipWithoutInput(t, config)
 	//os.Args = []string{"logan", "clean", "-c", config, "-silent"}
 	//main()
 
@@ -34,6 +45,7 @@ func Test_real_sophos(t *testing.T) {
 
 func Test_real_flip(t *testing.T) {
 	config := "/data/Documents/202510_flip3/new/loganal.yml"
+	skipWithoutInput(t, config)
 	//os.Args = []string{"logan", "clean", "-c", config, "-silent"}
 	//main()
 	os.Args = []string{"logan", "groups", "-c", config}
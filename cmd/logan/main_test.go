@@ -1,12 +1,19 @@
 package main
 
 import (
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
 	"goLogAnalyzer/pkg/utils"
 	"os"
+	"regexp"
 	"strings"
 	"testing"
 )
 
+// a row of the printed log groups. the groupId starts with the epoch the group was created,
+// which is the mtime of the log file without a logFormat
+var groupIdLineRe = regexp.MustCompile(`^\d{15}\s`)
+
 func Test_main_groups(t *testing.T) {
 	testDir, err := utils.InitTestDir("Test_main_groups")
 	if err != nil {
@@ -117,6 +124,7 @@ func Test_main_no_datadir(t *testing.T) {
 		return
 	}
 
+	// without a dataDir the log is grouped again with the new border and match rate
 	os.Args = []string{"logan", "history", "-c", config, "-o", testDir, "-b", "20", "-m", "0.5"}
 	main()
 	_, records, err = utils.ReadCsv(testDir+"/history.csv", ',', false)
@@ -124,7 +132,7 @@ func Test_main_no_datadir(t *testing.T) {
 		t.Errorf("%v", err)
 		return
 	}
-	if err := utils.GetGotExpErr("len(records)", len(records), 5); err != nil {
+	if err := utils.GetGotExpErr("len(records)", len(records), 10); err != nil {
 		t.Errorf("%v", err)
 		return
 	}
@@ -267,8 +275,30 @@ func Test_sbc_gateway(t *testing.T) {
 	//os.Args = []string{"logan", "test", "-c", config, "-line", `06th, 22:40:14.880+0900 TBLV1 CALL: CTBCMCLeg::Construct( LegId=0xbf97484b Type CALL, NAP NAPS_BR_PRO_FE, calling/called 05088882360/0199997017 )`}
 	//main()
 
-	os.Args = []string{"logan", "groups", "-c", config, "-minCount", "10"}
+	outDir := dataDir + "/../out"
+	os.Args = []string{"logan", "groups", "-c", config, "-minCount", "10", "-o", outDir}
 	main()
+
+	// the terms starting with 0x are masked without ignoreRegexes
+	_, records, err := utils.ReadCsv(outDir+"/logGroups.csv", ',', false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	masked := 0
+	for _, record := range records {
+		displayString := record[len(record)-1]
+		if strings.Contains(displayString, "0x") {
+			t.Errorf("unmasked hex in %s", displayString)
+			return
+		}
+		if strings.Contains(displayString, "<HEX>") {
+			masked++
+		}
+	}
+	if masked == 0 {
+		t.Errorf("no <HEX> in %d log groups", len(records))
+	}
 }
 
 func Test_detectPatterns(t *testing.T) {
@@ -350,7 +380,7 @@ func Test_no_datadir(t *testing.T) {
 	var groupIDCount int
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "173") {
+		if groupIdLineRe.MatchString(line) {
 			groupIDCount++
 		}
 	}
@@ -408,7 +438,7 @@ func Test_no_cmd(t *testing.T) {
 	var groupIDCount int
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "173") {
+		if groupIdLineRe.MatchString(line) {
 			groupIDCount++
 		}
 	}
@@ -570,3 +600,187 @@ func Test_groups_004_outfilter(t *testing.T) {
 		return
 	}
 }
+
+func Test_addWordToConfig(t *testing.T) {
+	testDir, err := utils.InitTestDir("Test_addWordToConfig")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	config := testDir + "/config.yml"
+	if err := os.WriteFile(config, []byte("dataDir: \"{{ HOME }}/data\" # data\nkeywords:\n  - foo\nignorewords:\n"), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for _, kw := range [][]string{{"keywords", "bar"}, {"keywords", "foo"}, {"ignorewords", "baz"}, {"keyRegexes", "qux"}} {
+		if err := addWordToConfig(config, kw[0], kw[1]); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	data, err := os.ReadFile(config)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	exp := "dataDir: \"{{ HOME }}/data\" # data\nkeywords:\n  - foo\n  - bar\nignorewords:\n  - baz\nkeyRegexes:\n  - qux\n"
+	if err := utils.GetGotExpErr("config", string(data), exp); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_lintConfig(t *testing.T) {
+	testDir, err := utils.InitTestDir("Test_lintConfig")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	config := testDir + "/config.yml"
+	yml := `dataDir: "{{ HOME }}/data"
+logFormat: '^(?P<timestamp>\S+) (?P<message>.+)$'
+timestampLayout: "2006-01-02T15:04:05"
+countBorder: 3
+retention:
+  - unitSecs: 3600
+    kep: 24
+patternKeyRegexes:
+  - 'id=(\d+'
+patternDetectionMode: relations
+`
+	if err := os.WriteFile(config, []byte(yml), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	problems, err := lintConfig(config, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	got := make([]string, len(problems))
+	for i, p := range problems {
+		got[i] = fmt.Sprintf("%d %s %s", p.line, p.Severity, p.Key)
+	}
+	exp := []string{
+		"4 WARN countBorder",
+		"5 ERROR retention",
+		"7 WARN retention[0].kep",
+		"9 ERROR patternKeyRegexes[0]",
+	}
+	if err := utils.GetGotExpErr("problems", strings.Join(got, ","), strings.Join(exp, ",")); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("suggestion", problems[0].Message, "unknown key. did you mean termCountBorder?"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if _, err := loadConfig(config); err == nil {
+		t.Errorf("loadConfig must fail with errors in the config")
+		return
+	}
+
+	if err := os.WriteFile(config, []byte("logFormat: [\n"), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if _, err := lintConfig(config, 0); err == nil {
+		t.Errorf("broken YAML must be an error")
+	}
+}
+
+func Test_applyConfigValues(t *testing.T) {
+	testDir, err := utils.InitTestDir("Test_applyConfigValues")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	config := testDir + "/config.yml"
+	yml := `dataDir: /tmp/data
+logFormat: '^(?P<timestamp>\S+) (?P<message>.+)$'
+timestampLayout: "2006-01-02T15:04:05"
+useUtcTime: true
+unitSecs: 3600
+rejectFile: /tmp/rejected.log
+keywords: [alpha]
+`
+	if err := os.WriteFile(config, []byte(yml), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	c, err := loadConfig(config)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the variables are shared with the other tests
+	saved := []interface{}{dataDir, logFormat, timestampLayout, useUtcTime, unitSecs, rejectFile, keywords, _keywords}
+	defer func() {
+		dataDir, logFormat, timestampLayout = saved[0].(string), saved[1].(string), saved[2].(string)
+		useUtcTime, unitSecs, rejectFile = saved[3].(bool), saved[4].(int64), saved[5].(string)
+		keywords, _keywords = saved[6].([]string), saved[7].(string)
+	}()
+
+	dataDir, logFormat, timestampLayout, useUtcTime, rejectFile, keywords = "", "", "", false, "", nil
+
+	// the flags given
+	unitSecs, _keywords = 60, "bravo,charlie"
+	applyConfigValues(c)
+
+	// the config lint checks is the config run runs with
+	conf := c.toConfig()
+	got := fmt.Sprintf("%s %v %d %s %v", conf.DataDir, conf.UseUtcTime, conf.UnitSecs,
+		conf.RejectFile, conf.Keywords)
+	exp := "/tmp/data true 60 /tmp/rejected.log [bravo charlie]"
+	if err := utils.GetGotExpErr("config", got, exp); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("dataDir", dataDir, "/tmp/data"); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_newStarterConfig(t *testing.T) {
+	testDir, err := utils.InitTestDir("Test_newStarterConfig")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	sample := "../../testdata/loganal/netscreen.log"
+	d, err := logan.DetectLogFormat(sample, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	data, err := newStarterConfig(d, sample, testDir+"/data")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	config := testDir + "/config.yml"
+	if err := os.WriteFile(config, data, 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	c, _, err := readConfig(config)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("timestampLayout", c.TimestampLayout, "Jan _2 15:04:05"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	problems, err := lintConfig(config, logan.DefaultLintSampleLines)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+	if err := utils.GetGotExpErr("log name", getLogName("/var/log/syslog*"), "syslog"); err != nil {
+		t.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
+	"goLogAnalyzer/pkg/utils"
+	"sort"
+)
+
+// parse shows how line is parsed and grouped with the config
+func parse(a *logan.Analyzer, line string) error {
+	p, err := a.Parse(line)
+	if err != nil {
+		return err
+	}
+	conf := a.Config()
+	dt := "PARSE ERROR"
+	if !p.Timestamp.IsZero() {
+		dt = p.Timestamp.Format(utils.GetDatetimeFormatFromUnitSecs(conf.UnitSecs))
+	}
+	fmt.Println("the line parsed as:")
+	fmt.Println("timestamp:", dt)
+	fmt.Println("message:", p.Message)
+
+	if len(conf.PatternKeyRegexes) == 0 {
+		return nil
+	}
+	if !p.PatternMatched {
+		fmt.Println("no pattern key matched")
+		return nil
+	}
+	fmt.Println("pattern matched:", p.PatternKey)
+	if len(p.Tags) > 0 {
+		fmt.Println("tags:")
+		names := make([]string, 0, len(p.Tags))
+		for name := range p.Tags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, p.Tags[name])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/logan"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// showPatterns prints the patterns of N or more, to outDir as well if set
+func showPatterns(a *logan.Analyzer) error {
+	if len(a.Config().PatternKeyRegexes) == 0 {
+		logrus.Warn("no pattern key regexes defined")
+		return nil
+	}
+	patterns, err := a.Patterns(N, patternDetectionMode)
+	if err != nil {
+		return fmt.Errorf("error detecting patterns: %w", err)
+	}
+	return printPatterns(patterns, outDir)
+}
+
+/*
+printPatterns shows the patterns in descending order of the totals, and writes each of them
+to pattern_001.txt, pattern_002.txt.. in outDir if set
+
+	5 6 7 => total 3
+	1234: {startEpoch: 2024/10/01 00:00:00, count: 2}
+	5678: {startEpoch: 2024/10/01 00:10:00, count: 1}
+	---
+	display string of group 5
+	...
+	--------------------------------------------------
+
+the keys are the pattern keys for firstMatch and the relation keys for relations,
+"all" if the pattern keys have no tags.
+*/
+func printPatterns(patterns []logan.Pattern, outDir string) error {
+	for i, p := range patterns {
+		var w io.Writer = os.Stdout
+		if outDir != "" {
+			fpath := fmt.Sprintf("%s/pattern_%03d.txt", outDir, i+1)
+			f, err := os.Create(fpath)
+			if err != nil {
+				return fmt.Errorf("error creating file %s: %v", fpath, err)
+			}
+			defer f.Close()
+			w = io.MultiWriter(os.Stdout, f)
+		}
+		printPattern(w, p)
+	}
+	return nil
+}
+
+func printPattern(w io.Writer, p logan.Pattern) {
+	groupIds := make([]string, len(p.GroupIds))
+	for i, groupId := range p.GroupIds {
+		groupIds[i] = fmt.Sprint(groupId)
+	}
+	fmt.Fprintf(w, "%s => total %d\n", strings.Join(groupIds, " "), p.Total)
+	for _, c := range p.Counts {
+		ts := c.Start.Local().Format("2006/01/02 15:04:05")
+		fmt.Fprintf(w, "%s: {startEpoch: %s, count: %d}\n", c.Key, ts, c.Count)
+	}
+	fmt.Fprintln(w, "---")
+
+	// display strings of the pattern
+	for i, displayString := range p.DisplayStrings {
+		if displayString == "" {
+			fmt.Fprintf(w, "(not found for groupId %d)\n", p.GroupIds[i])
+		} else {
+			fmt.Fprintln(w, displayString)
+		}
+	}
+	fmt.Fprintln(w, "--------------------------------------------------")
+}
@@ -1,43 +1,60 @@
 package logan
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
 	"goLogAnalyzer/pkg/filepointer"
 	"goLogAnalyzer/pkg/utils"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type AnalConfig struct {
-	DataDir             string   `json:"data_dir"`
-	LogPath             string   `json:"log_path"`
-	LogFormat           string   `json:"log_format"`
-	MsgFormats          []string `json:"msg_formats"`
-	PatternKeyRegexes   []string `json:"pattern_key_regexes"`
-	TimestampLayout     string   `json:"timestamp_layout"`
-	UseUtcTime          bool     `json:"use_utc_time"`
-	BlockSize           int      `json:"block_size"`
-	MaxBlocks           int      `json:"max_blocks"`
-	KeepPeriod          int64    `json:"keep_period"`
-	UnitSecs            int64    `json:"unit_secs"`
-	SearchRegex         []string `json:"search_regex"`
-	ExludeRegex         []string `json:"exclude_regex"`
-	TermCountBorderRate float64  `json:"term_count_border_rate"`
-	TermCountBorder     int      `json:"term_count_border"`
-	MinMatchRate        float64  `json:"min_match_rate"`
-	Keywords            []string `json:"keywords"`
-	KeyRegexes          []string `json:"key_regexes"`
-	Ignorewords         []string `json:"ignorewords"`
-	IgnoreRegexes       []string `json:"ignore_regexes"`
-	CustomLogGroups     []string `json:"custom_log_groups"`
-	Separators          string   `json:"separators"`
-	IgnoreNumbers       bool     `json:"ignore_numbers"`
+	DataDir             string          `json:"data_dir"`
+	LogPath             string          `json:"log_path"`
+	LogFormat           string          `json:"log_format"`
+	MsgFormats          []string        `json:"msg_formats"`
+	PatternKeyRegexes   []string        `json:"pattern_key_regexes"`
+	TimestampLayout     string          `json:"timestamp_layout"`
+	UseUtcTime          bool            `json:"use_utc_time"`
+	Timezone            string          `json:"timezone,omitempty"`
+	BlockSize           int             `json:"block_size"`
+	MaxBlocks           int             `json:"max_blocks"`
+	KeepPeriod          int64           `json:"keep_period"`
+	UnitSecs            int64           `json:"unit_secs"`
+	ReorderWindow       int64           `json:"reorder_window,omitempty"`
+	SearchRegex         []string        `json:"search_regex"`
+	ExludeRegex         []string        `json:"exclude_regex"`
+	TermCountBorderRate float64         `json:"term_count_border_rate"`
+	TermCountBorder     int             `json:"term_count_border"`
+	MinMatchRate        float64         `json:"min_match_rate"`
+	Keywords            []string        `json:"keywords"`
+	KeyRegexes          []string        `json:"key_regexes"`
+	Ignorewords         []string        `json:"ignorewords"`
+	IgnoreRegexes       []string        `json:"ignore_regexes"`
+	CustomLogGroups     []string        `json:"custom_log_groups"`
+	Maskers             []string        `json:"maskers,omitempty"`
+	Separators          string          `json:"separators"`
+	IgnoreNumbers       bool            `json:"ignore_numbers"`
+	Storage             string          `json:"storage"`
+	Retention           []RetentionTier `json:"retention"`
+	MergedFrom          []string        `json:"merged_from,omitempty"`
+	SourceFrom          string          `json:"source_from,omitempty"`
+	RejectFile          string          `json:"reject_file,omitempty"`
+	MemoryBudget        int             `json:"memory_budget,omitempty"` // MB of the terms and log groups in memory
 }
 
 type analStatus struct {
@@ -47,9 +64,10 @@ type analStatus struct {
 }
 
 type historyInfo struct {
-	Start    int64 `json:"start"`
-	End      int64 `json:"end"`
-	UnitSecs int64 `json:"unit_secs"`
+	Start    int64         `json:"start"`
+	End      int64         `json:"end"`
+	UnitSecs int64         `json:"unit_secs"`
+	Spans    []historySpan `json:"spans,omitempty"`
 }
 
 type Analyzer struct {
@@ -60,10 +78,20 @@ type Analyzer struct {
 	readOnly       bool
 	testMode       bool
 	linesProcessed int
+	lateLines      int
+	stopping       atomic.Bool
+	stopMu         sync.Mutex
+	stopc          chan struct{} // closed by Stop to wake FeedLines waiting for lines
+	progress       *progress
+	interrupted    bool
+	lock           *csvdb.Lock
 }
 
 // NewAnalyzer creates a new Analyzer instance with the provided configuration
 func NewAnalyzer(conf *AnalConfig, lastFileEpoch int64, readOnly, testMode bool) (*Analyzer, error) {
+	if err := checkConfig(conf); err != nil {
+		return nil, err
+	}
 	a := new(Analyzer)
 	a.AnalConfig = new(AnalConfig)
 	a.analStatus = new(analStatus)
@@ -72,6 +100,8 @@ func NewAnalyzer(conf *AnalConfig, lastFileEpoch int64, readOnly, testMode bool)
 	a.LogFormat = conf.LogFormat
 	a.MsgFormats = conf.MsgFormats
 	a.UseUtcTime = conf.UseUtcTime
+	a.Timezone = conf.Timezone
+	a.ReorderWindow = conf.ReorderWindow
 	a.Keywords = conf.Keywords
 	a.Ignorewords = conf.Ignorewords
 	a.KeyRegexes = conf.KeyRegexes
@@ -85,6 +115,9 @@ func NewAnalyzer(conf *AnalConfig, lastFileEpoch int64, readOnly, testMode bool)
 	a.ExludeRegex = conf.ExludeRegex
 	a.testMode = testMode
 	a.IgnoreNumbers = conf.IgnoreNumbers
+	a.Storage = conf.Storage
+	a.SourceFrom = conf.SourceFrom
+	a.Maskers = conf.Maskers
 
 	// set defaults
 	a.UnitSecs = utils.GetUnitsecs(utils.CFreqDay)
@@ -105,6 +138,14 @@ func NewAnalyzer(conf *AnalConfig, lastFileEpoch int64, readOnly, testMode bool)
 	if conf.KeepPeriod > 0 {
 		a.KeepPeriod = conf.KeepPeriod
 	}
+	if len(conf.Retention) > 0 {
+		if err := checkRetention(conf.Retention); err != nil {
+			return nil, err
+		}
+		a.Retention = conf.Retention
+		a.UnitSecs = conf.Retention[0].UnitSecs
+		a.KeepPeriod = conf.Retention[0].Keep
+	}
 
 	if conf.TermCountBorder > 0 {
 		a.TermCountBorder = conf.TermCountBorder
@@ -130,6 +171,27 @@ func NewAnalyzer(conf *AnalConfig, lastFileEpoch int64, readOnly, testMode bool)
 	if conf.LogPath != "" {
 		a.LogPath = conf.LogPath
 	}
+	a.RejectFile = conf.RejectFile
+	if conf.MemoryBudget > 0 {
+		a.SetMemoryBudget(conf.MemoryBudget)
+	}
+	if conf.Storage != "" && getStorage(a.Storage) != getStorage(conf.Storage) {
+		logrus.Warnf("%s is stored in %s. run 'logan migrate' to change the storage to %s",
+			a.DataDir, getStorage(a.Storage), conf.Storage)
+	}
+
+	if conf.SourceFrom != "" && a.SourceFrom != conf.SourceFrom {
+		logrus.Warnf("%s keeps the sourceFrom it was created with. use a new dataDir to change it", a.DataDir)
+	}
+	if len(conf.Retention) > 0 && !reflect.DeepEqual(a.Retention, conf.Retention) {
+		logrus.Warnf("%s keeps the retention it was created with. use a new dataDir to change it", a.DataDir)
+	}
+	if conf.Timezone != "" && a.Timezone != conf.Timezone {
+		logrus.Warnf("%s keeps the timezone it was created with. use a new dataDir to change it", a.DataDir)
+	}
+	if len(conf.Maskers) > 0 && !slices.Equal(a.Maskers, conf.Maskers) {
+		logrus.Warnf("%s keeps the maskers it was created with. use a new dataDir to change them", a.DataDir)
+	}
 
 	a.CustomLogGroups = conf.CustomLogGroups
 
@@ -224,6 +286,12 @@ func (a *Analyzer) Close() {
 	if a.trans != nil {
 		a.trans.close()
 	}
+	if a.lock != nil {
+		if err := a.lock.Unlock(); err != nil {
+			logrus.Warnf("failed to unlock %s: %v", a.DataDir, err)
+		}
+		a.lock = nil
+	}
 }
 
 func (a *Analyzer) Purge() error {
@@ -236,16 +304,250 @@ func (a *Analyzer) Purge() error {
 
 // Register terms and convert log lines to logGroups
 func (a *Analyzer) Feed(targetLinesCnt int) error {
+	// a merged dataDir has no log of its own
+	if a.LogPath == "" && len(a.MergedFrom) > 0 {
+		return nil
+	}
+	a.interrupted = false
+	// a Stop is for the running Feed only
+	defer a.stopping.Store(false)
 	targetLinesCnt, err := a._registerTerms(targetLinesCnt)
 	if err != nil {
 		return err
 	}
+	// the terms counted so far are not stored, so there is nothing to commit
+	if a.stopping.Load() {
+		a.interrupted = true
+		logrus.Warnf("feed stopped before registering log groups. nothing new was registered")
+		return nil
+	}
 	if err := a._registerLogGroups(targetLinesCnt); err != nil {
+		a.trans.rollback()
+		return err
+	}
+	return nil
+}
+
+/*
+FeedLines feeds the lines from lines until it is closed, instead of logPath.
+The lines are registered in batches of up to cFeedBatchLines, the terms of a batch first
+and then its log groups, so that nothing but a batch is kept of them.
+A batch is also registered once cFeedBatchWait passes after the first line of it.
+The position in logPath is kept for the next Feed, and the lines not registered before a Stop are not fed.
+*/
+func (a *Analyzer) FeedLines(lines <-chan string) error {
+	a.interrupted = false
+	defer a.stopping.Store(false)
+	stopc := make(chan struct{})
+	a.stopMu.Lock()
+	if a.stopping.Load() {
+		close(stopc)
+	} else {
+		a.stopc = stopc
+	}
+	a.stopMu.Unlock()
+	defer func() {
+		a.stopMu.Lock()
+		a.stopc = nil
+		a.stopMu.Unlock()
+	}()
+
+	// without the file pointer, the position in logPath is saved as it is
+	if a.fp != nil {
+		a.fp.Close()
+		a.fp = nil
+	}
+	tr := a.trans
+	updated := time.Now().Unix()
+	tr.setLogFile("", -1)
+	tr.setLogFile(cReaderFileName, updated)
+	rw := a._beginLogGroups()
+	defer rw.close()
+
+	// the terms pass goes on from where the last feed ended and the log groups pass from scratch, like Feed
+	var terms passState
+	tr.swapPassState(&terms)
+	batch := make([]string, 0, cFeedBatchLines)
+	linesProcessed := 0
+	row := 0
+	for more := true; more && !a.stopping.Load(); {
+		batch, more = a._nextBatch(lines, stopc, batch[:0])
+		if a.stopping.Load() {
+			break
+		}
+
+		tr.swapPassState(&terms)
+		for _, line := range batch {
+			if line != "" {
+				tr.lineToTerms(line, 1)
+			}
+		}
+		tr.swapPassState(&terms)
+		a.initBlocks()
+		tr.setCountBorder()
+
+		for _, line := range batch {
+			if a.stopping.Load() {
+				break
+			}
+			row++
+			if line == "" {
+				continue
+			}
+			if err := a._lineToLogGroup(rw, line, cReaderFileName, row, updated); err != nil {
+				tr.rollback()
+				return err
+			}
+			linesProcessed++
+		}
+	}
+
+	if !a.readOnly {
+		if err := a._commit(false); err != nil {
+			return err
+		}
+		if linesProcessed > 0 {
+			logrus.Infof("processed %d lines", linesProcessed)
+		}
+	}
+	if a.stopping.Load() {
+		a.interrupted = true
+		logrus.Warnf("feed stopped after %d lines of the %s. the rest were not fed", row, cReaderFileName)
+	}
+	return a._endLogGroups(linesProcessed, rw)
+}
+
+/*
+read the next batch of lines: cFeedBatchLines, the ones that came in cFeedBatchWait
+after the first one, or the ones until lines is closed or Stop.
+more is false once lines is closed or Stop is called
+*/
+func (a *Analyzer) _nextBatch(lines <-chan string, stopc <-chan struct{}, batch []string) ([]string, bool) {
+	var timeout <-chan time.Time
+	for len(batch) < cFeedBatchLines {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return batch, false
+			}
+			batch = append(batch, line)
+			if timeout == nil {
+				timer := time.NewTimer(cFeedBatchWait)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+		case <-timeout:
+			return batch, true
+		case <-stopc:
+			return batch, false
+		}
+	}
+	return batch, true
+}
+
+/*
+FeedReader feeds the lines of r until EOF like FeedLines.
+r is read in another goroutine, so that a Stop returns even while a Read of r is blocked.
+That Read is left to return in the background and the rest of r is not fed.
+*/
+func (a *Analyzer) FeedReader(r io.Reader) error {
+	lines := make(chan string, cFeedBatchLines)
+	done := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil && err != io.EOF {
+				errc <- err
+				return
+			}
+			eof := err == io.EOF
+			if line = strings.TrimRight(line, "\r\n"); line != "" || !eof {
+				select {
+				case lines <- line:
+				case <-done:
+					return
+				}
+			}
+			if eof {
+				return
+			}
+		}
+	}()
+	err := a.FeedLines(lines)
+	close(done)
+	if err != nil || a.interrupted {
 		return err
 	}
+	select {
+	case err := <-errc:
+		return fmt.Errorf("error reading the lines: %w", err)
+	default:
+	}
 	return nil
 }
 
+/*
+Stop makes a running Feed stop at the next line, commit the lines it has registered
+and return, so that the next Feed goes on from there.
+It is safe to call from another goroutine like a signal handler.
+*/
+func (a *Analyzer) Stop() {
+	a.stopping.Store(true)
+	a.stopMu.Lock()
+	defer a.stopMu.Unlock()
+	if a.stopc != nil {
+		close(a.stopc)
+		a.stopc = nil
+	}
+}
+
+/*
+ShowProgress makes Feed show how far it has read the logs on w, a terminal,
+or log it every few seconds if w is not a terminal. nil stops it.
+*/
+func (a *Analyzer) ShowProgress(w io.Writer) {
+	if w == nil {
+		a.progress = nil
+		return
+	}
+	a.progress = newProgress(w)
+}
+
+/*
+OnNewLogGroup makes Feed call f with each log group it creates, as the first line of it is registered.
+nil stops it.
+*/
+func (a *Analyzer) OnNewLogGroup(f func(LogGroup)) {
+	if f == nil {
+		a.trans.onNewLogGroup = nil
+		return
+	}
+	a.trans.onNewLogGroup = func(groupId int64) {
+		lg := a.trans.lgs.alllg[groupId]
+		f(a.trans.logGroup(groupId, lg.count, nil))
+	}
+}
+
+/*
+SetMemoryBudget keeps the terms and log groups in memory to about mb MB.
+The rare terms are evicted and counted in a sketch, and the cold log groups are spilled
+out of memory. 0 keeps all the terms and up to 1,000,000 log groups.
+*/
+func (a *Analyzer) SetMemoryBudget(mb int) {
+	a.MemoryBudget = mb
+	if a.trans != nil {
+		a.trans.setMemoryBudget(mb)
+	}
+}
+
+// Interrupted tells if the last Feed was stopped before the end of the logs
+func (a *Analyzer) Interrupted() bool {
+	return a.interrupted
+}
+
 func (a *Analyzer) load() error {
 	if err := a.trans.load(); err != nil {
 		return err
@@ -260,7 +562,14 @@ func (a *Analyzer) open() error {
 			return err
 		}
 	} else {
-		if utils.PathExist(a.DataDir) {
+		exists := utils.PathExist(a.DataDir)
+		if err := a.lockDataDir(exists); err != nil {
+			return err
+		}
+		if exists {
+			if err := a.recover(); err != nil {
+				return err
+			}
 			if err := a.loadConfig(); err != nil {
 				return err
 			}
@@ -295,21 +604,22 @@ func (a *Analyzer) init() error {
 			return err
 		}
 	}
-	trans, err := newTrans(a.DataDir, a.LogFormat, a.TimestampLayout,
+	trans, err := newTrans(a.DataDir, a.LogFormat, a.TimestampLayout, a.Timezone,
 		a.UseUtcTime,
-		a.MaxBlocks, a.BlockSize, a.UnitSecs, a.KeepPeriod,
+		a.MaxBlocks, a.BlockSize, a.UnitSecs, a.KeepPeriod, a.ReorderWindow, a.Retention,
 		a.TermCountBorderRate, a.TermCountBorder, a.MinMatchRate,
 		a.SearchRegex, a.ExludeRegex,
 		a.Keywords, a.Ignorewords,
 		a.KeyRegexes, a.IgnoreRegexes,
 		a.MsgFormats,
 		a.PatternKeyRegexes,
-		a.CustomLogGroups, a.Separators, true,
+		a.CustomLogGroups, a.Maskers, a.Separators, a.Storage, a.SourceFrom, true,
 		a.readOnly, a.testMode, a.IgnoreNumbers)
 	if err != nil {
 		return err
 	}
 	a.trans = trans
+	a.trans.setMemoryBudget(a.MemoryBudget)
 	return nil
 }
 
@@ -325,17 +635,13 @@ func (a *Analyzer) saveLastStatus() error {
 		return fmt.Errorf("%s does not exist", a.DataDir)
 	}
 
-	var epoch int64
-	rowNo := 0
-	if a.fp != nil {
-		epoch = a.fp.CurrFileEpoch()
-		rowNo = a.fp.Row()
-		a.LastFileEpoch = epoch
+	// the file and the row of the last line read, to go on from the next one.
+	// Row() is 0 until a line is read
+	if a.fp != nil && a.fp.Row() > 0 {
+		rowNo := a.fp.Row()
+		a.LastFileEpoch = a.fp.CurrFileMtime()
 		a.RowID = rowNo
-		a.LastFileRow = a.fp.Row()
-	} else {
-		epoch = 0
-		rowNo = 0
+		a.LastFileRow = rowNo
 	}
 
 	data, err := json.MarshalIndent(a.analStatus, "", "  ")
@@ -343,7 +649,11 @@ func (a *Analyzer) saveLastStatus() error {
 		return fmt.Errorf("failed to marshal config to JSON: %w", err)
 	}
 
-	err = ioutil.WriteFile(a._getLastStatusPath(), data, 0644)
+	path, err := csvdb.StagedPath(a._getLastStatusPath())
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, data, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write JSON to file: %w", err)
 	}
@@ -385,7 +695,11 @@ func (a *Analyzer) saveConfig() error {
 		return fmt.Errorf("failed to marshal config to JSON: %w", err)
 	}
 
-	err = ioutil.WriteFile(a._getConfigPath(), data, 0644)
+	path, err := csvdb.StagedPath(a._getConfigPath())
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, data, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write JSON to file: %w", err)
 	}
@@ -450,6 +764,51 @@ func (a *Analyzer) initBlocks() {
 
 }
 
+// SetLockTimeout sets how long to wait for other processes using the same dataDir
+func SetLockTimeout(timeout time.Duration) {
+	lockTimeout = timeout
+}
+
+// feed takes dataDir exclusively. readers share it so they never see half written blocks
+func (a *Analyzer) lockDataDir(exists bool) error {
+	if a.testMode {
+		return nil
+	}
+	if a.readOnly {
+		if !exists {
+			return nil
+		}
+	} else {
+		if err := utils.EnsureDir(a.DataDir); err != nil {
+			return err
+		}
+	}
+	lock, err := csvdb.LockDir(a.DataDir, !a.readOnly, lockTimeout)
+	if err != nil {
+		return err
+	}
+	a.lock = lock
+	return nil
+}
+
+// complete or roll back a commit interrupted by a crash
+func (a *Analyzer) recover() error {
+	if a.readOnly || a.testMode {
+		if csvdb.HasPendingTxn(a.DataDir) {
+			logrus.Warnf("%s has an interrupted commit. run feed to recover it", a.DataDir)
+		}
+		return nil
+	}
+	report, err := csvdb.RecoverTxn(a.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to recover %s: %w", a.DataDir, err)
+	}
+	if !report.IsEmpty() {
+		logrus.Warnf("recovered %s: %s", a.DataDir, report)
+	}
+	return nil
+}
+
 func (a *Analyzer) _commit(completed bool) error {
 	if a.readOnly {
 		return nil
@@ -457,6 +816,30 @@ func (a *Analyzer) _commit(completed bool) error {
 	if a.DataDir == "" {
 		return nil
 	}
+
+	// all files are written to temporary names and renamed at once in tx.Commit(),
+	// with the block rotations of the feed since the last commit
+	tx := a.trans.takeTxn()
+	var err error
+	if tx == nil && !a.testMode && utils.PathExist(a.DataDir) {
+		tx, err = csvdb.BeginTxn(a.DataDir)
+		if err != nil {
+			return err
+		}
+	}
+	if err := a._commitFiles(completed); err != nil {
+		if tx != nil {
+			tx.Rollback()
+		}
+		return err
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+func (a *Analyzer) _commitFiles(completed bool) error {
 	if err := a.trans.commit(completed); err != nil {
 		return err
 	}
@@ -472,6 +855,8 @@ func (a *Analyzer) _commit(completed bool) error {
 
 func (a *Analyzer) _initFilePointer() error {
 	var err error
+	// the previous pass may have ended in the same file
+	a.trans.setLogFile("", -1)
 	if a.fp == nil || !a.fp.IsOpen() {
 		a.fp, err = filepointer.NewFilePointer(a.LogPath, a.LastFileEpoch, a.LastFileRow)
 		if err != nil {
@@ -487,13 +872,16 @@ func (a *Analyzer) _initFilePointer() error {
 func (a *Analyzer) _registerTerms(targetLinesCnt int) (int, error) {
 	logrus.Infof("starting terms registering")
 	linesProcessed := 0
+	a.trans.te.evicted = 0
 
 	if err := a._initFilePointer(); err != nil {
 		return -1, err
 	}
+	a.progress.begin(cPhaseTerms, a.fp)
+	defer a.progress.end()
 
-	for a.fp.Next() {
-		if linesProcessed > 0 && linesProcessed%cLogPerLines == 0 {
+	for !a.stopping.Load() && a.fp.Next() {
+		if a.progress == nil && linesProcessed > 0 && linesProcessed%cLogPerLines == 0 {
 			logrus.Infof("processed %d lines", linesProcessed)
 		}
 
@@ -503,8 +891,10 @@ func (a *Analyzer) _registerTerms(targetLinesCnt int) (int, error) {
 			continue
 		}
 
+		a.trans.setLogFile(a.fp.CurrFileName(), a.fp.CurrFileMtime())
 		a.trans.lineToTerms(line, 1)
 		linesProcessed++
+		a.progress.line(a.fp, len(a.trans.te.id2term))
 
 		if targetLinesCnt > 0 && linesProcessed >= targetLinesCnt {
 			break
@@ -522,13 +912,16 @@ func (a *Analyzer) _registerLogGroups(targetLinesCnt int) error {
 	logrus.Infof("starting logGroups registering")
 	linesProcessed := 0
 	a.trans.setCountBorder()
+	rw := a._beginLogGroups()
+	defer rw.close()
 
 	if err := a._initFilePointer(); err != nil {
 		return err
 	}
+	a.progress.begin(cPhaseGroups, a.fp)
 
-	for a.fp.Next() {
-		if linesProcessed > 0 && linesProcessed%cLogPerLines == 0 {
+	for !a.stopping.Load() && a.fp.Next() {
+		if a.progress == nil && linesProcessed > 0 && linesProcessed%cLogPerLines == 0 {
 			logrus.Infof("processed %d lines", linesProcessed)
 		}
 
@@ -538,22 +931,20 @@ func (a *Analyzer) _registerLogGroups(targetLinesCnt int) error {
 			continue
 		}
 
-		if _, err := a.trans.lineToLogGroup(line, 1, a.fp.CurrFileEpoch()); err != nil {
+		a.trans.setLogFile(a.fp.CurrFileName(), a.fp.CurrFileMtime())
+		if err := a._lineToLogGroup(rw, line, a.fp.CurrFileName(), a.fp.Row(), a.fp.CurrFileEpoch()); err != nil {
 			return err
 		}
-		a.RowID++
-		if a.fp.IsEOF && (!a.fp.IsLastFile()) {
-			if err := a.saveLastStatus(); err != nil {
-				return err
-			}
-		}
 		linesProcessed++
+		a.progress.line(a.fp, len(a.trans.lgs.alllg))
 
 		if targetLinesCnt > 0 && linesProcessed >= targetLinesCnt {
 			break
 		}
 	}
 
+	a.progress.end()
+	// the block is committed as not completed so that the next feed goes on counting it
 	if !a.readOnly {
 		if err := a._commit(false); err != nil {
 			return err
@@ -562,59 +953,181 @@ func (a *Analyzer) _registerLogGroups(targetLinesCnt int) error {
 			logrus.Infof("processed %d lines", linesProcessed)
 		}
 	}
+	if a.stopping.Load() {
+		a.interrupted = true
+		logrus.Warnf("feed stopped after row %d of %s. feed again to go on from there",
+			a.LastFileRow, a.fp.CurrFileName())
+	}
 
 	a.fp.Close()
+	return a._endLogGroups(linesProcessed, rw)
+}
 
+// reset the counts of the last feed and open the file of the rejected lines
+func (a *Analyzer) _beginLogGroups() *rejectWriter {
+	a.trans.lateLines = 0
+	a.trans.overflowLines = 0
+	a.trans.lgs.spilled = 0
+	if a.trans.stats != nil {
+		a.trans.stats.resetFeed()
+	}
+	return newRejectWriter(a.RejectFile)
+}
+
+// register line of row of file to its logGroup
+func (a *Analyzer) _lineToLogGroup(rw *rejectWriter, line, file string, row int, updated int64) error {
+	groupId, err := a.trans.lineToLogGroup(line, 1, updated)
+	if err != nil {
+		return err
+	}
+	if err := rw.write(a.trans.lineStatus, file, row, line); err != nil {
+		return err
+	}
+	a.trans.addSource(groupId, line, file)
+	a.RowID++
+	return nil
+}
+
+// tell what became of the lines of the feed
+func (a *Analyzer) _endLogGroups(linesProcessed int, rw *rejectWriter) error {
 	a.linesProcessed = linesProcessed
+	a.lateLines = a.trans.lateLines
+	if a.lateLines > 0 {
+		logrus.Warnf("%d lines came more than reorderWindow %d secs late and were counted in the block of %s",
+			a.lateLines, a.trans.reorderWindow, time.Unix(a.trans.currRetentionPos, 0).Format(time.RFC3339))
+	}
+	a._logMemoryBudget()
+	for _, s := range a.FeedStats() {
+		if s.Grouped == 0 && s.FormatMismatch > 0 {
+			logrus.Warnf("%s: none of %d lines matched logFormat", s.File, s.Read)
+		}
+	}
+	if err := rw.close(); err != nil {
+		return err
+	}
+	if rw != nil && rw.written > 0 {
+		logrus.Infof("wrote %d rejected lines to %s", rw.written, rw.path)
+	}
 
 	return nil
 }
 
+// tell what the feed left out of memory
+func (a *Analyzer) _logMemoryBudget() {
+	tr := a.trans
+	if tr.te.evicted > 0 {
+		logrus.Infof("%d rare terms were evicted out of memory and counted approximately",
+			tr.te.evicted)
+	}
+	if tr.lgs.spilled > 0 {
+		logrus.Infof("%d cold log groups were spilled out of memory to %s",
+			tr.lgs.spilled, tr.lgs._getSpilledPath())
+	}
+	if tr.overflowLines > 0 {
+		logrus.Warnf("%d lines of new patterns were counted in the log group \"%s\" as %d log groups are kept in memory at most. set a larger memoryBudget to keep more",
+			tr.overflowLines, cOverflowDisplayString, tr.maxLogGroups)
+	}
+}
+
+// FeedStats returns the statistics by file of the lines of the last feed
+func (a *Analyzer) FeedStats() []FeedStats {
+	if a.trans.stats == nil {
+		return nil
+	}
+	return a.trans.stats.feedStats()
+}
+
+/*
+OutputLogGroups writes the top N log groups to logGroups.csv and logGroups_last.csv in outdir,
+with the history of all the selected log groups if isHistory, and the counts by source if by is CGroupBySource.
+*/
 func (a *Analyzer) OutputLogGroups(N int, outdir string,
 	searchString, excludeString string,
-	minLastUpdate int64, minCnt, maxCnt int,
-	isHistory, asc bool, groupId int64) error {
-	if err := a.Feed(0); err != nil {
+	minLastUpdate, from, to int64, minCnt, maxCnt int,
+	isHistory, asc bool, by string) error {
+	if outdir == "" {
+		return fmt.Errorf("output directory is mandatory")
+	}
+	if by != "" && by != CGroupBySource {
+		return fmt.Errorf("unknown dimension %s to break down groups by", by)
+	}
+	if from > 0 && to > 0 && from >= to {
+		return fmt.Errorf("from %s must be before to %s", utils.EpochToString(from), utils.EpochToString(to))
+	}
+	if err := a.trans.loadSpilled(); err != nil {
 		return err
 	}
+	if by == CGroupBySource && a.trans.src == nil {
+		return fmt.Errorf("%s has no source counts. set sourceFrom and feed again", a.DataDir)
+	}
 
-	//if len(a.trans.lgs.alllg) == 0 {
-	//	return fmt.Errorf("no log groups found")
-	//}
+	// counts in the time range
+	counts, err := a.trans.getRangeCounts(from, to)
+	if err != nil {
+		return err
+	}
+	src := a.trans.src
+	if src != nil && (from > 0 || to > 0) {
+		spans := a.trans.lgs.historySpans
+		src, err = src.inRange(from, to, func(pos int64) int64 {
+			return spanWidth(spans, pos, a.UnitSecs)
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	allgroupIds := a.trans.getTopNGroupIds(len(a.trans.lgs.alllg), minLastUpdate, searchString, excludeString, minCnt, maxCnt, asc)
+	// the search strings replace the filters of the lines until they are set back
+	defer a.trans._setFilters(a.SearchRegex, a.ExludeRegex)
+	allgroupIds := a.trans.getTopNGroupIds(len(a.trans.lgs.alllg), minLastUpdate, searchString, excludeString, minCnt, maxCnt, asc, counts)
 	if N == 0 {
 		N = CDefaultN
 	}
 
 	//var groupIds []int64
-	groupIds := a.trans.getTopNGroupIds(N, minLastUpdate, searchString, excludeString, minCnt, maxCnt, asc)
-
-	if outdir == "" {
-		if isHistory {
-			if groupId <= 0 {
-				return fmt.Errorf("you need to specify a groupId for history")
-			}
-			a._printLogGroupHistory(groupId)
-		} else {
-			a._printLogGroups(groupIds)
-		}
-		return nil
-	}
+	groupIds := a.trans.getTopNGroupIds(N, minLastUpdate, searchString, excludeString, minCnt, maxCnt, asc, counts)
 
 	if err := utils.EnsureDir(outdir); err != nil {
 		return err
 	}
 
 	if isHistory {
-		if err := a._outputLogGroupsHistoryToCsv("history", outdir, allgroupIds, N); err != nil {
+		if err := a._outputLogGroupsHistoryToCsv("history", outdir, allgroupIds, N, from, to); err != nil {
+			return err
+		}
+	}
+	if by == CGroupBySource {
+		if err := a._outputLogGroupsBySource("logGroups_by_source", outdir, groupIds, src); err != nil {
 			return err
 		}
 	}
-	return a._outputLogGroups("logGroups", outdir, groupIds)
+	return a._outputLogGroups("logGroups", outdir, groupIds, counts, src)
+}
+
+// groupId, source and count of each source of the groups
+func (a *Analyzer) _outputLogGroupsBySource(title, outdir string, groupIds []int64,
+	src *logGroupSources) error {
+	file, err := os.Create(fmt.Sprintf("%s/%s.csv", outdir, title))
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer file.Close()
+	logrus.Infof("writing %s", file.Name())
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"groupId", "source", "count"})
+	for _, groupId := range groupIds {
+		for _, source := range src.getSources([]int64{groupId}) {
+			writer.Write([]string{fmt.Sprint(groupId), source,
+				fmt.Sprint(src.counts[groupId][source])})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
 }
 
-func (a *Analyzer) _outputLogGroups(title, outdir string, groupIds []int64) error {
+func (a *Analyzer) _outputLogGroups(title, outdir string, groupIds []int64,
+	counts map[int64]int, src *logGroupSources) error {
 	var writer *csv.Writer
 	file, err := os.Create(fmt.Sprintf("%s/%s.csv", outdir, title))
 	if err != nil {
@@ -627,11 +1140,19 @@ func (a *Analyzer) _outputLogGroups(title, outdir string, groupIds []int64) erro
 	defer writer.Flush()
 	lgs := a.trans.lgs.alllg
 	// header
-	writer.Write([]string{"groupId", "count", "score", "text"})
+	header := []string{"groupId", "count", "score", "text"}
+	if src != nil {
+		header = append(header, "sources")
+	}
+	writer.Write(header)
 	for _, groupId := range groupIds {
 		lg := lgs[groupId]
-		writer.Write([]string{fmt.Sprint(groupId), fmt.Sprint(lg.count),
-			fmt.Sprintf("%.2f", lg.rareScore), lg.displayString})
+		row := []string{fmt.Sprint(groupId), fmt.Sprint(counts[groupId]),
+			fmt.Sprintf("%.2f", lg.rareScore), lg.displayString}
+		if src != nil {
+			row = append(row, strings.Join(src.format(groupId), " "))
+		}
+		writer.Write(row)
 	}
 	writer.Flush()
 	file.Close()
@@ -651,7 +1172,7 @@ func (a *Analyzer) _outputLogGroups(title, outdir string, groupIds []int64) erro
 	writer.Write([]string{"groupId", "lastUpdate", "count", "score", "text"})
 	for _, groupId := range groupIds {
 		lg := lgs[groupId]
-		writer.Write([]string{fmt.Sprint(groupId), utils.EpochToString(lg.updated), fmt.Sprint(lg.count),
+		writer.Write([]string{fmt.Sprint(groupId), utils.EpochToString(lg.updated), fmt.Sprint(counts[groupId]),
 			fmt.Sprintf("%.3f", lg.rareScore), a.trans.lgs.lastMessages[groupId]})
 	}
 	writer.Flush()
@@ -660,47 +1181,6 @@ func (a *Analyzer) _outputLogGroups(title, outdir string, groupIds []int64) erro
 	return nil
 }
 
-func (a *Analyzer) _printLogGroups(groupIds []int64) error {
-	lgs := a.trans.lgs.alllg
-	// Print header for log groups
-	fmt.Println("Log Groups")
-	fmt.Println("==========")
-	fmt.Printf("%-10s %-10s %-s\n", "groupId", "Count", "Text")
-	for _, groupId := range groupIds {
-		lg := lgs[groupId]
-		fmt.Printf("%-10d %-10d %s\n", groupId, lg.count, lg.displayString)
-	}
-	fmt.Println()
-
-	return nil
-}
-
-func (a *Analyzer) _printLogGroupHistory(groupId int64) error {
-	lg := a.trans.lgs.alllg[groupId]
-	if lg == nil {
-		return fmt.Errorf("log group %d not found", groupId)
-	}
-
-	lgsh, err := a.trans.getLogGroupsHistory([]int64{groupId})
-	if err != nil {
-		return err
-	}
-
-	// Print header for log group history
-	fmt.Printf("History for Log Group %d\n", groupId)
-	fmt.Println("=======================")
-	fmt.Printf("%-20s %-10s\n", "Timestamp", "Value")
-
-	rows := lgsh.buildRows(1)
-
-	for _, row := range rows {
-		fmt.Printf("%-20s %-10s\n", utils.EpochToString(utils.StringToInt64(row[1])), row[2])
-	}
-	fmt.Println()
-
-	return nil
-}
-
 func (a *Analyzer) _outputMetrics(title, outdir string, rows [][]string) error {
 	if err := utils.EnsureDir(outdir); err != nil {
 		return err
@@ -733,11 +1213,15 @@ func (a *Analyzer) _outputMetrics(title, outdir string, rows [][]string) error {
 }
 
 func (a *Analyzer) _outputLogGroupsHistoryToCsv(title string, outdir string,
-	groupIds []int64, topN int) error {
-	lgsh, err := a.trans.getLogGroupsHistory(groupIds)
+	groupIds []int64, topN int, from, to int64) error {
+	lgsh, err := a.trans.getLogGroupsHistory(groupIds, from, to)
 	if err != nil {
 		return err
 	}
+	lgsh.clip(from, to)
+	if len(lgsh.timeline) == 0 {
+		return fmt.Errorf("no history in the time range")
+	}
 
 	// Ensure output directory exists
 	if err := utils.EnsureDir(outdir); err != nil {
@@ -759,6 +1243,10 @@ func (a *Analyzer) _outputLogGroupsHistoryToCsv(title string, outdir string,
 		End:      lgsh.timeline[len(lgsh.timeline)-1],
 		UnitSecs: a.UnitSecs,
 	}
+	// rolled up history has coarser clocks before the spans of finer ones
+	if len(a.trans.lgs.historySpans) > 1 {
+		historyInfo.Spans = a.trans.lgs.historySpans
+	}
 	data, err := json.MarshalIndent(historyInfo, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal history info to JSON: %w", err)
@@ -782,18 +1270,23 @@ In case some of below have changed since the saved config, rebuild trans with re
 a.TermCountBorder, a.MinMatchRate, a.SearchRegex, a.ExludeRegex,a.Keywords, a.Ignorewords, a.CustomLogGroups
 */
 func (a *Analyzer) rebuildTrans() error {
-	tr2, err := newTrans(a.DataDir, "", "", a.UseUtcTime, a.MaxBlocks, a.BlockSize, a.UnitSecs, a.KeepPeriod,
+	// the spilled log groups are rebuilt too
+	if err := a.trans.loadSpilled(); err != nil {
+		return err
+	}
+	tr2, err := newTrans(a.DataDir, "", "", "", a.UseUtcTime, a.MaxBlocks, a.BlockSize, a.UnitSecs, a.KeepPeriod, a.ReorderWindow, a.Retention,
 		0, a.TermCountBorder, a.MinMatchRate, a.SearchRegex, a.ExludeRegex,
 		a.Keywords, a.Ignorewords,
 		a.KeyRegexes, a.IgnoreRegexes,
 		a.MsgFormats, a.PatternKeyRegexes,
-		a.CustomLogGroups, a.Separators,
+		a.CustomLogGroups, a.Maskers, a.Separators, a.Storage, "",
 		true, true, a.testMode, a.IgnoreNumbers)
 	if err != nil {
 		return err
 	}
 	tr2.te = a.trans.te
-	for _, lg := range a.trans.lgs.alllg {
+	groupIds := make(map[int64]int64, len(a.trans.lgs.alllg))
+	for orgGroupId, lg := range a.trans.lgs.alllg {
 		//tokens, displayString, err := tr2.toTokens(lg.displayString, 0, true, true, true)
 		//if err != nil {
 		//	return err
@@ -808,68 +1301,15 @@ func (a *Analyzer) rebuildTrans() error {
 				lg2.created = lg.created
 			}
 		}
+		groupIds[orgGroupId] = groupId
 
 	}
+	if a.trans.src != nil {
+		a.trans.src.remap(groupIds)
+		tr2.src = a.trans.src
+	}
 	tr2.lgs.orgDisplayStrings = a.trans.lgs.displayStrings
+	tr2.onNewLogGroup = a.trans.onNewLogGroup
 	a.trans = tr2
 	return nil
 }
-
-func (a *Analyzer) DetectPatterns(minCnt int, mode, outDir string) error {
-	if err := a.Feed(0); err != nil {
-		return err
-	}
-
-	if len(a.PatternKeyRegexes) == 0 {
-		logrus.Warn("no pattern key regexes defined")
-		return nil
-	}
-
-	if err := a.trans.detectPaterns(minCnt, mode, outDir); err != nil {
-		return fmt.Errorf("error detecting patterns: %w", err)
-	}
-
-	return nil
-}
-
-func (a *Analyzer) ParseLogLine(line string) {
-	if _, err := a.trans.lineToLogGroup(line, 1, 0); err != nil {
-		logrus.Errorf("%+v", err)
-	}
-
-	line, updated, _, err := a.trans.parseLine(line, 0)
-	if err != nil {
-		print(err)
-		return
-	}
-	format := utils.GetDatetimeFormatFromUnitSecs(a.UnitSecs)
-	dt := ""
-	if updated > 0 {
-		dt = time.Unix(updated, 0).Format(format)
-	} else {
-		dt = "PARSE ERROR"
-	}
-	println("the line parsed as:")
-	println("timestamp: ", dt)
-	println("message: ", line)
-
-	if len(a.PatternKeyRegexes) > 0 {
-		PatternKeyId, tags, matched, err := a.trans.pk.findAndRegister(line)
-		if err != nil {
-			print(err)
-			return
-		}
-		if matched {
-			println("pattern matched: ", PatternKeyId)
-			if len(tags) > 0 {
-				println("tags:")
-				for k, v := range tags {
-					println(fmt.Sprintf("  %s: %s", k, v))
-				}
-			}
-		} else {
-			println("no pattern key matched")
-		}
-	}
-
-}
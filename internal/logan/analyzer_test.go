@@ -1,9 +1,15 @@
 package logan
 
 import (
+	"compress/gzip"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"goLogAnalyzer/pkg/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -487,7 +493,7 @@ func Test_Analyzer_daily_Feed(t *testing.T) {
 		return
 	}
 
-	if err := a.OutputLogGroups(10, testDir, "", "", 0, 0, 0, true, false, -1); err != nil {
+	if err := a.OutputLogGroups(10, testDir, "", "", 0, 0, 0, 0, 0, true, false, ""); err != nil {
 		t.Errorf("%v", err)
 		return
 	}
@@ -533,7 +539,7 @@ func Test_Analyzer_daily_Feed(t *testing.T) {
 		return
 	}
 
-	if err := a.OutputLogGroups(10, testDir, "", "", 0, 0, 0, true, false, -1); err != nil {
+	if err := a.OutputLogGroups(10, testDir, "", "", 0, 0, 0, 0, 0, true, false, ""); err != nil {
 		t.Errorf("%v", err)
 		return
 	}
@@ -664,7 +670,12 @@ func Test_Analyzer_multisize(t *testing.T) {
 		return
 	}
 
-	err = a.OutputLogGroups(10, dataDir, "", "", 0, 0, 0, false, true, -1)
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	err = a.OutputLogGroups(10, dataDir, "", "", 0, 0, 0, 0, 0, false, true, "")
 	if err != nil {
 		t.Errorf("%v", err)
 		return
@@ -699,7 +710,7 @@ func Test_Analyzer_multisize(t *testing.T) {
 	//	return
 	//}
 
-	err = a.OutputLogGroups(10, dataDir, "", "", 0, 0, 0, false, false, -1)
+	err = a.OutputLogGroups(10, dataDir, "", "", 0, 0, 0, 0, 0, false, false, "")
 	if err != nil {
 		t.Errorf("%v", err)
 		return
@@ -714,3 +725,205 @@ func Test_Analyzer_multisize(t *testing.T) {
 	//	return
 	//}
 }
+
+func Test_Analyzer_timeRange(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_timeRange")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.UnitSecs = 3600
+	outDir := conf.DataDir + "/../out"
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// 2024-10-02T00:00:00Z to 2024-10-02T09:00:00Z has 4 lines
+	from := time.Date(2024, 10, 2, 0, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(2024, 10, 2, 9, 0, 0, 0, time.UTC).Unix()
+	if err := a.OutputLogGroups(10, outDir, "", "", 0, from, to, 0, 0, true, false, ""); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	_, records, err := utils.ReadCsv(outDir+"/logGroups.csv", ',', false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total := 0
+	for _, record := range records {
+		total += int(utils.StringToInt64(record[1]))
+	}
+	if err := utils.GetGotExpErr("total in range", total, 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	_, records, err = utils.ReadCsv(outDir+"/history.csv", ',', false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total = 0
+	for _, record := range records {
+		clock := utils.StringToInt64(record[1])
+		if clock < from || clock >= to {
+			t.Errorf("clock %d out of the range", clock)
+			return
+		}
+		total += int(utils.StringToInt64(record[2]))
+	}
+	if err := utils.GetGotExpErr("history in range", total, 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := a.OutputLogGroups(10, outDir, "", "", 0, to, from, 0, 0, false, false, ""); err == nil {
+		t.Errorf("from after to must be an error")
+	}
+}
+
+// true if the logGroups block at path has a row in [from, to)
+func _blockHasPos(path string, from, to int64) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return false, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return false, err
+	}
+	for _, record := range records {
+		if pos := utils.StringToInt64(record[1]); pos >= from && pos < to {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func Test_Analyzer_timeRangeSkipsBlocks(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_timeRangeSkipsBlocks")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.UnitSecs = 3600
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.Close()
+
+	b, err := LoadAnalyzer(conf.DataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+
+	// a block replaced with a directory can't be read
+	from := time.Date(2024, 10, 2, 0, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(2024, 10, 2, 9, 0, 0, 0, time.UTC).Unix()
+	paths, err := filepath.Glob(conf.DataDir + "/logGroups/logGroups/BLK*")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	broken := 0
+	for _, path := range paths {
+		inRange, err := _blockHasPos(path, from, to)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if inRange {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		broken++
+	}
+	if broken == 0 || broken == len(paths) {
+		t.Errorf("%d of %d blocks are out of the range", broken, len(paths))
+		return
+	}
+
+	// 2024-10-02T00:00:00Z to 2024-10-02T09:00:00Z has 4 lines
+	groups, err := b.LogGroups(LogGroupsQuery{N: -1, From: from, To: to})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total := 0
+	for _, g := range groups {
+		total += g.Count
+	}
+	if err := utils.GetGotExpErr("total in range", total, 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	g := groups[0]
+	h, err := b.LogGroupHistory(g.GroupId, from, to)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total = 0
+	for _, p := range h.Points {
+		total += p.Count
+	}
+	if err := utils.GetGotExpErr("history in range", total, g.Count); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the rows of the broken blocks are lost to the queries reading them
+	all, err := b.LogGroups(LogGroupsQuery{N: -1})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	h, err = b.LogGroupHistory(all[0].GroupId, 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total = 0
+	for _, p := range h.Points {
+		total += p.Count
+	}
+	if total >= all[0].Count {
+		t.Errorf("the history without the range read %d of %d lines", total, all[0].Count)
+	}
+}
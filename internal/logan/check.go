@@ -0,0 +1,288 @@
+package logan
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"goLogAnalyzer/pkg/filepointer"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CDefaultMaxShareDiff = 0.05
+	// fewer lines tell too little about the shares of the groups
+	CDefaultCheckMinLines = 100
+	CCheckFormatJson      = "json"
+	CCheckFormatJunit     = "junit"
+
+	cCheckRuleNewGroup    = "newGroup"
+	cCheckRuleShareChange = "shareChange"
+)
+
+// a log group in the checked log
+type CheckGroup struct {
+	GroupId       int64   `json:"group_id"` // -1 for groups not in the baseline
+	DisplayString string  `json:"display_string"`
+	Count         int     `json:"count"`
+	Share         float64 `json:"share"`
+	BaselineShare float64 `json:"baseline_share"`
+	Example       string  `json:"example,omitempty"`
+}
+
+/*
+CheckResult is the result of Check.
+NewGroups are the groups not in the baseline.
+ShareChanges are the groups whose share of lines moved more than MaxShareDiff from the baseline,
+including the baseline groups missing in the checked log.
+Shares are judged only when the checked log has MinLines lines or more.
+*/
+type CheckResult struct {
+	Baseline     string       `json:"baseline"`
+	LogPath      string       `json:"log_path"`
+	Lines        int          `json:"lines"`
+	MaxShareDiff float64      `json:"max_share_diff"`
+	MinLines     int          `json:"min_lines"`
+	Passed       bool         `json:"passed"`
+	Groups       []CheckGroup `json:"groups"`
+	NewGroups    []CheckGroup `json:"new_groups"`
+	ShareChanges []CheckGroup `json:"share_changes"`
+}
+
+/*
+Check classifies the lines of logPath against the log groups and terms of the analyzer,
+the baseline fed with known-good logs, and reports new groups and share changes.
+A log shorter than minLines, like the one of a short CI run, is only checked for new groups,
+since most groups of a large baseline can't show up in it.
+The baseline is not modified, so the analyzer should be loaded read only.
+*/
+func (a *Analyzer) Check(logPath string, maxShareDiff float64, minLines int) (*CheckResult, error) {
+	if logPath == "" {
+		return nil, fmt.Errorf("logPath is mandatory for check")
+	}
+	if maxShareDiff <= 0 {
+		maxShareDiff = CDefaultMaxShareDiff
+	}
+	if minLines <= 0 {
+		minLines = CDefaultCheckMinLines
+	}
+	if err := a.trans.loadSpilled(); err != nil {
+		return nil, err
+	}
+	tr := a.trans
+	lgs := tr.lgs
+	// mask the rare terms like feed does
+	tr.setCountBorder()
+
+	baselineTotal := 0
+	for _, lg := range lgs.alllg {
+		baselineTotal += lg.count
+	}
+	if baselineTotal == 0 {
+		return nil, fmt.Errorf("%s has no log groups to check against", a.DataDir)
+	}
+
+	fp, err := filepointer.NewFilePointer(logPath, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := fp.Open(); err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	counts := make(map[int64]int)
+	examples := make(map[int64]string)
+	newGroups := make(map[string]*CheckGroup)
+	lines := 0
+	for fp.Next() {
+		line := fp.Text()
+		tr.setLogFile(fp.CurrFileName(), fp.CurrFileMtime())
+		cl, err := tr.classify(line)
+		if err != nil {
+			return nil, err
+		}
+		if cl == nil {
+			continue
+		}
+		lines++
+		if cl.groupId >= 0 {
+			counts[cl.groupId]++
+			if _, ok := examples[cl.groupId]; !ok {
+				examples[cl.groupId] = line
+			}
+			continue
+		}
+		key := tokensKey(cl.tokens)
+		g, ok := newGroups[key]
+		if !ok {
+			g = &CheckGroup{GroupId: -1, DisplayString: cl.displayString, Example: line}
+			newGroups[key] = g
+		}
+		g.Count++
+	}
+	if err := fp.Err(); err != nil && !fp.IsEOF {
+		return nil, err
+	}
+	logrus.Infof("checked %d lines of %s", lines, logPath)
+	if lines < minLines {
+		logrus.Infof("less than %d lines. the shares of the groups are not checked", minLines)
+	}
+
+	r := &CheckResult{
+		Baseline:     a.DataDir,
+		LogPath:      logPath,
+		Lines:        lines,
+		MaxShareDiff: maxShareDiff,
+		MinLines:     minLines,
+		Groups:       []CheckGroup{},
+		NewGroups:    []CheckGroup{},
+		ShareChanges: []CheckGroup{},
+	}
+	share := func(count, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(count) / float64(total)
+	}
+	for groupId, lg := range lgs.alllg {
+		g := CheckGroup{
+			GroupId:       groupId,
+			DisplayString: lgs.displayStrings[groupId],
+			Count:         counts[groupId],
+			Share:         share(counts[groupId], lines),
+			BaselineShare: share(lg.count, baselineTotal),
+			Example:       examples[groupId],
+		}
+		if g.Count > 0 {
+			r.Groups = append(r.Groups, g)
+		}
+		if lines >= minLines && math.Abs(g.Share-g.BaselineShare) > maxShareDiff {
+			r.ShareChanges = append(r.ShareChanges, g)
+		}
+	}
+	for _, g := range newGroups {
+		g.Share = share(g.Count, lines)
+		r.Groups = append(r.Groups, *g)
+		r.NewGroups = append(r.NewGroups, *g)
+	}
+	sortCheckGroups(r.Groups)
+	sortCheckGroups(r.NewGroups)
+	sortCheckGroups(r.ShareChanges)
+	r.Passed = len(r.NewGroups) == 0 && len(r.ShareChanges) == 0
+	return r, nil
+}
+
+// the key of a new group
+func tokensKey(tokens []int) string {
+	var sb strings.Builder
+	for _, token := range tokens {
+		sb.WriteString(strconv.Itoa(token))
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+// in descending order of counts
+func sortCheckGroups(groups []CheckGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		if groups[i].BaselineShare != groups[j].BaselineShare {
+			return groups[i].BaselineShare > groups[j].BaselineShare
+		}
+		return groups[i].DisplayString < groups[j].DisplayString
+	})
+}
+
+// Write writes the result in format, json or junit
+func (r *CheckResult) Write(w io.Writer, format string) error {
+	switch format {
+	case "", CCheckFormatJson:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case CCheckFormatJunit:
+		return r.writeJunit(w)
+	default:
+		return fmt.Errorf("unknown format %s. %s or %s", format, CCheckFormatJson, CCheckFormatJunit)
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// one test case per group of the checked log and per share change of the groups missing in it
+func (r *CheckResult) writeJunit(w io.Writer) error {
+	suite := junitTestSuite{Name: fmt.Sprintf("logan check %s", r.LogPath)}
+	changed := make(map[int64]bool, len(r.ShareChanges))
+	for _, g := range r.ShareChanges {
+		changed[g.GroupId] = true
+	}
+	addCase := func(g CheckGroup, rule, message string) {
+		tc := junitTestCase{Name: g.DisplayString, ClassName: rule}
+		if message != "" {
+			tc.Failure = &junitFailure{Message: message, Type: rule, Text: g.Example}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+	}
+	for _, g := range r.Groups {
+		switch {
+		case g.GroupId < 0:
+			addCase(g, cCheckRuleNewGroup, fmt.Sprintf("not in the baseline: %d lines", g.Count))
+		case changed[g.GroupId]:
+			addCase(g, cCheckRuleShareChange, shareChangeMessage(g))
+		default:
+			addCase(g, cCheckRuleShareChange, "")
+		}
+	}
+	for _, g := range r.ShareChanges {
+		if g.Count == 0 {
+			addCase(g, cCheckRuleShareChange, shareChangeMessage(g))
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func shareChangeMessage(g CheckGroup) string {
+	return fmt.Sprintf("share changed from %.2f%% to %.2f%%", g.BaselineShare*100, g.Share*100)
+}
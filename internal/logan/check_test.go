@@ -0,0 +1,206 @@
+package logan
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Check(t *testing.T) {
+	a, err := _newSampleAnalyzer("Test_Check")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	logPath := a.LogPath
+	dataDir := a.DataDir
+	a.Close()
+
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+
+	// the logs of the baseline itself
+	r, err := b.Check(logPath, 0, 1)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed", r.Passed, true); err != nil {
+		t.Errorf("%v: %+v", err, r)
+		return
+	}
+	if err := utils.GetGotExpErr("lines", r.Lines, 34); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", len(r.Groups), len(b.trans.lgs.alllg)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// a new line twice and only one group of the baseline
+	newLog := filepath.Dir(dataDir) + "/new.log"
+	data := "2024-10-01T00:00:00] Com1, grpa10 Com2 (uniq)0001 grpa50 (uniq)0101 <coM3> (uniq)0201 grpa20 (uniq)0301\n" +
+		"2024-10-03T00:00:00] Fatal error: disk /dev/sda1 full\n" +
+		"2024-10-03T00:00:01] Fatal error: disk /dev/sdb1 full\n"
+	if err := os.WriteFile(newLog, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	r, err = b.Check(newLog, 0, 1)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed", r.Passed, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("new groups", len(r.NewGroups), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("new group count", r.NewGroups[0].Count, 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(r.ShareChanges) == 0 {
+		t.Errorf("no share changes")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, CCheckFormatJson); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	var decoded CheckResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("json new groups", len(decoded.NewGroups), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	buf.Reset()
+	if err := r.Write(&buf, CCheckFormatJunit); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("junit failures", suites.Suites[0].Failures,
+		len(r.NewGroups)+len(r.ShareChanges)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if err := r.Write(&buf, "csv"); err == nil {
+		t.Errorf("no error for an unknown format")
+	}
+}
+
+func Test_Check_shortLog(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Check_shortLog")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// 100 lines of groups of 10 lines each
+	conf.LogPath = "../../testdata/loganal/sample50_*.log"
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+
+	// a short run logs 2 lines of one group of the baseline and none of the others
+	shortLog := filepath.Dir(dataDir) + "/short.log"
+	data := "2024-10-05T00:00:00] Com1, grpa10 Com2 (uniq)0001 grpa50 (uniq)0101 <coM3> (uniq)0201 grpa20 (uniq)0301\n" +
+		"2024-10-05T00:00:01] Com1, grpa10 Com2 (uniq)0002 grpa50 (uniq)0102 <coM3> (uniq)0202 grpa20 (uniq)0302\n"
+	if err := os.WriteFile(shortLog, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	r, err := b.Check(shortLog, 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed", r.Passed, true); err != nil {
+		t.Errorf("%v: %+v", err, r)
+		return
+	}
+	if err := utils.GetGotExpErr("min lines", r.MinLines, CDefaultCheckMinLines); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the shares are judged when the lines are enough
+	r, err = b.Check(shortLog, 0, 2)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed with 2 min lines", r.Passed, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(r.ShareChanges) == 0 {
+		t.Errorf("no share changes")
+		return
+	}
+
+	// new groups fail the short log
+	data += "2024-10-05T00:00:02] Fatal error: disk /dev/sda1 full\n"
+	if err := os.WriteFile(shortLog, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	r, err = b.Check(shortLog, 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed with a new group", r.Passed, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("new groups", len(r.NewGroups), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("share changes", len(r.ShareChanges), 0); err != nil {
+		t.Errorf("%v", err)
+	}
+}
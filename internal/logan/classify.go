@@ -0,0 +1,130 @@
+package logan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*
+ClassifiedLine is a line of Classify.
+GroupId is -1 for lines of no existing log group, and for the lines filtered out or not matching logFormat,
+which have no DisplayString either.
+Values are the words of the message behind the "*"s and the placeholders of DisplayString.
+*/
+type ClassifiedLine struct {
+	Line          string   `json:"line"`
+	Timestamp     string   `json:"timestamp,omitempty"`
+	GroupId       int64    `json:"group_id"`
+	DisplayString string   `json:"display_string,omitempty"`
+	Values        []string `json:"values,omitempty"`
+	PatternKey    string   `json:"pattern_key,omitempty"`
+}
+
+/*
+Classify reads lines from r and writes each of them with its log group to w as NDJSON.
+Nothing is registered, so the analyzer should be loaded read only.
+*/
+func (a *Analyzer) Classify(r io.Reader, w io.Writer) error {
+	tr := a.trans
+	tr.setCountBorder()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	// the regexes picking the values up by group
+	valueRes := make(map[int64]*regexp.Regexp)
+
+	reader := bufio.NewReader(r)
+	lines := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading line %d: %w", lines+1, err)
+		}
+		eof := err == io.EOF
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			lines++
+			if err := tr.writeClassified(enc, valueRes, line); err != nil {
+				return err
+			}
+		}
+		if eof {
+			break
+		}
+	}
+	logrus.Infof("classified %d lines", lines)
+	return nil
+}
+
+func (tr *trans) writeClassified(enc *json.Encoder, valueRes map[int64]*regexp.Regexp, line string) error {
+	out := ClassifiedLine{Line: line, GroupId: -1}
+	cl, err := tr.classify(line)
+	if err != nil {
+		return err
+	}
+	if cl != nil {
+		out.GroupId, out.DisplayString, out.PatternKey = cl.groupId, cl.displayString, cl.patternKey
+		if cl.updated > 0 {
+			out.Timestamp = time.Unix(cl.updated, 0).Format(time.RFC3339)
+		}
+		re, ok := valueRes[cl.groupId]
+		if !ok {
+			re = displayStringRegex(cl.displayString, tr.maskers)
+			// new groups differ line by line
+			if cl.groupId >= 0 {
+				valueRes[cl.groupId] = re
+			}
+		}
+		out.Values = extractValues(re, cl.message)
+	}
+	return enc.Encode(out)
+}
+
+/*
+a regex matching the messages of displayString with a group for each "*" and each placeholder of mk.
+nil without any of them
+*/
+func displayStringRegex(displayString string, mk *maskers) *regexp.Regexp {
+	var b strings.Builder
+	values := 0
+	for _, seg := range mk.split(displayString) {
+		if seg.placeholder {
+			b.WriteString(`(.+?)`)
+			values++
+			continue
+		}
+		for i, part := range strings.Split(seg.text, "*") {
+			if i > 0 {
+				b.WriteString(`(.+?)`)
+				values++
+			}
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	if values == 0 {
+		return nil
+	}
+	// the terms are compared in lower case
+	re, err := regexp.Compile(`(?is)^` + b.String() + `$`)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+func extractValues(re *regexp.Regexp, message string) []string {
+	if re == nil {
+		return nil
+	}
+	ma := re.FindStringSubmatch(message)
+	if ma == nil {
+		return nil
+	}
+	return ma[1:]
+}
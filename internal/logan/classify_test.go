@@ -0,0 +1,88 @@
+package logan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"goLogAnalyzer/pkg/utils"
+	"strings"
+	"testing"
+)
+
+func Test_Classify(t *testing.T) {
+	a, err := _newSampleAnalyzer("Test_Classify")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	nGroups := len(b.trans.lgs.alllg)
+
+	input := "2024-10-01T00:00:00] Com1, grpa10 Com2 (uniq)0001 grpa50 (uniq)0101 <coM3> (uniq)0201 grpa20 (uniq)0301\n" +
+		"\n" +
+		"2024-10-03T00:00:00] Fatal error: disk full\n" +
+		"not a log line\n"
+	var out bytes.Buffer
+	if err := b.Classify(strings.NewReader(input), &out); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	var got []ClassifiedLine
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var cl ClassifiedLine
+		if err := json.Unmarshal(scanner.Bytes(), &cl); err != nil {
+			t.Errorf("%v: %s", err, scanner.Text())
+			return
+		}
+		got = append(got, cl)
+	}
+	if err := utils.GetGotExpErr("lines", len(got), 3); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	known := got[0]
+	if known.GroupId <= 0 {
+		t.Errorf("no group for %s", known.Line)
+		return
+	}
+	if err := utils.GetGotExpErr("displayString", known.DisplayString, b.trans.lgs.displayStrings[known.GroupId]); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := utils.GetGotExpErr("values", strings.Join(known.Values, ","),
+		"(uniq)0001,(uniq)0101,(uniq)0201,(uniq)0301"); err != nil {
+		t.Errorf("%v", err)
+	}
+	if known.Timestamp == "" {
+		t.Errorf("no timestamp for %s", known.Line)
+	}
+
+	if err := utils.GetGotExpErr("new groupId", got[1].GroupId, int64(-1)); err != nil {
+		t.Errorf("%v", err)
+	}
+	if got[1].DisplayString == "" {
+		t.Errorf("no displayString for %s", got[1].Line)
+	}
+	if err := utils.GetGotExpErr("unmatched displayString", got[2].DisplayString, ""); err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// nothing is registered
+	if err := utils.GetGotExpErr("groups", len(b.trans.lgs.alllg), nGroups); err != nil {
+		t.Errorf("%v", err)
+	}
+}
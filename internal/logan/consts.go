@@ -2,6 +2,7 @@ package logan
 
 import (
 	"goLogAnalyzer/pkg/utils"
+	"time"
 )
 
 const (
@@ -17,15 +18,24 @@ const (
 	CDefaultStdThreshold        = 2
 	CDefaultMinOccurrences      = 10
 	CDefaultN                   = 10
+	CDefaultLockTimeout         = 60 * time.Second
 	CFileFormatJson             = "json"
 	CFileFormatCsv              = "csv"
+	CGroupBySource              = "source"
+
+	// timestampLayout of epoch seconds like 1729000000.123 and epoch milliseconds
+	CTimestampLayoutEpoch       = "epoch"
+	CTimestampLayoutEpochMillis = "epochMillis"
 
 	cAsteriskItemID          = -1
 	cMaxNumDigits            = 3 // HTTP codes
 	cLogPerLines             = 1000000
 	cStageRegisterTerms      = 1
 	cStageRegisterLogStrings = 2
-	cMaxLogGroups            = 1000000
+	cMaxLogGroups            = 1000000 // kept in memory without memoryBudget
+	cFeedBatchLines          = 10000   // lines of a batch of FeedLines
+	cFeedBatchWait           = time.Second
+	cReaderFileName          = "reader" // the file of the lines of FeedLines in the feed stats
 	cKmeansMinK              = 5
 	cKmeansMaxIter           = 10
 	cKmeansTrial             = 10
@@ -0,0 +1,193 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/filepointer"
+	"strings"
+	"unicode"
+)
+
+// sample lines of logPath DetectLogFormat reads by default
+const CDefaultDetectSampleLines = 1000
+
+// a log format DetectLogFormat tries
+type knownLogFormat struct {
+	name            string
+	logFormat       string
+	timestampLayout string
+	useUtcTime      bool
+}
+
+// the separator between the timestamp and the message of the formats with the timestamp first
+const cTimestampSuffix = `[\]:,|]?\s+`
+
+// a format starting with the timestamp, optionally in brackets
+func timestampFirst(name, timestamp, layout string, useUtcTime bool) knownLogFormat {
+	return knownLogFormat{name,
+		`^\[?(?P<timestamp>` + timestamp + `)` + cTimestampSuffix + `(?P<message>.*)$`, layout, useUtcTime}
+}
+
+// in the order of preference when several formats match the same lines
+var knownLogFormats = []knownLogFormat{
+	timestampFirst("ISO8601 with time zone",
+		`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:\d{2})`, "2006-01-02T15:04:05Z07:00", true),
+	timestampFirst("ISO8601",
+		`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:[.,]\d+)?`, "2006-01-02T15:04:05", false),
+	timestampFirst("date time with time zone",
+		`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:[.,]\d+)? [+-]\d{4}`, "2006-01-02 15:04:05 -0700", true),
+	timestampFirst("date time",
+		`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:[.,]\d+)?`, "2006-01-02 15:04:05", false),
+	timestampFirst("date time with slashes",
+		`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:[.,]\d+)?`, "2006/01/02 15:04:05", false),
+	timestampFirst("syslog",
+		`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`, "Jan _2 15:04:05", false),
+	timestampFirst("day of month with time zone",
+		`\d{1,2}(?:st|nd|rd|th), \d{2}:\d{2}:\d{2}\.\d{3}[+-]\d{4}`, "02th, 15:04:05.000-0700", false),
+	{"apache/nginx access log",
+		`^\S+ \S+ \S+ \[(?P<timestamp>\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\] (?P<message>.*)$`,
+		"02/Jan/2006:15:04:05 -0700", true},
+	{"apache error log",
+		`^\[(?P<timestamp>[A-Z][a-z]{2} [A-Z][a-z]{2} \d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? \d{4})\] (?P<message>.*)$`,
+		"Mon Jan 02 15:04:05 2006", false},
+	timestampFirst("epoch milliseconds", `1\d{12}`, CTimestampLayoutEpochMillis, false),
+	timestampFirst("epoch", `1\d{9}(?:\.\d+)?`, CTimestampLayoutEpoch, false),
+}
+
+// characters joining ids and numbers like 0xBF9860B9-0x00000000 that are worth separating
+const cDetectSeparatorCandidates = "-.#*$%~^"
+
+/*
+DetectedLogFormat is the result of DetectLogFormat.
+Matched is the number of the Sampled lines the format matched and parsed the timestamps of.
+*/
+type DetectedLogFormat struct {
+	Name            string
+	LogFormat       string
+	TimestampLayout string
+	UseUtcTime      bool
+	Separators      string
+	Matched         int
+	Sampled         int
+	FirstLine       string
+}
+
+/*
+DetectLogFormat samples the first sampleLines lines of logPath and tries the known log formats:
+ISO8601, syslog, apache/nginx, the day of month style of sbc_gateway, epoch and so on.
+The format matching the most lines with parsable timestamps is returned.
+Name is empty when none of them matches, then the whole line is the message.
+Separators are the default ones plus the characters joining numbers in the messages.
+*/
+func DetectLogFormat(logPath string, sampleLines int) (*DetectedLogFormat, error) {
+	if logPath == "" {
+		return nil, fmt.Errorf("logPath is mandatory to detect the log format")
+	}
+	if sampleLines <= 0 {
+		sampleLines = CDefaultDetectSampleLines
+	}
+	lines, err := readSampleLines(logPath, sampleLines)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no lines to sample in %s", logPath)
+	}
+
+	d := &DetectedLogFormat{Sampled: len(lines), FirstLine: lines[0]}
+	var best *trans
+	for _, kf := range knownLogFormats {
+		tr := &trans{timestampLayout: kf.timestampLayout, useUtcTime: kf.useUtcTime}
+		if err := tr._parseLogFormat(kf.logFormat); err != nil {
+			return nil, err
+		}
+		if matched := countParsedLines(tr, lines); matched > d.Matched {
+			d.Name, d.LogFormat, d.TimestampLayout, d.UseUtcTime = kf.name, kf.logFormat, kf.timestampLayout, kf.useUtcTime
+			d.Matched = matched
+			best = tr
+		}
+	}
+
+	messages := lines
+	if best != nil {
+		messages = make([]string, 0, len(lines))
+		for _, line := range lines {
+			if ma := best.logFormatRe.FindStringSubmatch(line); ma != nil {
+				messages = append(messages, ma[best.messagePos])
+			}
+		}
+	}
+	d.Separators = detectSeparators(messages)
+	return d, nil
+}
+
+// lines whose timestamps tr parses
+func countParsedLines(tr *trans, lines []string) int {
+	matched := 0
+	for _, line := range lines {
+		ma := tr.logFormatRe.FindStringSubmatch(line)
+		if ma == nil {
+			continue
+		}
+		if _, err := tr.parseTimestamp(ma[tr.timestampPos]); err == nil {
+			matched++
+		}
+	}
+	return matched
+}
+
+// the default separators plus the candidates joining numbers in a tenth of the messages or more
+func detectSeparators(messages []string) string {
+	replacer := getDelimReplacer(CDefaultSeparators)
+	separators := CDefaultSeparators
+	for _, c := range cDetectSeparatorCandidates {
+		joined := 0
+		for _, msg := range messages {
+			for _, token := range strings.Fields(replacer.Replace(msg)) {
+				if joinsNumbers(token, c) {
+					joined++
+					break
+				}
+			}
+		}
+		if len(messages) > 0 && joined*10 >= len(messages) {
+			separators += string(c)
+		}
+	}
+	return separators
+}
+
+// token like 0xBF9860B9-0x00000000 with digits on both sides of c
+func joinsNumbers(token string, c rune) bool {
+	parts := strings.Split(token, string(c))
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if strings.IndexFunc(part, unicode.IsDigit) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// the first n non-empty lines of logPath
+func readSampleLines(logPath string, n int) ([]string, error) {
+	fp, err := filepointer.NewFilePointer(logPath, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := fp.Open(); err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	lines := make([]string, 0, n)
+	for len(lines) < n && fp.Next() {
+		if line := fp.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := fp.Err(); err != nil && !fp.IsEOF {
+		return nil, err
+	}
+	return lines, nil
+}
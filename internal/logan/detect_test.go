@@ -0,0 +1,75 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"testing"
+)
+
+func Test_DetectLogFormat(t *testing.T) {
+	testDir, err := utils.InitTestDir("Test_DetectLogFormat")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	samples := map[string]string{
+		"access.log": `192.168.0.1 - - [01/Oct/2024:10:00:00 +0900] "GET / HTTP/1.1" 200 512
+192.168.0.2 - - [01/Oct/2024:10:00:01 +0900] "GET /favicon.ico HTTP/1.1" 404 0
+`,
+		"epoch.log": `1727740800.123 started worker 1
+1727740801.456 stopped worker 1
+`,
+	}
+	for name, sample := range samples {
+		if err := os.WriteFile(testDir+"/"+name, []byte(sample), 0644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	tests := []struct {
+		path   string
+		name   string
+		layout string
+	}{
+		{"../../testdata/loganal/sample50_1.log", "ISO8601", "2006-01-02T15:04:05"},
+		{"../../testdata/loganal/hourly.log", "date time", "2006-01-02 15:04:05"},
+		{"../../testdata/loganal/netscreen.log", "syslog", "Jan _2 15:04:05"},
+		{"../../testdata/loganal/sbc_gateway.log", "day of month with time zone", "02th, 15:04:05.000-0700"},
+		{testDir + "/access.log", "apache/nginx access log", "02/Jan/2006:15:04:05 -0700"},
+		{testDir + "/epoch.log", "epoch", CTimestampLayoutEpoch},
+	}
+	for _, tt := range tests {
+		d, err := DetectLogFormat(tt.path, 0)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr(tt.path+" name", d.Name, tt.name); err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		if err := utils.GetGotExpErr(tt.path+" layout", d.TimestampLayout, tt.layout); err != nil {
+			t.Errorf("%v", err)
+		}
+		if err := utils.GetGotExpErr(tt.path+" matched", d.Matched, d.Sampled); err != nil {
+			t.Errorf("%v", err)
+		}
+
+		// the detected format must pass the lint
+		conf := &AnalConfig{LogPath: tt.path, LogFormat: d.LogFormat,
+			TimestampLayout: d.TimestampLayout, UseUtcTime: d.UseUtcTime, Separators: d.Separators}
+		if problems := LintConfig(conf, CDefaultLintSampleLines); len(problems) > 0 {
+			t.Errorf("%s: %v", tt.path, problems)
+		}
+	}
+
+	d, err := DetectLogFormat("../../testdata/loganal/sbc_gateway.log", 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("separators", d.Separators, CDefaultSeparators+"-"); err != nil {
+		t.Errorf("%v", err)
+	}
+}
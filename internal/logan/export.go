@@ -0,0 +1,180 @@
+package logan
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CExportFormatSqlite  = "sqlite"
+	CExportFormatParquet = "parquet"
+
+	cExportTypeInt  = "INTEGER"
+	cExportTypeReal = "REAL"
+	cExportTypeText = "TEXT"
+	cExportTypeBool = "BOOLEAN"
+)
+
+// a normalised table written by Export()
+type exportTable struct {
+	name       string
+	columns    []string
+	types      []string
+	primaryKey []string
+	indexes    [][]string
+	rows       [][]interface{}
+}
+
+func newExportTable(name string, columns, types []string, primaryKey []string, indexes ...[]string) *exportTable {
+	return &exportTable{
+		name:       name,
+		columns:    columns,
+		types:      types,
+		primaryKey: primaryKey,
+		indexes:    indexes,
+		rows:       make([][]interface{}, 0),
+	}
+}
+
+func (t *exportTable) append(values ...interface{}) {
+	t.rows = append(t.rows, values)
+}
+
+/*
+Export writes log groups, their history, terms, pattern keys and pattern tags
+to normalised tables in outPath.
+
+  - sqlite: a single database file with indexes
+  - parquet: outPath is a directory with one <table>.parquet file per table, rows sorted by the primary key
+*/
+func (a *Analyzer) Export(format, outPath string) error {
+	if outPath == "" {
+		return fmt.Errorf("output path is mandatory for export")
+	}
+	if format != CExportFormatSqlite && format != CExportFormatParquet {
+		return fmt.Errorf("unknown export format %s", format)
+	}
+	if err := a.trans.loadSpilled(); err != nil {
+		return err
+	}
+
+	tables, err := a._buildExportTables()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case CExportFormatSqlite:
+		err = exportToSqlite(outPath, tables)
+	case CExportFormatParquet:
+		err = exportToParquet(outPath, tables)
+	}
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		logrus.Infof("exported %d rows to %s", len(t.rows), t.name)
+	}
+	return nil
+}
+
+func (a *Analyzer) _buildExportTables() ([]*exportTable, error) {
+	tr := a.trans
+	groups := newExportTable("groups",
+		[]string{"groupId", "displayString", "count", "created", "updated", "rareScore"},
+		[]string{cExportTypeInt, cExportTypeText, cExportTypeInt, cExportTypeInt, cExportTypeInt, cExportTypeReal},
+		[]string{"groupId"},
+		[]string{"count"})
+	history := newExportTable("group_history",
+		[]string{"groupId", "epoch", "count"},
+		[]string{cExportTypeInt, cExportTypeInt, cExportTypeInt},
+		[]string{"groupId", "epoch"},
+		[]string{"epoch"})
+	terms := newExportTable("terms",
+		[]string{"term", "count", "idf"},
+		[]string{cExportTypeText, cExportTypeInt, cExportTypeReal},
+		[]string{"term"})
+	patternKeys := newExportTable("pattern_keys",
+		[]string{"patternKey", "epoch", "matched", "groupId"},
+		[]string{cExportTypeText, cExportTypeInt, cExportTypeBool, cExportTypeInt},
+		nil,
+		[]string{"patternKey", "epoch"}, []string{"groupId"})
+	patternTags := newExportTable("pattern_tags",
+		[]string{"patternKey", "name", "value"},
+		[]string{cExportTypeText, cExportTypeText, cExportTypeText},
+		[]string{"patternKey", "name"},
+		[]string{"name", "value"})
+
+	groupIds := make([]int64, 0, len(tr.lgs.alllg))
+	for groupId := range tr.lgs.alllg {
+		groupIds = append(groupIds, groupId)
+	}
+	sort.Slice(groupIds, func(i, j int) bool { return groupIds[i] < groupIds[j] })
+	for _, groupId := range groupIds {
+		lg := tr.lgs.alllg[groupId]
+		groups.append(groupId, lg.displayString, lg.count, lg.created, lg.updated, lg.rareScore)
+	}
+
+	countHistory, err := tr.loadLogGroupHistory(nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, groupId := range groupIds {
+		epochs := make([]int64, 0, len(countHistory[groupId]))
+		for epoch := range countHistory[groupId] {
+			epochs = append(epochs, epoch)
+		}
+		sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+		for _, epoch := range epochs {
+			history.append(groupId, epoch, countHistory[groupId][epoch])
+		}
+	}
+
+	termIds := make([]int, 0, len(tr.te.counts))
+	for termId := range tr.te.counts {
+		termIds = append(termIds, termId)
+	}
+	sort.Slice(termIds, func(i, j int) bool {
+		return tr.te.id2term[termIds[i]] < tr.te.id2term[termIds[j]]
+	})
+	for _, termId := range termIds {
+		terms.append(tr.te.id2term[termId], tr.te.counts[termId], tr.te.getIdf(termId))
+	}
+
+	if tr.pk != nil && tr.pk.Store != nil {
+		if err := tr.pk.loadAll(nil); err != nil {
+			return nil, err
+		}
+		keyIds := make([]string, 0, len(tr.pk.records))
+		for keyId := range tr.pk.records {
+			keyIds = append(keyIds, keyId)
+		}
+		sort.Strings(keyIds)
+		for _, keyId := range keyIds {
+			for _, rec := range tr.pk.records[keyId] {
+				patternKeys.append(keyId, rec.epoch, rec.matched, rec.groupId)
+			}
+		}
+
+		keyIds = make([]string, 0, len(tr.pk.pt.tags))
+		for keyId := range tr.pk.pt.tags {
+			keyIds = append(keyIds, keyId)
+		}
+		sort.Strings(keyIds)
+		for _, keyId := range keyIds {
+			tags := tr.pk.pt.tags[keyId]
+			names := make([]string, 0, len(tags))
+			for name := range tags {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				patternTags.append(keyId, name, tags[name])
+			}
+		}
+	}
+
+	return []*exportTable{groups, history, terms, patternKeys, patternTags}, nil
+}
@@ -0,0 +1,93 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquet has no indexes. rows are written sorted so readers can skip pages by min/max statistics
+func exportToParquet(outDir string, tables []*exportTable) error {
+	if err := utils.EnsureDir(outDir); err != nil {
+		return err
+	}
+	for _, t := range tables {
+		if err := _writeParquetTable(fmt.Sprintf("%s/%s.parquet", outDir, t.name), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func _parquetNode(typ string) parquet.Node {
+	switch typ {
+	case cExportTypeInt:
+		return parquet.Leaf(parquet.Int64Type)
+	case cExportTypeReal:
+		return parquet.Leaf(parquet.DoubleType)
+	case cExportTypeBool:
+		return parquet.Leaf(parquet.BooleanType)
+	default:
+		return parquet.String()
+	}
+}
+
+func _parquetValue(v interface{}) parquet.Value {
+	switch v := v.(type) {
+	case int:
+		return parquet.Int64Value(int64(v))
+	case int64:
+		return parquet.Int64Value(v)
+	case float64:
+		return parquet.DoubleValue(v)
+	case bool:
+		return parquet.BooleanValue(v)
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	default:
+		return parquet.ByteArrayValue([]byte(fmt.Sprint(v)))
+	}
+}
+
+func _writeParquetTable(path string, t *exportTable) error {
+	group := parquet.Group{}
+	for i, col := range t.columns {
+		group[col] = _parquetNode(t.types[i])
+	}
+	schema := parquet.NewSchema(t.name, group)
+
+	// parquet orders the columns of a group by name
+	colIndexes := make([]int, len(t.columns))
+	for i, col := range t.columns {
+		leaf, ok := schema.Lookup(col)
+		if !ok {
+			return fmt.Errorf("column %s not found in the schema of %s", col, t.name)
+		}
+		colIndexes[i] = leaf.ColumnIndex
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer file.Close()
+
+	writer := parquet.NewWriter(file, schema)
+	rows := make([]parquet.Row, 0, len(t.rows))
+	for _, values := range t.rows {
+		row := make(parquet.Row, len(values))
+		for i, v := range values {
+			row[colIndexes[i]] = _parquetValue(v).Level(0, 0, colIndexes[i])
+		}
+		rows = append(rows, row)
+	}
+	if _, err := writer.WriteRows(rows); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,84 @@
+package logan
+
+import (
+	"database/sql"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+func exportToSqlite(path string, tables []*exportTable) error {
+	// always write a fresh database like the CSV outputs
+	if utils.PathExist(path) {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("error removing %s: %w", path, err)
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range tables {
+		if err := _createSqliteTable(tx, t); err != nil {
+			return err
+		}
+		if err := _insertSqliteRows(tx, t); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func _createSqliteTable(tx *sql.Tx, t *exportTable) error {
+	defs := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		defs[i] = fmt.Sprintf("%s %s NOT NULL", col, t.types[i])
+	}
+	if len(t.primaryKey) > 0 {
+		defs = append(defs, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(t.primaryKey, ", ")))
+	}
+	query := fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", t.name, strings.Join(defs, ",\n  "))
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("error creating table %s: %w", t.name, err)
+	}
+
+	for _, index := range t.indexes {
+		query := fmt.Sprintf("CREATE INDEX idx_%s_%s ON %s (%s)",
+			t.name, strings.Join(index, "_"), t.name, strings.Join(index, ", "))
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("error creating index on %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+func _insertSqliteRows(tx *sql.Tx, t *exportTable) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(t.columns)), ", ")
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		t.name, strings.Join(t.columns, ", "), placeholders))
+	if err != nil {
+		return fmt.Errorf("error preparing insert into %s: %w", t.name, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range t.rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return fmt.Errorf("error inserting into %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
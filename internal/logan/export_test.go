@@ -0,0 +1,99 @@
+package logan
+
+import (
+	"database/sql"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func Test_Export(t *testing.T) {
+	a, err := _newSampleAnalyzer("Test_Export")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	totalCount := 0
+	for _, lg := range a.trans.lgs.alllg {
+		totalCount += lg.count
+	}
+	nGroups := len(a.trans.lgs.alllg)
+
+	// sqlite
+	dbPath := a.DataDir + ".db"
+	if err := a.Export(CExportFormatSqlite, dbPath); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer db.Close()
+
+	var cnt, sum int
+	if err := db.QueryRow("SELECT COUNT(*), SUM(count) FROM groups").Scan(&cnt, &sum); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", cnt, nGroups); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("sum of counts", sum, totalCount); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// history sums up to the group counts
+	if err := db.QueryRow(`SELECT COUNT(*) FROM groups g
+		WHERE g.count != (SELECT SUM(h.count) FROM group_history h WHERE h.groupId = g.groupId)`).Scan(&cnt); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups with unmatched history", cnt, 0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM terms WHERE idf > 0").Scan(&cnt); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if cnt == 0 {
+		t.Errorf("no terms exported")
+		return
+	}
+
+	// parquet
+	outDir := a.DataDir + "_parquet"
+	if err := a.Export(CExportFormatParquet, outDir); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	rows, err := parquet.ReadFile[struct {
+		GroupId int64 `parquet:"groupId"`
+		Count   int64 `parquet:"count"`
+	}](fmt.Sprintf("%s/groups.parquet", outDir))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("parquet groups", len(rows), nGroups); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	sum = 0
+	for _, row := range rows {
+		sum += int(row.Count)
+	}
+	if err := utils.GetGotExpErr("parquet sum of counts", sum, totalCount); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
@@ -0,0 +1,257 @@
+package logan
+
+import (
+	"bufio"
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"sort"
+)
+
+const (
+	// the store of the feed statistics
+	cFeedStatsStoreName = "feedStats"
+
+	// rejected lines written to rejectFile in a feed
+	cMaxRejectedLines = 1000
+)
+
+// what became of a line in lineToLogGroup
+type lineStatus int
+
+const (
+	cLineGrouped lineStatus = iota
+	cLineEmpty
+	cLineFiltered
+	cLineFormatMismatch
+	cLineBadTimestamp // grouped with the whole line in the current block
+	cLineLate         // grouped in the current block
+)
+
+var lineStatusNames = map[lineStatus]string{
+	cLineFiltered:       "filtered",
+	cLineFormatMismatch: "formatMismatch",
+	cLineBadTimestamp:   "badTimestamp",
+	cLineLate:           "late",
+}
+
+/*
+FeedStats counts the lines of a log file by what became of them.
+Read is the non empty lines. Grouped includes BadTimestamp and Late,
+the lines counted in the current block instead of at their own time.
+*/
+type FeedStats struct {
+	File           string `json:"file"`
+	Read           int    `json:"read"`
+	Grouped        int    `json:"grouped"`
+	Filtered       int    `json:"filtered"`
+	FormatMismatch int    `json:"format_mismatch"`
+	BadTimestamp   int    `json:"bad_timestamp"`
+	Late           int    `json:"late"`
+}
+
+func (fs *FeedStats) count(status lineStatus, n int) {
+	switch status {
+	case cLineEmpty:
+		return
+	case cLineFiltered:
+		fs.Filtered += n
+	case cLineFormatMismatch:
+		fs.FormatMismatch += n
+	case cLineBadTimestamp:
+		fs.BadTimestamp += n
+		fs.Grouped += n
+	case cLineLate:
+		fs.Late += n
+		fs.Grouped += n
+	default:
+		fs.Grouped += n
+	}
+	fs.Read += n
+}
+
+func (fs *FeedStats) add(other *FeedStats) {
+	fs.Read += other.Read
+	fs.Grouped += other.Grouped
+	fs.Filtered += other.Filtered
+	fs.FormatMismatch += other.FormatMismatch
+	fs.BadTimestamp += other.BadTimestamp
+	fs.Late += other.Late
+}
+
+/*
+feedStats keeps FeedStats by file for each block.
+The blocks rotate with the logGroups blocks, and the rows have the retentionPos of their block.
+*/
+type feedStats struct {
+	csvdb.Store
+	curr map[string]*FeedStats // of the current block
+	feed map[string]*FeedStats // of the current feed
+}
+
+func newFeedStats(dataDir, storage string,
+	maxBlocks int, unitSecs, keepPeriod int64, useGzip bool) (*feedStats, error) {
+	fs := &feedStats{
+		curr: make(map[string]*FeedStats),
+		feed: make(map[string]*FeedStats),
+	}
+	db, err := csvdb.NewStore(storage, dataDir, cFeedStatsStoreName,
+		tableDefs[cFeedStatsStoreName], maxBlocks, 0, keepPeriod, unitSecs, useGzip)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SetColumnTypes(columnTypes[cFeedStatsStoreName]); err != nil {
+		return nil, err
+	}
+	fs.Store = db
+	return fs, nil
+}
+
+// dataDir has feed statistics
+func hasFeedStats(dataDir string) bool {
+	return dataDir != "" && utils.PathExist(fmt.Sprintf("%s/%s", dataDir, cFeedStatsStoreName))
+}
+
+func statsOf(m map[string]*FeedStats, file string) *FeedStats {
+	s, ok := m[file]
+	if !ok {
+		s = &FeedStats{File: file}
+		m[file] = s
+	}
+	return s
+}
+
+func (fs *feedStats) count(file string, status lineStatus) {
+	statsOf(fs.curr, file).count(status, 1)
+	statsOf(fs.feed, file).count(status, 1)
+}
+
+func (fs *feedStats) flush(retentionPos int64) error {
+	for file, s := range fs.curr {
+		if s.Read <= 0 {
+			continue
+		}
+		if err := fs.InsertRow(tableDefs[cFeedStatsStoreName], retentionPos, file,
+			s.Read, s.Grouped, s.Filtered, s.FormatMismatch, s.BadTimestamp, s.Late); err != nil {
+			return err
+		}
+	}
+	if err := fs.FlushOverwriteCurrentTable(); err != nil {
+		return err
+	}
+	fs.curr = make(map[string]*FeedStats)
+	return nil
+}
+
+func (fs *feedStats) next(retentionPos, updated int64) error {
+	if err := fs.flush(retentionPos); err != nil {
+		return err
+	}
+	return fs.NextBlock(updated)
+}
+
+func (fs *feedStats) commit(retentionPos int64, completed bool) error {
+	if err := fs.flush(retentionPos); err != nil {
+		return err
+	}
+	return fs.UpdateBlockStatus(completed)
+}
+
+// load the current block to go on counting it
+func (fs *feedStats) load() error {
+	if fs.CountFromStatusTable(nil) <= 0 {
+		return nil
+	}
+	if err := fs.LoadCircuitDBStatus(); err != nil {
+		return err
+	}
+	rows, err := fs.SelectFromCurrentTable(nil, tableDefs[cFeedStatsStoreName])
+	if err != nil {
+		return err
+	}
+	return scanFeedStatsRows(rows, func(retentionPos int64, s *FeedStats) {
+		statsOf(fs.curr, s.File).add(s)
+	})
+}
+
+// call f for each feedStats row
+func scanFeedStatsRows(rows csvdb.RowScanner, f func(retentionPos int64, s *FeedStats)) error {
+	if rows == nil {
+		return nil
+	}
+	for rows.Next() {
+		var retentionPos int64
+		s := new(FeedStats)
+		if err := rows.Scan(&retentionPos, &s.File, &s.Read, &s.Grouped, &s.Filtered,
+			&s.FormatMismatch, &s.BadTimestamp, &s.Late); err != nil {
+			return err
+		}
+		f(retentionPos, s)
+	}
+	return nil
+}
+
+func (fs *feedStats) resetFeed() {
+	fs.feed = make(map[string]*FeedStats)
+}
+
+// stats of the current feed in the order of the files
+func (fs *feedStats) feedStats() []FeedStats {
+	stats := make([]FeedStats, 0, len(fs.feed))
+	for _, s := range fs.feed {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].File < stats[j].File })
+	return stats
+}
+
+// rejectWriter writes the first cMaxRejectedLines lines rejected in a feed to rejectFile
+type rejectWriter struct {
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	written int
+}
+
+func newRejectWriter(path string) *rejectWriter {
+	if path == "" {
+		return nil
+	}
+	return &rejectWriter{path: path}
+}
+
+// write "<reason>\t<file>:<row>\t<line>". the file is created with the first line
+func (rw *rejectWriter) write(status lineStatus, file string, row int, line string) error {
+	if rw == nil || rw.written >= cMaxRejectedLines {
+		return nil
+	}
+	reason, ok := lineStatusNames[status]
+	if !ok || status == cLineFiltered || status == cLineLate {
+		return nil
+	}
+	if rw.file == nil {
+		f, err := os.Create(rw.path)
+		if err != nil {
+			return err
+		}
+		rw.file, rw.w = f, bufio.NewWriter(f)
+	}
+	if _, err := fmt.Fprintf(rw.w, "%s\t%s:%d\t%s\n", reason, file, row, line); err != nil {
+		return err
+	}
+	rw.written++
+	return nil
+}
+
+func (rw *rejectWriter) close() error {
+	if rw == nil || rw.file == nil {
+		return nil
+	}
+	err := rw.w.Flush()
+	if cerr := rw.file.Close(); err == nil {
+		err = cerr
+	}
+	rw.file, rw.w = nil, nil
+	return err
+}
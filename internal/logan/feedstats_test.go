@@ -0,0 +1,194 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Analyzer_feedStats(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_feedStats")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/feedstats.log"
+	conf.RejectFile = filepath.Dir(conf.DataDir) + "/rejected.txt"
+	conf.ExludeRegex = []string{"debug"}
+	data := "2024-10-01T00:00:00] disk full on sda\n" +
+		"2024-10-01T00:00:01] debug noise\n" +
+		"garbage without timestamp\n" +
+		"2024-13-45T00:00:00] disk full on sdb\n" +
+		"2024-10-02T00:00:00] disk full on sda\n" +
+		"2024-09-20T00:00:00] disk full on sda\n"
+	if err := os.WriteFile(conf.LogPath, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	os.Remove(conf.RejectFile)
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	exp := FeedStats{File: conf.LogPath, Read: 6, Grouped: 4, Filtered: 1,
+		FormatMismatch: 1, BadTimestamp: 1, Late: 1}
+	stats := a.FeedStats()
+	if err := utils.GetGotExpErr("files", len(stats), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("feed stats", stats[0], exp); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	rejected, err := os.ReadFile(conf.RejectFile)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	expRejected := "formatMismatch\t" + conf.LogPath + ":3\tgarbage without timestamp\n" +
+		"badTimestamp\t" + conf.LogPath + ":4\t2024-13-45T00:00:00] disk full on sdb\n"
+	if err := utils.GetGotExpErr("rejected", string(rejected), expRejected); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	// the statistics are kept by block
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	rows, err := b.trans.stats.SelectRows(nil, nil, tableDefs[cFeedStatsStoreName])
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total := FeedStats{File: conf.LogPath}
+	blocks := make([]string, 0)
+	if err := scanFeedStatsRows(rows, func(retentionPos int64, s *FeedStats) {
+		total.add(s)
+		blocks = append(blocks, utils.EpochToString(retentionPos))
+	}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("stored stats", total, exp); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("blocks", len(blocks), 2); err != nil {
+		t.Errorf("%v %s", err, strings.Join(blocks, ","))
+		return
+	}
+
+	problems, err := Fsck(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+}
+
+func Test_Analyzer_FeedReader(t *testing.T) {
+	var sb strings.Builder
+	start := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	// more lines than a batch, over two days
+	for i := 0; i < cFeedBatchLines*2+cFeedBatchLines/2; i++ {
+		ts := start.Add(time.Duration(i*6) * time.Second).Format("2006-01-02T15:04:05")
+		switch i % 3 {
+		case 0:
+			fmt.Fprintf(&sb, "%s] user u%d logged in\n", ts, i%50)
+		case 1:
+			fmt.Fprintf(&sb, "%s] disk full on sd%c\n", ts, 'a'+i%4)
+		default:
+			fmt.Fprintf(&sb, "%s] link down on eth%d\n", ts, i%8)
+		}
+	}
+	feed := func(name string, reader bool) (*Analyzer, error) {
+		conf, err := _newSampleConfig(name)
+		if err != nil {
+			return nil, err
+		}
+		conf.LogPath = filepath.Dir(conf.DataDir) + "/feedreader.log"
+		if err := os.WriteFile(conf.LogPath, []byte(sb.String()), 0644); err != nil {
+			return nil, err
+		}
+		a, err := NewAnalyzer(conf, 0, false, false)
+		if err != nil {
+			return nil, err
+		}
+		if reader {
+			err = a.FeedReader(strings.NewReader(sb.String()))
+		} else {
+			err = a.Feed(0)
+		}
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		return a, nil
+	}
+
+	exp, err := feed("Test_Analyzer_FeedReader_feed", false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer exp.Close()
+	a, err := feed("Test_Analyzer_FeedReader", true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	// the same groups with the same histories
+	expGroups, err := _analyzerGroups(exp)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	gotGroups, err := _analyzerGroups(a)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", gotGroups, expGroups); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("term counts", fmt.Sprint(_termCounts(a)), fmt.Sprint(_termCounts(exp))); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	stats := a.FeedStats()
+	if err := utils.GetGotExpErr("files", len(stats), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("feed stats", stats[0],
+		FeedStats{File: cReaderFileName, Read: cFeedBatchLines*2 + cFeedBatchLines/2, Grouped: cFeedBatchLines*2 + cFeedBatchLines/2}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// the lines of the reader do not move the position in logPath
+	if err := utils.GetGotExpErr("last file row", a.LastFileRow, 0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
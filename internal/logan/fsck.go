@@ -0,0 +1,199 @@
+package logan
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var circuitDBNames = []string{"terms", "logGroups", "patternkeys", "patternTags", cSourcesStoreName,
+	cFeedStatsStoreName}
+
+// circuitDBNames and rollup stores of logGroups in dataDir
+func getStoreNames(dataDir string) ([]string, error) {
+	rollupNames, err := getRollupStoreNames(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, circuitDBNames...), rollupNames...), nil
+}
+
+// read "<groupId> <text>" lines from a gzip file written by writeDisplayStrings()/writeLastMessages()
+// lines read before an error are returned with the error
+func readGzipLines(path string) ([]string, error) {
+	lines := make([]string, 0)
+	file, err := os.Open(path)
+	if err != nil {
+		return lines, err
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return lines, err
+	}
+	defer gzReader.Close()
+
+	reader := bufio.NewReader(gzReader)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			if line != "" {
+				// the last line has no new line. must be truncated
+				return lines, io.ErrUnexpectedEOF
+			}
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, strings.TrimRight(line, "\n"))
+	}
+}
+
+func writeGzipLines(path string, lines []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gzWriter := gzip.NewWriter(file)
+	defer gzWriter.Close()
+	writer := bufio.NewWriter(gzWriter)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+func checkGzipLines(path string) (map[int64]bool, []*csvdb.Problem) {
+	problems := make([]*csvdb.Problem, 0)
+	groupIds := make(map[int64]bool)
+	if !utils.PathExist(path) {
+		return groupIds, problems
+	}
+	lines, err := readGzipLines(path)
+	if err != nil {
+		problems = append(problems, csvdb.NewProblem(csvdb.CSeverityError, path,
+			fmt.Sprintf("truncated after %d lines: %v", len(lines), err),
+			func() error {
+				return writeGzipLines(path, lines)
+			}))
+	}
+	for _, line := range lines {
+		parts := strings.SplitN(line, " ", 2)
+		groupId, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		groupIds[groupId] = true
+	}
+	return groupIds, problems
+}
+
+func checkJsonFile(path string, v interface{}) []*csvdb.Problem {
+	problems := make([]*csvdb.Problem, 0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		problems = append(problems, csvdb.NewProblem(csvdb.CSeverityError, path,
+			fmt.Sprintf("unreadable: %v", err), nil))
+		return problems
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		problems = append(problems, csvdb.NewProblem(csvdb.CSeverityError, path,
+			fmt.Sprintf("broken JSON: %v", err), nil))
+	}
+	return problems
+}
+
+/*
+Fsck checks the integrity of dataDir and returns the problems found.
+
+  - interrupted commits
+  - config.json and status.json
+  - block files of all stores (column counts, truncated gzip or binary files, blocks in the block status)
+  - displayStrings for all groupIds in logGroups blocks and source counts, spilled ones included
+*/
+func Fsck(dataDir string) ([]*csvdb.Problem, error) {
+	problems := make([]*csvdb.Problem, 0)
+	if !utils.PathExist(dataDir) {
+		return nil, fmt.Errorf("%s does not exist", dataDir)
+	}
+
+	if csvdb.HasPendingTxn(dataDir) {
+		problems = append(problems, csvdb.NewProblem(csvdb.CSeverityError, dataDir,
+			"has an interrupted commit", func() error {
+				_, err := csvdb.RecoverTxn(dataDir)
+				return err
+			}))
+	}
+
+	a := &Analyzer{AnalConfig: &AnalConfig{DataDir: dataDir}}
+	conf := new(AnalConfig)
+	problems = append(problems, checkJsonFile(a._getConfigPath(), conf)...)
+	problems = append(problems, checkJsonFile(a._getLastStatusPath(), new(analStatus))...)
+
+	storeNames, err := getStoreNames(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range storeNames {
+		cproblems, err := csvdb.CheckStore(conf.Storage, dataDir, name)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, cproblems...)
+	}
+
+	lgs := &logGroups{DataDir: fmt.Sprintf("%s/logGroups", dataDir)}
+	if !utils.PathExist(lgs.DataDir) {
+		return problems, nil
+	}
+	_, lproblems := checkGzipLines(lgs._getLastMessagePath())
+	problems = append(problems, lproblems...)
+
+	dsPath := lgs._getDisplayStringPath()
+	if !utils.PathExist(dsPath) {
+		problems = append(problems, csvdb.NewProblem(csvdb.CSeverityError, dsPath,
+			"displayStrings do not exist", nil))
+		return problems, nil
+	}
+	groupIds, dproblems := checkGzipLines(dsPath)
+	problems = append(problems, dproblems...)
+	// the groups spilled out of memory keep theirs in another file
+	spilledIds, sproblems := checkGzipLines(lgs._getSpilledPath())
+	problems = append(problems, sproblems...)
+	for groupId := range spilledIds {
+		groupIds[groupId] = true
+	}
+
+	for _, name := range storeNames {
+		if name != "logGroups" && !strings.HasPrefix(name, "logGroups_") && name != cSourcesStoreName {
+			continue
+		}
+		gproblems, err := csvdb.CheckStoreColumn(conf.Storage, dataDir, name,
+			getStoreColumns(name), "groupId",
+			func(v string) bool {
+				groupId, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return false
+				}
+				return groupIds[groupId]
+			}, "groupIds without displayString")
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, gproblems...)
+	}
+
+	return problems, nil
+}
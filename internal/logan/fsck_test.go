@@ -0,0 +1,66 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"testing"
+)
+
+func Test_Fsck(t *testing.T) {
+	a, err := _newSampleAnalyzer("Test_Fsck")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	problems, err := Fsck(a.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+		return
+	}
+
+	// drop all displayStrings but the first one
+	lgs := a.trans.lgs
+	lines, err := readGzipLines(lgs._getDisplayStringPath())
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := writeGzipLines(lgs._getDisplayStringPath(), lines[:1]); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	problems, err = Fsck(a.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(problems) == 0 {
+		t.Errorf("missing displayStrings must be detected")
+		return
+	}
+	for _, p := range problems {
+		if err := p.Repair(); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	problems, err = Fsck(a.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems after repair", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+		return
+	}
+}
@@ -0,0 +1,287 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// two log files of 3 lines with different mtimes
+func _writeInterruptLogs(conf *AnalConfig) error {
+	dir := filepath.Dir(conf.DataDir) + "/logs"
+	if err := utils.EnsureDir(dir); err != nil {
+		return err
+	}
+	conf.LogPath = dir + "/*.log"
+	mtime := time.Now().Add(-time.Hour)
+	for i, name := range []string{"a.log", "b.log"} {
+		path := dir + "/" + name
+		data := "2024-10-01T00:00:00] disk full on sda\n" +
+			"2024-10-01T00:00:01] disk full on sdb\n" +
+			"2024-10-01T00:00:02] link down on eth0\n"
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			return err
+		}
+		t := mtime.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, t, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func _totalCount(a *Analyzer) int {
+	total := 0
+	for _, lg := range a.trans.lgs.alllg {
+		total += lg.count
+	}
+	return total
+}
+
+func Test_Analyzer_resumeAtFileEnd(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_resumeAtFileEnd")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := _writeInterruptLogs(conf); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// stop at the last line of the first file
+	if err := a.Feed(3); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	a, err = LoadAnalyzer(dataDir, "", 0, 0, 0, nil, false, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	if err := a._checkLastStatus(3, 3, filepath.Dir(conf.DataDir)+"/logs/a.log"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("total count", _totalCount(a), 6); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a._checkLastStatus(3, 3, filepath.Dir(conf.DataDir)+"/logs/b.log"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
+
+func Test_Analyzer_Stop(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_Stop")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := _writeInterruptLogs(conf); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.Stop()
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if !a.Interrupted() {
+		t.Errorf("feed is not interrupted")
+		return
+	}
+	if err := utils.GetGotExpErr("total count after stop", _totalCount(a), 0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	// the next feed reads all the lines
+	a, err = LoadAnalyzer(dataDir, "", 0, 0, 0, nil, false, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if a.Interrupted() {
+		t.Errorf("feed is interrupted")
+		return
+	}
+	if err := utils.GetGotExpErr("total count", _totalCount(a), 6); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
+
+func _groupCounts(a *Analyzer) map[string]int {
+	counts := make(map[string]int)
+	for _, lg := range a.trans.lgs.alllg {
+		counts[lg.displayString] += lg.count
+	}
+	return counts
+}
+
+func _termCounts(a *Analyzer) map[string]int {
+	counts := make(map[string]int)
+	for termId, term := range a.trans.te.id2term {
+		counts[term] = a.trans.te.counts[termId]
+	}
+	return counts
+}
+
+func Test_Analyzer_stopInGroupsPass(t *testing.T) {
+	var a *Analyzer
+	feed := func(name string, stop bool) (map[string]int, map[string]int, error) {
+		conf, err := _newSampleConfig(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the lines of the same second make the resumed feed create log groups
+		// with the created epoch of the loaded ones
+		dir := filepath.Dir(conf.DataDir) + "/logs"
+		if err := utils.EnsureDir(dir); err != nil {
+			return nil, nil, err
+		}
+		conf.LogPath = dir + "/*.log"
+		data := "2024-10-01T00:00:00] disk full on sda\n" +
+			"2024-10-01T00:00:00] link down on eth0\n" +
+			"2024-10-01T00:00:00] disk full on sdb\n" +
+			"2024-10-01T00:00:00] user root logged in\n" +
+			"2024-10-01T00:00:00] link down on eth1\n"
+		if err := os.WriteFile(dir+"/a.log", []byte(data), 0644); err != nil {
+			return nil, nil, err
+		}
+		a, err = NewAnalyzer(conf, 0, false, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if stop {
+			// stop after the first line of the groups pass
+			a.OnNewLogGroup(func(LogGroup) { a.Stop() })
+			if err := a.Feed(0); err != nil {
+				return nil, nil, err
+			}
+			if !a.Interrupted() {
+				return nil, nil, fmt.Errorf("feed is not interrupted")
+			}
+			if err := utils.GetGotExpErr("total count after stop", _totalCount(a), 1); err != nil {
+				return nil, nil, err
+			}
+			dataDir := a.DataDir
+			a.Close()
+			a, err = LoadAnalyzer(dataDir, "", 0, 0, 0, nil, false, false, false, false)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		defer a.Close()
+		if err := a.Feed(0); err != nil {
+			return nil, nil, err
+		}
+		return _groupCounts(a), _termCounts(a), nil
+	}
+
+	expGroups, expTerms, err := feed("Test_Analyzer_stopInGroupsPass_all", false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	gotGroups, gotTerms, err := feed("Test_Analyzer_stopInGroupsPass", true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total := 0
+	for _, count := range gotGroups {
+		total += count
+	}
+	if err := utils.GetGotExpErr("total count", total, 5); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("group counts", fmt.Sprint(gotGroups), fmt.Sprint(expGroups)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("term counts", fmt.Sprint(gotTerms), fmt.Sprint(expTerms)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
+
+func Test_Analyzer_rotationsInCommit(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_rotationsInCommit")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// 50 lines in blocks of 10
+	conf.LogPath = "../../testdata/loganal/sample50_1.log"
+	conf.BlockSize = 10
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	gen, err := csvdb.TxnGeneration(a.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the rotations are in the commit of the feed, not one commit each
+	got, err := csvdb.TxnGeneration(a.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("generation", got, gen+1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	staged, err := filepath.Glob(a.DataDir + "/*/*.txn")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("staged files", len(staged), 0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("count", _totalCount(a), 50); err != nil {
+		t.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/filepointer"
+	"regexp"
+	"time"
+)
+
+// sample lines of logPath LintConfig parses by default
+const CDefaultLintSampleLines = 100
+
+/*
+ConfigProblem is a problem of a config value.
+Key is the path of the value in the config file like patternKeyRegexes[0].
+Severity is csvdb.CSeverityError for values logan can't work with
+and csvdb.CSeverityWarning for values that are silently ignored or give odd results.
+*/
+type ConfigProblem struct {
+	Severity string
+	Key      string
+	Message  string
+}
+
+func (p ConfigProblem) String() string {
+	return fmt.Sprintf("%-5s %s: %s", p.Severity, p.Key, p.Message)
+}
+
+func (p ConfigProblem) IsError() bool {
+	return p.Severity == csvdb.CSeverityError
+}
+
+type configLinter struct {
+	problems []ConfigProblem
+}
+
+func (l *configLinter) errorf(key, format string, args ...interface{}) {
+	l.problems = append(l.problems, ConfigProblem{csvdb.CSeverityError, key, fmt.Sprintf(format, args...)})
+}
+
+func (l *configLinter) warnf(key, format string, args ...interface{}) {
+	l.problems = append(l.problems, ConfigProblem{csvdb.CSeverityWarning, key, fmt.Sprintf(format, args...)})
+}
+
+// compiles re. nil on errors
+func (l *configLinter) compile(key, re string) *regexp.Regexp {
+	compiled, err := regexp.Compile(re)
+	if err != nil {
+		l.errorf(key, "%v", err)
+		return nil
+	}
+	return compiled
+}
+
+func (l *configLinter) compileAll(key string, res []string) {
+	for i, re := range res {
+		l.compile(fmt.Sprintf("%s[%d]", key, i), re)
+	}
+}
+
+func hasSubexp(re *regexp.Regexp, name string) bool {
+	return re.SubexpIndex(name) >= 0
+}
+
+/*
+LintConfig checks conf without touching dataDir and returns the problems found.
+
+  - regexes must compile. patternKeyRegexes must have a (?P<patternKey>) group
+  - logFormat needs a (?P<timestamp>) group to use timestampLayout and the other way round
+  - retention, sourceFrom and the rates must be valid and must not conflict with other values
+  - with sampleLines > 0, the first sampleLines lines of logPath must match logFormat
+    and their timestamps must parse with timestampLayout
+*/
+func LintConfig(conf *AnalConfig, sampleLines int) []ConfigProblem {
+	l := new(configLinter)
+
+	var logFormatRe *regexp.Regexp
+	if conf.LogFormat != "" {
+		logFormatRe = l.compile("logFormat", conf.LogFormat)
+	}
+	if logFormatRe != nil {
+		if !hasSubexp(logFormatRe, "timestamp") {
+			l.warnf("logFormat", "no (?P<timestamp>...) group. lines get the time of the file instead of their own")
+		} else if conf.TimestampLayout == "" {
+			l.warnf("timestampLayout", "empty. the (?P<timestamp>...) group of logFormat is not parsed")
+		}
+		if !hasSubexp(logFormatRe, "message") {
+			l.warnf("logFormat", "no (?P<message>...) group. the whole line including the timestamp is grouped")
+		}
+	}
+	if conf.TimestampLayout != "" && conf.LogFormat == "" {
+		l.warnf("timestampLayout", "ignored without a logFormat with a (?P<timestamp>...) group")
+	}
+
+	for i, msgFormat := range conf.MsgFormats {
+		key := fmt.Sprintf("msgFormats[%d]", i)
+		if re := l.compile(key, msgFormat); re != nil && !hasSubexp(re, "message") {
+			l.warnf(key, "no (?P<message>...) group. the whole match is grouped")
+		}
+	}
+	for i, patternKeyRegex := range conf.PatternKeyRegexes {
+		key := fmt.Sprintf("patternKeyRegexes[%d]", i)
+		if re := l.compile(key, patternKeyRegex); re != nil && !hasSubexp(re, cPatternKey) {
+			l.errorf(key, "no (?P<%s>...) group. no pattern key is picked up", cPatternKey)
+		}
+	}
+	l.compileAll("keyRegexes", conf.KeyRegexes)
+	l.compileAll("ignoreRegexes", conf.IgnoreRegexes)
+	l.compileAll("searchRegex", conf.SearchRegex)
+	l.compileAll("excludeRegex", conf.ExludeRegex)
+
+	if len(conf.Retention) > 0 {
+		if err := checkRetention(conf.Retention); err != nil {
+			l.errorf("retention", "%v", err)
+		}
+		if conf.UnitSecs > 0 {
+			l.warnf("unitSecs", "ignored. retention[0].unitSecs is used")
+		}
+		if conf.KeepPeriod > 0 {
+			l.warnf("keepPeriod", "ignored. retention[0].keep is used")
+		}
+	}
+	if conf.UnitSecs < 0 {
+		l.errorf("unitSecs", "must not be negative")
+	}
+	if conf.KeepPeriod < 0 {
+		l.errorf("keepPeriod", "must not be negative")
+	}
+	if conf.ReorderWindow < 0 {
+		l.errorf("reorderWindow", "must not be negative")
+	}
+	if conf.MemoryBudget < 0 {
+		l.errorf("memoryBudget", "must not be negative")
+	}
+	if conf.MinMatchRate < 0 || conf.MinMatchRate > 1 {
+		l.errorf("minMatchRate", "%g is not between 0 and 1", conf.MinMatchRate)
+	}
+	if conf.TermCountBorderRate < 0 || conf.TermCountBorderRate > 1 {
+		l.errorf("termCountBorderRate", "%g is not between 0 and 1", conf.TermCountBorderRate)
+	}
+	if conf.TermCountBorder > 0 && conf.TermCountBorderRate > 0 {
+		l.warnf("termCountBorderRate", "ignored. termCountBorder is set")
+	}
+	if conf.Timezone != "" {
+		if _, err := time.LoadLocation(conf.Timezone); err != nil {
+			l.errorf("timezone", "%v", err)
+		}
+		if conf.UseUtcTime {
+			l.warnf("useUtcTime", "ignored. timezone is set")
+		}
+	}
+	if _, err := newMaskers(conf.Maskers); err != nil {
+		l.errorf("maskers", "%v", err)
+	}
+	if conf.SourceFrom != "" {
+		if _, err := newSourceExtractor(conf.SourceFrom); err != nil {
+			l.errorf("sourceFrom", "%v", err)
+		}
+	}
+	switch getStorage(conf.Storage) {
+	case csvdb.CStorageCsv, csvdb.CStorageBin:
+	default:
+		l.errorf("storage", "unknown storage %s. %s or %s", conf.Storage, csvdb.CStorageCsv, csvdb.CStorageBin)
+	}
+
+	if sampleLines > 0 && conf.LogPath != "" && logFormatRe != nil {
+		l.sample(conf, sampleLines)
+	}
+	return l.problems
+}
+
+// parse the first lines of logPath like feed does
+func (l *configLinter) sample(conf *AnalConfig, sampleLines int) {
+	tr := &trans{timestampLayout: conf.TimestampLayout, useUtcTime: conf.UseUtcTime}
+	if conf.Timezone != "" {
+		// an error of its own
+		tr.location, _ = time.LoadLocation(conf.Timezone)
+	}
+	if err := tr._parseLogFormat(conf.LogFormat); err != nil {
+		return
+	}
+	// the logs may not be there yet
+	fp, err := filepointer.NewFilePointer(conf.LogPath, 0, 0)
+	if err != nil {
+		l.warnf("logPath", "%v", err)
+		return
+	}
+	if err := fp.Open(); err != nil {
+		l.warnf("logPath", "%v", err)
+		return
+	}
+	defer fp.Close()
+
+	lines, unmatched, badTimestamps := 0, 0, 0
+	firstUnmatched, firstBadTimestamp := "", ""
+	for lines < sampleLines && fp.Next() {
+		line := fp.Text()
+		if line == "" {
+			continue
+		}
+		lines++
+		ma := tr.logFormatRe.FindStringSubmatch(line)
+		if ma == nil {
+			if unmatched == 0 {
+				firstUnmatched = fmt.Sprintf("%s line %d: %s", fp.CurrFileName(), fp.Row(), line)
+			}
+			unmatched++
+			continue
+		}
+		if tr.timestampPos < 0 || tr.timestampLayout == "" {
+			continue
+		}
+		tr.setLogFile(fp.CurrFileName(), fp.CurrFileMtime())
+		if _, err := tr.parseTimestamp(ma[tr.timestampPos]); err != nil {
+			if badTimestamps == 0 {
+				firstBadTimestamp = fmt.Sprintf("%s line %d: %v", fp.CurrFileName(), fp.Row(), err)
+			}
+			badTimestamps++
+		}
+	}
+	if err := fp.Err(); err != nil && !fp.IsEOF {
+		l.errorf("logPath", "%v", err)
+		return
+	}
+	if lines == 0 {
+		l.warnf("logPath", "no lines to sample in %s", conf.LogPath)
+		return
+	}
+	if unmatched > 0 {
+		severity := csvdb.CSeverityWarning
+		if unmatched == lines {
+			severity = csvdb.CSeverityError
+		}
+		l.problems = append(l.problems, ConfigProblem{severity, "logFormat",
+			fmt.Sprintf("%d of %d sample lines do not match. first at %s", unmatched, lines, firstUnmatched)})
+	}
+	if badTimestamps > 0 {
+		severity := csvdb.CSeverityWarning
+		if badTimestamps == lines-unmatched {
+			severity = csvdb.CSeverityError
+		}
+		l.problems = append(l.problems, ConfigProblem{severity, "timestampLayout",
+			fmt.Sprintf("%d of %d sample timestamps do not parse. first at %s", badTimestamps, lines-unmatched, firstBadTimestamp)})
+	}
+}
+
+// the first error of LintConfig
+func checkConfig(conf *AnalConfig) error {
+	for _, p := range LintConfig(conf, 0) {
+		if p.IsError() {
+			return fmt.Errorf("invalid config. %s: %s", p.Key, p.Message)
+		}
+	}
+	return nil
+}
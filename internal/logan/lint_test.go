@@ -0,0 +1,54 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"strings"
+	"testing"
+)
+
+func Test_LintConfig(t *testing.T) {
+	conf, err := _newSampleConfig("Test_LintConfig")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if problems := LintConfig(conf, CDefaultLintSampleLines); len(problems) > 0 {
+		t.Errorf("sample config must have no problems: %v", problems)
+		return
+	}
+
+	conf.TimestampLayout = "2006/01/02 15:04:05"
+	conf.PatternKeyRegexes = []string{`id=(\d+`, `id=(\d+)`}
+	conf.MinMatchRate = 1.5
+	conf.Retention = []RetentionTier{{UnitSecs: 3600, Keep: 24}}
+	problems := LintConfig(conf, CDefaultLintSampleLines)
+	got := make([]string, len(problems))
+	for i, p := range problems {
+		got[i] = p.Severity + " " + p.Key
+	}
+	exp := []string{
+		"ERROR patternKeyRegexes[0]",
+		"ERROR patternKeyRegexes[1]",
+		"WARN unitSecs",
+		"WARN keepPeriod",
+		"ERROR minMatchRate",
+		"ERROR timestampLayout",
+	}
+	if err := utils.GetGotExpErr("problems", strings.Join(got, ","), strings.Join(exp, ",")); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("sample line", strings.Contains(problems[5].Message, "34 of 34"), true); err != nil {
+		t.Errorf("%v %s", err, problems[5])
+		return
+	}
+
+	// no panics from bad regexes
+	if _, err := NewAnalyzer(conf, 0, false, false); err == nil {
+		t.Errorf("NewAnalyzer must fail with bad patternKeyRegexes")
+		return
+	}
+	if _, err := newPatternKeys("", "", []string{`(?P<patternKey>`}, false, true); err == nil {
+		t.Errorf("newPatternKeys must fail with a bad regex")
+	}
+}
@@ -6,7 +6,6 @@ type logGroup struct {
 	retentionPos  int64
 	created       int64 // first epoch in the current block
 	updated       int64 // last epoch in the current block
-	countHistory  map[int64]int
 	rareScore     float64
 }
 
@@ -13,12 +13,19 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// a row of the current logGroups block. late lines make rows of earlier retentionPos
+type blockKey struct {
+	groupId      int64
+	retentionPos int64
+}
+
 type logGroups struct {
-	*csvdb.CircuitDB
+	csvdb.Store
+	DataDir           string
 	maxLgId           int64
 	totalCount        int // total count of entire log groups
 	alllg             map[int64]*logGroup
-	curlg             map[int64]*logGroup
+	curlg             map[blockKey]*logGroup
 	lt                *logTree
 	retentionPos      int64
 	maxRetentionPos   int64
@@ -27,11 +34,18 @@ type logGroups struct {
 	lastMessages      map[int64]string
 	orgDisplayStrings map[int64]string
 	testMode          bool
+	tiers             []*rollupTier
+	historySpans      []historySpan
+	spilled           int                     // log groups spilled out of memory
+	spilledGroups     map[int64]*spilledGroup // counts of the log groups spilled out of memory
+	spilledIds        map[uint64]int64        // groupIds of spilledGroups by the hash of the displayString
+	spilledLines      []string                // lines of the spilled log groups to append to cSpilledFileName at commit
 }
 
-func newLogGroups(dataDir string,
+func newLogGroups(dataDir, storage string,
 	maxBlocks int,
 	unitSecs, keepPeriod int64,
+	retention []RetentionTier,
 	useGzip, testMode bool) (*logGroups, error) {
 
 	lgs := new(logGroups)
@@ -40,30 +54,52 @@ func newLogGroups(dataDir string,
 	lgs.totalCount = 0
 	lgs.retentionPos = 0
 	lgs.alllg = make(map[int64]*logGroup)
-	lgs.curlg = make(map[int64]*logGroup)
+	lgs.curlg = make(map[blockKey]*logGroup)
 	lgs.displayStrings = make(map[int64]string)
 	lgs.lastMessages = make(map[int64]string)
+	lgs.spilledGroups = make(map[int64]*spilledGroup)
+	lgs.spilledIds = make(map[uint64]int64)
 	lgs.lt = newLogTree(0)
 	lgs.testMode = testMode
 	if testMode {
 		return lgs, nil
 	}
-	lgdb, err := csvdb.NewCircuitDB(dataDir, "logGroups",
+	lgdb, err := csvdb.NewStore(storage, dataDir, "logGroups",
 		tableDefs["logGroups"], maxBlocks, 0, keepPeriod, unitSecs, useGzip)
 	if err != nil {
 		return nil, err
 	}
-	lgs.CircuitDB = lgdb
+	if err := lgdb.SetColumnTypes(columnTypes["logGroups"]); err != nil {
+		return nil, err
+	}
+	lgs.Store = lgdb
+	if dataDir != "" {
+		lgs.DataDir = fmt.Sprintf("%s/logGroups", dataDir)
+		lgs.tiers, err = newRollupTiers(lgdb, dataDir, storage, retention, useGzip)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return lgs, nil
 }
 
 // generate group id in {epoch}-{lgid}-{randomNumber} format
-// lgid is the lgid of the first time this logGroup registerd
+// lgid is the lgid of the first time this logGroup registerd.
+// maxLgId starts over in each block and after loading. When a feed stopped in the groups pass
+// is resumed, the loaded logGroups of the not completed block may have been created in the same
+// second as the new ones, so the ids taken are skipped instead of overwriting them.
 func (lgs *logGroups) _genGroupId(created int64) int64 {
-	lgs.maxLgId++
-	lgid := lgs.maxLgId
-	lgid = lgid % 1e5
-	return created*1e5 + lgid
+	var groupId int64
+	for i := 0; i < 1e5; i++ {
+		lgs.maxLgId++
+		lgid := lgs.maxLgId
+		lgid = lgid % 1e5
+		groupId = created*1e5 + lgid
+		if _, ok := lgs.alllg[groupId]; !ok {
+			break
+		}
+	}
+	return groupId
 }
 
 // Register logGroup info
@@ -111,6 +147,10 @@ func (lgs *logGroups) registerLogTree(tokens []int,
 	if groupId <= 0 {
 		groupId = lt.groupId
 	}
+	// the lines of a log group spilled out of memory come back to it
+	if groupId <= 0 {
+		groupId = lgs.unspill(displayString)
+	}
 
 	groupId = lgs._registerLg(lgs.alllg, groupId, retentionPos,
 		addCnt, displayString, created, updated)
@@ -120,8 +160,7 @@ func (lgs *logGroups) registerLogTree(tokens []int,
 	}
 
 	if isNew {
-		lgs._registerLg(lgs.curlg, lt.groupId, retentionPos,
-			addCnt, displayString, created, updated)
+		lgs._registerCurr(lt.groupId, retentionPos, addCnt, created, updated)
 	}
 	lgs.totalCount += addCnt
 
@@ -135,18 +174,36 @@ func (lgs *logGroups) registerLogTree(tokens []int,
 	return lt.groupId
 }
 
+// count groupId up in the current block at retentionPos
+func (lgs *logGroups) _registerCurr(groupId, retentionPos int64,
+	addCnt int, created, updated int64) {
+	key := blockKey{groupId, retentionPos}
+	lg, ok := lgs.curlg[key]
+	if !ok {
+		lg = &logGroup{retentionPos: retentionPos}
+		lgs.curlg[key] = lg
+	}
+	if lg.created == 0 || created < lg.created {
+		lg.created = created
+	}
+	if updated > lg.updated {
+		lg.updated = updated
+	}
+	lg.count += addCnt
+}
+
 func (lgs *logGroups) flush() error {
 	if lgs.DataDir == "" {
 		return nil
 	}
-	for groupId, lg := range lgs.curlg {
+	for key, lg := range lgs.curlg {
 		if lg.count <= 0 {
 			continue
 		}
 		//{"groupId", "retentionPos", "count", "created", "updated"}
 		if err := lgs.InsertRow(tableDefs["logGroups"],
 			//utils.Int64Tobase36(groupId), lg.retentionPos, lg.count, lg.created, lg.updated); err != nil {
-			groupId, lg.retentionPos, lg.count, lg.created, lg.updated); err != nil {
+			key.groupId, key.retentionPos, lg.count, lg.created, lg.updated); err != nil {
 			return err
 		}
 	}
@@ -155,7 +212,7 @@ func (lgs *logGroups) flush() error {
 		return err
 	}
 
-	lgs.curlg = make(map[int64]*logGroup)
+	lgs.curlg = make(map[blockKey]*logGroup)
 	return nil
 }
 
@@ -179,7 +236,11 @@ func (lgs *logGroups) _getLastMessagePath() string {
 }
 
 func (lgs *logGroups) writeDisplayStrings() error {
-	file, err := os.Create(lgs._getDisplayStringPath())
+	path, err := csvdb.StagedPath(lgs._getDisplayStringPath())
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("error creating file: %v", err)
 	}
@@ -206,7 +267,11 @@ func (lgs *logGroups) writeDisplayStrings() error {
 }
 
 func (lgs *logGroups) writeLastMessages() error {
-	file, err := os.Create(lgs._getLastMessagePath())
+	path, err := csvdb.StagedPath(lgs._getLastMessagePath())
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("error creating file: %v", err)
 	}
@@ -329,6 +394,10 @@ func (lgs *logGroups) commit(completed bool) error {
 		return err
 	}
 
+	if err := lgs.writeSpilled(); err != nil {
+		return err
+	}
+
 	if err := lgs.writeDisplayStrings(); err != nil {
 		return err
 	}
@@ -347,11 +416,6 @@ func (lgs *logGroups) commit(completed bool) error {
 // displayString will not be loaded
 // mainly for testing
 func (lgs *logGroups) getBlockData(blockNo int) (map[string]logGroup, error) {
-	table, err := lgs.GetBlockTable(blockNo)
-	if err != nil {
-		return nil, err
-	}
-
 	if err := lgs.readDisplayStrings(); err != nil {
 		return nil, err
 	}
@@ -359,7 +423,7 @@ func (lgs *logGroups) getBlockData(blockNo int) (map[string]logGroup, error) {
 		return nil, err
 	}
 
-	rows, err := table.SelectRows(nil, nil)
+	rows, err := lgs.SelectBlockRows(blockNo, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -8,8 +8,17 @@ import (
 	"time"
 )
 
+// retentionPos range of a resolution
+type historySpan struct {
+	Start    int64 `json:"start"`
+	End      int64 `json:"end"`
+	UnitSecs int64 `json:"unit_secs"`
+}
+
 type logGroupsHistory struct {
 	timeline       []int64
+	widths         []int64
+	unitSecs       int64
 	counts         [][]int
 	totalCounts    []int
 	groupIds       []int64
@@ -18,25 +27,46 @@ type logGroupsHistory struct {
 	timelineMap    map[int64]int
 }
 
-func newLogGroupsHistory(lgs *logGroups, start, end,
-	unitSecs int64, groupIds []int64) *logGroupsHistory {
+/*
+spans are from the oldest resolution.
+each span fills the timeline up to the start of the next one,
+so rolled up and fine grained counts line up in one timeline
+*/
+func newLogGroupsHistory(lgs *logGroups, spans []historySpan,
+	unitSecs int64, groupIds []int64, countHistory map[int64]map[int64]int) *logGroupsHistory {
 	lgsh := new(logGroupsHistory)
 	timeline := make([]int64, 0)
+	widths := make([]int64, 0)
 	groupIdsMap := make(map[int64]int)
 	timelineMap := make(map[int64]int)
 
 	if groupIds == nil {
 		groupIds = make([]int64, 0)
 	}
+	if len(spans) == 0 {
+		spans = []historySpan{{UnitSecs: unitSecs}}
+	}
 
-	i := 0
-	pos := start
-	for pos <= end {
-		timeline = append(timeline, pos)
+	posWidths := make(map[int64]int64)
+	for k, span := range spans {
+		end := span.End
+		if k+1 < len(spans) && spans[k+1].Start-span.UnitSecs > end {
+			end = spans[k+1].Start - span.UnitSecs
+		}
+		for pos := span.Start; pos <= end; pos += span.UnitSecs {
+			if _, ok := posWidths[pos]; !ok {
+				posWidths[pos] = span.UnitSecs
+				timeline = append(timeline, pos)
+			}
+		}
+	}
+	sort.Slice(timeline, func(i, j int) bool { return timeline[i] < timeline[j] })
+	for i, pos := range timeline {
 		timelineMap[pos] = i
-		i++
-		pos += unitSecs
+		widths = append(widths, posWidths[pos])
 	}
+	lgsh.widths = widths
+	lgsh.unitSecs = unitSecs
 	lgsh.timeline = timeline
 	lgsh.timelineMap = timelineMap
 
@@ -55,11 +85,11 @@ func newLogGroupsHistory(lgs *logGroups, start, end,
 	totalCounts := make([]int, len(groupIds))
 	for i, groupId := range groupIds {
 		gr_timeline := make([]int, len(timeline))
-		for epoch, cnt := range lgs.alllg[groupId].countHistory {
+		for epoch, cnt := range countHistory[groupId] {
 			gr_timeline[timelineMap[epoch]] = cnt
+			totalCounts[i] += cnt
 		}
 		counts[i] = gr_timeline
-		totalCounts[i] = lgs.alllg[groupId].count
 	}
 	lgsh.counts = counts
 	lgsh.totalCounts = totalCounts
@@ -72,6 +102,55 @@ func newLogGroupsHistory(lgs *logGroups, start, end,
 	return lgsh
 }
 
+// unitSecs of the span pos is in. spans are from the oldest resolution
+func spanWidth(spans []historySpan, pos, unitSecs int64) int64 {
+	for _, span := range spans {
+		if pos >= span.Start && pos <= span.End {
+			return span.UnitSecs
+		}
+	}
+	return unitSecs
+}
+
+// the bucket starting at pos overlaps [from, to). 0 means no limit
+func bucketInRange(pos, width, from, to int64) bool {
+	return (from == 0 || pos+width > from) && (to == 0 || pos < to)
+}
+
+// drop the buckets out of [from, to) and count the groups in the rest
+func (lgsh *logGroupsHistory) clip(from, to int64) {
+	if from == 0 && to == 0 {
+		return
+	}
+	timeline := make([]int64, 0, len(lgsh.timeline))
+	widths := make([]int64, 0, len(lgsh.widths))
+	cols := make([]int, 0, len(lgsh.timeline))
+	for j, pos := range lgsh.timeline {
+		if bucketInRange(pos, lgsh.widths[j], from, to) {
+			timeline = append(timeline, pos)
+			widths = append(widths, lgsh.widths[j])
+			cols = append(cols, j)
+		}
+	}
+	timelineMap := make(map[int64]int, len(timeline))
+	for j, pos := range timeline {
+		timelineMap[pos] = j
+	}
+	for i, counts := range lgsh.counts {
+		clipped := make([]int, len(cols))
+		total := 0
+		for j, col := range cols {
+			clipped[j] = counts[col]
+			total += counts[col]
+		}
+		lgsh.counts[i] = clipped
+		lgsh.totalCounts[i] = total
+	}
+	lgsh.timeline = timeline
+	lgsh.widths = widths
+	lgsh.timelineMap = timelineMap
+}
+
 func (lgsh *logGroupsHistory) getCount(groupId, epoch int64) int {
 	i, ok := lgsh.groupIdsMap[groupId]
 	if !ok {
@@ -93,9 +172,10 @@ func (lgsh *logGroupsHistory) detectAnomaly(groupId int64,
 	}
 	epochs = make([]int64, 0)
 
+	// rolled up counts are scaled to the finest resolution to share the baseline
 	values := make([]float64, 0)
-	for _, cnt := range lgsh.counts[i] {
-		values = append(values, float64(cnt))
+	for j, cnt := range lgsh.counts[i] {
+		values = append(values, float64(cnt)*float64(lgsh.unitSecs)/float64(lgsh.widths[j]))
 	}
 	mean, stdDev := utils.CalculateStats(values)
 	upperThreshold := mean + stdThreshold*stdDev
@@ -43,6 +43,31 @@ func (lt *logTree) search(tokens []int) int64 {
 	return ltc.groupId
 }
 
+/*
+groupId of the leaf tokens lead to, trying the term itself first and then "*" at each depth,
+as the terms of the group may be masked. -1 if the tokens are not in the tree
+*/
+func (lt *logTree) find(tokens []int) int64 {
+	if len(tokens) == 0 {
+		if lt.groupId <= 0 {
+			return -1
+		}
+		return lt.groupId
+	}
+	if child, ok := lt.children[tokens[0]]; ok {
+		if groupId := child.find(tokens[1:]); groupId > 0 {
+			return groupId
+		}
+	}
+	if tokens[0] == cAsteriskItemID {
+		return -1
+	}
+	if child, ok := lt.children[cAsteriskItemID]; ok {
+		return child.find(tokens[1:])
+	}
+	return -1
+}
+
 // rebuildHelper is a helper function that traverses the logTree and rebuilds it with replacements
 func (lt *logTree) rebuildHelper(newTree *logTree, te *terms, termCountBorder int) error {
 	if lt.depth != newTree.depth {
@@ -60,3 +85,41 @@ func (lt *logTree) rebuildHelper(newTree *logTree, te *terms, termCountBorder in
 	}
 	return nil
 }
+
+// groupId of the leaf of exactly the tokens. -1 if there is none
+func (lt *logTree) get(tokens []int) int64 {
+	ltc := lt
+	for _, termId := range tokens {
+		lttmp, ok := ltc.children[termId]
+		if !ok {
+			return -1
+		}
+		ltc = lttmp
+	}
+	if ltc.groupId <= 0 {
+		return -1
+	}
+	return ltc.groupId
+}
+
+// add the termIds in the tree to ids
+func (lt *logTree) termIds(ids map[int]bool) {
+	for termId, child := range lt.children {
+		ids[termId] = true
+		child.termIds(ids)
+	}
+}
+
+// remove the leaves of groupIds and the branches left empty.
+// returns true if lt itself is left empty
+func (lt *logTree) prune(groupIds map[int64]bool) bool {
+	for termId, child := range lt.children {
+		if child.prune(groupIds) {
+			delete(lt.children, termId)
+		}
+	}
+	if groupIds[lt.groupId] {
+		lt.groupId = 0
+	}
+	return lt.groupId <= 0 && len(lt.children) == 0
+}
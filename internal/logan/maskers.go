@@ -0,0 +1,182 @@
+package logan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maskers config value enabling all the built-in maskers
+const CMaskersAll = "all"
+
+type builtinMasker struct {
+	name        string
+	placeholder string
+	regex       string
+}
+
+// in the order they are tried at the same position, the more specific first
+var builtinMaskers = []builtinMasker{
+	{"url", "URL", `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`},
+	{"email", "EMAIL", `\b[\w.+-]+@[\w-]+(?:\.[\w-]+)+\b`},
+	{"uuid", "UUID", `\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`},
+	{"mac", "MAC", `\b[0-9a-fA-F]{2}(?:[:-][0-9a-fA-F]{2}){5}\b`},
+	{"ipv6", "IP", `\b(?:[0-9a-fA-F]{1,4}:){7}[0-9a-fA-F]{1,4}\b|\b(?:[0-9a-fA-F]{1,4}:){1,6}:(?:[0-9a-fA-F]{1,4}:){0,5}[0-9a-fA-F]{1,4}\b`},
+	{"ipv4", "IP", `\b(?:25[0-5]|2[0-4]\d|1?\d?\d)(?:\.(?:25[0-5]|2[0-4]\d|1?\d?\d)){3}\b`},
+	{"hex", "HEX", `\b0[xX][0-9a-fA-F]+\b`},
+	{"path", "PATH", `\B(?:/[\w.-]+){2,}/?`},
+	{"duration", "DURATION", `\b(?:\d+(?:\.\d+)?(?:ns|us|ms|s|m|h))+\b`},
+	{"number", "NUM", `[-+]?\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?\b`},
+}
+
+/*
+maskers replace values like IPs and UUIDs with typed placeholders like <IP> and <UUID>
+before the line is split into terms, so that the placeholders are terms of their own.
+*/
+type maskers struct {
+	re           *regexp.Regexp
+	groups       []int    // the capture group of each alternative of re
+	placeholders []string // of each alternative. empty for the placeholders in the line
+	isTyped      map[string]bool
+}
+
+/*
+newMaskers compiles the maskers config.
+Each value is the name of a built-in masker, "all", or NAME=regex for a custom one.
+Custom maskers are tried before the built-in ones. nil without values.
+*/
+func newMaskers(values []string) (*maskers, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	enabled := make(map[string]bool)
+	var custom []builtinMasker
+	for _, value := range values {
+		if name, regex, ok := strings.Cut(value, "="); ok {
+			name = strings.TrimSpace(name)
+			if name == "" || strings.ContainsAny(name, "<> ") {
+				return nil, fmt.Errorf("masker %s: the name must be a word", value)
+			}
+			if _, err := regexp.Compile(regex); err != nil {
+				return nil, fmt.Errorf("masker %s: %w", name, err)
+			}
+			custom = append(custom, builtinMasker{name, strings.ToUpper(name), regex})
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(value))
+		if name == "ip" {
+			enabled["ipv4"], enabled["ipv6"] = true, true
+			continue
+		}
+		if name == CMaskersAll {
+			for _, bm := range builtinMaskers {
+				enabled[bm.name] = true
+			}
+			continue
+		}
+		if !isBuiltinMasker(name) {
+			return nil, fmt.Errorf("unknown masker %s. %s, ip, %s or NAME=regex",
+				value, strings.Join(builtinMaskerNames(), ", "), CMaskersAll)
+		}
+		enabled[name] = true
+	}
+
+	mk := &maskers{isTyped: make(map[string]bool)}
+	list := custom
+	for _, bm := range builtinMaskers {
+		if enabled[bm.name] {
+			list = append(list, bm)
+		}
+	}
+	// placeholders already in the line, like in displayStrings, stay as they are
+	literal := make([]string, 0, len(list))
+	for _, m := range list {
+		tag := "<" + m.placeholder + ">"
+		if !mk.isTyped[tag] {
+			mk.isTyped[tag] = true
+			literal = append(literal, regexp.QuoteMeta(tag))
+		}
+	}
+	alts := []string{"(" + strings.Join(literal, "|") + ")"}
+	mk.groups = []int{1}
+	mk.placeholders = []string{""}
+	group := 2
+	for _, m := range list {
+		re, err := regexp.Compile(m.regex)
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, "("+m.regex+")")
+		mk.groups = append(mk.groups, group)
+		mk.placeholders = append(mk.placeholders, "<"+m.placeholder+">")
+		group += 1 + re.NumSubexp()
+	}
+	re, err := regexp.Compile(strings.Join(alts, "|"))
+	if err != nil {
+		return nil, err
+	}
+	mk.re = re
+	return mk, nil
+}
+
+func isBuiltinMasker(name string) bool {
+	for _, bm := range builtinMaskers {
+		if bm.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func builtinMaskerNames() []string {
+	names := make([]string, len(builtinMaskers))
+	for i, bm := range builtinMaskers {
+		names[i] = bm.name
+	}
+	return names
+}
+
+// isPlaceholder tells if word is a placeholder like <IP>
+func (mk *maskers) isPlaceholder(word string) bool {
+	return mk != nil && mk.isTyped[word]
+}
+
+// a part of a line. text is the placeholder for the masked values
+type maskedSegment struct {
+	text        string
+	placeholder bool
+	value       string // the masked value
+}
+
+// split splits line into the values replaced with their placeholders and the text between them
+func (mk *maskers) split(line string) []maskedSegment {
+	if mk == nil {
+		return []maskedSegment{{line, false, ""}}
+	}
+	segments := make([]maskedSegment, 0)
+	last := 0
+	for _, loc := range mk.re.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		placeholder := ""
+		for i, g := range mk.groups {
+			if loc[2*g] < 0 {
+				continue
+			}
+			if placeholder = mk.placeholders[i]; placeholder == "" {
+				placeholder = line[loc[2*g]:loc[2*g+1]]
+			}
+			break
+		}
+		if loc[0] > last {
+			segments = append(segments, maskedSegment{line[last:loc[0]], false, ""})
+		}
+		segments = append(segments, maskedSegment{placeholder, true, line[loc[0]:loc[1]]})
+		last = loc[1]
+	}
+	if last < len(line) {
+		segments = append(segments, maskedSegment{line[last:], false, ""})
+	}
+	return segments
+}
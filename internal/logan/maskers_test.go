@@ -0,0 +1,123 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_maskers(t *testing.T) {
+	mk, err := newMaskers([]string{"ip", "uuid", "hex", "SESS=sess-[0-9]+"})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	join := func(segments []maskedSegment) string {
+		var sb strings.Builder
+		for _, seg := range segments {
+			sb.WriteString(seg.text)
+		}
+		return sb.String()
+	}
+	cases := []struct{ line, masked string }{
+		{"connect from 10.0.0.12 port 22", "connect from <IP> port 22"},
+		{"connect from fe80::1:2 port 22", "connect from <IP> port 22"},
+		{"req 123e4567-e89b-12d3-a456-426614174000 done", "req <UUID> done"},
+		{"addr 0x7ffee3b0 sess-991", "addr <HEX> <SESS>"},
+		{"connect from <IP> port 22", "connect from <IP> port 22"},
+		{"no values here", "no values here"},
+	}
+	for _, c := range cases {
+		if err := utils.GetGotExpErr(c.line, join(mk.split(c.line)), c.masked); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	if !mk.isPlaceholder("<SESS>") || mk.isPlaceholder("<MAC>") {
+		t.Errorf("wrong placeholders %v", mk.isTyped)
+		return
+	}
+
+	if mk, err := newMaskers(nil); mk != nil || err != nil {
+		t.Errorf("maskers without values: %v %v", mk, err)
+		return
+	}
+	if _, err := newMaskers([]string{"ipv7"}); err == nil {
+		t.Errorf("no error for an unknown masker")
+		return
+	}
+	if _, err := newMaskers([]string{"BAD=("}); err == nil {
+		t.Errorf("no error for a wrong regex")
+		return
+	}
+}
+
+func Test_Analyzer_maskers(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_maskers")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/maskers.log"
+	conf.Maskers = []string{"ip", "hex", "uuid"}
+	data := "2024-10-01T00:00:00] connect from 10.0.0.1 id 0x1f\n" +
+		"2024-10-01T00:00:01] connect from 192.168.1.20 id 0xff01\n" +
+		"2024-10-01T00:00:02] connect from fe80::1 id 0x2\n" +
+		"2024-10-01T00:00:03] request 123e4567-e89b-12d3-a456-426614174000 accepted\n"
+	if err := os.WriteFile(conf.LogPath, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	displayStrings := make(map[string]bool)
+	for groupId := range a.trans.lgs.alllg {
+		displayStrings[a.trans.lgs.displayStrings[groupId]] = true
+	}
+	for _, exp := range []string{"connect from <IP> id <HEX>", "request <UUID> accepted"} {
+		if !displayStrings[exp] {
+			t.Errorf("no group %s in %v", exp, displayStrings)
+			return
+		}
+	}
+	if err := utils.GetGotExpErr("groups", len(displayStrings), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	// the groups of the loaded analyzer match the same lines
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	r, err := b.Check(conf.LogPath, 0, 1)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed", r.Passed, true); err != nil {
+		t.Errorf("%v: %+v", err, r)
+		return
+	}
+
+	re := displayStringRegex("connect from <IP> id <HEX>", b.trans.maskers)
+	values := extractValues(re, "connect from 10.0.0.1 id 0x1f")
+	if err := utils.GetGotExpErr("values", strings.Join(values, ","), "10.0.0.1,0x1f"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
@@ -0,0 +1,315 @@
+package logan
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// rough bytes a term and a log group take in memory, with the maps and the logTree
+	cTermBytes     = 160
+	cLogGroupBytes = 1024
+
+	// shares of memoryBudget
+	cTermsBudgetRate     = 0.3
+	cLogGroupsBudgetRate = 0.6
+	cSketchBudgetRate    = 0.05
+
+	// terms and log groups are evicted down to this rate of the limit at once
+	cEvictRate = 0.8
+
+	// display string of the log group counting the lines of new patterns
+	// once no more log groups fit in memory
+	cOverflowDisplayString = "*"
+
+	cSpilledFileName = "spilled.txt.gz"
+)
+
+/*
+setMemoryBudget limits the terms and log groups kept in memory to about mb MB.
+Without it, the terms are not limited and the log groups are up to cMaxLogGroups.
+*/
+func (tr *trans) setMemoryBudget(mb int) {
+	tr.maxLogGroups = cMaxLogGroups
+	if mb <= 0 {
+		tr.te.setMaxTerms(0, 0)
+		return
+	}
+	budget := float64(mb) * 1024 * 1024
+	tr.te.setMaxTerms(int(budget*cTermsBudgetRate/cTermBytes),
+		int(budget*cSketchBudgetRate/(cSketchDepth*4)))
+	tr.maxLogGroups = int(budget * cLogGroupsBudgetRate / cLogGroupBytes)
+}
+
+// evict rare terms, keeping the ones in the logTree, the keywords and tokens of the current line
+func (tr *trans) evictTerms(tokens []int) {
+	keep := make(map[int]bool)
+	tr.lgs.lt.termIds(keep)
+	for _, termId := range tokens {
+		keep[termId] = true
+	}
+	for word := range tr.keywords {
+		if termId, ok := tr.te.term2Id[word]; ok {
+			keep[termId] = true
+		}
+	}
+	n := tr.te.evict(keep)
+	logrus.Debugf("evicted %d terms. %d terms left", n, len(tr.te.term2Id))
+}
+
+/*
+overflow gives the tokens and displayString to register the line of retentionPos with.
+When the log groups are at maxLogGroups, the ones not updated since retentionPos are spilled.
+If there are none left, the lines that would make a new log group go to
+the one of cOverflowDisplayString until a later retentionPos.
+*/
+func (tr *trans) overflow(tokens []int, displayString string, retentionPos int64) ([]int, string, error) {
+	lgs := tr.lgs
+	if len(lgs.alllg) < tr.maxLogGroups || lgs.lt.get(tokens) > 0 {
+		return tokens, displayString, nil
+	}
+	if tr.noSpill && retentionPos > tr.noSpillPos {
+		tr.noSpill = false
+	}
+	if !tr.noSpill {
+		target := int(float64(tr.maxLogGroups) * cEvictRate)
+		if _, err := lgs.spillColdGroups(target, retentionPos); err != nil {
+			return nil, "", err
+		}
+		// the rest are updated in retentionPos
+		if len(lgs.alllg) > target {
+			tr.noSpill, tr.noSpillPos = true, retentionPos
+		}
+		if len(lgs.alllg) < tr.maxLogGroups {
+			return tokens, displayString, nil
+		}
+	}
+	tr.overflowLines++
+	return []int{cAsteriskItemID}, cOverflowDisplayString, nil
+}
+
+// a log group spilled out of memory. its rows stay in the blocks
+type spilledGroup struct {
+	count        int
+	retentionPos int64
+	created      int64
+	updated      int64
+}
+
+func hashDisplayString(displayString string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(displayString))
+	return h.Sum64()
+}
+
+/*
+spillColdGroups moves the log groups not updated since before out of memory,
+the least recently updated first, until maxGroups are left.
+Their rows stay in the blocks and their displayStrings go to cSpilledFileName at commit.
+Only their counts and the hashes of their displayStrings are kept,
+so that the lines of them come back to the same groupIds.
+returns the number of log groups spilled
+*/
+func (lgs *logGroups) spillColdGroups(maxGroups int, before int64) (int, error) {
+	n := len(lgs.alllg) - maxGroups
+	if n <= 0 {
+		return 0, nil
+	}
+	cold := make([]int64, 0)
+	for groupId, lg := range lgs.alllg {
+		if lg.updated < before {
+			cold = append(cold, groupId)
+		}
+	}
+	if n > len(cold) {
+		n = len(cold)
+	}
+	if n <= 0 {
+		return 0, nil
+	}
+	sort.Slice(cold, func(i, j int) bool {
+		ui, uj := lgs.alllg[cold[i]].updated, lgs.alllg[cold[j]].updated
+		if ui != uj {
+			return ui < uj
+		}
+		return cold[i] < cold[j]
+	})
+	cold = cold[:n]
+
+	// the rows in curlg are still flushed
+	spilled := make(map[int64]bool, n)
+	for _, groupId := range cold {
+		lg := lgs.alllg[groupId]
+		lgs.spilledGroups[groupId] = &spilledGroup{count: lg.count,
+			retentionPos: lg.retentionPos, created: lg.created, updated: lg.updated}
+		lgs.spilledIds[hashDisplayString(lg.displayString)] = groupId
+		lgs.spilledLines = append(lgs.spilledLines, fmt.Sprintf("%d %s", groupId, lg.displayString))
+		spilled[groupId] = true
+		delete(lgs.alllg, groupId)
+		delete(lgs.displayStrings, groupId)
+		delete(lgs.lastMessages, groupId)
+	}
+	lgs.lt.prune(spilled)
+	lgs.spilled += n
+	logrus.Debugf("spilled %d log groups. %d log groups left", n, len(lgs.alllg))
+	return n, nil
+}
+
+/*
+unspill brings the log group of displayString back to memory with the count it was spilled with.
+returns its groupId or 0 if it was not spilled
+*/
+func (lgs *logGroups) unspill(displayString string) int64 {
+	h := hashDisplayString(displayString)
+	groupId, ok := lgs.spilledIds[h]
+	if !ok {
+		return 0
+	}
+	sg := lgs.spilledGroups[groupId]
+	lgs.alllg[groupId] = &logGroup{displayString: displayString, count: sg.count,
+		retentionPos: sg.retentionPos, created: sg.created, updated: sg.updated}
+	lgs.displayStrings[groupId] = displayString
+	delete(lgs.spilledIds, h)
+	delete(lgs.spilledGroups, groupId)
+	return groupId
+}
+
+// count the row of groupId loaded from the blocks for the spilled log group
+func (lgs *logGroups) countSpilled(groupId, retentionPos int64, count int, created, updated int64) {
+	sg, ok := lgs.spilledGroups[groupId]
+	if !ok {
+		sg = new(spilledGroup)
+		lgs.spilledGroups[groupId] = sg
+	}
+	sg.count += count
+	if retentionPos > sg.retentionPos {
+		sg.retentionPos = retentionPos
+	}
+	if sg.created == 0 || created < sg.created {
+		sg.created = created
+	}
+	if updated > sg.updated {
+		sg.updated = updated
+	}
+}
+
+// index the spilled log groups counted from the blocks by the hashes of their displayStrings
+func (lgs *logGroups) indexSpilled() error {
+	displayStrings, err := lgs.readSpilled()
+	if err != nil {
+		return err
+	}
+	for groupId := range lgs.spilledGroups {
+		if displayString, ok := displayStrings[groupId]; ok {
+			lgs.spilledIds[hashDisplayString(displayString)] = groupId
+		}
+	}
+	return nil
+}
+
+/*
+loadSpilled brings all the spilled log groups back to memory for the output,
+with their displayStrings in cSpilledFileName.
+*/
+func (tr *trans) loadSpilled() error {
+	lgs := tr.lgs
+	if len(lgs.spilledGroups) == 0 {
+		return nil
+	}
+	displayStrings, err := lgs.readSpilled()
+	if err != nil {
+		return err
+	}
+	for groupId, sg := range lgs.spilledGroups {
+		line, ok := displayStrings[groupId]
+		if !ok {
+			continue
+		}
+		tokens, displayString, _, err := tr.toTokens(line, 0, true, true, false, false)
+		if err != nil {
+			return err
+		}
+		lgs.registerLogTree(tokens, sg.count, displayString, sg.created, sg.updated, false,
+			sg.retentionPos, groupId)
+	}
+	lgs.spilledGroups = make(map[int64]*spilledGroup)
+	lgs.spilledIds = make(map[uint64]int64)
+	return nil
+}
+
+func (lgs *logGroups) _getSpilledPath() string {
+	return fmt.Sprintf("%s/%s", lgs.DataDir, cSpilledFileName)
+}
+
+// dataDir has log groups spilled out of memory
+func (lgs *logGroups) hasSpilled() bool {
+	return lgs.DataDir != "" && utils.PathExist(lgs._getSpilledPath())
+}
+
+// read the displayStrings of the spilled log groups, the ones not committed yet included
+func (lgs *logGroups) readSpilled() (map[int64]string, error) {
+	lines := make([]string, 0)
+	if lgs.hasSpilled() {
+		var err error
+		lines, err = readGzipLines(lgs._getSpilledPath())
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", lgs._getSpilledPath(), err)
+		}
+	}
+	displayStrings := make(map[int64]string, len(lines)+len(lgs.spilledLines))
+	for _, line := range append(lines, lgs.spilledLines...) {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		groupId, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		displayStrings[groupId] = parts[1]
+	}
+	return displayStrings, nil
+}
+
+// append the lines of the log groups spilled since the last commit to cSpilledFileName
+func (lgs *logGroups) writeSpilled() error {
+	if lgs.DataDir == "" || len(lgs.spilledLines) == 0 {
+		return nil
+	}
+	path, err := csvdb.StagedPath(lgs._getSpilledPath())
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	// a gzip member for each commit. gzip.Reader reads them all in a row
+	gzWriter := gzip.NewWriter(file)
+	writer := bufio.NewWriter(gzWriter)
+	for _, line := range lgs.spilledLines {
+		if _, err := fmt.Fprintln(writer, line); err != nil {
+			return fmt.Errorf("error writing to gzip file: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("error flushing buffered writer: %v", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		return fmt.Errorf("error closing gzip writer: %v", err)
+	}
+	lgs.spilledLines = nil
+	return nil
+}
@@ -0,0 +1,385 @@
+package logan
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_countMinSketch(t *testing.T) {
+	cms := newCountMinSketch(1 << 16)
+	for i := 0; i < 100; i++ {
+		cms.add(fmt.Sprintf("term%d", i), i+1)
+	}
+	for i := 0; i < 100; i++ {
+		term := fmt.Sprintf("term%d", i)
+		if err := utils.GetGotExpErr(term, cms.estimate(term), i+1); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	if err := utils.GetGotExpErr("unknown", cms.estimate("unknown"), 0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// many more rare terms than the counters
+	cms = newCountMinSketch(1024)
+	cms.add("frequent", 1000)
+	for i := 0; i < 100000; i++ {
+		cms.add(fmt.Sprintf("user%d", i), 1)
+	}
+	if est := cms.estimate("frequent"); est < 900 || est > 1100 {
+		t.Errorf("frequent estimated %d", est)
+		return
+	}
+	// the rare ones are within the noise
+	for i := 0; i < 1000; i++ {
+		term := fmt.Sprintf("user%d", i)
+		if err := utils.GetGotExpErr(term, cms.estimate(term), 0); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+}
+
+func Test_terms_evict(t *testing.T) {
+	te, err := newTerms("", "", 0, 0, 0, false, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	te.setMaxTerms(10, 1024)
+	for i := 0; i < 12; i++ {
+		termId := te.register(fmt.Sprintf("t%d", i))
+		te.addCount(termId, i+1, false)
+	}
+	if !te.overLimit() {
+		t.Errorf("12 terms are not over 10")
+		return
+	}
+	keep := map[int]bool{te.term2Id["t0"]: true}
+	if err := utils.GetGotExpErr("evicted", te.evict(keep), 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// t0 is kept, t1 to t4 are the least counted
+	for word, exp := range map[string]int{"t0": 1, "t1": -1, "t4": -1, "t5": 6} {
+		if err := utils.GetGotExpErr(word, te.getCount(word), exp); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	// the count comes back from the sketch
+	termId := te.register("t3")
+	te.addCount(termId, 1, false)
+	if err := utils.GetGotExpErr("t3", te.getCount("t3"), 5); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_Analyzer_memoryBudget(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_memoryBudget")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/memory.log"
+	var sb strings.Builder
+	days := map[string][]string{
+		"2024-10-01": {"alpha apple", "bravo banana", "charlie cherry", "delta date", "echo elder", "foxtrot fig"},
+		"2024-10-02": {"golf grape", "hotel honeydew", "india iceberg", "juliet jujube"},
+	}
+	for _, day := range []string{"2024-10-01", "2024-10-02"} {
+		for i, msg := range days[day] {
+			for j := 0; j < 2; j++ {
+				fmt.Fprintf(&sb, "%sT00:%02d:%02d] %s\n", day, i, j, msg)
+			}
+		}
+	}
+	if err := os.WriteFile(conf.LogPath, []byte(sb.String()), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.trans.maxLogGroups = 4
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// echo and foxtrot of the first day find no room in its block
+	if err := utils.GetGotExpErr("overflow lines", a.trans.overflowLines, 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// the second day spills the groups of the first one, the overflow one too
+	if err := utils.GetGotExpErr("groups in memory", len(a.trans.lgs.alllg), 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if a.trans.searchLogGroup("juliet jujube") == nil {
+		t.Errorf("no log group of the last line")
+		return
+	}
+	groups := len(a.trans.lgs.alllg)
+	dataDir := a.DataDir
+	a.Close()
+
+	f, err := os.Open(dataDir + "/logGroups/" + cSpilledFileName)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	spilled := make([]string, 0)
+	for scanner := bufio.NewScanner(gz); scanner.Scan(); {
+		spilled = append(spilled, strings.SplitN(scanner.Text(), " ", 2)[1])
+	}
+	sort.Strings(spilled)
+	exp := []string{"*", "alpha apple", "bravo banana", "charlie cherry", "delta date"}
+	if err := utils.GetGotExpErr("spilled", strings.Join(spilled, ","), strings.Join(exp, ",")); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the spilled log groups are not loaded again
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	if err := utils.GetGotExpErr("loaded groups", len(b.trans.lgs.alllg), groups); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	problems, err := Fsck(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+}
+
+func Test_Analyzer_spilledGroups(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_spilledGroups")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/memory.log"
+	writeLog := func(days [][2]string) error {
+		var sb strings.Builder
+		for i, day := range days {
+			for j := 0; j < 2; j++ {
+				fmt.Fprintf(&sb, "%sT00:%02d:%02d] %s\n", day[0], i, j, day[1])
+			}
+		}
+		return os.WriteFile(conf.LogPath, []byte(sb.String()), 0644)
+	}
+	// the groups of the first day are spilled on the second one and alpha comes back on the third
+	if err := writeLog([][2]string{
+		{"2024-10-01", "alpha apple"}, {"2024-10-01", "bravo banana"},
+		{"2024-10-01", "charlie cherry"}, {"2024-10-01", "delta date"},
+		{"2024-10-02", "golf grape"}, {"2024-10-02", "hotel honeydew"},
+		{"2024-10-02", "india iceberg"}, {"2024-10-02", "juliet jujube"},
+		{"2024-10-03", "alpha apple"}}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.trans.maxLogGroups = 4
+	created := make(map[string]int64)
+	a.OnNewLogGroup(func(g LogGroup) {
+		if _, ok := created[g.DisplayString]; ok {
+			t.Errorf("%s is created twice", g.DisplayString)
+		}
+		created[g.DisplayString] = g.GroupId
+	})
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		a.Close()
+		return
+	}
+	if err := utils.GetGotExpErr("spilled", a.trans.lgs.spilled, 5); err != nil {
+		t.Errorf("%v", err)
+		a.Close()
+		return
+	}
+	lg := a.trans.lgs.alllg[created["alpha apple"]]
+	if lg == nil {
+		t.Errorf("alpha apple did not come back to groupId %d", created["alpha apple"])
+		a.Close()
+		return
+	}
+	if err := utils.GetGotExpErr("alpha apple", lg.count, 4); err != nil {
+		t.Errorf("%v", err)
+		a.Close()
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	// bravo comes back after loading
+	f, err := os.OpenFile(conf.LogPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	_, err = f.WriteString("2024-10-04T00:00:00] bravo banana\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err = LoadAnalyzer(dataDir, conf.LogPath, 0, 0, 0, nil, false, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	a.trans.maxLogGroups = 4
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the spilled groups are in the output with the counts of all their lines
+	groups, err := a.LogGroups(LogGroupsQuery{N: 100})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", len(groups), len(created)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	total := 0
+	for _, g := range groups {
+		if err := utils.GetGotExpErr(g.DisplayString+" groupId", g.GroupId, created[g.DisplayString]); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		exp := 2
+		switch g.DisplayString {
+		case "alpha apple":
+			exp = 4
+		case "bravo banana":
+			exp = 3
+		}
+		if err := utils.GetGotExpErr(g.DisplayString, g.Count, exp); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		total += g.Count
+	}
+	if err := utils.GetGotExpErr("total", total, 19); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	h, err := a.LogGroupHistory(created["charlie cherry"], 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("charlie cherry history", len(h.Points), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	problems, err := Fsck(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+}
+
+func Test_Analyzer_memoryBudget_spill(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_memoryBudget_spill")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// 1MB holds about 600 log groups. 650 patterns on the first day and 100 on the second
+	conf.MemoryBudget = 1
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/memory.log"
+	var sb strings.Builder
+	lines := 0
+	for day, patterns := range []int{650, 100} {
+		for i := 0; i < patterns; i++ {
+			for j := 0; j < 2; j++ {
+				fmt.Fprintf(&sb, "2024-10-%02dT00:00:00] w%d%04dx w%d%04dy\n", day+1, day, i, day, i)
+				lines++
+			}
+		}
+	}
+	if err := os.WriteFile(conf.LogPath, []byte(sb.String()), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	maxLogGroups := a.trans.maxLogGroups
+	if maxLogGroups >= 650 {
+		t.Errorf("maxLogGroups %d does not make the first day overflow", maxLogGroups)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	if a.trans.overflowLines == 0 {
+		t.Errorf("no overflow lines")
+		return
+	}
+	if a.trans.lgs.spilled == 0 {
+		t.Errorf("no log groups spilled")
+		return
+	}
+	if len(a.trans.lgs.alllg) > maxLogGroups {
+		t.Errorf("%d log groups in memory over %d", len(a.trans.lgs.alllg), maxLogGroups)
+		return
+	}
+	if a.trans.searchLogGroup("w10099x w10099y") == nil {
+		t.Errorf("no log group of the last line")
+		return
+	}
+	// no line is lost in spilling
+	total := _totalCount(a)
+	for _, sg := range a.trans.lgs.spilledGroups {
+		total += sg.count
+	}
+	if err := utils.GetGotExpErr("lines", total, lines); err != nil {
+		t.Errorf("%v", err)
+	}
+}
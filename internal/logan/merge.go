@@ -0,0 +1,368 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+type merger struct {
+	conf        *AnalConfig
+	tr          *trans // re-tokenises displayStrings with the merged term counts
+	terms       map[string]int
+	groups      map[int64]*logGroup
+	stores      map[string]map[int64]map[int64]*logGroup // store name -> retentionPos -> groupId
+	sources     map[sourceKey]int
+	lastMessage map[int64]string
+}
+
+/*
+Merge combines dataDirs fed on different hosts into a new dataDir outDir.
+
+  - term counts are summed up
+  - log groups are re-tokenised with the merged term counts like rebuildTrans(),
+    so the same message logged on several hosts ends up in one group
+  - the count history is summed up by retentionPos in the store it was kept in
+  - counts by source, the base name of each dataDir, are kept in logGroupSources
+    so that groups shows the counts per host
+
+All dataDirs must have the same unitSecs and retention. Pattern keys are not merged.
+The merged dataDir has no log of its own, so feed does nothing on it.
+*/
+func Merge(outDir string, dataDirs []string) error {
+	if outDir == "" || len(dataDirs) == 0 {
+		return fmt.Errorf("output dataDir and dataDirs to merge are mandatory")
+	}
+	if utils.PathExist(outDir) {
+		return fmt.Errorf("%s already exists", outDir)
+	}
+	names := make([]string, len(dataDirs))
+	seen := make(map[string]bool)
+	for i, dataDir := range dataDirs {
+		names[i] = filepath.Base(filepath.Clean(dataDir))
+		if seen[names[i]] {
+			return fmt.Errorf("dataDirs must have different base names to tell the sources: %s", names[i])
+		}
+		seen[names[i]] = true
+	}
+
+	srcs := make([]*Analyzer, 0, len(dataDirs))
+	defer func() {
+		for _, a := range srcs {
+			a.Close()
+		}
+	}()
+	for _, dataDir := range dataDirs {
+		a, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", dataDir, err)
+		}
+		srcs = append(srcs, a)
+		first := srcs[0]
+		if a.UnitSecs != first.UnitSecs || !reflect.DeepEqual(a.Retention, first.Retention) {
+			return fmt.Errorf("%s has a different unitSecs or retention from %s", dataDir, dataDirs[0])
+		}
+	}
+
+	m, err := newMerger(srcs)
+	if err != nil {
+		return err
+	}
+	for i, a := range srcs {
+		logrus.Infof("merging %s", dataDirs[i])
+		if err := m.add(a, names[i]); err != nil {
+			return fmt.Errorf("failed to merge %s: %w", dataDirs[i], err)
+		}
+	}
+	m.conf.MergedFrom = dataDirs
+
+	if err := utils.EnsureDir(outDir); err != nil {
+		return err
+	}
+	lock, err := csvdb.LockDir(outDir, true, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	tx, err := csvdb.BeginTxn(outDir)
+	if err != nil {
+		return err
+	}
+	if err := m.write(outDir); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logrus.Infof("merged %d log groups into %s", len(m.groups), outDir)
+	return nil
+}
+
+// the merged config starts from the first dataDir.
+// all term counts are loaded to re-tokenise the displayStrings
+func newMerger(srcs []*Analyzer) (*merger, error) {
+	conf := *srcs[0].AnalConfig
+	conf.LogPath = ""
+	conf.PatternKeyRegexes = nil
+	conf.SourceFrom = ""
+	m := &merger{
+		conf:        &conf,
+		terms:       make(map[string]int),
+		groups:      make(map[int64]*logGroup),
+		stores:      make(map[string]map[int64]map[int64]*logGroup),
+		sources:     make(map[sourceKey]int),
+		lastMessage: make(map[int64]string),
+	}
+	for _, a := range srcs {
+		te := a.trans.te
+		for termId, count := range te.counts {
+			m.terms[te.id2term[termId]] += count
+		}
+		if a.MaxBlocks > m.conf.MaxBlocks {
+			m.conf.MaxBlocks = a.MaxBlocks
+		}
+	}
+
+	tr, err := newTrans("", "", "", "", conf.UseUtcTime,
+		conf.MaxBlocks, conf.BlockSize, conf.UnitSecs, conf.KeepPeriod, 0, nil,
+		conf.TermCountBorderRate, conf.TermCountBorder, conf.MinMatchRate,
+		nil, nil,
+		conf.Keywords, conf.Ignorewords,
+		conf.KeyRegexes, conf.IgnoreRegexes,
+		nil, nil, nil, conf.Maskers, conf.Separators, conf.Storage, "",
+		true, true, false, conf.IgnoreNumbers)
+	if err != nil {
+		return nil, err
+	}
+	for term, count := range m.terms {
+		tr.te.addCount(tr.te.register(term), count, false)
+	}
+	tr.setCountBorder()
+	m.tr = tr
+	return m, nil
+}
+
+// add all logGroups rows of a as source
+func (m *merger) add(a *Analyzer, source string) error {
+	// the rows of the spilled log groups need their displayStrings too
+	if err := a.trans.loadSpilled(); err != nil {
+		return err
+	}
+	lgs := a.trans.lgs
+	names := []string{"logGroups"}
+	stores := []csvdb.Store{lgs.Store}
+	for _, rt := range lgs.tiers {
+		names = append(names, getRollupStoreName(rt.unitSecs))
+		stores = append(stores, rt.Store)
+	}
+
+	groupIds := make(map[int64]int64)
+	lastUpdates := make(map[int64]int64)
+	for i, store := range stores {
+		rows, err := store.SelectRows(nil, nil, tableDefs["logGroups"])
+		if err != nil {
+			return err
+		}
+		if rows == nil {
+			continue
+		}
+		if _, ok := m.stores[names[i]]; !ok {
+			m.stores[names[i]] = make(map[int64]map[int64]*logGroup)
+		}
+		byPos := m.stores[names[i]]
+
+		var scanErr error
+		if err := scanLogGroupRows(rows, func(groupId int64, lg *logGroup) {
+			if scanErr != nil {
+				return
+			}
+			newId, ok := groupIds[groupId]
+			if !ok {
+				newId, scanErr = m.rekey(lgs.displayStrings[groupId], groupId, lg)
+				if scanErr != nil {
+					return
+				}
+				groupIds[groupId] = newId
+			}
+			if _, ok := byPos[lg.retentionPos]; !ok {
+				byPos[lg.retentionPos] = make(map[int64]*logGroup)
+			}
+			mergeLogGroup(byPos[lg.retentionPos], newId, lg)
+			mergeLogGroup(m.groups, newId, lg)
+			m.groups[newId].displayString = m.tr.lgs.alllg[newId].displayString
+			m.sources[sourceKey{newId, lg.retentionPos, source}] += lg.count
+
+			// the last message of the latest host
+			if lg.updated >= lastUpdates[newId] {
+				lastUpdates[newId] = lg.updated
+				if msg, ok := lgs.lastMessages[groupId]; ok {
+					m.lastMessage[newId] = msg
+				}
+			}
+		}); err != nil {
+			return err
+		}
+		if scanErr != nil {
+			return scanErr
+		}
+	}
+	return nil
+}
+
+// groupId in the merged dataDir of the displayString
+func (m *merger) rekey(displayString string, groupId int64, lg *logGroup) (int64, error) {
+	if displayString == "" {
+		return -1, fmt.Errorf("groupId %d has no displayString. run fsck", groupId)
+	}
+	tokens, displayString, _, err := m.tr.toTokens(displayString, 0, true, true, false, false)
+	if err != nil {
+		return -1, err
+	}
+	return m.tr.lgs.registerLogTree(tokens, 0, displayString, lg.created, lg.updated,
+		false, lg.retentionPos, -1), nil
+}
+
+func (m *merger) write(outDir string) error {
+	conf := m.conf
+	storage := conf.Storage
+	a := &Analyzer{AnalConfig: conf, analStatus: new(analStatus)}
+	conf.DataDir = outDir
+	a.DataDir = outDir
+
+	// blocks must not be cleared nor rolled up while being written.
+	// maxBlocks covers all retentionPos so that no block is overwritten
+	for name, byPos := range m.stores {
+		unitSecs := conf.UnitSecs
+		for _, tier := range conf.Retention {
+			if getRollupStoreName(tier.UnitSecs) == name {
+				unitSecs = tier.UnitSecs
+			}
+		}
+		if name == "logGroups" && len(byPos)+1 > conf.MaxBlocks {
+			conf.MaxBlocks = len(byPos) + 1
+		}
+		db, err := csvdb.NewStore(storage, outDir, name, tableDefs["logGroups"],
+			len(byPos)+1, 0, 0, unitSecs, true)
+		if err != nil {
+			return err
+		}
+		if err := db.SetColumnTypes(columnTypes["logGroups"]); err != nil {
+			return err
+		}
+		if err := writeLogGroupBlocks(db, byPos); err != nil {
+			return err
+		}
+	}
+
+	if err := writeTermCounts(storage, outDir, conf.MaxBlocks, conf.UnitSecs, m.terms); err != nil {
+		return err
+	}
+
+	src, err := newLogGroupSources(outDir, storage, 0, 0, 0, true)
+	if err != nil {
+		return err
+	}
+	keys := make([]sourceKey, 0, len(m.sources))
+	for key := range m.sources {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].retentionPos != keys[j].retentionPos {
+			return keys[i].retentionPos < keys[j].retentionPos
+		}
+		if keys[i].groupId != keys[j].groupId {
+			return keys[i].groupId < keys[j].groupId
+		}
+		return keys[i].source < keys[j].source
+	})
+	for _, key := range keys {
+		if err := src.InsertRow(tableDefs[cSourcesStoreName],
+			key.groupId, key.retentionPos, key.source, m.sources[key]); err != nil {
+			return err
+		}
+	}
+	if err := src.FlushOverwriteCurrentTable(); err != nil {
+		return err
+	}
+	if err := src.UpdateBlockStatus(false); err != nil {
+		return err
+	}
+
+	lgs := &logGroups{DataDir: fmt.Sprintf("%s/logGroups", outDir),
+		alllg: m.groups, lastMessages: m.lastMessage}
+	if err := utils.EnsureDir(lgs.DataDir); err != nil {
+		return err
+	}
+	if err := lgs.writeDisplayStrings(); err != nil {
+		return err
+	}
+	if err := lgs.writeLastMessages(); err != nil {
+		return err
+	}
+
+	if err := a.saveConfig(); err != nil {
+		return err
+	}
+	return a.saveLastStatus()
+}
+
+// one block per retentionPos in ascending order. the last one stays the current block
+func writeLogGroupBlocks(db csvdb.Store, byPos map[int64]map[int64]*logGroup) error {
+	poses := make([]int64, 0, len(byPos))
+	for pos := range byPos {
+		poses = append(poses, pos)
+	}
+	sort.Slice(poses, func(i, j int) bool { return poses[i] < poses[j] })
+	for i, pos := range poses {
+		if i > 0 {
+			if err := db.NextBlock(pos); err != nil {
+				return err
+			}
+		}
+		groupIds := make([]int64, 0, len(byPos[pos]))
+		for groupId := range byPos[pos] {
+			groupIds = append(groupIds, groupId)
+		}
+		sort.Slice(groupIds, func(i, j int) bool { return groupIds[i] < groupIds[j] })
+		for _, groupId := range groupIds {
+			lg := byPos[pos][groupId]
+			if err := db.InsertRow(tableDefs["logGroups"], groupId,
+				pos, lg.count, lg.created, lg.updated); err != nil {
+				return err
+			}
+		}
+		if err := db.FlushOverwriteCurrentTable(); err != nil {
+			return err
+		}
+	}
+	return db.UpdateBlockStatus(false)
+}
+
+// the terms store of outDir with counts in one block, in the order of the terms
+func writeTermCounts(storage, outDir string, maxBlocks int, unitSecs int64, counts map[string]int) error {
+	tedb, err := csvdb.NewStore(storage, outDir, "terms", tableDefs["terms"],
+		maxBlocks, 0, 0, unitSecs, true)
+	if err != nil {
+		return err
+	}
+	terms := make([]string, 0, len(counts))
+	for term := range counts {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	for _, term := range terms {
+		if err := tedb.InsertRow(tableDefs["terms"], term, counts[term]); err != nil {
+			return err
+		}
+	}
+	if err := tedb.FlushOverwriteCurrentTable(); err != nil {
+		return err
+	}
+	return tedb.UpdateBlockStatus(false)
+}
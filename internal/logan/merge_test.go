@@ -0,0 +1,82 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Merge(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Merge")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	testDir := filepath.Dir(conf.DataDir)
+
+	// the same log fed on 2 hosts
+	dataDirs := []string{testDir + "/hostA", testDir + "/hostB"}
+	counts := make(map[string]int)
+	for _, dataDir := range dataDirs {
+		conf.DataDir = dataDir
+		a, err := NewAnalyzer(conf, 0, false, false)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := a.Feed(0); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		for _, lg := range a.trans.lgs.alllg {
+			counts[lg.displayString] += lg.count
+		}
+		a.Close()
+	}
+
+	mergedDir := testDir + "/merged"
+	if err := Merge(mergedDir, dataDirs); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := Merge(mergedDir, dataDirs); err == nil {
+		t.Errorf("merging into an existing dataDir must be an error")
+	}
+
+	a, err := LoadAnalyzer(mergedDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", len(a.trans.lgs.alllg), len(counts)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for groupId, lg := range a.trans.lgs.alllg {
+		if err := utils.GetGotExpErr(lg.displayString, lg.count, counts[lg.displayString]); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		exp := fmt.Sprintf("hostA=%d hostB=%d", lg.count/2, lg.count/2)
+		if err := utils.GetGotExpErr("sources", strings.Join(a.trans.src.format(groupId), " "), exp); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	problems, err := Fsck(mergedDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+}
@@ -0,0 +1,134 @@
+package logan
+
+import (
+	"encoding/json"
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// tableDefs of the stores in circuitDBNames
+var circuitDBColumns = map[string][]string{
+	"terms":             tableDefs["terms"],
+	"logGroups":         tableDefs["logGroups"],
+	"patternkeys":       tableDefs["patternKeys"],
+	"patternTags":       tableDefs["patternTags"],
+	cSourcesStoreName:   tableDefs[cSourcesStoreName],
+	cFeedStatsStoreName: tableDefs[cFeedStatsStoreName],
+}
+
+// columns of the store. rollup stores have the same columns as logGroups
+func getStoreColumns(name string) []string {
+	if columns, ok := circuitDBColumns[name]; ok {
+		return columns
+	}
+	return tableDefs["logGroups"]
+}
+
+// "" in old config.json means csv
+func getStorage(storage string) string {
+	if storage == "" {
+		return csvdb.CStorageCsv
+	}
+	return storage
+}
+
+/*
+Migrate converts all stores in dataDir to storage.
+New stores are built in dataDir/.migrate and swapped with the old ones,
+then storage is saved in config.json.
+*/
+func Migrate(dataDir, storage string) error {
+	if !utils.PathExist(dataDir) {
+		return fmt.Errorf("%s does not exist", dataDir)
+	}
+	if storage != csvdb.CStorageCsv && storage != csvdb.CStorageBin {
+		return fmt.Errorf("unknown storage %s", storage)
+	}
+	lock, err := csvdb.LockDir(dataDir, true, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	if _, err := csvdb.RecoverTxn(dataDir); err != nil {
+		return err
+	}
+
+	a := &Analyzer{AnalConfig: new(AnalConfig)}
+	a.DataDir = dataDir
+	if err := a.loadConfig(); err != nil {
+		return err
+	}
+	storedDataDir := a.DataDir
+	a.DataDir = dataDir
+	from := getStorage(a.Storage)
+	if from == storage {
+		logrus.Infof("%s is already stored in %s", dataDir, storage)
+		return nil
+	}
+
+	tmpRoot := fmt.Sprintf("%s/.migrate", dataDir)
+	if err := os.RemoveAll(tmpRoot); err != nil {
+		return err
+	}
+	if err := utils.EnsureDir(tmpRoot); err != nil {
+		return err
+	}
+	storeNames, err := getStoreNames(dataDir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(storeNames))
+	for _, name := range storeNames {
+		if !utils.PathExist(fmt.Sprintf("%s/%s", dataDir, name)) {
+			continue
+		}
+		logrus.Infof("migrating %s from %s to %s", name, from, storage)
+		if err := csvdb.CopyStore(dataDir, tmpRoot, name, getStoreColumns(name),
+			from, storage, true); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", name, err)
+		}
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		oldDir := fmt.Sprintf("%s/%s", dataDir, name)
+		newDir := fmt.Sprintf("%s/%s", tmpRoot, name)
+		if err := os.Rename(oldDir, newDir+".old"); err != nil {
+			return err
+		}
+		if err := os.Rename(newDir, oldDir); err != nil {
+			return err
+		}
+	}
+
+	// files of logGroups which are not in the store
+	oldLgs := &logGroups{DataDir: fmt.Sprintf("%s/logGroups.old", tmpRoot)}
+	newLgs := &logGroups{DataDir: fmt.Sprintf("%s/logGroups", dataDir)}
+	for _, paths := range [][2]string{
+		{oldLgs._getDisplayStringPath(), newLgs._getDisplayStringPath()},
+		{oldLgs._getLastMessagePath(), newLgs._getLastMessagePath()},
+		{oldLgs._getSpilledPath(), newLgs._getSpilledPath()},
+	} {
+		if !utils.PathExist(paths[0]) {
+			continue
+		}
+		if err := os.Rename(paths[0], paths[1]); err != nil {
+			return err
+		}
+	}
+
+	a.Storage = storage
+	a.DataDir = storedDataDir
+	data, err := json.MarshalIndent(a.AnalConfig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config to JSON: %w", err)
+	}
+	if err := os.WriteFile(fmt.Sprintf("%s/config.json", dataDir), data, 0644); err != nil {
+		return fmt.Errorf("failed to write JSON to file: %w", err)
+	}
+	return os.RemoveAll(tmpRoot)
+}
@@ -0,0 +1,110 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// the groups of dataDir with their counts and history, one per line
+func _snapshotGroups(dataDir string) (string, error) {
+	a, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		return "", err
+	}
+	defer a.Close()
+	return _analyzerGroups(a)
+}
+
+func _analyzerGroups(a *Analyzer) (string, error) {
+	groups, err := a.LogGroups(LogGroupsQuery{N: -1})
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, len(groups))
+	for _, g := range groups {
+		h, err := a.LogGroupHistory(g.GroupId, 0, 0)
+		if err != nil {
+			return "", err
+		}
+		points := make([]string, len(h.Points))
+		total := 0
+		for i, p := range h.Points {
+			points[i] = fmt.Sprintf("%d=%d", p.Epoch, p.Count)
+			total += p.Count
+		}
+		if total != g.Count {
+			return "", fmt.Errorf("history of %d sums up to %d, not %d", g.GroupId, total, g.Count)
+		}
+		lines = append(lines, fmt.Sprintf("%d %d %s [%s]", g.GroupId, g.Count, g.DisplayString, strings.Join(points, " ")))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n"), nil
+}
+
+func Test_Migrate_bin(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Migrate_bin")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.Storage = csvdb.CStorageBin
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	exp, err := _analyzerGroups(a)
+	a.Close()
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if strings.Count(exp, "=") <= len(strings.Split(exp, "\n")) {
+		t.Errorf("no history of more than a block in\n%s", exp)
+		return
+	}
+
+	// reopened from the bin blocks
+	got, err := _snapshotGroups(conf.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("reopened groups", got, exp); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, storage := range []string{csvdb.CStorageCsv, csvdb.CStorageBin} {
+		if err := Migrate(conf.DataDir, storage); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		got, err := _snapshotGroups(conf.DataDir)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr("groups in "+storage, got, exp); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		problems, err := Fsck(conf.DataDir)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr("problems in "+storage, len(problems), 0); err != nil {
+			t.Errorf("%v %v", err, problems)
+			return
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package logan
+
+import (
+	"encoding/json"
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cModelFormat  = "logan-model"
+	cModelVersion = 1
+)
+
+/*
+Model is the template model of a dataDir in a single file, to build it on one host
+and classify or check logs with it on others.
+Templates keep their groupIds so that the ids stay the same on all hosts.
+Local paths, the count history, last messages and pattern keys are not in it.
+*/
+type Model struct {
+	Format    string          `json:"format"`
+	Version   int             `json:"version"`
+	CreatedAt string          `json:"created_at"`
+	Config    ModelConfig     `json:"config"`
+	Terms     []ModelTerm     `json:"terms"`
+	Templates []ModelTemplate `json:"templates"`
+}
+
+// ModelConfig is the part of AnalConfig that decides how lines are parsed and grouped
+type ModelConfig struct {
+	LogFormat           string   `json:"log_format"`
+	MsgFormats          []string `json:"msg_formats"`
+	TimestampLayout     string   `json:"timestamp_layout"`
+	UseUtcTime          bool     `json:"use_utc_time"`
+	Timezone            string   `json:"timezone,omitempty"`
+	SearchRegex         []string `json:"search_regex"`
+	ExludeRegex         []string `json:"exclude_regex"`
+	Separators          string   `json:"separators"`
+	Keywords            []string `json:"keywords"`
+	KeyRegexes          []string `json:"key_regexes"`
+	Ignorewords         []string `json:"ignorewords"`
+	IgnoreRegexes       []string `json:"ignore_regexes"`
+	IgnoreNumbers       bool     `json:"ignore_numbers"`
+	Maskers             []string `json:"maskers"`
+	TermCountBorderRate float64  `json:"term_count_border_rate"`
+	TermCountBorder     int      `json:"term_count_border"`
+	MinMatchRate        float64  `json:"min_match_rate"`
+	UnitSecs            int64    `json:"unit_secs"`
+	KeepPeriod          int64    `json:"keep_period"`
+}
+
+type ModelTerm struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+type ModelTemplate struct {
+	Id            int64  `json:"id"`
+	DisplayString string `json:"display_string"`
+	Count         int    `json:"count"`
+	Created       int64  `json:"created"`
+	Updated       int64  `json:"updated"`
+}
+
+// ExportModel writes the template model of the analyzer to outPath as JSON
+func (a *Analyzer) ExportModel(outPath string) error {
+	if outPath == "" {
+		return fmt.Errorf("output file is mandatory for model export")
+	}
+	if err := a.trans.loadSpilled(); err != nil {
+		return err
+	}
+	tr := a.trans
+	m := &Model{
+		Format:    cModelFormat,
+		Version:   cModelVersion,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Config: ModelConfig{
+			LogFormat:           a.LogFormat,
+			MsgFormats:          a.MsgFormats,
+			TimestampLayout:     a.TimestampLayout,
+			UseUtcTime:          a.UseUtcTime,
+			Timezone:            a.Timezone,
+			SearchRegex:         a.SearchRegex,
+			ExludeRegex:         a.ExludeRegex,
+			Separators:          a.Separators,
+			Keywords:            a.Keywords,
+			KeyRegexes:          a.KeyRegexes,
+			Ignorewords:         a.Ignorewords,
+			IgnoreRegexes:       a.IgnoreRegexes,
+			IgnoreNumbers:       a.IgnoreNumbers,
+			Maskers:             a.Maskers,
+			TermCountBorderRate: a.TermCountBorderRate,
+			TermCountBorder:     a.TermCountBorder,
+			MinMatchRate:        a.MinMatchRate,
+			UnitSecs:            a.UnitSecs,
+			KeepPeriod:          a.KeepPeriod,
+		},
+		Terms:     make([]ModelTerm, 0, len(tr.te.counts)),
+		Templates: make([]ModelTemplate, 0, len(tr.lgs.alllg)),
+	}
+	for termId, count := range tr.te.counts {
+		if count > 0 {
+			m.Terms = append(m.Terms, ModelTerm{tr.te.id2term[termId], count})
+		}
+	}
+	sort.Slice(m.Terms, func(i, j int) bool { return m.Terms[i].Term < m.Terms[j].Term })
+	for groupId, lg := range tr.lgs.alllg {
+		m.Templates = append(m.Templates, ModelTemplate{groupId, tr.lgs.displayStrings[groupId],
+			lg.count, lg.created, lg.updated})
+	}
+	sort.Slice(m.Templates, func(i, j int) bool { return m.Templates[i].Id < m.Templates[j].Id })
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(outPath, data, 0644); err != nil {
+		return err
+	}
+	logrus.Infof("exported %d templates and %d terms to %s", len(m.Templates), len(m.Terms), outPath)
+	return nil
+}
+
+// LoadModel reads a model file written by ExportModel
+func LoadModel(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := new(Model)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Format != cModelFormat {
+		return nil, fmt.Errorf("%s is not a logan model", path)
+	}
+	if m.Version < 1 || m.Version > cModelVersion {
+		return nil, fmt.Errorf("%s is a model of version %d. this logan reads up to version %d",
+			path, m.Version, cModelVersion)
+	}
+	return m, nil
+}
+
+/*
+ImportModel creates dataDir from the model file at path.
+The dataDir has no log of its own like a merged one; classify and check use it,
+and feed continues it with the logPath of a config file.
+*/
+func ImportModel(path, dataDir string) error {
+	if path == "" || dataDir == "" {
+		return fmt.Errorf("model file and dataDir are mandatory for model import")
+	}
+	if utils.PathExist(dataDir) {
+		return fmt.Errorf("%s already exists", dataDir)
+	}
+	m, err := LoadModel(path)
+	if err != nil {
+		return err
+	}
+	mc := m.Config
+	conf := &AnalConfig{
+		DataDir:             dataDir,
+		LogFormat:           mc.LogFormat,
+		MsgFormats:          mc.MsgFormats,
+		TimestampLayout:     mc.TimestampLayout,
+		UseUtcTime:          mc.UseUtcTime,
+		Timezone:            mc.Timezone,
+		SearchRegex:         mc.SearchRegex,
+		ExludeRegex:         mc.ExludeRegex,
+		Separators:          mc.Separators,
+		Keywords:            mc.Keywords,
+		KeyRegexes:          mc.KeyRegexes,
+		Ignorewords:         mc.Ignorewords,
+		IgnoreRegexes:       mc.IgnoreRegexes,
+		IgnoreNumbers:       mc.IgnoreNumbers,
+		Maskers:             mc.Maskers,
+		TermCountBorderRate: mc.TermCountBorderRate,
+		TermCountBorder:     mc.TermCountBorder,
+		MinMatchRate:        mc.MinMatchRate,
+		UnitSecs:            mc.UnitSecs,
+		KeepPeriod:          mc.KeepPeriod,
+	}
+	if err := checkConfig(conf); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if conf.UnitSecs <= 0 {
+		return fmt.Errorf("%s: unit_secs must be positive", path)
+	}
+
+	terms := make(map[string]int, len(m.Terms))
+	for _, t := range m.Terms {
+		terms[t.Term] += t.Count
+	}
+	groups := make(map[int64]*logGroup, len(m.Templates))
+	byPos := make(map[int64]map[int64]*logGroup)
+	for _, t := range m.Templates {
+		if t.DisplayString == "" {
+			return fmt.Errorf("%s: template %d has no display_string", path, t.Id)
+		}
+		if _, ok := groups[t.Id]; ok {
+			return fmt.Errorf("%s: template %d is duplicated", path, t.Id)
+		}
+		pos := int64(math.Floor(float64(t.Updated)/float64(conf.UnitSecs))) * conf.UnitSecs
+		lg := &logGroup{displayString: t.DisplayString, count: t.Count,
+			retentionPos: pos, created: t.Created, updated: t.Updated}
+		groups[t.Id] = lg
+		if _, ok := byPos[pos]; !ok {
+			byPos[pos] = make(map[int64]*logGroup)
+		}
+		byPos[pos][t.Id] = lg
+	}
+
+	if err := utils.EnsureDir(dataDir); err != nil {
+		return err
+	}
+	lock, err := csvdb.LockDir(dataDir, true, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	tx, err := csvdb.BeginTxn(dataDir)
+	if err != nil {
+		return err
+	}
+	if err := writeModel(conf, terms, groups, byPos); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logrus.Infof("imported %d templates and %d terms into %s", len(groups), len(terms), dataDir)
+	return nil
+}
+
+func writeModel(conf *AnalConfig, terms map[string]int,
+	groups map[int64]*logGroup, byPos map[int64]map[int64]*logGroup) error {
+	dataDir := conf.DataDir
+	// one block per retentionPos like merge
+	conf.MaxBlocks = max(conf.MaxBlocks, len(byPos)+1)
+	db, err := csvdb.NewStore(conf.Storage, dataDir, "logGroups", tableDefs["logGroups"],
+		conf.MaxBlocks, 0, 0, conf.UnitSecs, true)
+	if err != nil {
+		return err
+	}
+	if err := db.SetColumnTypes(columnTypes["logGroups"]); err != nil {
+		return err
+	}
+	if err := writeLogGroupBlocks(db, byPos); err != nil {
+		return err
+	}
+	if err := writeTermCounts(conf.Storage, dataDir, conf.MaxBlocks, conf.UnitSecs, terms); err != nil {
+		return err
+	}
+
+	lgs := &logGroups{DataDir: fmt.Sprintf("%s/logGroups", dataDir),
+		alllg: groups, lastMessages: make(map[int64]string)}
+	if err := utils.EnsureDir(lgs.DataDir); err != nil {
+		return err
+	}
+	if err := lgs.writeDisplayStrings(); err != nil {
+		return err
+	}
+	if err := lgs.writeLastMessages(); err != nil {
+		return err
+	}
+
+	a := &Analyzer{AnalConfig: conf, analStatus: new(analStatus)}
+	if err := a.saveConfig(); err != nil {
+		return err
+	}
+	return a.saveLastStatus()
+}
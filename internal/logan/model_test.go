@@ -0,0 +1,91 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Model(t *testing.T) {
+	a, err := _newSampleAnalyzer("Test_Model")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	testDir := filepath.Dir(a.DataDir)
+	logPath := a.LogPath
+	modelPath := testDir + "/model.json"
+	if err := a.ExportModel(modelPath); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	displayStrings := make(map[int64]string)
+	for groupId := range a.trans.lgs.alllg {
+		displayStrings[groupId] = a.trans.lgs.displayStrings[groupId]
+	}
+	termCounts := make(map[string]int)
+	for termId, count := range a.trans.te.counts {
+		if count > 0 {
+			termCounts[a.trans.te.id2term[termId]] = count
+		}
+	}
+	a.Close()
+
+	importedDir := testDir + "/imported"
+	if err := ImportModel(modelPath, importedDir); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := ImportModel(modelPath, importedDir); err == nil {
+		t.Errorf("imported into an existing dataDir")
+		return
+	}
+
+	b, err := LoadAnalyzer(importedDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	// the same ids and templates
+	if err := utils.GetGotExpErr("groups", len(b.trans.lgs.alllg), len(displayStrings)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for groupId, displayString := range displayStrings {
+		if err := utils.GetGotExpErr("displayString", b.trans.lgs.displayStrings[groupId], displayString); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	for term, count := range termCounts {
+		if err := utils.GetGotExpErr("count of "+term, b.trans.te.getCount(term), count); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	r, err := b.Check(logPath, 0, 1)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("passed", r.Passed, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// newer versions are refused
+	newer := testDir + "/newer.json"
+	if err := os.WriteFile(newer, []byte(`{"format": "logan-model", "version": 99}`), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if _, err := LoadModel(newer); err == nil {
+		t.Errorf("no error for a newer model version")
+	}
+}
@@ -5,17 +5,17 @@ import (
 )
 
 type patternTags struct {
-	db       *csvdb.CircuitDB
+	db       csvdb.Store
 	DataDir  string // directory for the pattern tags
 	testMode bool
 	tags     map[string]map[string]string // patternKeyId -> tagName -> tagValue
 }
 
-func newPatternTags(dataDir string, useGzip, testMode bool) (*patternTags, error) {
+func newPatternTags(dataDir, storage string, useGzip, testMode bool) (*patternTags, error) {
 	pt := &patternTags{
 		tags: make(map[string]map[string]string, 1000),
 	}
-	ptdb, err := csvdb.NewCircuitDB(dataDir, "patternTags",
+	ptdb, err := csvdb.NewStore(storage, dataDir, "patternTags",
 		tableDefs["patternTags"], 0, 0, 0, 0, useGzip)
 	if err != nil {
 		return nil, err
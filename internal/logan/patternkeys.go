@@ -7,9 +7,9 @@ import (
 	"goLogAnalyzer/pkg/utils"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 )
 
 type patternkey struct {
@@ -24,7 +24,8 @@ type pattern struct {
 }
 
 type patternkeys struct {
-	*csvdb.CircuitDB
+	csvdb.Store
+	DataDir              string
 	ac                   *utils.AC
 	regexRes             []*regexp.Regexp
 	regexPatternKeyPoses map[*regexp.Regexp]int
@@ -38,7 +39,7 @@ type patternkeys struct {
 }
 
 // Newpatternkeys creates a new patternkeys instance
-func newPatternKeys(dataDir string, regexes []string, useGzip bool, testMode bool) (*patternkeys, error) {
+func newPatternKeys(dataDir, storage string, regexes []string, useGzip bool, testMode bool) (*patternkeys, error) {
 
 	pk := &patternkeys{
 		ac:                   utils.NewAC(),
@@ -55,8 +56,11 @@ func newPatternKeys(dataDir string, regexes []string, useGzip bool, testMode boo
 	pk.testMode = testMode
 
 	// Compile regexes and store them in the patternkeys
-	for _, classRegex := range pk.regexes {
-		re := regexp.MustCompile(`` + classRegex)
+	for i, classRegex := range pk.regexes {
+		re, err := regexp.Compile(`` + classRegex)
+		if err != nil {
+			return nil, fmt.Errorf("patternKeyRegexes[%d]: %w", i, err)
+		}
 		pk.regexRes = append(pk.regexRes, re)
 		names := re.SubexpNames()
 		for i, name := range names {
@@ -75,14 +79,20 @@ func newPatternKeys(dataDir string, regexes []string, useGzip bool, testMode boo
 		return pk, nil
 	}
 
-	kgdb, err := csvdb.NewCircuitDB(dataDir, "patternkeys",
+	kgdb, err := csvdb.NewStore(storage, dataDir, "patternkeys",
 		tableDefs["patternKeys"], 0, 0, 0, 0, useGzip)
 	if err != nil {
 		return nil, err
 	}
-	pk.CircuitDB = kgdb
+	if err := kgdb.SetColumnTypes(columnTypes["patternKeys"]); err != nil {
+		return nil, err
+	}
+	pk.Store = kgdb
+	if dataDir != "" {
+		pk.DataDir = fmt.Sprintf("%s/patternkeys", dataDir)
+	}
 
-	pt, err := newPatternTags(dataDir, useGzip, testMode)
+	pt, err := newPatternTags(dataDir, storage, useGzip, testMode)
 	if err != nil {
 		return nil, fmt.Errorf("error creating pattern pt: %v", err)
 	}
@@ -126,6 +136,18 @@ func (pk *patternkeys) findAndRegister(line string) (string, map[string]string,
 	return patternKeyId, tags, matched, nil
 }
 
+// patternKeyId of the last regex matching line like findAndRegister without registering it
+func (pk *patternkeys) find(line string) string {
+	patternKeyId := ""
+	for _, re := range pk.regexRes {
+		ma := re.FindStringSubmatch(line)
+		if pos := pk.regexPatternKeyPoses[re]; len(ma) > 0 && pos >= 0 && pos < len(ma) {
+			patternKeyId = ma[pos]
+		}
+	}
+	return patternKeyId
+}
+
 func (pk *patternkeys) hasMatch(term []byte) bool {
 	// Check if the term matches any of the registered patternKeyIds
 	return len(pk.ac.MatchExact(term)) > 0
@@ -159,7 +181,11 @@ func (pk *patternkeys) flush() error {
 	}
 
 	// write the keygroup IDs to the file
-	if err := utils.WriteStringToFile(pk.idFilePath, pk.ac.GetPatterns()); err != nil {
+	idFilePath, err := csvdb.StagedPath(pk.idFilePath)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteStringToFile(idFilePath, pk.ac.GetPatterns()); err != nil {
 		return err
 	}
 
@@ -262,15 +288,6 @@ func (pk *patternkeys) load() error {
 }
 
 // values: ["patternKeyId"]
-func (pk *patternkeys) filterKeys(values []string) bool {
-	for _, keyId := range pk.searchKeyIds {
-		if keyId == values[0] {
-			return true
-		}
-	}
-	return false
-}
-
 func (pk *patternkeys) loadAll(searchKeyIds []string) error {
 	// init pk
 	pk.records = make(map[string][]patternkey, 10000)
@@ -281,14 +298,16 @@ func (pk *patternkeys) loadAll(searchKeyIds []string) error {
 	}
 
 	pk.searchKeyIds = nil
-	var f func(values []string) bool
+	var cond *csvdb.Cond
 	if searchKeyIds != nil {
 		pk.searchKeyIds = searchKeyIds
-		f = pk.filterKeys
-	} else {
-		f = nil
+		keyIds := make([]interface{}, len(searchKeyIds))
+		for i, keyId := range searchKeyIds {
+			keyIds[i] = keyId
+		}
+		cond = csvdb.In("patternKey", keyIds...)
 	}
-	rows, err := pk.SelectRows(f, nil, tableDefs["patternKeys"])
+	rows, err := pk.SelectWhere(cond, nil, tableDefs["patternKeys"])
 	if err != nil {
 		return err
 	}
@@ -385,116 +404,6 @@ func (pk *patternkeys) detectPatternsByFirstMatch() map[string](map[string]*patt
 	return patterns
 }
 
-/*
-show patterns by patternStr ordered by count descending
-example1)
-5 6 7 => total 2
-
-	1234: {startEpoch: 1, count: 2}
-	5678: {startEpoch: 25, count: 1}
-
-5 6 7 7 => total 1
-
-	1234: {startEpoch: 11, count: 1}
-
-5 => total 1
-
-	1234: {startEpoch: 30, count: 1}
-
-9 => total 1
-
-	5678: {startEpoch: 35, count: 1}
-*/
-func (pk *patternkeys) ShowPatternsByFirstMatch(minCount int,
-	lgs *logGroups) (map[string](map[string]*pattern), error) {
-	patterns := pk.detectPatternsByFirstMatch()
-	if patterns == nil {
-		return nil, nil
-	}
-
-	type patSum struct {
-		patternStr string
-		total      int
-	}
-
-	sums := make([]patSum, 0, len(patterns))
-	// calculate totals and filter by minCount
-	for pstr, sub := range patterns {
-		total := 0
-		for _, pat := range sub {
-			total += pat.count
-		}
-		if total < minCount {
-			delete(patterns, pstr)
-			continue
-		}
-		sums = append(sums, patSum{patternStr: pstr, total: total})
-	}
-
-	// selection sort sums by total desc
-	for i := 0; i < len(sums)-1; i++ {
-		maxIdx := i
-		for j := i + 1; j < len(sums); j++ {
-			if sums[j].total > sums[maxIdx].total {
-				maxIdx = j
-			}
-		}
-		if maxIdx != i {
-			sums[i], sums[maxIdx] = sums[maxIdx], sums[i]
-		}
-	}
-
-	for _, ps := range sums {
-		fmt.Printf("%s => total %d\n", ps.patternStr, ps.total)
-
-		// collect sub patterns for ordering
-		type subInfo struct {
-			keyId      string
-			startEpoch int64
-			count      int
-		}
-		subInfos := make([]subInfo, 0, len(patterns[ps.patternStr]))
-		for keyId, pat := range patterns[ps.patternStr] {
-			subInfos = append(subInfos, subInfo{keyId: keyId, startEpoch: pat.startEpoch, count: pat.count})
-		}
-		// sort subInfos by count desc
-		for i := 0; i < len(subInfos)-1; i++ {
-			maxIdx := i
-			for j := i + 1; j < len(subInfos); j++ {
-				if subInfos[j].count > subInfos[maxIdx].count {
-					maxIdx = j
-				}
-			}
-			if maxIdx != i {
-				subInfos[i], subInfos[maxIdx] = subInfos[maxIdx], subInfos[i]
-			}
-		}
-		for _, si := range subInfos {
-			ts := time.Unix(si.startEpoch, 0).Local().Format("2006/01/02 15:04:05")
-			fmt.Printf("%s: {startEpoch: %s, count: %d}\n", si.keyId, ts, si.count)
-		}
-		fmt.Println("---")
-
-		// print display strings for the pattern
-		for _, groupIdStr := range strings.Split(ps.patternStr, " ") {
-			groupId, err := strconv.ParseInt(groupIdStr, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("error parsing groupId %s: %v", groupIdStr, err)
-			}
-			displayString, ok := lgs.displayStrings[groupId]
-			if ok {
-				fmt.Printf("%s\n", displayString)
-			} else {
-				fmt.Printf("(not found for groupId %d)\n", groupId)
-			}
-		}
-		fmt.Println()
-		fmt.Println("--------------------------------------------------")
-	}
-
-	return patterns, nil
-}
-
 /*
 Count patternKeys in the patterns which are group of ordered logGroupIds
 example)
@@ -558,8 +467,14 @@ func (pk *patternkeys) detectPatternsByPatternKeys() map[string](map[string]*pat
 		relSet := make(map[string]struct{})
 		if pk.pt != nil {
 			tagStr := ""
-			for tagName, tagValue := range pk.pt.get(patternKeyId) {
-				if tagValue != "" {
+			tags := pk.pt.get(patternKeyId)
+			tagNames := make([]string, 0, len(tags))
+			for tagName := range tags {
+				tagNames = append(tagNames, tagName)
+			}
+			sort.Strings(tagNames)
+			for _, tagName := range tagNames {
+				if tagValue := tags[tagName]; tagValue != "" {
 					tagStr += fmt.Sprintf("%s:%s, ", tagName, tagValue)
 				}
 			}
@@ -593,52 +508,48 @@ func (pk *patternkeys) detectPatternsByPatternKeys() map[string](map[string]*pat
 
 }
 
-/*
-Show patterns by pt ordered by count descending
-If there are no pt, it will show "all" relation.
-example1)
-5 6 7 => total 3
-
-	1234: {startEpoch: 1, count: 2}
-	5678: {startEpoch: 25, count: 1}
-
-5 6 7 7 => total 1
-
-	1234: {startEpoch: 11, count: 1}
+// a pattern with its counts by relationKey
+type patternSummary struct {
+	patternStr string
+	total      int
+	startEpoch int64 // the earliest of the relations
+	relations  []relationSummary
+}
 
-7 8 9 => total 5
+type relationSummary struct {
+	relationKey string
+	startEpoch  int64
+	count       int
+}
 
-	all: {startEpoch: 15, count: 5}
+/*
+patterns with minCount or more in descending order of the totals,
+the earlier ones first among the same totals. patterns below minCount are deleted from patterns
 */
-func (pk *patternkeys) ShowPatternsByPatternsKeys(minCount int, lgs *logGroups, outDir string) (map[string](map[string]*pattern), error) {
-	patterns := pk.detectPatternsByPatternKeys()
-	if patterns == nil {
-		return nil, nil
-	}
-
-	// summarize totals and filter by minCount
-	type patSum struct {
-		patternStr string
-		total      int
-	}
-	sums := make([]patSum, 0, len(patterns))
+func rankPatterns(patterns map[string](map[string]*pattern), minCount int) []patternSummary {
+	sums := make([]patternSummary, 0, len(patterns))
 	for pstr, sub := range patterns {
 		total := 0
+		var startEpoch int64
 		for _, pat := range sub {
 			total += pat.count
+			if startEpoch == 0 || pat.startEpoch < startEpoch {
+				startEpoch = pat.startEpoch
+			}
 		}
 		if total < minCount {
 			delete(patterns, pstr)
 			continue
 		}
-		sums = append(sums, patSum{patternStr: pstr, total: total})
+		sums = append(sums, patternSummary{patternStr: pstr, total: total, startEpoch: startEpoch})
 	}
 
 	// selection sort by total desc
 	for i := 0; i < len(sums)-1; i++ {
 		maxIdx := i
 		for j := i + 1; j < len(sums); j++ {
-			if sums[j].total > sums[maxIdx].total {
+			if rankedBefore(sums[j].total, sums[j].startEpoch, sums[j].patternStr,
+				sums[maxIdx].total, sums[maxIdx].startEpoch, sums[maxIdx].patternStr) {
 				maxIdx = j
 			}
 		}
@@ -647,44 +558,17 @@ func (pk *patternkeys) ShowPatternsByPatternsKeys(minCount int, lgs *logGroups,
 		}
 	}
 
-	// output helper
-	outputLine := func(f *os.File, format string, a ...interface{}) {
-		if f != nil {
-			fmt.Fprintf(f, format+"\n", a...)
-		}
-		fmt.Printf(format+"\n", a...)
-	}
-
-	for i, ps := range sums {
-		f := (*os.File)(nil)
-		err := error(nil)
-		if outDir != "" {
-			// write to file
-			fpath := fmt.Sprintf("%s/pattern_%03d.txt", outDir, i+1)
-			f, err = os.Create(fpath)
-			if err != nil {
-				return nil, fmt.Errorf("error creating file %s: %v", fpath, err)
-			}
-			defer f.Close()
-		}
-
-		outputLine(f, "%s => total %d", ps.patternStr, ps.total)
-
-		// collect pt for ordering
-		type relInfo struct {
-			relationKey string
-			startEpoch  int64
-			count       int
-		}
-		relInfos := make([]relInfo, 0, len(patterns[ps.patternStr]))
-		for rk, pat := range patterns[ps.patternStr] {
-			relInfos = append(relInfos, relInfo{relationKey: rk, startEpoch: pat.startEpoch, count: pat.count})
+	for i := range sums {
+		relInfos := make([]relationSummary, 0, len(patterns[sums[i].patternStr]))
+		for rk, pat := range patterns[sums[i].patternStr] {
+			relInfos = append(relInfos, relationSummary{relationKey: rk, startEpoch: pat.startEpoch, count: pat.count})
 		}
 		// sort by count desc
 		for i := 0; i < len(relInfos)-1; i++ {
 			maxIdx := i
 			for j := i + 1; j < len(relInfos); j++ {
-				if relInfos[j].count > relInfos[maxIdx].count {
+				if rankedBefore(relInfos[j].count, relInfos[j].startEpoch, relInfos[j].relationKey,
+					relInfos[maxIdx].count, relInfos[maxIdx].startEpoch, relInfos[maxIdx].relationKey) {
 					maxIdx = j
 				}
 			}
@@ -692,26 +576,18 @@ func (pk *patternkeys) ShowPatternsByPatternsKeys(minCount int, lgs *logGroups,
 				relInfos[i], relInfos[maxIdx] = relInfos[maxIdx], relInfos[i]
 			}
 		}
-		for _, ri := range relInfos {
-			ts := time.Unix(ri.startEpoch, 0).Local().Format("2006/01/02 15:04:05")
-			outputLine(f, "%s: {startEpoch: %s, count: %d}", ri.relationKey, ts, ri.count)
-		}
-		outputLine(f, "---")
-
-		// print display strings for the pattern
-		for _, groupIdStr := range strings.Split(ps.patternStr, " ") {
-			groupId, err := strconv.ParseInt(groupIdStr, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("error parsing groupId %s: %v", groupIdStr, err)
-			}
-			if displayString, ok := lgs.displayStrings[groupId]; ok {
-				outputLine(f, "%s", displayString)
-			} else {
-				outputLine(f, "(not found for groupId %d)", groupId)
-			}
-		}
-		outputLine(f, "--------------------------------------------------")
+		sums[i].relations = relInfos
 	}
+	return sums
+}
 
-	return patterns, nil
+// the larger count first, then the earlier, then by the key not to depend on the map order
+func rankedBefore(count1 int, epoch1 int64, key1 string, count2 int, epoch2 int64, key2 string) bool {
+	if count1 != count2 {
+		return count1 > count2
+	}
+	if epoch1 != epoch2 {
+		return epoch1 < epoch2
+	}
+	return key1 < key2
 }
@@ -13,7 +13,7 @@ func Test_patternkeys_detectPatternsByFirstMatch(t *testing.T) {
 	}
 
 	reStr := `key=(?P<patternKey>\w+) .*`
-	kg, err := newPatternKeys(testDir, []string{reStr}, false, false)
+	kg, err := newPatternKeys(testDir, "", []string{reStr}, false, false)
 	if err != nil {
 		t.Errorf("Error creating patternkeys: %v", err)
 		return
@@ -87,7 +87,7 @@ func Test_patternkeys_detectPatternsByPatternKeys(t *testing.T) {
 	}
 
 	reStr := `key=(?P<patternKey>\w+) relation=(?P<relationKey>\w+) .*`
-	pk, err := newPatternKeys(testDir, []string{reStr}, false, false)
+	pk, err := newPatternKeys(testDir, "", []string{reStr}, false, false)
 	if err != nil {
 		t.Errorf("Error creating patternkeys: %v", err)
 		return
@@ -0,0 +1,155 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/filepointer"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+const (
+	cPhaseTerms  = "terms"
+	cPhaseGroups = "groups"
+
+	// lines between the checks of the clock
+	cProgressCheckLines = 1000
+	// how often the progress is shown on a terminal, and logged otherwise
+	cProgressTtyInterval = 500 * time.Millisecond
+	cProgressLogInterval = 10 * time.Second
+)
+
+/*
+progress shows how far Feed has read the logs in each pass.
+On a terminal it rewrites a single line, otherwise it logs a line with fields every cProgressLogInterval.
+The methods do nothing on nil, the progress not shown.
+*/
+type progress struct {
+	w          io.Writer
+	fd         int // of the terminal, -1 if w is not one
+	interval   time.Duration
+	phase      string
+	pass       int
+	start      time.Time
+	last       time.Time
+	startBytes int64
+	lines      int
+}
+
+func newProgress(w io.Writer) *progress {
+	p := &progress{w: w, fd: -1, interval: cProgressLogInterval}
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		p.fd = int(f.Fd())
+		p.interval = cProgressTtyInterval
+	}
+	return p
+}
+
+func (p *progress) begin(phase string, fp *filepointer.FilePointer) {
+	if p == nil {
+		return
+	}
+	p.phase = phase
+	p.pass = 1
+	if phase == cPhaseGroups {
+		p.pass = 2
+	}
+	p.start = time.Now()
+	p.last = p.start
+	p.startBytes = fp.ReadBytes()
+	p.lines = 0
+}
+
+// count a line up. found is the number of terms or groups found so far
+func (p *progress) line(fp *filepointer.FilePointer, found int) {
+	if p == nil {
+		return
+	}
+	p.lines++
+	if p.lines%cProgressCheckLines != 0 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	p.show(fp, found, now)
+}
+
+func (p *progress) show(fp *filepointer.FilePointer, found int, now time.Time) {
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	file := fp.CurrFileName()
+	if file == "" {
+		file = "stdin"
+	}
+	read, total := fp.ReadBytes(), fp.TotalBytes()
+	linesPerSec := float64(p.lines) / elapsed
+	// the pass reads the same bytes in about the same time from here
+	eta := time.Duration(-1)
+	if bytesPerSec := float64(read-p.startBytes) / elapsed; total > 0 && bytesPerSec > 0 {
+		eta = time.Duration(float64(total-read)/bytesPerSec) * time.Second
+	}
+
+	if p.fd < 0 {
+		fields := logrus.Fields{
+			"phase":         p.phase,
+			"file":          file,
+			"read_bytes":    read,
+			"total_bytes":   total,
+			"lines":         p.lines,
+			"lines_per_sec": int(linesPerSec),
+			p.phase:         found,
+		}
+		if eta >= 0 {
+			fields["eta"] = eta.String()
+		}
+		logrus.WithFields(fields).Info("feed progress")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s %d/2] %s %s", p.phase, p.pass, filepath.Base(file), formatBytes(read))
+	if total > 0 {
+		fmt.Fprintf(&sb, "/%s %d%%", formatBytes(total), read*100/total)
+	}
+	fmt.Fprintf(&sb, " %d lines/s %d %s", int(linesPerSec), found, p.phase)
+	if eta >= 0 {
+		fmt.Fprintf(&sb, " ETA %s", eta)
+	}
+	text := sb.String()
+	// a wrapped line would not be overwritten
+	if width, _, err := term.GetSize(p.fd); err == nil && width > 1 && len(text) >= width {
+		text = text[:width-1]
+	}
+	fmt.Fprintf(p.w, "\r\x1b[K%s", text)
+}
+
+// clear the line on a terminal
+func (p *progress) end() {
+	if p == nil || p.fd < 0 {
+		return
+	}
+	fmt.Fprint(p.w, "\r\x1b[K")
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,67 @@
+package logan
+
+import (
+	"bytes"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func Test_formatBytes(t *testing.T) {
+	for n, exp := range map[int64]string{0: "0B", 1023: "1023B", 1536: "1.5KB", 5 << 30: "5.0GB"} {
+		if err := utils.GetGotExpErr(fmt.Sprint(n), formatBytes(n), exp); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+}
+
+func Test_Analyzer_progress(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_progress")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/progress.log"
+	var sb strings.Builder
+	for i := 0; i < 2*cProgressCheckLines; i++ {
+		fmt.Fprintf(&sb, "2024-10-01T00:00:%02d] user%d logged in\n", i%60, i%3)
+	}
+	if err := os.WriteFile(conf.LogPath, []byte(sb.String()), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+
+	// not a terminal, so the progress is logged
+	var w, logs bytes.Buffer
+	a.ShowProgress(&w)
+	a.progress.interval = 0
+	logrus.SetOutput(&logs)
+	defer logrus.SetOutput(os.Stderr)
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if w.Len() > 0 {
+		t.Errorf("progress written to a non terminal: %s", w.String())
+		return
+	}
+	for _, exp := range []string{"phase=terms", "phase=groups",
+		fmt.Sprintf("read_bytes=%d", len(sb.String())), "groups=3", "eta=0s"} {
+		if !strings.Contains(logs.String(), exp) {
+			t.Errorf("no %s in\n%s", exp, logs.String())
+			return
+		}
+	}
+}
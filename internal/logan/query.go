@@ -0,0 +1,230 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"strconv"
+	"strings"
+)
+
+/*
+LogGroupsQuery selects the log groups of LogGroups like the options of OutputLogGroups.
+From and To limit the counts to [From, To), 0 for no limit on that side.
+N is CDefaultN if 0, and no limit if negative.
+*/
+type LogGroupsQuery struct {
+	N             int
+	Search        string
+	Exclude       string
+	MinLastUpdate int64
+	From          int64
+	To            int64
+	MinCount      int
+	MaxCount      int
+	Asc           bool
+}
+
+// LogGroup is a log group with its count in the range of LogGroupsQuery
+type LogGroup struct {
+	GroupId       int64
+	Count         int
+	Score         float64
+	DisplayString string
+	LastMessage   string
+	Created       int64
+	Updated       int64
+	Sources       map[string]int // counts by source. nil without sourceFrom
+}
+
+// HistoryPoint is the count of a log group in a bucket of the timeline
+type HistoryPoint struct {
+	Epoch int64 // the start of the bucket
+	Secs  int64 // the width of the bucket. rolled up buckets are wider than unitSecs
+	Count int
+}
+
+// LogGroupHistory is the non zero counts of a log group along the timeline
+type LogGroupHistory struct {
+	GroupId       int64
+	DisplayString string
+	Points        []HistoryPoint
+}
+
+// PatternCount is how many times a pattern appeared for a pattern key, or a relation key
+type PatternCount struct {
+	Key   string
+	Start int64
+	Count int
+}
+
+// Pattern is a sequence of log groups repeated in the lines of the pattern keys
+type Pattern struct {
+	GroupIds       []int64
+	DisplayStrings []string // "" for the log groups no longer kept
+	Total          int
+	Counts         []PatternCount // in descending order of the counts
+}
+
+// ParsedLine is how ParseLine parsed and grouped a line
+type ParsedLine struct {
+	Epoch          int64 // 0 if the timestamp is not parsed
+	Message        string
+	GroupId        int64
+	DisplayString  string
+	PatternKey     string
+	PatternMatched bool
+	Tags           map[string]string
+}
+
+/*
+LogGroups returns the log groups of dataDir selected by q in the order OutputLogGroups writes them.
+It does not feed.
+*/
+func (a *Analyzer) LogGroups(q LogGroupsQuery) ([]LogGroup, error) {
+	if q.From > 0 && q.To > 0 && q.From >= q.To {
+		return nil, fmt.Errorf("from %s must be before to %s", utils.EpochToString(q.From), utils.EpochToString(q.To))
+	}
+	if err := a.trans.loadSpilled(); err != nil {
+		return nil, err
+	}
+	counts, err := a.trans.getRangeCounts(q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	src := a.trans.src
+	if src != nil && (q.From > 0 || q.To > 0) {
+		spans := a.trans.lgs.historySpans
+		src, err = src.inRange(q.From, q.To, func(pos int64) int64 {
+			return spanWidth(spans, pos, a.UnitSecs)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	N := q.N
+	if N == 0 {
+		N = CDefaultN
+	}
+	// the search strings replace the filters of the lines until they are set back
+	defer a.trans._setFilters(a.SearchRegex, a.ExludeRegex)
+	groupIds := a.trans.getTopNGroupIds(max(N, 0), q.MinLastUpdate, q.Search, q.Exclude,
+		q.MinCount, q.MaxCount, q.Asc, counts)
+
+	groups := make([]LogGroup, len(groupIds))
+	for i, groupId := range groupIds {
+		groups[i] = a.trans.logGroup(groupId, counts[groupId], src)
+	}
+	return groups, nil
+}
+
+func (tr *trans) logGroup(groupId int64, count int, src *logGroupSources) LogGroup {
+	lg := tr.lgs.alllg[groupId]
+	g := LogGroup{
+		GroupId:       groupId,
+		Count:         count,
+		Score:         lg.rareScore,
+		DisplayString: lg.displayString,
+		LastMessage:   tr.lgs.lastMessages[groupId],
+		Created:       lg.created,
+		Updated:       lg.updated,
+	}
+	if src != nil {
+		g.Sources = make(map[string]int, len(src.counts[groupId]))
+		for source, n := range src.counts[groupId] {
+			g.Sources[source] = n
+		}
+	}
+	return g
+}
+
+// LogGroupHistory returns the counts of groupId in [from, to). 0 means no limit on that side
+func (a *Analyzer) LogGroupHistory(groupId, from, to int64) (*LogGroupHistory, error) {
+	if err := a.trans.loadSpilled(); err != nil {
+		return nil, err
+	}
+	lg := a.trans.lgs.alllg[groupId]
+	if lg == nil {
+		return nil, fmt.Errorf("log group %d not found", groupId)
+	}
+	lgsh, err := a.trans.getLogGroupsHistory([]int64{groupId}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	lgsh.clip(from, to)
+
+	h := &LogGroupHistory{GroupId: groupId, DisplayString: lg.displayString,
+		Points: make([]HistoryPoint, 0)}
+	for j, epoch := range lgsh.timeline {
+		if count := lgsh.counts[0][j]; count > 0 {
+			h.Points = append(h.Points, HistoryPoint{Epoch: epoch, Secs: lgsh.widths[j], Count: count})
+		}
+	}
+	return h, nil
+}
+
+/*
+Patterns returns the patterns of minCnt or more in descending order of the totals.
+mode is "firstMatch" or "relations". It does not feed.
+*/
+func (a *Analyzer) Patterns(minCnt int, mode string) ([]Pattern, error) {
+	if err := a.trans.loadSpilled(); err != nil {
+		return nil, err
+	}
+	pk := a.trans.pk
+	var patterns map[string](map[string]*pattern)
+	switch mode {
+	case "firstMatch":
+		if pk != nil {
+			patterns = pk.detectPatternsByFirstMatch()
+		}
+	case "relations":
+		if pk != nil {
+			patterns = pk.detectPatternsByPatternKeys()
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %s for patterns", mode)
+	}
+
+	result := make([]Pattern, 0)
+	for _, ps := range rankPatterns(patterns, minCnt) {
+		p := Pattern{Total: ps.total}
+		for _, groupIdStr := range strings.Split(ps.patternStr, " ") {
+			groupId, err := strconv.ParseInt(groupIdStr, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing groupId %s: %v", groupIdStr, err)
+			}
+			p.GroupIds = append(p.GroupIds, groupId)
+			p.DisplayStrings = append(p.DisplayStrings, a.trans.lgs.displayStrings[groupId])
+		}
+		for _, rs := range ps.relations {
+			p.Counts = append(p.Counts, PatternCount{Key: rs.relationKey, Start: rs.startEpoch, Count: rs.count})
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+/*
+ParseLine registers line like Feed and returns how it was parsed and grouped,
+to try logFormat and the other settings on a line.
+*/
+func (a *Analyzer) ParseLine(line string) (*ParsedLine, error) {
+	groupId, err := a.trans.lineToLogGroup(line, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	message, updated, _, err := a.trans.parseLine(line, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &ParsedLine{Epoch: updated, Message: message, GroupId: groupId,
+		DisplayString: a.trans.lgs.displayStrings[groupId]}
+	if a.trans.pk != nil {
+		p.PatternKey, p.Tags, p.PatternMatched, err = a.trans.pk.findAndRegister(message)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,139 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Analyzer_lateLines(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_lateLines")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/late.log"
+	data := "2024-10-01T00:00:00] disk full on sda\n" +
+		"2024-10-02T00:00:00] disk full on sda\n" +
+		// within reorderWindow, one unitSecs by default
+		"2024-10-01T12:00:00] disk full on sda\n" +
+		"2024-10-02T01:00:00] disk full on sda\n" +
+		// beyond it
+		"2024-09-29T00:00:00] disk full on sda\n" +
+		"2024-10-03T00:00:00] disk full on sda\n"
+	if err := os.WriteFile(conf.LogPath, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("late lines", a.lateLines, 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	countHistory, err := b.trans.loadLogGroupHistory(nil, 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", len(b.trans.lgs.alllg), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	day := func(d int) int64 {
+		return time.Date(2024, 10, d, 0, 0, 0, 0, time.UTC).Unix()
+	}
+	for groupId := range b.trans.lgs.alllg {
+		for pos, exp := range map[int64]int{day(1): 2, day(2): 3, day(3): 1} {
+			if err := utils.GetGotExpErr(time.Unix(pos, 0).UTC().String(), countHistory[groupId][pos], exp); err != nil {
+				t.Errorf("%v: %v", err, countHistory[groupId])
+				return
+			}
+		}
+	}
+}
+
+func Test_Analyzer_lateLines_window(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_lateLines_window")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.ReorderWindow = 2 * 86400
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/late.log"
+	data := "2024-10-05T00:00:00] disk full on sda\n" +
+		// just as late as reorderWindow
+		"2024-10-03T00:00:00] disk full on sda\n" +
+		// a second later than it
+		"2024-10-02T23:59:59] disk full on sda\n" +
+		// late in the next block, within reorderWindow of it
+		"2024-10-06T00:00:00] disk full on sda\n" +
+		"2024-10-04T00:00:00] disk full on sda\n" +
+		// older than everything fed
+		"2024-09-01T00:00:00] disk full on sda\n"
+	if err := os.WriteFile(conf.LogPath, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("late lines", a.lateLines, 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dataDir := a.DataDir
+	a.Close()
+
+	b, err := LoadAnalyzer(dataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	countHistory, err := b.trans.loadLogGroupHistory(nil, 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	day := func(d int) int64 {
+		return time.Date(2024, 10, d, 0, 0, 0, 0, time.UTC).Unix()
+	}
+	for groupId := range b.trans.lgs.alllg {
+		exps := map[int64]int{day(2): 0, day(3): 1, day(4): 1, day(5): 2, day(6): 2}
+		for pos, exp := range exps {
+			if err := utils.GetGotExpErr(time.Unix(pos, 0).UTC().String(), countHistory[groupId][pos], exp); err != nil {
+				t.Errorf("%v: %v", err, countHistory[groupId])
+				return
+			}
+		}
+		if err := utils.GetGotExpErr("buckets", len(countHistory[groupId]), 4); err != nil {
+			t.Errorf("%v: %v", err, countHistory[groupId])
+			return
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"html/template"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cReportChartWidth  = 720
+	cReportChartHeight = 120
+	cReportMaxPatterns = 20
+)
+
+type reportGroup struct {
+	GroupId       int64
+	Count         int
+	RareScore     float64
+	Created       string
+	Updated       string
+	DisplayString string
+	LastMessage   string
+	Anomalies     []string
+	Chart         template.HTML
+}
+
+type reportPattern struct {
+	Total     int
+	Relations []string
+	Steps     []reportStep
+}
+
+type reportStep struct {
+	GroupId       int64
+	DisplayString string
+}
+
+type reportData struct {
+	Title     string
+	Generated string
+	Range     string
+	DataDir   string
+	Groups    []reportGroup
+	Patterns  []reportPattern
+}
+
+/*
+Report writes a single HTML file with the top N groups, their history charts with
+anomalies marked and the patterns by pattern keys.
+Everything is inlined so the file can be sent by mail.
+from and to limit the counts and the history like OutputLogGroups.
+*/
+func (a *Analyzer) Report(N int, path string, from, to int64,
+	stdThreshold, minOccurrences float64) error {
+	if path == "" {
+		return fmt.Errorf("output file is mandatory for report")
+	}
+	if from > 0 && to > 0 && from >= to {
+		return fmt.Errorf("from %s must be before to %s", utils.EpochToString(from), utils.EpochToString(to))
+	}
+	if err := a.trans.loadSpilled(); err != nil {
+		return err
+	}
+	if N == 0 {
+		N = CDefaultN
+	}
+	if stdThreshold == 0 {
+		stdThreshold = CDefaultStdThreshold
+	}
+	if minOccurrences == 0 {
+		minOccurrences = CDefaultMinOccurrences
+	}
+
+	counts, err := a.trans.getRangeCounts(from, to)
+	if err != nil {
+		return err
+	}
+	groupIds := a.trans.getTopNGroupIds(N, 0, "", "", 0, 0, false, counts)
+
+	data := reportData{
+		Title:     "logan report",
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Range:     "all",
+		DataDir:   a.DataDir,
+	}
+	if from > 0 || to > 0 {
+		data.Range = fmt.Sprintf("%s - %s", rangeEndToString(from), rangeEndToString(to))
+	}
+
+	lgs := a.trans.lgs
+	for _, groupId := range groupIds {
+		lg := lgs.alllg[groupId]
+		data.Groups = append(data.Groups, reportGroup{
+			GroupId:       groupId,
+			Count:         counts[groupId],
+			RareScore:     lg.rareScore,
+			Created:       utils.EpochToString(lg.created),
+			Updated:       utils.EpochToString(lg.updated),
+			DisplayString: lg.displayString,
+			LastMessage:   lgs.lastMessages[groupId],
+		})
+	}
+
+	if len(groupIds) > 0 {
+		lgsh, err := a.trans.getLogGroupsHistory(groupIds, from, to)
+		if err != nil {
+			return err
+		}
+		lgsh.clip(from, to)
+		for i := range data.Groups {
+			g := &data.Groups[i]
+			values := lgsh.counts[lgsh.groupIdsMap[g.GroupId]]
+			anomalies := lgsh.detectAnomaly(g.GroupId, stdThreshold, minOccurrences, 0)
+			for _, epoch := range anomalies {
+				g.Anomalies = append(g.Anomalies, utils.EpochToString(epoch))
+			}
+			g.Chart = svgChart(lgsh.timeline, values, anomalies)
+		}
+	}
+
+	if a.trans.pk != nil {
+		if patterns := a.trans.pk.detectPatternsByPatternKeys(); patterns != nil {
+			for i, ps := range rankPatterns(patterns, 1) {
+				if i >= cReportMaxPatterns {
+					break
+				}
+				data.Patterns = append(data.Patterns, newReportPattern(ps, lgs))
+			}
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer f.Close()
+	if err := reportTemplate.Execute(f, data); err != nil {
+		return err
+	}
+	logrus.Infof("report written to %s", path)
+	return f.Close()
+}
+
+func rangeEndToString(epoch int64) string {
+	if epoch == 0 {
+		return ""
+	}
+	return utils.EpochToString(epoch)
+}
+
+func newReportPattern(ps patternSummary, lgs *logGroups) reportPattern {
+	rp := reportPattern{Total: ps.total}
+	for _, ri := range ps.relations {
+		rp.Relations = append(rp.Relations, fmt.Sprintf("%s: {startEpoch: %s, count: %d}",
+			ri.relationKey, utils.EpochToString(ri.startEpoch), ri.count))
+	}
+	for _, groupIdStr := range strings.Fields(ps.patternStr) {
+		groupId, err := strconv.ParseInt(groupIdStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		displayString, ok := lgs.displayStrings[groupId]
+		if !ok {
+			displayString = fmt.Sprintf("(not found for groupId %d)", groupId)
+		}
+		rp.Steps = append(rp.Steps, reportStep{GroupId: groupId, DisplayString: displayString})
+	}
+	return rp
+}
+
+// bar chart of values along timeline as inline SVG. anomalies are marked red
+func svgChart(timeline []int64, values []int, anomalies []int64) template.HTML {
+	if len(timeline) == 0 {
+		return ""
+	}
+	isAnomaly := make(map[int64]bool, len(anomalies))
+	for _, epoch := range anomalies {
+		isAnomaly[epoch] = true
+	}
+	maxv := 1
+	for _, v := range values {
+		maxv = max(maxv, v)
+	}
+
+	const labelHeight = 16
+	w, h := float64(cReportChartWidth), float64(cReportChartHeight-labelHeight)
+	barWidth := w / float64(len(timeline))
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg width="%d" height="%d" viewBox="0 0 %d %d">`,
+		cReportChartWidth, cReportChartHeight, cReportChartWidth, cReportChartHeight)
+	fmt.Fprintf(&sb, `<line x1="0" y1="%.1f" x2="%.1f" y2="%.1f" class="axis"/>`, h, w, h)
+	for j, epoch := range timeline {
+		v := 0
+		if j < len(values) {
+			v = values[j]
+		}
+		class := "bar"
+		if isAnomaly[epoch] {
+			class = "anomaly"
+		}
+		bh := h * float64(v) / float64(maxv)
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" class="%s"><title>%s %d</title></rect>`,
+			float64(j)*barWidth, h-bh, max(barWidth-1, 0.5), bh, class,
+			template.HTMLEscapeString(utils.EpochToString(epoch)), v)
+	}
+	fmt.Fprintf(&sb, `<text x="0" y="%d" class="label">%s</text>`, cReportChartHeight-2,
+		template.HTMLEscapeString(utils.EpochToString(timeline[0])))
+	fmt.Fprintf(&sb, `<text x="%.1f" y="%d" class="label" text-anchor="end">%s</text>`, w, cReportChartHeight-2,
+		template.HTMLEscapeString(utils.EpochToString(timeline[len(timeline)-1])))
+	fmt.Fprintf(&sb, `<text x="%.1f" y="10" class="label" text-anchor="end">max %d</text>`, w, maxv)
+	sb.WriteString(`</svg>`)
+	return template.HTML(sb.String())
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+td.num { text-align: right; }
+code, pre { font-family: monospace; font-size: 90%; white-space: pre-wrap; word-break: break-all; }
+section { margin-bottom: 2em; }
+.bar { fill: #4a7ab5; }
+.anomaly { fill: #d9534f; }
+.axis { stroke: #999; }
+.label { font-size: 10px; fill: #666; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">dataDir: {{.DataDir}}<br>range: {{.Range}}<br>generated: {{.Generated}}</p>
+
+<h2>Top groups</h2>
+<table>
+<tr><th>#</th><th>groupId</th><th>count</th><th>rareScore</th><th>updated</th><th>anomalies</th><th>text</th></tr>
+{{range $i, $g := .Groups}}<tr><td class="num">{{inc $i}}</td><td><a href="#g{{$g.GroupId}}">{{$g.GroupId}}</a></td><td class="num">{{$g.Count}}</td><td class="num">{{printf "%.2f" $g.RareScore}}</td><td>{{$g.Updated}}</td><td class="num">{{len $g.Anomalies}}</td><td><code>{{$g.DisplayString}}</code></td></tr>
+{{end}}</table>
+
+<h2>History</h2>
+{{range .Groups}}<section id="g{{.GroupId}}">
+<h3>{{.GroupId}}: {{.Count}}</h3>
+<p><code>{{.DisplayString}}</code></p>
+{{.Chart}}
+{{if .Anomalies}}<p>anomalies: {{range .Anomalies}}{{.}} {{end}}</p>{{end}}
+<p class="meta">created: {{.Created}} updated: {{.Updated}}</p>
+{{if .LastMessage}}<pre>{{.LastMessage}}</pre>{{end}}
+</section>
+{{end}}
+{{if .Patterns}}<h2>Patterns</h2>
+{{range $i, $p := .Patterns}}<section>
+<h3>pattern {{inc $i}}: total {{$p.Total}}</h3>
+<ul>{{range $p.Relations}}<li>{{.}}</li>{{end}}</ul>
+<table>
+<tr><th>step</th><th>groupId</th><th>template</th></tr>
+{{range $j, $s := $p.Steps}}<tr><td class="num">{{inc $j}}</td><td>{{$s.GroupId}}</td><td><code>{{$s.DisplayString}}</code></td></tr>
+{{end}}</table>
+</section>
+{{end}}{{end}}
+</body>
+</html>
+`))
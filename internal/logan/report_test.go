@@ -0,0 +1,65 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Report(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Report")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.UnitSecs = 3600
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	path := filepath.Dir(conf.DataDir) + "/report.html"
+	if err := a.Report(3, path, 0, 0, 0, 0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	html := string(b)
+	if err := utils.GetGotExpErr("charts", strings.Count(html, "<svg"), 3); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// displayStrings are escaped
+	if !strings.Contains(html, "&lt;coM3&gt;") {
+		t.Errorf("displayStrings are not escaped")
+	}
+	// no external assets
+	for _, s := range []string{"http:", "https:", "src="} {
+		if strings.Contains(html, s) {
+			t.Errorf("report refers %s", s)
+		}
+	}
+}
+
+func Test_svgChart(t *testing.T) {
+	chart := string(svgChart([]int64{0, 3600, 7200}, []int{1, 10, 1}, []int64{3600}))
+	if err := utils.GetGotExpErr("bars", strings.Count(chart, "<rect"), 3); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := utils.GetGotExpErr("anomalies", strings.Count(chart, `class="anomaly"`), 1); err != nil {
+		t.Errorf("%v", err)
+	}
+}
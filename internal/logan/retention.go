@@ -0,0 +1,214 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+/*
+RetentionTier keeps logGroups history in blocks of UnitSecs for Keep units.
+The first tier is the resolution logs are analyzed in.
+Blocks older than Keep are rolled up into the next tier and dropped from the last one.
+
+	retention: [{unitSecs: 3600, keep: 168}, {unitSecs: 86400, keep: 365}]
+*/
+type RetentionTier struct {
+	UnitSecs int64 `json:"unit_secs" yaml:"unitSecs"`
+	Keep     int64 `json:"keep" yaml:"keep"`
+}
+
+func checkRetention(tiers []RetentionTier) error {
+	for i, tier := range tiers {
+		if tier.UnitSecs <= 0 || tier.Keep <= 0 {
+			return fmt.Errorf("retention[%d]: unitSecs and keep must be positive", i)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := tiers[i-1].UnitSecs
+		if tier.UnitSecs <= prev || tier.UnitSecs%prev != 0 {
+			return fmt.Errorf("retention[%d]: unitSecs %d must be a multiple of %d",
+				i, tier.UnitSecs, prev)
+		}
+	}
+	return nil
+}
+
+// rolled up logGroups are stored in logGroups_<unitSecs>
+func getRollupStoreName(unitSecs int64) string {
+	return fmt.Sprintf("logGroups_%d", unitSecs)
+}
+
+// names of rollup stores existing in dataDir
+func getRollupStoreNames(dataDir string) ([]string, error) {
+	paths, err := filepath.Glob(fmt.Sprintf("%s/logGroups_*", dataDir))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, filepath.Base(path))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// rollupTier is a logGroups store with one block per unitSecs
+type rollupTier struct {
+	csvdb.Store
+	unitSecs int64
+	currPos  int64
+	current  map[int64]*logGroup
+}
+
+/*
+newRollupTiers opens stores of tiers[1:].
+Rows of the expired blocks of each store are rolled up into the next tier.
+*/
+func newRollupTiers(lgdb csvdb.Store, dataDir, storage string,
+	tiers []RetentionTier, useGzip bool) ([]*rollupTier, error) {
+	if len(tiers) < 2 {
+		return nil, nil
+	}
+	rts := make([]*rollupTier, 0, len(tiers)-1)
+	prev := lgdb
+	for _, tier := range tiers[1:] {
+		db, err := csvdb.NewStore(storage, dataDir, getRollupStoreName(tier.UnitSecs),
+			tableDefs["logGroups"], int(tier.Keep)+1, 0, tier.Keep, tier.UnitSecs, useGzip)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.SetColumnTypes(columnTypes["logGroups"]); err != nil {
+			return nil, err
+		}
+		rt := &rollupTier{Store: db, unitSecs: tier.UnitSecs}
+		if err := rt.load(); err != nil {
+			return nil, err
+		}
+		prev.SetExpireHandler(rt.add)
+		prev = db
+		rts = append(rts, rt)
+	}
+	return rts, nil
+}
+
+// load the current block
+func (rt *rollupTier) load() error {
+	rt.current = make(map[int64]*logGroup)
+	if rt.CountFromStatusTable(nil) <= 0 {
+		return nil
+	}
+	if err := rt.LoadCircuitDBStatus(); err != nil {
+		return err
+	}
+	rows, err := rt.SelectFromCurrentTable(nil, tableDefs["logGroups"])
+	if err != nil || rows == nil {
+		return err
+	}
+	return scanLogGroupRows(rows, func(groupId int64, lg *logGroup) {
+		rt.currPos = lg.retentionPos
+		rt.current[groupId] = lg
+	})
+}
+
+// add rows of an expired block of the previous tier
+func (rt *rollupTier) add(rows csvdb.RowScanner) error {
+	byPos := make(map[int64]map[int64]*logGroup)
+	if err := scanLogGroupRows(rows, func(groupId int64, lg *logGroup) {
+		pos := lg.retentionPos / rt.unitSecs * rt.unitSecs
+		// late rows are added to the current block
+		if pos < rt.currPos {
+			pos = rt.currPos
+		}
+		if _, ok := byPos[pos]; !ok {
+			byPos[pos] = make(map[int64]*logGroup)
+		}
+		mergeLogGroup(byPos[pos], groupId, lg)
+	}); err != nil {
+		return err
+	}
+
+	poses := make([]int64, 0, len(byPos))
+	for pos := range byPos {
+		poses = append(poses, pos)
+	}
+	sort.Slice(poses, func(i, j int) bool { return poses[i] < poses[j] })
+	for _, pos := range poses {
+		if rt.currPos > 0 && pos > rt.currPos {
+			if err := rt.NextBlock(pos); err != nil {
+				return err
+			}
+			rt.current = make(map[int64]*logGroup)
+		}
+		rt.currPos = pos
+		for groupId, lg := range byPos[pos] {
+			lg.retentionPos = pos
+			mergeLogGroup(rt.current, groupId, lg)
+		}
+		if err := rt.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rt *rollupTier) flush() error {
+	for groupId, lg := range rt.current {
+		if err := rt.InsertRow(tableDefs["logGroups"],
+			groupId, lg.retentionPos, lg.count, lg.created, lg.updated); err != nil {
+			return err
+		}
+	}
+	if err := rt.FlushOverwriteCurrentTable(); err != nil {
+		return err
+	}
+	return rt.UpdateBlockStatus(false)
+}
+
+// sum counts and widen created/updated of groupId in lgmap
+func mergeLogGroup(lgmap map[int64]*logGroup, groupId int64, lg *logGroup) {
+	cur, ok := lgmap[groupId]
+	if !ok {
+		cur = &logGroup{retentionPos: lg.retentionPos, created: lg.created, updated: lg.updated}
+		lgmap[groupId] = cur
+	}
+	cur.count += lg.count
+	if lg.created > 0 && (cur.created == 0 || lg.created < cur.created) {
+		cur.created = lg.created
+	}
+	if lg.updated > cur.updated {
+		cur.updated = lg.updated
+	}
+}
+
+// call f for each logGroups row
+func scanLogGroupRows(rows csvdb.RowScanner, f func(groupId int64, lg *logGroup)) error {
+	for rows.Next() {
+		var groupIdstr string
+		lg := new(logGroup)
+		if err := rows.Scan(&groupIdstr, &lg.retentionPos, &lg.count,
+			&lg.created, &lg.updated); err != nil {
+			return err
+		}
+		groupId, err := strconv.ParseInt(groupIdstr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("error parsing %s to int64", groupIdstr)
+		}
+		f(groupId, lg)
+	}
+	return nil
+}
+
+// stores of logGroups and their unitSecs from the oldest resolution
+func (lgs *logGroups) getHistoryStores(unitSecs int64) ([]csvdb.Store, []int64) {
+	stores := make([]csvdb.Store, 0, len(lgs.tiers)+1)
+	unitSecsList := make([]int64, 0, len(lgs.tiers)+1)
+	for i := len(lgs.tiers) - 1; i >= 0; i-- {
+		stores = append(stores, lgs.tiers[i].Store)
+		unitSecsList = append(unitSecsList, lgs.tiers[i].unitSecs)
+	}
+	return append(stores, lgs.Store), append(unitSecsList, unitSecs)
+}
@@ -0,0 +1,154 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Retention(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Retention")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// 6 hours in hourly blocks, then daily blocks
+	conf.Retention = []RetentionTier{{UnitSecs: 3600, Keep: 6}, {UnitSecs: 86400, Keep: 30}}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if _, err := os.Stat(a.DataDir + "/" + getRollupStoreName(86400)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	_checkHistory := func(a *Analyzer, title string) {
+		counts := make(map[int64]int)
+		totalCount := 0
+		for groupId, lg := range a.trans.lgs.alllg {
+			counts[groupId] = lg.count
+			totalCount += lg.count
+		}
+		if err := utils.GetGotExpErr(title+" total count", totalCount, 34); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		lgsh, err := a.trans.getLogGroupsHistory(nil, 0, 0)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr(title+" spans", len(a.trans.lgs.historySpans), 2); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		// rolled up history still sums up to the group counts
+		for i, groupId := range lgsh.groupIds {
+			sum := 0
+			for _, cnt := range lgsh.counts[i] {
+				sum += cnt
+			}
+			if err := utils.GetGotExpErr(title+" history", sum, counts[groupId]); err != nil {
+				t.Errorf("%v groupId=%d", err, groupId)
+				return
+			}
+		}
+	}
+	_checkHistory(a, "fed")
+	a.Close()
+
+	a, err = LoadAnalyzer(conf.DataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	_checkHistory(a, "loaded")
+	a.Close()
+
+	problems, err := Fsck(conf.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+
+	if err := checkRetention([]RetentionTier{{UnitSecs: 3600, Keep: 6}, {UnitSecs: 5000, Keep: 3}}); err == nil {
+		t.Errorf("unitSecs not a multiple of the previous tier must be an error")
+	}
+}
+
+func Test_Retention_tierBoundary(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Retention_tierBoundary")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// 2 lines an hour from 18:00 to 05:00 of the next day, across the day and the 6 hourly blocks kept
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/boundary.log"
+	start := time.Date(2024, 10, 1, 18, 0, 0, 0, time.UTC)
+	var sb strings.Builder
+	for h := 0; h < 12; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour).Format("2006-01-02T15:04:05")
+		sb.WriteString(ts + "] disk full on sda\n")
+		sb.WriteString(ts + "] disk full on sdb\n")
+	}
+	if err := os.WriteFile(conf.LogPath, []byte(sb.String()), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.Retention = []RetentionTier{{UnitSecs: 3600, Keep: 6}, {UnitSecs: 86400, Keep: 30}}
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.Close()
+
+	a, err = LoadAnalyzer(conf.DataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	lgsh, err := a.trans.getLogGroupsHistory(nil, 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("spans", len(a.trans.lgs.historySpans), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// each line is in one bucket, rolled up or hourly, and in the day it was logged
+	day2 := time.Date(2024, 10, 2, 0, 0, 0, 0, time.UTC).Unix()
+	days := make(map[bool]int)
+	for i := range lgsh.groupIds {
+		for j, cnt := range lgsh.counts[i] {
+			days[lgsh.timeline[j] >= day2] += cnt
+		}
+	}
+	if err := utils.GetGotExpErr("lines of day 1", days[false], 12); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("lines of day 2", days[true], 12); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
@@ -0,0 +1,83 @@
+package logan
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+const cSketchDepth = 4
+
+/*
+countMinSketch counts terms in a fixed size of memory.
+The estimates take off the counts of the other terms expected in the counters of each row,
+count-mean-min, so that rare terms don't come back with the counts of the many others
+sharing their counters.
+*/
+type countMinSketch struct {
+	width  uint64
+	counts []uint32 // cSketchDepth rows of width counters
+	total  uint64   // added to each row
+}
+
+func newCountMinSketch(width int) *countMinSketch {
+	if width < 2 {
+		width = 2
+	}
+	return &countMinSketch{
+		width:  uint64(width),
+		counts: make([]uint32, cSketchDepth*width),
+	}
+}
+
+// the counter of term in each row, by double hashing
+func (cms *countMinSketch) indexes(term string) [cSketchDepth]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(term))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	var idx [cSketchDepth]uint64
+	for i := range idx {
+		idx[i] = uint64(i)*cms.width + (h1+uint64(i)*h2)%cms.width
+	}
+	return idx
+}
+
+func (cms *countMinSketch) add(term string, count int) {
+	if count <= 0 {
+		return
+	}
+	cms.total += uint64(count)
+	for _, i := range cms.indexes(term) {
+		if c := uint64(cms.counts[i]) + uint64(count); c < math.MaxUint32 {
+			cms.counts[i] = uint32(c)
+		} else {
+			cms.counts[i] = math.MaxUint32
+		}
+	}
+}
+
+/*
+the median of the counters less the noise, up to the minimum of them.
+Counts within 3 sigmas of the mean of the counters can't be told from the noise and are 0
+*/
+func (cms *countMinSketch) estimate(term string) int {
+	minCount := float64(math.MaxUint32)
+	var ests [cSketchDepth]float64
+	for r, i := range cms.indexes(term) {
+		c := float64(cms.counts[i])
+		if c < minCount {
+			minCount = c
+		}
+		ests[r] = c - (float64(cms.total)-c)/float64(cms.width-1)
+	}
+	sort.Float64s(ests[:])
+	est := (ests[cSketchDepth/2-1] + ests[cSketchDepth/2]) / 2
+	if est > minCount {
+		est = minCount
+	}
+	if mean := float64(cms.total) / float64(cms.width); est <= mean+3*math.Sqrt(mean) {
+		return 0
+	}
+	return int(math.Round(est))
+}
@@ -0,0 +1,301 @@
+package logan
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
+	"goLogAnalyzer/pkg/utils"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the store of the source counts
+	cSourcesStoreName = "logGroupSources"
+
+	CSourceFromFilename = "filename"
+	cSourceFromRegex    = "regex:"
+	cSourceFromField    = "field:"
+
+	// lines the source could not be picked up from
+	cUnknownSource = "-"
+)
+
+// a logGroupSources row
+type sourceKey struct {
+	groupId      int64
+	retentionPos int64
+	source       string
+}
+
+/*
+logGroupSources keeps the counts of log groups by the source (host, file...) they came from.
+The blocks rotate with the logGroups blocks, so the counts cover the blocks kept in logGroups.
+*/
+type logGroupSources struct {
+	csvdb.Store
+	counts map[int64]map[string]int
+	curr   map[sourceKey]int
+}
+
+func newLogGroupSources(dataDir, storage string,
+	maxBlocks int, unitSecs, keepPeriod int64, useGzip bool) (*logGroupSources, error) {
+	src := &logGroupSources{
+		counts: make(map[int64]map[string]int),
+		curr:   make(map[sourceKey]int),
+	}
+	db, err := csvdb.NewStore(storage, dataDir, cSourcesStoreName,
+		tableDefs[cSourcesStoreName], maxBlocks, 0, keepPeriod, unitSecs, useGzip)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SetColumnTypes(columnTypes[cSourcesStoreName]); err != nil {
+		return nil, err
+	}
+	src.Store = db
+	return src, nil
+}
+
+// dataDir has source counts
+func hasLogGroupSources(dataDir string) bool {
+	return dataDir != "" && utils.PathExist(fmt.Sprintf("%s/%s", dataDir, cSourcesStoreName))
+}
+
+func (src *logGroupSources) add(groupId int64, source string, count int) {
+	if _, ok := src.counts[groupId]; !ok {
+		src.counts[groupId] = make(map[string]int)
+	}
+	src.counts[groupId][source] += count
+}
+
+// count up the current block
+func (src *logGroupSources) addCurr(groupId, retentionPos int64, source string, count int) {
+	src.add(groupId, source, count)
+	src.curr[sourceKey{groupId, retentionPos, source}] += count
+}
+
+func (src *logGroupSources) flush() error {
+	for key, count := range src.curr {
+		if count <= 0 {
+			continue
+		}
+		if err := src.InsertRow(tableDefs[cSourcesStoreName],
+			key.groupId, key.retentionPos, key.source, count); err != nil {
+			return err
+		}
+	}
+	if err := src.FlushOverwriteCurrentTable(); err != nil {
+		return err
+	}
+	src.curr = make(map[sourceKey]int)
+	return nil
+}
+
+func (src *logGroupSources) next(updated int64) error {
+	if err := src.flush(); err != nil {
+		return err
+	}
+	if err := src.NextBlock(updated); err != nil {
+		return err
+	}
+
+	// the block to be overwritten is not counted any more
+	rows, err := src.SelectFromCurrentTable(nil, tableDefs[cSourcesStoreName])
+	if err != nil {
+		return err
+	}
+	return scanSourceRows(rows, func(key sourceKey, count int) {
+		src.add(key.groupId, key.source, -count)
+	})
+}
+
+func (src *logGroupSources) commit(completed bool) error {
+	if err := src.flush(); err != nil {
+		return err
+	}
+	return src.UpdateBlockStatus(completed)
+}
+
+func (src *logGroupSources) load() error {
+	if src.CountFromStatusTable(nil) <= 0 {
+		return nil
+	}
+	if err := src.LoadCircuitDBStatus(); err != nil {
+		return err
+	}
+	rows, err := src.SelectCompletedRows(nil, nil, tableDefs[cSourcesStoreName])
+	if err != nil {
+		return err
+	}
+	if err := scanSourceRows(rows, func(key sourceKey, count int) {
+		src.add(key.groupId, key.source, count)
+	}); err != nil {
+		return err
+	}
+	rows, err = src.SelectFromCurrentTable(nil, tableDefs[cSourcesStoreName])
+	if err != nil {
+		return err
+	}
+	return scanSourceRows(rows, func(key sourceKey, count int) {
+		src.addCurr(key.groupId, key.retentionPos, key.source, count)
+	})
+}
+
+// call f for each logGroupSources row
+func scanSourceRows(rows csvdb.RowScanner, f func(key sourceKey, count int)) error {
+	if rows == nil {
+		return nil
+	}
+	for rows.Next() {
+		var groupIdstr string
+		var key sourceKey
+		var count int
+		if err := rows.Scan(&groupIdstr, &key.retentionPos, &key.source, &count); err != nil {
+			return err
+		}
+		groupId, err := strconv.ParseInt(groupIdstr, 10, 64)
+		if err != nil {
+			return fmt.Errorf("error parsing %s to int64", groupIdstr)
+		}
+		key.groupId = groupId
+		f(key, count)
+	}
+	return nil
+}
+
+// source counts in the blocks overlapping [from, to). width gives the bucket size of a retentionPos
+func (src *logGroupSources) inRange(from, to int64, width func(pos int64) int64) (*logGroupSources, error) {
+	ranged := &logGroupSources{counts: make(map[int64]map[string]int)}
+	rows, err := src.SelectRows(nil, nil, tableDefs[cSourcesStoreName])
+	if err != nil {
+		return nil, err
+	}
+	if err := scanSourceRows(rows, func(key sourceKey, count int) {
+		if bucketInRange(key.retentionPos, width(key.retentionPos), from, to) {
+			ranged.add(key.groupId, key.source, count)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return ranged, nil
+}
+
+// move the counts of groupIds merged by analyzer.rebuildTrans()
+func (src *logGroupSources) remap(groupIds map[int64]int64) {
+	counts := make(map[int64]map[string]int, len(src.counts))
+	for groupId, bySource := range src.counts {
+		newId, ok := groupIds[groupId]
+		if !ok {
+			newId = groupId
+		}
+		if _, ok := counts[newId]; !ok {
+			counts[newId] = make(map[string]int)
+		}
+		for source, count := range bySource {
+			counts[newId][source] += count
+		}
+	}
+	src.counts = counts
+}
+
+// sources of groupIds in descending order of the total counts
+func (src *logGroupSources) getSources(groupIds []int64) []string {
+	totals := make(map[string]int)
+	for _, groupId := range groupIds {
+		for source, count := range src.counts[groupId] {
+			totals[source] += count
+		}
+	}
+	return sortSources(totals)
+}
+
+// the sources of counts in descending order of the counts
+func sortSources(counts map[string]int) []string {
+	sources := make([]string, 0, len(counts))
+	for source := range counts {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if counts[sources[i]] == counts[sources[j]] {
+			return sources[i] < sources[j]
+		}
+		return counts[sources[i]] > counts[sources[j]]
+	})
+	return sources
+}
+
+// "source=count" of groupId in descending order of counts
+func (src *logGroupSources) format(groupId int64) []string {
+	return formatSources(src.counts[groupId])
+}
+
+func formatSources(counts map[string]int) []string {
+	sources := sortSources(counts)
+	for i, source := range sources {
+		sources[i] = fmt.Sprintf("%s=%d", source, counts[source])
+	}
+	return sources
+}
+
+/*
+sourceExtractor picks up the source of a line as configured by sourceFrom
+
+  - filename: the base name of the log file
+  - regex:<regexp>: the first capture group of regexp
+  - field:<n>: the n-th field of the line separated by spaces, from 1
+*/
+type sourceExtractor struct {
+	byFilename bool
+	re         *regexp.Regexp
+	field      int
+}
+
+func newSourceExtractor(sourceFrom string) (*sourceExtractor, error) {
+	se := new(sourceExtractor)
+	switch {
+	case sourceFrom == CSourceFromFilename:
+		se.byFilename = true
+	case strings.HasPrefix(sourceFrom, cSourceFromRegex):
+		re, err := regexp.Compile(strings.TrimPrefix(sourceFrom, cSourceFromRegex))
+		if err != nil {
+			return nil, fmt.Errorf("sourceFrom: %w", err)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, fmt.Errorf("sourceFrom: %s has no capture group", re)
+		}
+		se.re = re
+	case strings.HasPrefix(sourceFrom, cSourceFromField):
+		field, err := strconv.Atoi(strings.TrimPrefix(sourceFrom, cSourceFromField))
+		if err != nil || field < 1 {
+			return nil, fmt.Errorf("sourceFrom: field must be a number from 1: %s", sourceFrom)
+		}
+		se.field = field
+	default:
+		return nil, fmt.Errorf("unknown sourceFrom %s. filename, regex:<regexp> or field:<n>", sourceFrom)
+	}
+	return se, nil
+}
+
+func (se *sourceExtractor) extract(line, filename string) string {
+	source := ""
+	switch {
+	case se.byFilename:
+		if filename != "" {
+			source = filepath.Base(filename)
+		}
+	case se.re != nil:
+		if ma := se.re.FindStringSubmatch(line); len(ma) > 1 {
+			source = ma[1]
+		}
+	case se.field > 0:
+		if fields := strings.Fields(line); len(fields) >= se.field {
+			source = fields[se.field-1]
+		}
+	}
+	if source == "" {
+		return cUnknownSource
+	}
+	return source
+}
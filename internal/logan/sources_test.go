@@ -0,0 +1,87 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"testing"
+)
+
+func Test_Sources(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Sources")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// grpa10, grpb10...
+	conf.SourceFrom = "field:3"
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	_checkSources := func(a *Analyzer, title string) {
+		if a.trans.src == nil {
+			t.Errorf("%s: no source counts", title)
+			return
+		}
+		total := 0
+		groupIds := make([]int64, 0, len(a.trans.lgs.alllg))
+		for groupId, lg := range a.trans.lgs.alllg {
+			sum := 0
+			for _, count := range a.trans.src.counts[groupId] {
+				sum += count
+			}
+			if err := utils.GetGotExpErr(title+" sources", sum, lg.count); err != nil {
+				t.Errorf("%v groupId=%d", err, groupId)
+				return
+			}
+			total += sum
+			groupIds = append(groupIds, groupId)
+		}
+		if err := utils.GetGotExpErr(title+" total", total, 34); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr(title+" top source", a.trans.src.getSources(groupIds)[0], "grpa10"); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+	_checkSources(a, "fed")
+	a.Close()
+
+	a, err = LoadAnalyzer(conf.DataDir, "", 0, 0, 0, nil, true, false, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	_checkSources(a, "loaded")
+	a.Close()
+
+	problems, err := Fsck(conf.DataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("problems", len(problems), 0); err != nil {
+		t.Errorf("%v %v", err, problems)
+	}
+
+	se, err := newSourceExtractor(`regex:host=(\w+)`)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("regex", se.extract("error on host=web01", ""), "web01"); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := utils.GetGotExpErr("no match", se.extract("error", ""), cUnknownSource); err != nil {
+		t.Errorf("%v", err)
+	}
+	if _, err := newSourceExtractor("regex:host"); err == nil {
+		t.Errorf("regex without a capture group must be an error")
+	}
+}
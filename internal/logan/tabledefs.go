@@ -12,5 +12,19 @@ var (
 		"terms":            {"term", "count"},
 		"patternKeys":      {"patternKey", "epoch", "matched", "groupId"},
 		"patternTags":      {"patternKey", "name", "value"},
+		"logGroupSources":  {"groupId", "retentionPos", "source", "count"},
+		"feedStats": {"retentionPos", "file", "read", "grouped", "filtered",
+			"formatMismatch", "badTimestamp", "late"},
+	}
+
+	// typed columns have min/max statistics per block to skip blocks in queries
+	columnTypes = map[string]map[string]string{
+		"logGroups": {"groupId": "int64", "retentionPos": "int64", "count": "int",
+			"created": "int64", "updated": "int64"},
+		"patternKeys": {"patternKey": "string", "epoch": "int64", "groupId": "int64"},
+		"logGroupSources": {"groupId": "int64", "retentionPos": "int64", "source": "string",
+			"count": "int"},
+		"feedStats": {"retentionPos": "int64", "file": "string", "read": "int", "grouped": "int",
+			"filtered": "int", "formatMismatch": "int", "badTimestamp": "int", "late": "int"},
 	}
 )
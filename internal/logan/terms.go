@@ -1,13 +1,15 @@
 package logan
 
 import (
+	"fmt"
 	"goLogAnalyzer/pkg/csvdb"
 	"math"
 	"sort"
 )
 
 type terms struct {
-	*csvdb.CircuitDB
+	csvdb.Store
+	DataDir    string
 	maxTermId  int
 	term2Id    map[string]int
 	id2term    map[int]string
@@ -15,9 +17,14 @@ type terms struct {
 	currCounts map[int]int
 	totalCount int
 	testMode   bool
+	maxTerms   int             // 0 for no limit
+	evictAt    int             // number of terms to evict at. over maxTerms if the kept ones are
+	sketch     *countMinSketch // counts of the evicted terms
+	restored   map[int]int     // counts taken from sketch when the terms came back
+	evicted    int
 }
 
-func newTerms(dataDir string,
+func newTerms(dataDir, storage string,
 	maxBlocks int,
 	unitSecs, keepPeriod int64,
 	useGzip, testMode bool) (*terms, error) {
@@ -31,12 +38,15 @@ func newTerms(dataDir string,
 	if testMode {
 		return te, nil
 	}
-	tedb, err := csvdb.NewCircuitDB(dataDir, "terms",
+	tedb, err := csvdb.NewStore(storage, dataDir, "terms",
 		tableDefs["terms"], maxBlocks, 0, keepPeriod, unitSecs, useGzip)
 	if err != nil {
 		return nil, err
 	}
-	te.CircuitDB = tedb
+	te.Store = tedb
+	if dataDir != "" {
+		te.DataDir = fmt.Sprintf("%s/terms", dataDir)
+	}
 
 	return te, nil
 }
@@ -50,6 +60,12 @@ func (te *terms) register(term string) int {
 		te.id2term[termId] = term
 		te.term2Id[term] = termId
 		//te.counts[termId] += addCnt
+		if te.sketch != nil {
+			if cnt := te.sketch.estimate(term); cnt > 0 {
+				te.counts[termId] = cnt
+				te.restored[termId] = cnt
+			}
+		}
 	}
 	//te.counts[termId] += addCnt
 	//te.totalCount += addCnt
@@ -57,6 +73,57 @@ func (te *terms) register(term string) int {
 	return termId
 }
 
+// keep maxTerms terms at most, the rest counted in a sketch of sketchWidth
+func (te *terms) setMaxTerms(maxTerms, sketchWidth int) {
+	te.maxTerms = maxTerms
+	te.evictAt = maxTerms
+	if maxTerms <= 0 {
+		te.sketch = nil
+		te.restored = nil
+		return
+	}
+	if te.sketch == nil {
+		te.sketch = newCountMinSketch(sketchWidth)
+		te.restored = make(map[int]int)
+	}
+}
+
+func (te *terms) overLimit() bool {
+	return te.maxTerms > 0 && len(te.term2Id) > te.evictAt
+}
+
+/*
+evict the least counted terms but the ones in keep, until cEvictRate of maxTerms are left.
+Their counts go to the sketch and come back when they are registered again.
+The counts of the current block of them are not stored.
+*/
+func (te *terms) evict(keep map[int]bool) int {
+	target := int(float64(te.maxTerms) * cEvictRate)
+	ids := make([]int, 0, len(te.id2term))
+	for termId := range te.id2term {
+		if !keep[termId] {
+			ids = append(ids, termId)
+		}
+	}
+	n := max(0, min(len(te.id2term)-target, len(ids)))
+	sort.Slice(ids, func(i, j int) bool {
+		return te.counts[ids[i]] < te.counts[ids[j]]
+	})
+	for _, termId := range ids[:n] {
+		term := te.id2term[termId]
+		te.sketch.add(term, te.counts[termId]-te.restored[termId])
+		delete(te.term2Id, term)
+		delete(te.id2term, termId)
+		delete(te.counts, termId)
+		delete(te.currCounts, termId)
+		delete(te.restored, termId)
+	}
+	te.evicted += n
+	// not to try again with every new term when too many are kept
+	te.evictAt = max(te.maxTerms, len(te.term2Id)+te.maxTerms-target)
+	return n
+}
+
 func (te *terms) addCount(termId int, addCnt int, isNew bool) {
 	te.counts[termId] += addCnt
 	te.totalCount += addCnt
@@ -211,11 +278,7 @@ func (te *terms) load() error {
 // read from specified block into a map[string]int
 // mainly for testing
 func (te *terms) getBlockData(blockNo int) (map[string]int, error) {
-	table, err := te.GetBlockTable(blockNo)
-	if err != nil {
-		return nil, err
-	}
-	rows, err := table.SelectRows(nil, nil)
+	rows, err := te.SelectBlockRows(blockNo, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -82,3 +82,34 @@ func (a *Analyzer) _checkConfig(expect_logPath string,
 
 	return nil
 }
+
+// create an analyzer on testdata/loganal/sample_multisize.log with the data at {testDir}/data
+func _newSampleAnalyzer(testName string) (*Analyzer, error) {
+	conf, err := _newSampleConfig(testName)
+	if err != nil {
+		return nil, err
+	}
+	return NewAnalyzer(conf, 0, false, false)
+}
+
+func _newSampleConfig(testName string) (*AnalConfig, error) {
+	testDir, err := utils.InitTestDir(testName)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := new(AnalConfig)
+	conf.DataDir = testDir + "/data"
+	conf.LogPath = "../../testdata/loganal/sample_multisize.log"
+	conf.LogFormat = `^(?P<timestamp>\d+-\d+-\d+T\d+:\d+:\d+)] (?P<message>.+)$`
+	conf.TimestampLayout = "2006-01-02T15:04:05"
+	conf.UseUtcTime = true
+	conf.MaxBlocks = 100
+	conf.BlockSize = 100
+	conf.KeepPeriod = 100
+	conf.UnitSecs = 3600 * 24
+	conf.TermCountBorder = 2
+	conf.MinMatchRate = 0.6
+	conf.Separators = " ,<>"
+	return conf, nil
+}
@@ -0,0 +1,67 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Analyzer_yearInference(t *testing.T) {
+	conf, err := _newSampleConfig("Test_Analyzer_yearInference")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.LogPath = filepath.Dir(conf.DataDir) + "/syslog"
+	conf.LogFormat = `^(?P<timestamp>\w{3} \d{1,2} \d{2}:\d{2}:\d{2}) (?P<message>.+)$`
+	conf.TimestampLayout = "Jan _2 15:04:05"
+	conf.UseUtcTime = false
+	conf.Timezone = "Asia/Tokyo"
+	data := "Dec 31 23:00:00 host sshd: session opened\n" +
+		"Jan  1 01:00:00 host sshd: session opened\n"
+	if err := os.WriteFile(conf.LogPath, []byte(data), 0644); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// an archived log of last year
+	mtime := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(conf.LogPath, mtime, mtime); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	a, err := NewAnalyzer(conf, 0, false, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", len(a.trans.lgs.alllg), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	for _, lg := range a.trans.lgs.alllg {
+		if err := utils.GetGotExpErr("created", lg.created,
+			time.Date(2024, 12, 31, 23, 0, 0, 0, tokyo).Unix()); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr("updated", lg.updated,
+			time.Date(2025, 1, 1, 1, 0, 0, 0, tokyo).Unix()); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	conf.Timezone = "Mars/Olympus"
+	if err := checkConfig(conf); err == nil {
+		t.Errorf("no error for an unknown timezone")
+	}
+}
@@ -2,6 +2,7 @@ package logan
 
 import (
 	"fmt"
+	"goLogAnalyzer/pkg/csvdb"
 	"goLogAnalyzer/pkg/utils"
 	"math"
 	"regexp"
@@ -9,8 +10,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
 type trans struct {
@@ -18,6 +17,10 @@ type trans struct {
 	lt                  *logTree
 	lgs                 *logGroups
 	pk                  *patternkeys
+	src                 *logGroupSources
+	stats               *feedStats
+	lineStatus          lineStatus // of the last line of lineToLogGroup
+	se                  *sourceExtractor
 	customLogGroups     []string
 	replacer            *strings.Replacer
 	logFormatRe         *regexp.Regexp
@@ -25,6 +28,10 @@ type trans struct {
 	msgPoses            map[*regexp.Regexp]int
 	timestampLayout     string
 	useUtcTime          bool
+	location            *time.Location
+	logFile             string    // the log file of the current line
+	logFileMtime        int64     // the latest possible timestamp in it
+	lastTimestamp       time.Time // of the previous line in logFile
 	timestampPos        int
 	messagePos          int
 	readOnly            bool
@@ -42,17 +49,28 @@ type trans struct {
 	countByBlock        int
 	maxCountByBlock     int
 	currRetentionPos    int64
+	reorderWindow       int64 // secs lines earlier than currRetentionPos are counted at their own retentionPos
+	lateLines           int   // lines earlier than reorderWindow, counted at currRetentionPos
+	maxLogGroups        int   // kept in memory
+	noSpill             bool  // no cold log groups to spill until a later retentionPos than noSpillPos
+	noSpillPos          int64 // retentionPos of the last line no log groups were cold for
+	overflowLines       int   // lines of new patterns counted in the overflow log group
+	onNewLogGroup       func(groupId int64)
 	separators          string
 	timestampRe         *regexp.Regexp
 	testMode            bool
 	ignoreNumbers       bool
+	maskers             *maskers
 	dataDir             string
+	tx                  *csvdb.Txn // the block rotations since the last commit of the analyzer
 }
 
-func newTrans(dataDir, logFormat, timestampLayout string,
+func newTrans(dataDir, logFormat, timestampLayout, timezone string,
 	useUtcTime bool,
 	maxBlocks, blockSize int,
 	unitSecs int64, keepPeriod int64,
+	reorderWindow int64,
+	retention []RetentionTier,
 	termCountBorderRate float64,
 	termCountBorder int,
 	minMatchRate float64,
@@ -62,17 +80,27 @@ func newTrans(dataDir, logFormat, timestampLayout string,
 	_msgFormats []string,
 	_kgRegexes []string,
 	_customLogGroups []string,
-	separators string,
+	_maskers []string,
+	separators, storage, sourceFrom string,
 	useGzip, readOnly, testMode, ignoreNumbers bool) (*trans, error) {
 	tr := new(trans)
 	tr.dataDir = dataDir
 	tr.replacer = getDelimReplacer(separators)
 	tr.timestampLayout = timestampLayout
-	tr._parseLogFormat(logFormat)
+	if err := tr._parseLogFormat(logFormat); err != nil {
+		return nil, err
+	}
 	tr.termCountBorder = termCountBorder
 	tr.termCountBorderRate = termCountBorderRate
 	tr.minMatchRate = minMatchRate
 	tr.useUtcTime = useUtcTime
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone: %w", err)
+		}
+		tr.location = loc
+	}
 	tr.separators = separators
 	tr.readOnly = readOnly
 	tr.testMode = testMode
@@ -88,22 +116,40 @@ func newTrans(dataDir, logFormat, timestampLayout string,
 	for _, word := range _ignorewords {
 		tr.ignorewords[word] = true
 	}
+	mk, err := newMaskers(_maskers)
+	if err != nil {
+		return nil, fmt.Errorf("maskers: %w", err)
+	}
+	tr.maskers = mk
+	// the placeholders are never masked with "*"
+	if mk != nil {
+		for placeholder := range mk.isTyped {
+			tr.keywords[placeholder] = true
+		}
+	}
 	tr.unitSecs = unitSecs
+	tr.reorderWindow = reorderWindow
+	if tr.reorderWindow <= 0 {
+		tr.reorderWindow = unitSecs
+	}
 	tr.maxCountByBlock = blockSize
+	tr.maxLogGroups = cMaxLogGroups
 
 	// don't need blockSize for terms because the rotation follows trans.next()
-	te, err := newTerms(dataDir, maxBlocks, unitSecs, keepPeriod, useGzip, tr.testMode)
+	te, err := newTerms(dataDir, storage, maxBlocks, unitSecs, keepPeriod, useGzip, tr.testMode)
 	if err != nil {
 		return nil, err
 	}
 	tr.te = te
 
 	if len(_msgFormats) > 0 {
-		tr._parseMsgFormat(_msgFormats)
+		if err := tr._parseMsgFormat(_msgFormats); err != nil {
+			return nil, err
+		}
 	}
 
 	if len(_kgRegexes) > 0 {
-		tr.pk, err = newPatternKeys(dataDir, _kgRegexes, useGzip, tr.testMode)
+		tr.pk, err = newPatternKeys(dataDir, storage, _kgRegexes, useGzip, tr.testMode)
 		if err != nil {
 			return nil, err
 		}
@@ -111,12 +157,31 @@ func newTrans(dataDir, logFormat, timestampLayout string,
 
 	tr.lt = newLogTree(0)
 	// don't need blockSize for terms because the rotation follows trans.next()
-	lgs, err := newLogGroups(dataDir, maxBlocks, unitSecs, keepPeriod, useGzip, tr.testMode)
+	lgs, err := newLogGroups(dataDir, storage, maxBlocks, unitSecs, keepPeriod, retention, useGzip, tr.testMode)
 	if err != nil {
 		return nil, err
 	}
 	tr.lgs = lgs
 
+	if sourceFrom != "" {
+		tr.se, err = newSourceExtractor(sourceFrom)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !tr.testMode && (tr.se != nil || hasLogGroupSources(dataDir)) {
+		tr.src, err = newLogGroupSources(dataDir, storage, maxBlocks, unitSecs, keepPeriod, useGzip)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !tr.testMode && dataDir != "" && (!readOnly || hasFeedStats(dataDir)) {
+		tr.stats, err = newFeedStats(dataDir, storage, maxBlocks, unitSecs, keepPeriod, useGzip)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	tr.initCounters()
 	return tr, nil
 }
@@ -127,7 +192,23 @@ func (tr *trans) initCounters() {
 	tr.countByBlock = 0
 }
 
-func (tr *trans) _parseLogFormat(logFormat string) {
+// the state of trans a pass over the lines goes on from
+type passState struct {
+	currRetentionPos int64
+	totalLines       int
+	countByBlock     int
+	lastTimestamp    time.Time
+}
+
+// swap the state of the current pass with s, for FeedLines to go back and forth between the terms and the log groups
+func (tr *trans) swapPassState(s *passState) {
+	tr.currRetentionPos, s.currRetentionPos = s.currRetentionPos, tr.currRetentionPos
+	tr.totalLines, s.totalLines = s.totalLines, tr.totalLines
+	tr.countByBlock, s.countByBlock = s.countByBlock, tr.countByBlock
+	tr.lastTimestamp, s.lastTimestamp = s.lastTimestamp, tr.lastTimestamp
+}
+
+func (tr *trans) _parseLogFormat(logFormat string) error {
 	suffixPattern := regexp.MustCompile(`\b(\d{1,2})(st|nd|rd|th)\b`)
 
 	// If suffixes are found, replace them
@@ -137,8 +218,10 @@ func (tr *trans) _parseLogFormat(logFormat string) {
 		needDateFormatCleaning = true
 	}
 
-	//re := regexp.MustCompile(logFormat)
-	re := regexp.MustCompile(`` + logFormat) // Use (?i) for case-insensitive matching if needed
+	re, err := regexp.Compile(`` + logFormat) // Use (?i) for case-insensitive matching if needed
+	if err != nil {
+		return fmt.Errorf("logFormat: %w", err)
+	}
 
 	names := re.SubexpNames()
 	tr.timestampPos = -1
@@ -152,13 +235,17 @@ func (tr *trans) _parseLogFormat(logFormat string) {
 		}
 	}
 	tr.logFormatRe = re
+	return nil
 }
 
-func (tr *trans) _parseMsgFormat(msgFormats []string) {
+func (tr *trans) _parseMsgFormat(msgFormats []string) error {
 	tr.msgFormatRes = make([]*regexp.Regexp, 0)
 	tr.msgPoses = make(map[*regexp.Regexp]int)
-	for _, msgFormat := range msgFormats {
-		re := regexp.MustCompile(`` + msgFormat)
+	for i, msgFormat := range msgFormats {
+		re, err := regexp.Compile(`` + msgFormat)
+		if err != nil {
+			return fmt.Errorf("msgFormats[%d]: %w", i, err)
+		}
 		tr.msgFormatRes = append(tr.msgFormatRes, re)
 		names := re.SubexpNames()
 		for i, name := range names {
@@ -167,6 +254,7 @@ func (tr *trans) _parseMsgFormat(msgFormats []string) {
 			}
 		}
 	}
+	return nil
 }
 
 func (tr *trans) _setFilters(searchRegex, exludeRegex []string) {
@@ -245,13 +333,20 @@ func (tr *trans) setBlockSize(blockSize int) {
 }
 
 func (tr *trans) parseLine(line string, updated int64) (string, int64, int64, error) {
+	line, updated, retentionPos, _, err := tr.parseLineStatus(line, updated)
+	return line, updated, retentionPos, err
+}
+
+// parseLine with cLineEmpty, cLineFormatMismatch or cLineBadTimestamp for the lines it could not parse
+func (tr *trans) parseLineStatus(line string, updated int64) (string, int64, int64, lineStatus, error) {
 	var lastdt time.Time
 	var err error
+	status := cLineGrouped
 	lastUpdate := int64(0)
 	retentionPos := int64(-1)
 	line = strings.TrimSpace(reMultiSpace.ReplaceAllString(line, " "))
 	if line == "" {
-		return "", 0, 0, nil
+		return "", 0, 0, cLineEmpty, nil
 	}
 
 	if tr.timestampPos >= 0 || tr.messagePos >= 0 {
@@ -295,23 +390,15 @@ func (tr *trans) parseLine(line string, updated int64) (string, int64, int64, er
 		}
 		if tr.timestampPos >= 0 && len(ma) == 0 {
 			//return "", 0, 0, fmt.Errorf("line does not match format:\n%s", line)
-			return "", 0, 0, nil // treat as no match
+			return "", 0, 0, cLineFormatMismatch, nil // treat as no match
 		}
 		if len(ma) > 0 {
 			if tr.timestampPos >= 0 && tr.timestampLayout != "" && len(ma) > tr.timestampPos {
-				if tr.useUtcTime {
-					lastdt, err = utils.Str2Timestamp(tr.timestampLayout, ma[tr.timestampPos])
-				} else {
-					// system time zone
-					dtstr := ma[tr.timestampPos]
-					if needDateFormatCleaning && tr.timestampRe != nil {
-						dtstr = tr.timestampRe.ReplaceAllString(dtstr, "$1")
-					}
-
-					lastdt, err = utils.Str2date(tr.timestampLayout, dtstr)
-				}
+				lastdt, err = tr.parseTimestamp(ma[tr.timestampPos])
 				if err == nil {
 					lastUpdate = lastdt.Unix()
+				} else {
+					status = cLineBadTimestamp
 				}
 
 				retentionPos = int64(math.Floor(float64(lastUpdate)/float64(tr.unitSecs))) * tr.unitSecs
@@ -327,7 +414,58 @@ func (tr *trans) parseLine(line string, updated int64) (string, int64, int64, er
 	if lastUpdate == 0 {
 		lastUpdate = updated
 	}
-	return line, lastUpdate, retentionPos, nil
+	return line, lastUpdate, retentionPos, status, nil
+}
+
+/*
+setLogFile tells the file the next lines come from with its mtime.
+The timestamps without a year get the year by the mtime. "" for stdin, where it is now.
+*/
+func (tr *trans) setLogFile(path string, mtime int64) {
+	if path == tr.logFile && mtime == tr.logFileMtime {
+		return
+	}
+	tr.logFile = path
+	tr.logFileMtime = mtime
+	tr.lastTimestamp = time.Time{}
+}
+
+// the time zone of the timestamps without one
+func (tr *trans) timeLocation() *time.Location {
+	if tr.location != nil {
+		return tr.location
+	}
+	if tr.useUtcTime {
+		return time.UTC
+	}
+	// system time zone
+	return time.Local
+}
+
+// timestamp of a line by timestampLayout
+func (tr *trans) parseTimestamp(dtstr string) (time.Time, error) {
+	switch tr.timestampLayout {
+	case CTimestampLayoutEpoch:
+		return utils.EpochStr2Time(dtstr, false)
+	case CTimestampLayoutEpochMillis:
+		return utils.EpochStr2Time(dtstr, true)
+	}
+	if needDateFormatCleaning && tr.timestampRe != nil {
+		dtstr = tr.timestampRe.ReplaceAllString(dtstr, "$1")
+	}
+	ref := time.Now()
+	if tr.logFileMtime > 0 {
+		ref = time.Unix(tr.logFileMtime, 0)
+	}
+	t, err := utils.Str2dateIn(tr.timestampLayout, dtstr, tr.timeLocation(), ref, tr.lastTimestamp)
+	if err != nil {
+		return time.Time{}, err
+	}
+	tr.lastTimestamp = t
+	if tr.useUtcTime {
+		return t.UTC(), nil
+	}
+	return t, nil
 }
 
 func (tr *trans) parseMessage(line string) string {
@@ -345,10 +483,18 @@ func (tr *trans) parseMessage(line string) string {
 func (tr *trans) toTokens(line string, addCnt int,
 	useTermBorder, needDisplayString, onlyCurrTerms, doPatternKeyMatching bool,
 ) ([]int, string, string, error) {
-	displayString := line
-	line = tr.replacer.Replace(line)
-	//line = strings.TrimSpace(reMultiSpace.ReplaceAllString(line, " "))
-	words := strings.Split(line, " ")
+	segments := tr.maskers.split(line)
+	words := make([]string, 0)
+	values := make(map[int]string) // masked values by the index of their placeholders
+	for _, seg := range segments {
+		if seg.placeholder {
+			values[len(words)] = seg.value
+			words = append(words, seg.text)
+			continue
+		}
+		//line = strings.TrimSpace(reMultiSpace.ReplaceAllString(line, " "))
+		words = append(words, strings.Split(tr.replacer.Replace(seg.text), " ")...)
+	}
 	tokens := make([]int, 0)
 	uniqTokens := make(map[int]bool, 0)
 	excludesMap := make(map[string]bool)
@@ -357,15 +503,23 @@ func (tr *trans) toTokens(line string, addCnt int,
 	termId := -1
 
 	patternKey := ""
-	for _, w := range words {
+	for i, w := range words {
 		if w == "" {
 			continue
 		}
 
 		word := strings.ToLower(w)
+		if tr.maskers.isPlaceholder(w) {
+			word = w
+		}
 		if doPatternKeyMatching && tr.pk != nil {
-			if ok := tr.pk.hasMatch([]byte(word)); ok {
-				patternKey = word
+			// the pattern keys are the values before masking
+			key := word
+			if value, ok := values[i]; ok {
+				key = strings.ToLower(value)
+			}
+			if ok := tr.pk.hasMatch([]byte(key)); ok {
+				patternKey = key
 			}
 		}
 
@@ -413,6 +567,9 @@ func (tr *trans) toTokens(line string, addCnt int,
 		//}
 		tokens = append(tokens, termId)
 	}
+	if tr.te.overLimit() {
+		tr.evictTerms(tokens)
+	}
 
 	if useTermBorder {
 		//if len(tokens) != len(counts) {
@@ -465,16 +622,24 @@ func (tr *trans) toTokens(line string, addCnt int,
 		}
 	}
 
+	displayString := ""
 	if needDisplayString {
-		for target := range excludesMap {
-			displayString = utils.Replace(displayString, target, "*", tr.separators)
-		}
-		for target := range excludedNumbers {
-			displayString = utils.Replace(displayString, target, "*", tr.separators)
+		var sb strings.Builder
+		for _, seg := range segments {
+			text := seg.text
+			// the placeholders are not replaced
+			if !seg.placeholder {
+				for target := range excludesMap {
+					text = utils.Replace(text, target, "*", tr.separators)
+				}
+				for target := range excludedNumbers {
+					text = utils.Replace(text, target, "*", tr.separators)
+				}
+			}
+			sb.WriteString(text)
 		}
+		displayString = sb.String()
 		// Combine multiple consecutive "*" into a single "*"
-	} else {
-		displayString = ""
 	}
 
 	return tokens, displayString, patternKey, nil
@@ -502,32 +667,49 @@ func (tr *trans) lineToTerms(line string, addCnt int) error {
 	tr.countByBlock++
 	tr.totalLines++
 
-	tr.currRetentionPos = retentionPos
+	if retentionPos > tr.currRetentionPos {
+		tr.currRetentionPos = retentionPos
+	}
 	return nil
 }
 
 // analyze the line and
 func (tr *trans) lineToLogGroup(orgLine string, addCnt int, updated int64) (int64, error) {
+	groupId, status, err := tr._lineToLogGroup(orgLine, addCnt, updated)
+	if err != nil {
+		return -1, err
+	}
+	tr.lineStatus = status
+	if tr.stats != nil {
+		tr.stats.count(tr.logFile, status)
+	}
+	return groupId, nil
+}
+
+func (tr *trans) _lineToLogGroup(orgLine string, addCnt int, updated int64) (int64, lineStatus, error) {
 	//if orgLine == "09th, 20:35:34.107+0900 TBLV3 CALL:  [0x00610F9A: 0x8823B0B2-0x00000000] CTBCAFBridge:     m=audio 27868 RTP/AVP 0 101 " {
 	//	print("")
 	//}
 
-	if !tr._match(orgLine) {
-		return -1, nil
-	}
 	if orgLine == "" {
-		return -1, nil
+		return -1, cLineEmpty, nil
 	}
-	line, updated, retentionPos, err := tr.parseLine(orgLine, updated)
+	if !tr._match(orgLine) {
+		return -1, cLineFiltered, nil
+	}
+	line, updated, retentionPos, status, err := tr.parseLineStatus(orgLine, updated)
 	if err != nil {
-		return -1, err
+		return -1, status, err
+	}
+	if status == cLineEmpty || status == cLineFormatMismatch {
+		return -1, status, nil
 	}
 	// pick up classid from the line
 	matched := false
 	if tr.pk != nil {
 		_, _, matched, err = tr.pk.findAndRegister(line)
 		if err != nil {
-			return -1, err
+			return -1, status, err
 		}
 	}
 
@@ -536,23 +718,32 @@ func (tr *trans) lineToLogGroup(orgLine string, addCnt int, updated int64) (int6
 	//}
 
 	line = tr.parseMessage(line)
+	if status == cLineBadTimestamp {
+		// counted in the current block
+		retentionPos = tr.currRetentionPos
+	} else if retentionPos >= 0 && tr.currRetentionPos-retentionPos > tr.reorderWindow {
+		// late lines beyond reorderWindow go to the current block
+		tr.lateLines++
+		retentionPos = tr.currRetentionPos
+		status = cLineLate
+	}
 	if (tr.currRetentionPos > 0 && retentionPos > tr.currRetentionPos) || tr.countByBlock > tr.maxCountByBlock {
 		if err := tr.next(updated); err != nil {
-			return -1, err
+			return -1, status, err
 		}
 	}
 
 	tokens, displayString, patternKey, err := tr.toTokens(line, addCnt, true, true, true, true)
 	if err != nil {
-		return -1, err
+		return -1, status, err
 	}
 
-	groupId := tr.lgs.registerLogTree(tokens, addCnt, displayString, updated, updated, true, retentionPos, -1)
-	cnt := len(tr.lgs.alllg)
-	if cnt > cMaxLogGroups {
-		logrus.Error(displayString)
-		return -1, fmt.Errorf("logTree size went over %d", cMaxLogGroups)
+	tokens, displayString, err = tr.overflow(tokens, displayString, retentionPos)
+	if err != nil {
+		return -1, status, err
 	}
+	groups, spilled := len(tr.lgs.alllg), len(tr.lgs.spilledGroups)
+	groupId := tr.lgs.registerLogTree(tokens, addCnt, displayString, updated, updated, true, retentionPos, -1)
 
 	// register the logGroupId to the patternkeys
 	if tr.pk != nil {
@@ -565,9 +756,73 @@ func (tr *trans) lineToLogGroup(orgLine string, addCnt int, updated int64) (int6
 	lg.calcScore(tokens, tr.te)
 
 	tr.lgs.lastMessages[groupId] = orgLine
+	// a log group back from the spill file is not a new one
+	if tr.onNewLogGroup != nil && len(tr.lgs.alllg) > groups && len(tr.lgs.spilledGroups) == spilled {
+		tr.onNewLogGroup(groupId)
+	}
 
-	tr.currRetentionPos = retentionPos
-	return groupId, nil
+	if retentionPos > tr.currRetentionPos {
+		tr.currRetentionPos = retentionPos
+	}
+	return groupId, status, nil
+}
+
+// a line classified against the existing log groups
+type classifiedLine struct {
+	groupId       int64 // -1 for lines of no existing group
+	tokens        []int
+	displayString string // of the group, or of the new group the line would make
+	message       string
+	updated       int64 // 0 if the timestamp is not parsed
+	patternKey    string
+}
+
+/*
+classify finds the log group of a line without registering the line nor counting its terms.
+nil for the lines filtered out.
+*/
+func (tr *trans) classify(orgLine string) (*classifiedLine, error) {
+	if orgLine == "" || !tr._match(orgLine) {
+		return nil, nil
+	}
+	line, updated, _, err := tr.parseLine(orgLine, 0)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, nil
+	}
+	cl := &classifiedLine{groupId: -1, updated: updated}
+	if tr.pk != nil {
+		cl.patternKey = tr.pk.find(line)
+	}
+	cl.message = tr.parseMessage(line)
+	// the terms as they are, to reach both the groups with and without them masked
+	tokens, _, _, err := tr.toTokens(cl.message, 0, false, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if groupId := tr.lgs.lt.find(tokens); groupId > 0 {
+		cl.groupId, cl.tokens, cl.displayString = groupId, tokens, tr.lgs.displayStrings[groupId]
+		return cl, nil
+	}
+	cl.tokens, cl.displayString, _, err = tr.toTokens(cl.message, 0, true, true, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+// count groupId up for the source of the line
+func (tr *trans) addSource(groupId int64, line, filename string) {
+	if tr.se == nil || tr.src == nil || groupId < 0 {
+		return
+	}
+	lg, ok := tr.lgs.alllg[groupId]
+	if !ok {
+		return
+	}
+	tr.src.addCurr(groupId, lg.retentionPos, tr.se.extract(line, filename), 1)
 }
 
 func (tr *trans) commit(completed bool) error {
@@ -580,6 +835,16 @@ func (tr *trans) commit(completed bool) error {
 	if err := tr.lgs.commit(completed); err != nil {
 		return err
 	}
+	if tr.src != nil && tr.dataDir != "" {
+		if err := tr.src.commit(completed); err != nil {
+			return err
+		}
+	}
+	if tr.stats != nil && tr.dataDir != "" {
+		if err := tr.stats.commit(tr.currRetentionPos, completed); err != nil {
+			return err
+		}
+	}
 	if tr.pk != nil {
 		if err := tr.pk.commit(completed); err != nil {
 			return err
@@ -589,23 +854,34 @@ func (tr *trans) commit(completed bool) error {
 }
 
 func (tr *trans) close() {
-	if tr.lgs.CircuitDB != nil {
+	tr.rollback()
+	if tr.lgs.Store != nil {
 		tr.lgs = nil
 	}
-	if tr.te.CircuitDB != nil {
+	if tr.te.Store != nil {
 		tr.te = nil
 	}
 }
 
 func (tr *trans) load() error {
 	lgs := tr.lgs
-	if lgs.CircuitDB == nil || lgs.DataDir == "" {
+	if lgs.Store == nil || lgs.DataDir == "" {
 		return nil
 	}
 
 	if err := tr.te.load(); err != nil {
 		return err
 	}
+	if tr.src != nil {
+		if err := tr.src.load(); err != nil {
+			return err
+		}
+	}
+	if tr.stats != nil {
+		if err := tr.stats.load(); err != nil {
+			return err
+		}
+	}
 
 	cnt := lgs.CountFromStatusTable(nil)
 	if cnt <= 0 {
@@ -624,48 +900,67 @@ func (tr *trans) load() error {
 		return err
 	}
 
+	// rolled up logGroups first so that retentionPos ends up with the latest one
+	scanners := make([]csvdb.RowScanner, 0, len(lgs.tiers)+1)
+	for i := len(lgs.tiers) - 1; i >= 0; i-- {
+		rows, err := lgs.tiers[i].SelectRows(nil, nil, tableDefs["logGroups"])
+		if err != nil {
+			return err
+		}
+		if rows != nil {
+			scanners = append(scanners, rows)
+		}
+	}
 	rows, err := lgs.SelectCompletedRows(nil, nil, tableDefs["logGroups"])
 	if err != nil {
 		return err
 	}
-	if rows == nil {
-		return nil
+	if rows != nil {
+		scanners = append(scanners, rows)
 	}
 
+	// the rows of the log groups spilled out of memory stay out of it
+	spilled := lgs.hasSpilled()
 	ds := lgs.displayStrings
-	for rows.Next() {
-		var groupIdstr string
-		var retentionPos int64
-		var count int
-		var created int64
-		var updated int64
-		err = rows.Scan(&groupIdstr, &retentionPos, &count, &created, &updated)
-		if err != nil {
-			return err
-		}
-		//groupId, err := utils.Base36ToInt64(groupIdstr)
-		groupId, err := strconv.ParseInt(groupIdstr, 10, 64)
-		if err != nil {
-			return fmt.Errorf("error parsing %s to int64", groupIdstr)
-		}
-
-		tokens, displayString, _, err := tr.toTokens(ds[groupId], 0, true, true, false, false)
-		if err != nil {
-			return err
-		}
-		ds[groupId] = displayString
-
-		// for debugging
-		//if displayString != line {
-		//	_, _, _ = tr.toTokens(line, 0, true, true, false)
-		//	return utils.ErrorStack("loaded displayString does not match parsed displayString\nparsed:\n%s \n\nloaded:\n%s\n\n",
-		//		displayString, line)
-		//}
-		tr.lgs.registerLogTree(tokens, count, displayString, updated, updated, false,
-			retentionPos, groupId)
+	for _, rows := range scanners {
+		for rows.Next() {
+			var groupIdstr string
+			var retentionPos int64
+			var count int
+			var created int64
+			var updated int64
+			err = rows.Scan(&groupIdstr, &retentionPos, &count, &created, &updated)
+			if err != nil {
+				return err
+			}
+			//groupId, err := utils.Base36ToInt64(groupIdstr)
+			groupId, err := strconv.ParseInt(groupIdstr, 10, 64)
+			if err != nil {
+				return fmt.Errorf("error parsing %s to int64", groupIdstr)
+			}
+			if _, ok := ds[groupId]; !ok && spilled {
+				lgs.countSpilled(groupId, retentionPos, count, created, updated)
+				continue
+			}
 
-		if retentionPos > tr.currRetentionPos {
-			tr.currRetentionPos = retentionPos
+			tokens, displayString, _, err := tr.toTokens(ds[groupId], 0, true, true, false, false)
+			if err != nil {
+				return err
+			}
+			ds[groupId] = displayString
+
+			// for debugging
+			//if displayString != line {
+			//	_, _, _ = tr.toTokens(line, 0, true, true, false)
+			//	return utils.ErrorStack("loaded displayString does not match parsed displayString\nparsed:\n%s \n\nloaded:\n%s\n\n",
+			//		displayString, line)
+			//}
+			tr.lgs.registerLogTree(tokens, count, displayString, updated, updated, false,
+				retentionPos, groupId)
+
+			if retentionPos > tr.currRetentionPos {
+				tr.currRetentionPos = retentionPos
+			}
 		}
 	}
 
@@ -674,7 +969,7 @@ func (tr *trans) load() error {
 		return err
 	}
 	if trows == nil {
-		return nil
+		return lgs.indexSpilled()
 	}
 	for trows.Next() {
 		var groupIdstr string
@@ -691,6 +986,12 @@ func (tr *trans) load() error {
 		if err != nil {
 			return fmt.Errorf("error parsing %s to int64", groupIdstr)
 		}
+		// the rows of the current block are written again at the next flush
+		if _, ok := ds[groupId]; !ok && spilled {
+			lgs.countSpilled(groupId, retentionPos, count, created, updated)
+			lgs._registerCurr(groupId, retentionPos, count, created, updated)
+			continue
+		}
 
 		line := ds[groupId]
 		tokens, displayString, _, err := tr.toTokens(line, 0, true, true, false, false)
@@ -703,15 +1004,51 @@ func (tr *trans) load() error {
 			tr.currRetentionPos = retentionPos
 		}
 	}
-	return nil
+	return lgs.indexSpilled()
 }
 
+/*
+rotate all block tables at once.
+The rotations are staged in tx, which the next commit of the analyzer commits with the
+position in the log. Until then the dataDir has none of the blocks of the lines it has not read.
+*/
 func (tr *trans) next(updated int64) error {
-	lgs := tr.lgs
 	if tr.readOnly || tr.dataDir == "" {
 		return nil
 	}
 
+	if tr.tx == nil && !tr.testMode && utils.PathExist(tr.dataDir) {
+		tx, err := csvdb.BeginTxn(tr.dataDir)
+		if err != nil {
+			return err
+		}
+		tr.tx = tx
+	}
+	if err := tr._next(updated); err != nil {
+		tr.rollback()
+		return err
+	}
+	return nil
+}
+
+// takeTxn hands the staged block rotations over to the caller to commit them. nil if none
+func (tr *trans) takeTxn() *csvdb.Txn {
+	tx := tr.tx
+	tr.tx = nil
+	return tx
+}
+
+// rollback discards the block rotations not committed yet
+func (tr *trans) rollback() {
+	if tr.tx != nil {
+		tr.tx.Rollback()
+		tr.tx = nil
+	}
+}
+
+func (tr *trans) _next(updated int64) error {
+	lgs := tr.lgs
+
 	// write the current block
 	if err := tr.te.next(updated); err != nil {
 		return err
@@ -726,9 +1063,21 @@ func (tr *trans) next(updated int64) error {
 			return err
 		}
 	}
+	// write the current source counts
+	if tr.src != nil {
+		if err := tr.src.next(updated); err != nil {
+			return err
+		}
+	}
+	// write the current feed statistics
+	if tr.stats != nil {
+		if err := tr.stats.next(tr.currRetentionPos, updated); err != nil {
+			return err
+		}
+	}
 
 	// clear "current" logGroup
-	lgs.curlg = make(map[int64]*logGroup)
+	lgs.curlg = make(map[blockKey]*logGroup)
 
 	// in case the block table already exists and will be overrided
 	// we subtract counts in the block table from total item counts
@@ -788,14 +1137,14 @@ returns table of LogGroup history, list of groupIds and corresponding displayStr
 		grp1     20     21     25
 		grp2     30     31      5
 */
-func (tr *trans) getLogGroupsHistory(groupIds []int64) (*logGroupsHistory, error) {
+func (tr *trans) getLogGroupsHistory(groupIds []int64, from, to int64) (*logGroupsHistory, error) {
 	lgs := tr.lgs
-	if err := tr.loadLogGroupHistory(); err != nil {
+	countHistory, err := tr.loadLogGroupHistory(groupIds, from, to)
+	if err != nil {
 		return nil, err
 	}
 
-	lgsh := newLogGroupsHistory(lgs, tr.lgs.minRetentionPos,
-		tr.lgs.maxRetentionPos, tr.unitSecs, groupIds)
+	lgsh := newLogGroupsHistory(lgs, lgs.historySpans, tr.unitSecs, groupIds, countHistory)
 	return lgsh, nil
 }
 
@@ -812,11 +1161,41 @@ func (tr *trans) setCountBorder() {
 	}
 }
 
-// get top N logGroups
+/*
+counts of each logGroup in the countHistory buckets overlapping [from, to).
+from or to 0 means no limit on that side. Without both, the lifetime counts.
+*/
+func (tr *trans) getRangeCounts(from, to int64) (map[int64]int, error) {
+	lgs := tr.lgs
+	counts := make(map[int64]int, len(lgs.alllg))
+	if from == 0 && to == 0 {
+		for groupId, lg := range lgs.alllg {
+			counts[groupId] = lg.count
+		}
+		return counts, nil
+	}
+
+	if err := tr.scanLogGroupBlocks(nil, from, to, func(groupId, retentionPos int64, count int,
+		created, updated int64, displayString string) {
+		counts[groupId] += count
+	}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// get top N logGroups.
+// counts from getRangeCounts() replace the lifetime counts if not nil
 func (tr *trans) getTopNGroupIds(N int, minLastUpdate int64,
 	searchString, excludeString string,
-	minCnt, maxCnt int, asc bool) []int64 {
+	minCnt, maxCnt int, asc bool, counts map[int64]int) []int64 {
 	lgs := tr.lgs
+	countOf := func(groupId int64) int {
+		if counts != nil {
+			return counts[groupId]
+		}
+		return lgs.alllg[groupId].count
+	}
 
 	searchStrings := make([]string, 0)
 	exludeStrings := make([]string, 0)
@@ -831,7 +1210,11 @@ func (tr *trans) getTopNGroupIds(N int, minLastUpdate int64,
 	// Create a slice of key-value pairs
 	groupIds := make([]int64, 0, len(lgs.alllg))
 	for groupId, lg := range lgs.alllg {
-		if lg.updated >= minLastUpdate && lg.count >= minCnt && (maxCnt == 0 || lg.count <= maxCnt) {
+		count := countOf(groupId)
+		if counts != nil && count == 0 {
+			continue
+		}
+		if lg.updated >= minLastUpdate && count >= minCnt && (maxCnt == 0 || count <= maxCnt) {
 			if !tr._match(lg.displayString) {
 				continue
 			}
@@ -841,8 +1224,8 @@ func (tr *trans) getTopNGroupIds(N int, minLastUpdate int64,
 
 	// Sort the slice by Count in ascending order if asc is true else descending order
 	sort.Slice(groupIds, func(i, j int) bool {
-		cnti := lgs.alllg[groupIds[i]].count
-		cntj := lgs.alllg[groupIds[j]].count
+		cnti := countOf(groupIds[i])
+		cntj := countOf(groupIds[j])
 		if cnti == cntj {
 			scorei := lgs.alllg[groupIds[i]].rareScore
 			scorej := lgs.alllg[groupIds[j]].rareScore
@@ -871,100 +1254,203 @@ func (tr *trans) getTopNGroupIds(N int, minLastUpdate int64,
 	return groupIds
 }
 
-// load countHistory.
-// call this function only when needed as it eats memory
-func (tr *trans) loadLogGroupHistory() error {
-	lgs := tr.lgs
-	if lgs.DataDir == "" {
-		return nil
+/*
+load the counts by retentionPos of groupIds, all logGroups if empty, in [from, to).
+call this function only when needed as it eats memory
+*/
+func (tr *trans) loadLogGroupHistory(groupIds []int64,
+	from, to int64) (map[int64]map[int64]int, error) {
+	var wanted map[int64]bool
+	if len(groupIds) > 0 {
+		wanted = make(map[int64]bool, len(groupIds))
+		for _, groupId := range groupIds {
+			wanted[groupId] = true
+		}
 	}
+	countHistory := make(map[int64]map[int64]int)
+	err := tr.scanLogGroupBlocks(groupIds, from, to, func(groupId, retentionPos int64, count int,
+		created, updated int64, displayString string) {
+		// union logGroups of rebuildTrans() are not selected by groupId
+		if wanted != nil && !wanted[groupId] {
+			return
+		}
+		if _, ok := countHistory[groupId]; !ok {
+			countHistory[groupId] = make(map[int64]int)
+		}
+		countHistory[groupId][retentionPos] += count
+	})
+	return countHistory, err
+}
 
-	rows, err := lgs.SelectRows(nil, nil, tableDefs["logGroups"])
-	if err != nil {
-		return err
+/*
+condition of the logGroups rows of groupIds in the buckets of a store of unitSecs
+overlapping [from, to). nil for all rows
+*/
+func logGroupsCond(groupIds []int64, from, to, unitSecs int64) *csvdb.Cond {
+	conds := make([]*csvdb.Cond, 0, 2)
+	switch len(groupIds) {
+	case 0:
+	case 1:
+		conds = append(conds, csvdb.Eq("groupId", groupIds[0]))
+	default:
+		ids := make([]interface{}, len(groupIds))
+		for i, groupId := range groupIds {
+			ids[i] = groupId
+		}
+		conds = append(conds, csvdb.In("groupId", ids...))
 	}
-	if rows == nil {
+
+	// the bucket at retentionPos covers [retentionPos, retentionPos+unitSecs)
+	switch {
+	case from > 0 && to > 0:
+		conds = append(conds, csvdb.Between("retentionPos", from-unitSecs+1, to-1))
+	case from > 0:
+		conds = append(conds, csvdb.Ge("retentionPos", from-unitSecs+1))
+	case to > 0:
+		conds = append(conds, csvdb.Le("retentionPos", to-1))
+	}
+	if len(conds) == 0 {
 		return nil
 	}
+	return csvdb.And(conds...)
+}
+
+/*
+call f for each row of groupIds, all logGroups if empty, in the buckets
+overlapping [from, to). 0 means no limit on that side.
+blocks which can't have such rows are not read
+*/
+func (tr *trans) scanLogGroupBlocks(groupIds []int64, from, to int64,
+	f func(groupId, retentionPos int64, count int,
+		created, updated int64, displayString string)) error {
+	lgs := tr.lgs
+	if lgs.DataDir == "" {
+		return tr.scanCurrentLogGroups(groupIds, from, to, f)
+	}
 
 	ds := lgs.displayStrings
 
 	// for when after analyzer.rebuildTrans() has called
 	orgDs := lgs.orgDisplayStrings
+	if orgDs != nil {
+		// the rows have the groupIds before rebuilding
+		groupIds = nil
+	}
 
-	for rows.Next() {
-		var groupIdstr string
-		var retentionPos int64
-		var count int
-		var created int64
-		var updated int64
-		err = rows.Scan(&groupIdstr, &retentionPos, &count, &created, &updated)
+	// rolled up stores first, from the oldest resolution
+	lgs.historySpans = make([]historySpan, 0)
+	stores, unitSecsList := lgs.getHistoryStores(tr.unitSecs)
+	for i, store := range stores {
+		cond := logGroupsCond(groupIds, from, to, unitSecsList[i])
+		rows, err := store.SelectWhere(cond, nil, tableDefs["logGroups"])
 		if err != nil {
 			return err
 		}
-		//groupId, err := utils.Base36ToInt64(groupIdstr)
-		groupId, err := strconv.ParseInt(groupIdstr, 10, 64)
-		if err != nil {
-			return fmt.Errorf("error parsing %s to int64", groupIdstr)
+		if rows == nil {
+			continue
 		}
+		span := historySpan{UnitSecs: unitSecsList[i]}
+
+		for rows.Next() {
+			var groupIdstr string
+			var retentionPos int64
+			var count int
+			var created int64
+			var updated int64
+			err = rows.Scan(&groupIdstr, &retentionPos, &count, &created, &updated)
+			if err != nil {
+				return err
+			}
+			//groupId, err := utils.Base36ToInt64(groupIdstr)
+			groupId, err := strconv.ParseInt(groupIdstr, 10, 64)
+			if err != nil {
+				return fmt.Errorf("error parsing %s to int64", groupIdstr)
+			}
 
-		if retentionPos > lgs.maxRetentionPos {
-			lgs.maxRetentionPos = retentionPos
-		}
-		if lgs.minRetentionPos == 0 || (retentionPos < lgs.minRetentionPos && retentionPos > 0) {
-			lgs.minRetentionPos = retentionPos
-		}
+			if retentionPos > lgs.maxRetentionPos {
+				lgs.maxRetentionPos = retentionPos
+			}
+			if lgs.minRetentionPos == 0 || (retentionPos < lgs.minRetentionPos && retentionPos > 0) {
+				lgs.minRetentionPos = retentionPos
+			}
+			if retentionPos > span.End {
+				span.End = retentionPos
+			}
+			if span.Start == 0 || (retentionPos < span.Start && retentionPos > 0) {
+				span.Start = retentionPos
+			}
 
-		displayString := ""
-		if orgDs == nil {
-			// rebuildTrans() is not called so no need to consider union logGroups
-			displayString = ds[groupId]
-		} else {
-			if line, ok := orgDs[groupId]; ok {
-				groupId, err = tr.lineToLogGroup(line, 0, 0)
-				if err != nil {
-					return err
-				}
+			displayString := ""
+			if orgDs == nil {
+				// rebuildTrans() is not called so no need to consider union logGroups
+				displayString = ds[groupId]
 			} else {
-				return utils.ErrorStack("displayString below did not match any logGrouop\n%s\n\n", line)
+				if line, ok := orgDs[groupId]; ok {
+					groupId, err = tr.lineToLogGroup(line, 0, 0)
+					if err != nil {
+						return err
+					}
+				} else {
+					return utils.ErrorStack("displayString below did not match any logGrouop\n%s\n\n", line)
+				}
+				displayString = lgs.displayStrings[groupId]
 			}
-			displayString = lgs.displayStrings[groupId]
+			f(groupId, retentionPos, count, created, updated, displayString)
 		}
-
-		lg, ok := lgs.alllg[groupId]
-		if !ok {
-			lg = new(logGroup)
-			lg.countHistory = make(map[int64]int)
-		} else if lg.countHistory == nil {
-			lg.countHistory = make(map[int64]int)
-		}
-		lg.countHistory[retentionPos] += count
-		lg.displayString = displayString
-		lg.count += count
-		if created > 0 && lg.created > created {
-			lg.created = created
-		}
-		if updated > 0 && lg.updated < updated {
-			lg.updated = updated
+		if span.End > 0 {
+			lgs.historySpans = append(lgs.historySpans, span)
 		}
 	}
 
 	return nil
 }
 
-func (tr *trans) detectPaterns(minCnt int, mode, outDir string) error {
-	if tr.pk == nil {
-		return nil
+/*
+scanLogGroupBlocks() without a dataDir.
+nothing is flushed then, so all the buckets of the run are in curlg
+*/
+func (tr *trans) scanCurrentLogGroups(groupIds []int64, from, to int64,
+	f func(groupId, retentionPos int64, count int,
+		created, updated int64, displayString string)) error {
+	lgs := tr.lgs
+	var wanted map[int64]bool
+	if len(groupIds) > 0 {
+		wanted = make(map[int64]bool, len(groupIds))
+		for _, groupId := range groupIds {
+			wanted[groupId] = true
+		}
 	}
 
-	switch mode {
-	case "firstMatch":
-		tr.pk.ShowPatternsByFirstMatch(minCnt, tr.lgs)
-	case "relations":
-		tr.pk.ShowPatternsByPatternsKeys(minCnt, tr.lgs, outDir)
-	default:
-		return fmt.Errorf("unknown mode %s for detectPaterns", mode)
-	}
+	lgs.historySpans = make([]historySpan, 0)
+	span := historySpan{UnitSecs: tr.unitSecs}
+	for key, lg := range lgs.curlg {
+		if lg.count <= 0 || (wanted != nil && !wanted[key.groupId]) {
+			continue
+		}
+		// the same buckets as logGroupsCond()
+		if from > 0 && key.retentionPos < from-tr.unitSecs+1 {
+			continue
+		}
+		if to > 0 && key.retentionPos > to-1 {
+			continue
+		}
 
+		if key.retentionPos > lgs.maxRetentionPos {
+			lgs.maxRetentionPos = key.retentionPos
+		}
+		if lgs.minRetentionPos == 0 || (key.retentionPos < lgs.minRetentionPos && key.retentionPos > 0) {
+			lgs.minRetentionPos = key.retentionPos
+		}
+		if key.retentionPos > span.End {
+			span.End = key.retentionPos
+		}
+		if span.Start == 0 || (key.retentionPos < span.Start && key.retentionPos > 0) {
+			span.Start = key.retentionPos
+		}
+		f(key.groupId, key.retentionPos, lg.count, lg.created, lg.updated, lgs.displayStrings[key.groupId])
+	}
+	if span.End > 0 {
+		lgs.historySpans = append(lgs.historySpans, span)
+	}
 	return nil
 }
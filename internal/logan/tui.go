@@ -0,0 +1,633 @@
+package logan
+
+import (
+	"bufio"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+const (
+	cTuiList = iota
+	cTuiDetail
+	cTuiFilter
+	cTuiWord
+
+	cTuiSparkWidth    = 20
+	cTuiMaxPatterns   = 10
+	cTuiKeywords      = "keywords"
+	cTuiIgnorewords   = "ignorewords"
+	cTuiDefaultWidth  = 80
+	cTuiDefaultHeight = 24
+)
+
+var (
+	tuiSortKeys = []string{"count", "rareScore", "updated"}
+	sparkChars  = []rune("▁▂▃▄▅▆▇█")
+)
+
+// TUIOptions are the hooks of the tui to the outside of the dataDir
+type TUIOptions struct {
+	// AddWord adds word to key ("keywords" or "ignorewords") in the config file.
+	// nil disables the keys to add words
+	AddWord func(key, word string) error
+}
+
+// a logGroup in the tui
+type tuiGroup struct {
+	groupId       int64
+	count         int
+	rareScore     float64
+	updated       int64
+	created       int64
+	displayString string
+	lastMessage   string
+	history       []int // counts along tuiModel.timeline
+}
+
+/*
+tuiModel is the state of the tui.
+It handles keys and renders lines without touching the terminal, so it can be tested.
+*/
+type tuiModel struct {
+	groups     []*tuiGroup
+	shown      []*tuiGroup // filtered and sorted
+	timeline   []int64
+	separators string
+	sortKey    int
+	asc        bool
+	filter     string
+	cursor     int
+	offset     int
+	pageSize   int
+	mode       int
+	prevMode   int
+	detailPos  int
+	input      string
+	wordKey    string
+	words      []string
+	wordPos    int
+	status     string
+	quit       bool
+	patterns   func(groupId int64) []string
+	addWord    func(key, word string) error
+}
+
+/*
+TUI browses the log groups in the terminal.
+
+  - the group list sorted by count, rareScore or updated with a sparkline of the history
+  - "/" filters the list by a string
+  - enter shows the history, the last message and the pattern sequences of the group
+  - "i" and "K" add a word of the group to ignorewords and keywords of the config
+*/
+func (a *Analyzer) TUI(opts TUIOptions) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("tui needs a terminal")
+	}
+	m, err := a.newTuiModel(opts)
+	if err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+	out := bufio.NewWriter(os.Stdout)
+	// alternate screen without the cursor
+	out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		out.WriteString("\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	buf := make([]byte, 64)
+	for !m.quit {
+		width, height, err := term.GetSize(fd)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = cTuiDefaultWidth, cTuiDefaultHeight
+		}
+		out.WriteString("\x1b[H\x1b[2J")
+		out.WriteString(strings.Join(m.render(width, height), "\r\n"))
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			m.handleKey(key)
+		}
+	}
+	return nil
+}
+
+func (a *Analyzer) newTuiModel(opts TUIOptions) (*tuiModel, error) {
+	if err := a.trans.loadSpilled(); err != nil {
+		return nil, err
+	}
+	lgs := a.trans.lgs
+	m := &tuiModel{
+		groups:     make([]*tuiGroup, 0, len(lgs.alllg)),
+		separators: a.Separators,
+		addWord:    opts.AddWord,
+	}
+	for groupId, lg := range lgs.alllg {
+		m.groups = append(m.groups, &tuiGroup{
+			groupId:       groupId,
+			count:         lg.count,
+			rareScore:     lg.rareScore,
+			updated:       lg.updated,
+			created:       lg.created,
+			displayString: lg.displayString,
+			lastMessage:   lgs.lastMessages[groupId],
+		})
+	}
+
+	lgsh, err := a.trans.getLogGroupsHistory(nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	m.timeline = lgsh.timeline
+	for _, g := range m.groups {
+		if i, ok := lgsh.groupIdsMap[g.groupId]; ok {
+			g.history = lgsh.counts[i]
+		}
+	}
+
+	var patterns map[string](map[string]*pattern)
+	m.patterns = func(groupId int64) []string {
+		if a.trans.pk == nil {
+			return nil
+		}
+		if patterns == nil {
+			patterns = a.trans.pk.detectPatternsByFirstMatch()
+		}
+		return formatPatternsOf(patterns, groupId)
+	}
+
+	m.apply()
+	return m, nil
+}
+
+// pattern sequences groupId appears in, in descending order of counts
+func formatPatternsOf(patterns map[string](map[string]*pattern), groupId int64) []string {
+	type patSum struct {
+		patternStr string
+		count      int
+	}
+	id := fmt.Sprint(groupId)
+	sums := make([]patSum, 0)
+	for patternStr, byKey := range patterns {
+		found := false
+		for _, s := range strings.Fields(patternStr) {
+			if s == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		sum := patSum{patternStr: patternStr}
+		for _, pat := range byKey {
+			sum.count += pat.count
+		}
+		sums = append(sums, sum)
+	}
+	sort.Slice(sums, func(i, j int) bool {
+		if sums[i].count == sums[j].count {
+			return sums[i].patternStr < sums[j].patternStr
+		}
+		return sums[i].count > sums[j].count
+	})
+
+	lines := make([]string, 0, len(sums))
+	for i, sum := range sums {
+		if i >= cTuiMaxPatterns {
+			break
+		}
+		ids := strings.Fields(sum.patternStr)
+		for j, s := range ids {
+			if s == id {
+				ids[j] = "[" + s + "]"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%6d  %s", sum.count, strings.Join(ids, " ")))
+	}
+	return lines
+}
+
+// filter and sort the groups
+func (m *tuiModel) apply() {
+	var curr int64 = -1
+	if g := m.current(); g != nil {
+		curr = g.groupId
+	}
+
+	filter := strings.ToLower(m.filter)
+	m.shown = make([]*tuiGroup, 0, len(m.groups))
+	for _, g := range m.groups {
+		if filter == "" || strings.Contains(strings.ToLower(g.displayString), filter) {
+			m.shown = append(m.shown, g)
+		}
+	}
+	key := tuiSortKeys[m.sortKey]
+	sort.Slice(m.shown, func(i, j int) bool {
+		gi, gj := m.shown[i], m.shown[j]
+		var less, equal bool
+		switch key {
+		case "rareScore":
+			less, equal = gi.rareScore < gj.rareScore, gi.rareScore == gj.rareScore
+		case "updated":
+			less, equal = gi.updated < gj.updated, gi.updated == gj.updated
+		default:
+			less, equal = gi.count < gj.count, gi.count == gj.count
+		}
+		if equal {
+			return gi.groupId < gj.groupId
+		}
+		if m.asc {
+			return less
+		}
+		return !less
+	})
+
+	// keep the cursor on the same group
+	m.cursor = 0
+	for i, g := range m.shown {
+		if g.groupId == curr {
+			m.cursor = i
+		}
+	}
+}
+
+func (m *tuiModel) current() *tuiGroup {
+	if m.cursor < 0 || m.cursor >= len(m.shown) {
+		return nil
+	}
+	return m.shown[m.cursor]
+}
+
+func (m *tuiModel) moveCursor(n int) {
+	m.cursor += n
+	if m.cursor >= len(m.shown) {
+		m.cursor = len(m.shown) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+// terms of the current group to add to keywords or ignorewords
+func (m *tuiModel) startWord(key string) {
+	g := m.current()
+	if g == nil {
+		return
+	}
+	if m.addWord == nil {
+		m.status = "no config file to add words to. run with -c"
+		return
+	}
+	seen := make(map[string]bool)
+	m.words = make([]string, 0)
+	for _, w := range strings.FieldsFunc(g.displayString, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(m.separators, r)
+	}) {
+		w = strings.ToLower(w)
+		if strings.Contains(w, "*") || seen[w] {
+			continue
+		}
+		seen[w] = true
+		m.words = append(m.words, w)
+	}
+	m.wordKey = key
+	m.wordPos = 0
+	m.input = ""
+	if len(m.words) > 0 {
+		m.input = m.words[0]
+	}
+	m.prevMode = m.mode
+	m.mode = cTuiWord
+}
+
+func (m *tuiModel) handleKey(key string) {
+	m.status = ""
+	if key == "ctrl-c" {
+		m.quit = true
+		return
+	}
+	switch m.mode {
+	case cTuiFilter:
+		switch key {
+		case "enter":
+			m.filter = m.input
+			m.mode = cTuiList
+			m.apply()
+		case "esc":
+			m.mode = cTuiList
+		default:
+			m.input = editInput(m.input, key)
+		}
+	case cTuiWord:
+		switch key {
+		case "enter":
+			m.mode = m.prevMode
+			if m.input == "" {
+				return
+			}
+			if err := m.addWord(m.wordKey, m.input); err != nil {
+				m.status = err.Error()
+				return
+			}
+			m.status = fmt.Sprintf("added %s to %s. run again to regroup", m.input, m.wordKey)
+		case "esc":
+			m.mode = m.prevMode
+		case "tab":
+			if len(m.words) > 0 {
+				m.wordPos = (m.wordPos + 1) % len(m.words)
+				m.input = m.words[m.wordPos]
+			}
+		default:
+			m.input = editInput(m.input, key)
+		}
+	case cTuiDetail:
+		switch key {
+		case "q", "esc", "backspace", "left":
+			m.mode = cTuiList
+		case "up", "k":
+			if m.detailPos > 0 {
+				m.detailPos--
+			}
+		case "down", "j":
+			m.detailPos++
+		case "i":
+			m.startWord(cTuiIgnorewords)
+		case "K":
+			m.startWord(cTuiKeywords)
+		}
+	default:
+		switch key {
+		case "q":
+			m.quit = true
+		case "up", "k":
+			m.moveCursor(-1)
+		case "down", "j":
+			m.moveCursor(1)
+		case "pgup":
+			m.moveCursor(-max(m.pageSize, 1))
+		case "pgdn":
+			m.moveCursor(max(m.pageSize, 1))
+		case "home", "g":
+			m.cursor = 0
+		case "end", "G":
+			m.moveCursor(len(m.shown))
+		case "s":
+			m.sortKey = (m.sortKey + 1) % len(tuiSortKeys)
+			m.apply()
+		case "r":
+			m.asc = !m.asc
+			m.apply()
+		case "/":
+			m.input = m.filter
+			m.mode = cTuiFilter
+		case "esc":
+			m.filter = ""
+			m.apply()
+		case "enter", "right":
+			if m.current() != nil {
+				m.detailPos = 0
+				m.mode = cTuiDetail
+			}
+		case "i":
+			m.startWord(cTuiIgnorewords)
+		case "K":
+			m.startWord(cTuiKeywords)
+		}
+	}
+}
+
+func editInput(input, key string) string {
+	if key == "backspace" {
+		if r := []rune(input); len(r) > 0 {
+			return string(r[:len(r)-1])
+		}
+		return input
+	}
+	if r := []rune(key); len(r) == 1 && unicode.IsPrint(r[0]) {
+		return input + key
+	}
+	return input
+}
+
+func (m *tuiModel) render(width, height int) []string {
+	var lines []string
+	footer := ""
+	switch m.mode {
+	case cTuiFilter:
+		footer = "filter: " + m.input + "_"
+	case cTuiWord:
+		footer = fmt.Sprintf("add to %s: %s_  (tab: next word, enter: add, esc: cancel)", m.wordKey, m.input)
+	case cTuiDetail:
+		footer = "↑↓ scroll  esc back  i ignoreword  K keyword"
+	default:
+		footer = "↑↓ move  enter detail  / filter  s sort  r reverse  i ignoreword  K keyword  q quit"
+	}
+	if m.status != "" {
+		footer = m.status
+	}
+
+	body := height - 1
+	if m.mode == cTuiDetail || (m.mode == cTuiWord && m.prevMode == cTuiDetail) {
+		lines = m.renderDetail(width, body)
+	} else {
+		lines = m.renderList(width, body)
+	}
+	for len(lines) < body {
+		lines = append(lines, "")
+	}
+	return append(lines, truncate(footer, width))
+}
+
+func (m *tuiModel) renderList(width, height int) []string {
+	order := "desc"
+	if m.asc {
+		order = "asc"
+	}
+	title := fmt.Sprintf("logan  %d/%d groups  sort: %s %s", len(m.shown), len(m.groups),
+		tuiSortKeys[m.sortKey], order)
+	if m.filter != "" {
+		title += "  filter: " + m.filter
+	}
+	lines := []string{truncate(title, width),
+		truncate(fmt.Sprintf("%-8s %-6s %-19s %-*s %s", "Count", "Score", "Updated",
+			cTuiSparkWidth, "History", "Text"), width)}
+
+	m.pageSize = height - len(lines)
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.pageSize {
+		m.offset = m.cursor - m.pageSize + 1
+	}
+	for i := m.offset; i < len(m.shown) && i < m.offset+m.pageSize; i++ {
+		g := m.shown[i]
+		line := truncate(fmt.Sprintf("%-8d %-6.2f %-19s %-*s %s", g.count, g.rareScore,
+			utils.EpochToString(g.updated), cTuiSparkWidth, sparkline(g.history, cTuiSparkWidth),
+			g.displayString), width)
+		if i == m.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (m *tuiModel) renderDetail(width, height int) []string {
+	g := m.current()
+	if g == nil {
+		return nil
+	}
+	lines := []string{
+		fmt.Sprintf("group %d", g.groupId),
+		fmt.Sprintf("count: %d  rareScore: %.2f  created: %s  updated: %s", g.count, g.rareScore,
+			utils.EpochToString(g.created), utils.EpochToString(g.updated)),
+		"",
+	}
+	lines = append(lines, wrap(g.displayString, width)...)
+	lines = append(lines, "", "last message:")
+	lines = append(lines, wrap(g.lastMessage, width)...)
+	lines = append(lines, "", fmt.Sprintf("history (%d buckets):", len(m.timeline)),
+		sparkline(g.history, width))
+	for j := len(g.history) - 1; j >= 0; j-- {
+		if g.history[j] > 0 {
+			lines = append(lines, fmt.Sprintf("%-20s %d", utils.EpochToString(m.timeline[j]), g.history[j]))
+		}
+	}
+	if m.patterns != nil {
+		if patterns := m.patterns(g.groupId); len(patterns) > 0 {
+			lines = append(lines, "", "patterns:")
+			lines = append(lines, patterns...)
+		}
+	}
+
+	if m.detailPos > len(lines)-height {
+		m.detailPos = max(len(lines)-height, 0)
+	}
+	lines = lines[m.detailPos:]
+	for i := range lines {
+		lines[i] = truncate(lines[i], width)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+/*
+sparkline of values in width characters.
+values are summed up into width buckets if more, and 0 is a space
+*/
+func sparkline(values []int, width int) string {
+	if len(values) == 0 || width <= 0 {
+		return ""
+	}
+	per := (len(values) + width - 1) / width
+	buckets := make([]int, 0, width)
+	maxv := 0
+	for i := 0; i < len(values); i += per {
+		sum := 0
+		for j := i; j < i+per && j < len(values); j++ {
+			sum += values[j]
+		}
+		buckets = append(buckets, sum)
+		maxv = max(maxv, sum)
+	}
+
+	var sb strings.Builder
+	for _, v := range buckets {
+		if v <= 0 || maxv == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		sb.WriteRune(sparkChars[(v*(len(sparkChars)-1))/maxv])
+	}
+	return sb.String()
+}
+
+// keys in the bytes read from the terminal
+func parseKeys(b []byte) []string {
+	escapes := map[string]string{
+		"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+		"\x1b[5~": "pgup", "\x1b[6~": "pgdn", "\x1b[H": "home", "\x1b[F": "end",
+		"\x1b[1~": "home", "\x1b[4~": "end",
+	}
+	keys := make([]string, 0)
+	s := string(b)
+	for len(s) > 0 {
+		if s[0] == 0x1b {
+			matched := false
+			for seq, key := range escapes {
+				if strings.HasPrefix(s, seq) {
+					keys = append(keys, key)
+					s = s[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				keys = append(keys, "esc")
+				s = s[1:]
+			}
+			continue
+		}
+		switch s[0] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case '\t':
+			keys = append(keys, "tab")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		default:
+			r := []rune(s)[0]
+			keys = append(keys, string(r))
+			s = s[len(string(r)):]
+			continue
+		}
+		s = s[1:]
+	}
+	return keys
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
+
+func wrap(s string, width int) []string {
+	r := []rune(s)
+	if width <= 0 || len(r) == 0 {
+		return []string{s}
+	}
+	lines := make([]string, 0, len(r)/width+1)
+	for len(r) > width {
+		lines = append(lines, string(r[:width]))
+		r = r[width:]
+	}
+	return append(lines, string(r))
+}
@@ -0,0 +1,85 @@
+package logan
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"strings"
+	"testing"
+)
+
+func Test_TUI_model(t *testing.T) {
+	a, err := _newSampleAnalyzer("Test_TUI_model")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	if err := a.Feed(0); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	var added []string
+	m, err := a.newTuiModel(TUIOptions{AddWord: func(key, word string) error {
+		added = append(added, key+":"+word)
+		return nil
+	}})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("top count", m.current().count, 10); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("history", len(m.current().history), len(m.timeline)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	for _, key := range parseKeys([]byte("/grpc05\r")) {
+		m.handleKey(key)
+	}
+	if err := utils.GetGotExpErr("filtered", len(m.shown), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("filtered count", m.current().count, 5); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	m.handleKey("enter")
+	lines := strings.Join(m.render(120, 40), "\n")
+	if !strings.Contains(lines, "last message:") || !strings.Contains(lines, "grpc05") {
+		t.Errorf("unexpected detail view:\n%s", lines)
+		return
+	}
+
+	// the words of the group are offered in order
+	for _, key := range parseKeys([]byte("i\t\r")) {
+		m.handleKey(key)
+	}
+	if err := utils.GetGotExpErr("added", strings.Join(added, " "), "ignorewords:"+m.words[1]); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	m.handleKey("esc")
+	m.handleKey("esc")
+	if err := utils.GetGotExpErr("filter cleared", len(m.shown), len(m.groups)); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_sparkline(t *testing.T) {
+	if err := utils.GetGotExpErr("sparkline", sparkline([]int{0, 1, 2, 4}, 4), " ▂▄█"); err != nil {
+		t.Errorf("%v", err)
+	}
+	// summed up into 2 buckets
+	if err := utils.GetGotExpErr("sparkline", sparkline([]int{0, 1, 2, 2}, 2), "▂█"); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := utils.GetGotExpErr("keys", strings.Join(parseKeys([]byte("\x1b[Aj\r\x1b")), " "), "up j enter esc"); err != nil {
+		t.Errorf("%v", err)
+	}
+}
@@ -5,3 +5,4 @@ import "regexp"
 var reMultiSpace = regexp.MustCompile(`\s+`)
 var debug = false
 var needDateFormatCleaning = false
+var lockTimeout = CDefaultLockTimeout
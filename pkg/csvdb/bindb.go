@@ -0,0 +1,552 @@
+package csvdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// status of a block. the same as a row of CircuitDBStatus
+type BlockStatus struct {
+	LastIndex int64  `json:"last_index"`
+	BlockNo   int    `json:"block_no"`
+	BlockID   string `json:"block_id"`
+	RowNo     int    `json:"row_no"`
+	LastEpoch int64  `json:"last_epoch"`
+	Completed bool   `json:"completed"`
+}
+
+/*
+BinDB is a Store keeping each block in a binary file.
+A row is the number of columns followed by length prefixed values, all uvarint encoded,
+so rows are read without CSV parsing or decompression.
+Block statuses are kept in a JSON file.
+*/
+type BinDB struct {
+	DataDir    string
+	Name       string
+	RowNo      int
+	columns    []string
+	colMap     map[string]int
+	maxBlocks  int
+	blockSize  int
+	keepPeriod int64
+	unitsecs   int64
+	blockNo    int
+	lastIndex  int64
+	lastEpoch  int64
+	writeMode  string
+	completed  bool
+	status     []*BlockStatus
+	buff       [][]string
+	stats      *blockStats
+	onExpire   func(rows RowScanner) error
+}
+
+func NewBinDB(rootDir, name string,
+	columns []string,
+	maxBlocks, blockSize int,
+	keepPeriod, unitSecs int64) (*BinDB, error) {
+	bdb := new(BinDB)
+	bdb.Name = name
+	bdb.columns = columns
+	bdb.colMap = make(map[string]int, len(columns))
+	for i, col := range columns {
+		bdb.colMap[col] = i
+	}
+	bdb.maxBlocks = maxBlocks
+	bdb.blockSize = blockSize
+	bdb.keepPeriod = keepPeriod
+	bdb.unitsecs = unitSecs
+	bdb.writeMode = CWriteModeAppend
+	bdb.status = make([]*BlockStatus, 0)
+	bdb.buff = make([][]string, 0)
+	bdb.stats = newBlockStats("")
+
+	if rootDir == "" {
+		return bdb, nil
+	}
+	bdb.DataDir = fmt.Sprintf("%s/%s", rootDir, name)
+	if err := utils.EnsureDir(bdb.DataDir); err != nil {
+		return nil, err
+	}
+	status, err := readBinStatus(bdb.getStatusPath())
+	if err != nil {
+		return nil, err
+	}
+	bdb.status = status
+	bdb.stats = newBlockStats(fmt.Sprintf("%s/%s", bdb.DataDir, cStatsFile))
+	if err := bdb.stats.load(); err != nil {
+		return nil, err
+	}
+	return bdb, nil
+}
+
+func (bdb *BinDB) getStatusPath() string {
+	return fmt.Sprintf("%s/%s", bdb.DataDir, cBinStatusFile)
+}
+
+func (bdb *BinDB) getBlockTableName(blockNo int) string {
+	return fmt.Sprintf("BLK%0"+strconv.Itoa(cMaxBlockDitigs)+"d", blockNo)
+}
+
+func (bdb *BinDB) getBlockPath(blockNo int) string {
+	return fmt.Sprintf("%s/%s.%s", bdb.DataDir, bdb.getBlockTableName(blockNo), cBinBlockExt)
+}
+
+func (bdb *BinDB) SetMaxBlocks(maxBlocks int) {
+	bdb.maxBlocks = maxBlocks
+}
+
+func (bdb *BinDB) SetBlockSize(blockSize int) {
+	bdb.blockSize = blockSize
+}
+
+// statusRow returns a CircuitDBStatus compatible row for condition check functions
+func (st *BlockStatus) statusRow() []string {
+	return []string{asString(st.LastIndex), asString(st.BlockNo), st.BlockID,
+		asString(st.RowNo), asString(st.LastEpoch), asString(st.Completed)}
+}
+
+// SetColumnTypes declares the types of columns and keeps min/max statistics of them per block
+func (bdb *BinDB) SetColumnTypes(columnTypes map[string]string) error {
+	for col := range columnTypes {
+		if _, ok := bdb.colMap[col]; !ok {
+			return errors.Errorf("col %s is not in the table", col)
+		}
+	}
+	return bdb.stats.setColumnTypes(bdb.columns, columnTypes)
+}
+
+// SetExpireHandler sets f called with rows of each block before it is deleted by keepPeriod.
+// blocks are passed from the oldest
+func (bdb *BinDB) SetExpireHandler(f func(rows RowScanner) error) {
+	bdb.onExpire = f
+}
+
+func (bdb *BinDB) CountFromStatusTable(conditionCheckFunc func([]string) bool) int {
+	cnt := 0
+	for _, st := range bdb.status {
+		if conditionCheckFunc == nil || conditionCheckFunc(st.statusRow()) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (bdb *BinDB) LoadCircuitDBStatus() error {
+	if bdb.DataDir == "" || len(bdb.status) == 0 {
+		return nil
+	}
+	last := bdb.status[0]
+	for _, st := range bdb.status {
+		if st.LastIndex > last.LastIndex {
+			last = st
+		}
+	}
+	bdb.blockNo = last.BlockNo
+	bdb.lastIndex = last.LastIndex
+	bdb.lastEpoch = last.LastEpoch
+	bdb.RowNo = 0
+	bdb.writeMode = CWriteModeAppend
+
+	if last.Completed {
+		if err := bdb.NextBlock(last.LastEpoch); err != nil {
+			return err
+		}
+	} else {
+		bdb.stats.resume(bdb.blockNo)
+	}
+	bdb.completed = last.Completed
+	return nil
+}
+
+func (bdb *BinDB) NextBlock(lastEpoch int64) error {
+	bdb.lastEpoch = lastEpoch
+
+	if err := bdb.UpdateBlockStatus(true); err != nil {
+		return err
+	}
+
+	bdb.RowNo = 0
+	bdb.blockNo++
+	if bdb.blockNo >= bdb.maxBlocks && bdb.maxBlocks > 0 {
+		bdb.blockNo = 0
+	}
+	bdb.lastIndex++
+	bdb.buff = make([][]string, 0)
+
+	if bdb.DataDir == "" {
+		return nil
+	}
+	bdb.writeMode = CWriteModeWrite
+	return nil
+}
+
+func (bdb *BinDB) InsertRow(columns []string, args ...interface{}) error {
+	if bdb.DataDir == "" {
+		return nil
+	}
+	if columns == nil && len(args) != len(bdb.columns) {
+		return errors.New("len of args do not match to table columns")
+	}
+	if columns != nil && len(columns) != len(args) {
+		return errors.New("len of columns and args do not match")
+	}
+
+	if bdb.writeMode == CWriteModeWrite {
+		// the block is reused by the rotation
+		if err := writeBinBlock(bdb.getBlockPath(bdb.blockNo), nil, CWriteModeWrite); err != nil {
+			return err
+		}
+		bdb.writeMode = CWriteModeAppend
+		bdb.RowNo = 0
+		bdb.stats.reset(bdb.blockNo)
+	}
+
+	row := make([]string, len(bdb.columns))
+	if columns == nil {
+		for i, v := range args {
+			row[i] = asString(v)
+		}
+	} else {
+		for i, col := range columns {
+			j, ok := bdb.colMap[col]
+			if !ok {
+				return errors.Errorf("column %s does not exist", col)
+			}
+			row[j] = asString(args[i])
+		}
+	}
+	bdb.buff = append(bdb.buff, row)
+	bdb.stats.add(bdb.blockNo, row)
+	bdb.RowNo++
+	return nil
+}
+
+func (bdb *BinDB) FlushOverwriteCurrentTable() error {
+	if bdb.DataDir == "" || len(bdb.buff) == 0 {
+		return nil
+	}
+	if err := writeBinBlock(bdb.getBlockPath(bdb.blockNo), bdb.buff, CWriteModeWrite); err != nil {
+		return err
+	}
+	bdb.buff = make([][]string, 0)
+	return nil
+}
+
+func (bdb *BinDB) UpdateBlockStatus(completed bool) error {
+	if bdb.DataDir == "" {
+		return nil
+	}
+	bdb.completed = completed
+
+	var st *BlockStatus
+	for _, s := range bdb.status {
+		if s.BlockNo == bdb.blockNo {
+			st = s
+		}
+	}
+	if st == nil {
+		st = new(BlockStatus)
+		bdb.status = append(bdb.status, st)
+	}
+	st.LastIndex = bdb.lastIndex
+	st.BlockNo = bdb.blockNo
+	st.BlockID = bdb.getBlockTableName(bdb.blockNo)
+	st.RowNo = bdb.RowNo
+	st.LastEpoch = bdb.lastEpoch
+	st.Completed = completed
+
+	if err := bdb.deleteOldBlocks(); err != nil {
+		return err
+	}
+	if err := writeBinStatus(bdb.getStatusPath(), bdb.status); err != nil {
+		return err
+	}
+	return bdb.stats.save(bdb.getBlockNos(true))
+}
+
+func (bdb *BinDB) deleteOldBlocks() error {
+	if bdb.keepPeriod == 0 {
+		return nil
+	}
+	oldEpoch := bdb.lastEpoch - bdb.keepPeriod*bdb.unitsecs + 1
+	oldBlocks := make([]*BlockStatus, 0)
+	status := make([]*BlockStatus, 0, len(bdb.status))
+	for _, st := range bdb.status {
+		if st.LastEpoch >= oldEpoch {
+			status = append(status, st)
+		} else {
+			oldBlocks = append(oldBlocks, st)
+		}
+	}
+	sort.Slice(oldBlocks, func(i, j int) bool { return oldBlocks[i].LastIndex < oldBlocks[j].LastIndex })
+	for _, st := range oldBlocks {
+		if bdb.onExpire != nil {
+			rows, err := bdb.SelectBlockRows(st.BlockNo, nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := bdb.onExpire(rows); err != nil {
+				return err
+			}
+		}
+		// blocks are truncated, not removed like CircuitDB
+		if err := writeBinBlock(bdb.getBlockPath(st.BlockNo), nil, CWriteModeWrite); err != nil {
+			return err
+		}
+	}
+	bdb.status = status
+	return nil
+}
+
+func (bdb *BinDB) SelectFromCurrentTable(conditionCheckFunc func([]string) bool,
+	colNames []string) (RowScanner, error) {
+	return bdb.SelectBlockRows(bdb.blockNo, conditionCheckFunc, colNames)
+}
+
+func (bdb *BinDB) SelectBlockRows(blockNo int, conditionCheckFunc func([]string) bool,
+	colNames []string) (RowScanner, error) {
+	return bdb._selectRows(conditionCheckFunc, []int{blockNo}, colNames)
+}
+
+func (bdb *BinDB) getBlockNos(includeNonCompleted bool) []int {
+	status := make([]*BlockStatus, len(bdb.status))
+	copy(status, bdb.status)
+	sort.Slice(status, func(i, j int) bool { return status[i].LastIndex < status[j].LastIndex })
+	blockNos := make([]int, 0, len(status))
+	for _, st := range status {
+		if !includeNonCompleted && !bdb.completed && bdb.blockNo == st.BlockNo {
+			continue
+		}
+		blockNos = append(blockNos, st.BlockNo)
+	}
+	return blockNos
+}
+
+func (bdb *BinDB) SelectRows(conditionCheckFunc func([]string) bool,
+	blockNos []int, columns []string) (RowScanner, error) {
+	if blockNos == nil {
+		blockNos = bdb.getBlockNos(true)
+	}
+	return bdb._selectRows(conditionCheckFunc, blockNos, columns)
+}
+
+func (bdb *BinDB) SelectCompletedRows(conditionCheckFunc func([]string) bool,
+	blockNos []int, columns []string) (RowScanner, error) {
+	if blockNos == nil {
+		blockNos = bdb.getBlockNos(false)
+	}
+	return bdb._selectRows(conditionCheckFunc, blockNos, columns)
+}
+
+// SelectWhere selects rows matching cond skipping blocks which can't have them
+func (bdb *BinDB) SelectWhere(cond *Cond,
+	blockNos []int, columns []string) (RowScanner, error) {
+	f, err := cond.Compile(bdb.columns, bdb.stats.columnTypes)
+	if err != nil {
+		return nil, err
+	}
+	if blockNos == nil {
+		blockNos = bdb.getBlockNos(true)
+	}
+	return bdb._selectRows(f, bdb.stats.prune(cond, blockNos), columns)
+}
+
+func (bdb *BinDB) _selectRows(conditionCheckFunc func([]string) bool,
+	blockNos []int, columns []string) (RowScanner, error) {
+	if bdb.DataDir == "" {
+		return nil, nil
+	}
+	colIndexes := make([]int, len(columns))
+	for i, col := range columns {
+		j, ok := bdb.colMap[col]
+		if !ok {
+			return nil, errors.Errorf("col %s is not in the table", col)
+		}
+		colIndexes[i] = j
+	}
+	paths := make([]string, len(blockNos))
+	for i, blockNo := range blockNos {
+		paths[i] = bdb.getBlockPath(blockNo)
+	}
+	r := new(binRows)
+	r.paths = paths
+	r.conditionCheckFunc = conditionCheckFunc
+	r.colIndexes = colIndexes
+	r.ncols = len(bdb.columns)
+	return r, nil
+}
+
+// binRows reads the blocks one by one
+type binRows struct {
+	paths              []string
+	pos                int
+	rows               [][]string
+	rowPos             int
+	values             []string
+	colIndexes         []int
+	ncols              int
+	conditionCheckFunc func([]string) bool
+	err                error
+}
+
+func (r *binRows) Next() bool {
+	for {
+		if r.rows == nil {
+			if r.pos >= len(r.paths) {
+				r.err = io.EOF
+				return false
+			}
+			rows, err := readBinBlock(resolvePath(r.paths[r.pos]))
+			if err != nil {
+				r.err = err
+				return false
+			}
+			r.rows = rows
+			r.rowPos = 0
+		}
+		for r.rowPos < len(r.rows) {
+			v := r.rows[r.rowPos]
+			r.rowPos++
+			if r.conditionCheckFunc == nil || r.conditionCheckFunc(v) {
+				r.values = v
+				return true
+			}
+		}
+		r.rows = nil
+		r.pos++
+	}
+}
+
+func (r *binRows) Err() error {
+	return r.err
+}
+
+func (r *binRows) Scan(args ...interface{}) error {
+	if len(r.colIndexes) == 0 {
+		if len(args) != r.ncols {
+			return errors.Errorf("Got %d args while expected %d", len(args), r.ncols)
+		}
+		for i, dst := range args {
+			if err := convFromString(r.values[i], dst); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(args) != len(r.colIndexes) {
+		return errors.Errorf("Got %d args while expected %d", len(args), len(r.colIndexes))
+	}
+	for i, colidx := range r.colIndexes {
+		if err := convFromString(r.values[colidx], args[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read all rows of a block file. rows read before an error are returned with the error
+func readBinBlock(path string) ([][]string, error) {
+	rows := make([][]string, 0)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return rows, nil
+	}
+	if err != nil {
+		return rows, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		ncols, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, io.ErrUnexpectedEOF
+		}
+		row := make([]string, ncols)
+		for i := range row {
+			l, err := binary.ReadUvarint(r)
+			if err != nil {
+				return rows, io.ErrUnexpectedEOF
+			}
+			buf := make([]byte, l)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return rows, io.ErrUnexpectedEOF
+			}
+			row[i] = string(buf)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func writeBinBlock(path string, rows [][]string, writeMode string) error {
+	stagedPath, err := StagedPath(path)
+	if err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if writeMode == CWriteModeAppend {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(stagedPath, flags, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, row := range rows {
+		n := binary.PutUvarint(buf, uint64(len(row)))
+		w.Write(buf[:n])
+		for _, v := range row {
+			n := binary.PutUvarint(buf, uint64(len(v)))
+			w.Write(buf[:n])
+			w.WriteString(v)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func readBinStatus(path string) ([]*BlockStatus, error) {
+	status := make([]*BlockStatus, 0)
+	data, err := os.ReadFile(resolvePath(path))
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, errors.Wrapf(err, "broken status file %s", path)
+	}
+	return status, nil
+}
+
+func writeBinStatus(path string, status []*BlockStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	stagedPath, err := StagedPath(path)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(os.WriteFile(stagedPath, data, 0644))
+}
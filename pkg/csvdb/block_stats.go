@@ -0,0 +1,155 @@
+package csvdb
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// min and max values of a column in a block
+type colStats struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+/*
+blockStats keeps min/max values of the typed columns per block
+so that queries can skip blocks without reading them.
+The statistics of a block only grow until the block is reused by the rotation,
+so they may be wider than the actual rows but never narrower.
+Every row inserted or updated in a block widens them, whichever way it is written.
+Blocks without statistics (e.g. written by an older version) are always read.
+*/
+type blockStats struct {
+	path        string
+	columns     []string
+	columnTypes map[string]string
+	blocks      map[int]map[string]*colStats
+	untracked   map[int]bool
+}
+
+func newBlockStats(path string) *blockStats {
+	return &blockStats{
+		path:        path,
+		columnTypes: make(map[string]string),
+		blocks:      make(map[int]map[string]*colStats),
+		untracked:   make(map[int]bool),
+	}
+}
+
+// setColumnTypes enables statistics on the typed columns of tableCols
+func (bs *blockStats) setColumnTypes(tableCols []string, columnTypes map[string]string) error {
+	bs.columns = make([]string, len(tableCols))
+	for _, colType := range columnTypes {
+		if err := checkColumnType(colType); err != nil {
+			return err
+		}
+	}
+	for i, col := range tableCols {
+		if _, ok := columnTypes[col]; ok {
+			bs.columns[i] = col
+		}
+	}
+	bs.columnTypes = columnTypes
+	return nil
+}
+
+func (bs *blockStats) load() error {
+	if bs.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(resolvePath(bs.path))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	blocks := make(map[int]map[string]*colStats)
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return errors.Wrapf(err, "broken stats file %s", bs.path)
+	}
+	bs.blocks = blocks
+	return nil
+}
+
+// save the statistics of blockNos. statistics of other blocks are dropped
+func (bs *blockStats) save(blockNos []int) error {
+	if bs.path == "" || (len(bs.blocks) == 0 && len(bs.columnTypes) == 0) {
+		return nil
+	}
+	blocks := make(map[int]map[string]*colStats, len(blockNos))
+	for _, blockNo := range blockNos {
+		if stats, ok := bs.blocks[blockNo]; ok {
+			blocks[blockNo] = stats
+		}
+	}
+	bs.blocks = blocks
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	stagedPath, err := StagedPath(bs.path)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(os.WriteFile(stagedPath, data, 0644))
+}
+
+// reset the statistics of a block being overwritten
+func (bs *blockStats) reset(blockNo int) {
+	delete(bs.blocks, blockNo)
+	delete(bs.untracked, blockNo)
+}
+
+// rows appended to a block having rows but no statistics can't make them complete
+func (bs *blockStats) resume(blockNo int) {
+	if _, ok := bs.blocks[blockNo]; !ok {
+		bs.untracked[blockNo] = true
+	}
+}
+
+// widen the statistics of blockNo by row
+func (bs *blockStats) add(blockNo int, row []string) {
+	if len(bs.columnTypes) == 0 || bs.untracked[blockNo] {
+		return
+	}
+	stats, ok := bs.blocks[blockNo]
+	if !ok {
+		stats = make(map[string]*colStats)
+		bs.blocks[blockNo] = stats
+	}
+	for i, col := range bs.columns {
+		if col == "" {
+			continue
+		}
+		v := row[i]
+		st, ok := stats[col]
+		if !ok {
+			stats[col] = &colStats{Min: v, Max: v}
+			continue
+		}
+		colType := bs.columnTypes[col]
+		if compareValues(v, st.Min, colType) < 0 {
+			st.Min = v
+		}
+		if compareValues(v, st.Max, colType) > 0 {
+			st.Max = v
+		}
+	}
+}
+
+// filter blockNos to those which may have rows matching cond
+func (bs *blockStats) prune(cond *Cond, blockNos []int) []int {
+	if cond == nil {
+		return blockNos
+	}
+	found := make([]int, 0, len(blockNos))
+	for _, blockNo := range blockNos {
+		if cond.mayMatch(bs.blocks[blockNo], bs.columnTypes) {
+			found = append(found, blockNo)
+		}
+	}
+	return found
+}
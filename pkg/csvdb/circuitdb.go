@@ -3,6 +3,7 @@ package csvdb
 import (
 	"fmt"
 	"goLogAnalyzer/pkg/utils"
+	"sort"
 	"strconv"
 
 	"github.com/pkg/errors"
@@ -24,6 +25,8 @@ type CircuitDB struct {
 	writeMode   string
 	unitsecs    int64
 	completed   bool
+	stats       *blockStats
+	onExpire    func(rows RowScanner) error
 }
 
 var (
@@ -47,11 +50,16 @@ func NewCircuitDB(rootDir, name string,
 	cdb.maxBlocks = maxBlocks
 	cdb.keepPeriod = keepPeriod
 	cdb.completed = false
+	cdb.stats = newBlockStats("")
 
 	if rootDir == "" {
 		return cdb, nil
 	}
 	cdb.DataDir = fmt.Sprintf("%s/%s", rootDir, name)
+	cdb.stats = newBlockStats(fmt.Sprintf("%s/%s", cdb.DataDir, cStatsFile))
+	if err := cdb.stats.load(); err != nil {
+		return nil, err
+	}
 
 	db, err := NewCsvDB(cdb.DataDir)
 	if err != nil {
@@ -85,9 +93,18 @@ func NewCircuitDB(rootDir, name string,
 	return cdb, nil
 }
 
+// GetBlockTable returns the table of blockNo.
+// rows inserted or updated through it widen the statistics of the block
 func (cdb *CircuitDB) GetBlockTable(blockNo int) (*Table, error) {
 	blockID := cdb.getBlockTableName(blockNo)
-	return cdb.Groups[cdb.Name].GetTable(blockID)
+	t, err := cdb.Groups[cdb.Name].GetTable(blockID)
+	if err != nil {
+		return nil, err
+	}
+	t.onWrite = func(row []string) {
+		cdb.stats.add(blockNo, row)
+	}
+	return t, nil
 }
 
 func (cdb *CircuitDB) SetMaxBlocks(maxBlocks int) {
@@ -97,6 +114,25 @@ func (cdb *CircuitDB) SetBlockSize(blockSize int) {
 	cdb.blockSize = blockSize
 }
 
+// SetColumnTypes declares the types of columns and keeps min/max statistics of them per block
+func (cdb *CircuitDB) SetColumnTypes(columnTypes map[string]string) error {
+	if cdb.DataDir == "" {
+		return nil
+	}
+	g := cdb.Groups[cdb.Name]
+	if err := g.SetColumnTypes(columnTypes); err != nil {
+		return err
+	}
+	cdb.currTable.columnTypes = columnTypes
+	return cdb.stats.setColumnTypes(g.columns, columnTypes)
+}
+
+// SetExpireHandler sets f called with rows of each block before it is deleted by keepPeriod.
+// blocks are passed from the oldest
+func (cdb *CircuitDB) SetExpireHandler(f func(rows RowScanner) error) {
+	cdb.onExpire = f
+}
+
 func (cdb *CircuitDB) LoadCircuitDBStatus() error {
 	if cdb.DataDir == "" {
 		return nil
@@ -130,6 +166,8 @@ func (cdb *CircuitDB) LoadCircuitDBStatus() error {
 		if err := cdb.NextBlock(lastEpoch); err != nil {
 			return err
 		}
+	} else {
+		cdb.stats.resume(cdb.blockNo)
 	}
 	cdb.completed = completed
 
@@ -192,9 +230,11 @@ func (cdb *CircuitDB) InsertRow(columns []string, row ...interface{}) error {
 		}
 		cdb.writeMode = CWriteModeAppend
 		cdb.RowNo = 0
+		cdb.stats.reset(cdb.blockNo)
 	}
 
-	if err := cdb.currTable.InsertRow(columns, row...); err != nil {
+	// the statistics are widened by currTable
+	if _, err := cdb.currTable.insertRow(columns, row...); err != nil {
 		return errors.WithStack(err)
 	}
 	cdb.RowNo++
@@ -219,24 +259,43 @@ func (cdb *CircuitDB) deleteOldBlocks() error {
 		return lastEpoch < oldEpoch
 	}
 
-	rows, err := cdb.SelectFromStatusTable(selectOldBlocks, []string{"blockNo"})
+	rows, err := cdb.SelectFromStatusTable(selectOldBlocks, []string{"lastIndex", "blockNo"})
 
 	if err != nil {
 		return err
 	}
 
-	var blockNo int
+	type oldBlock struct {
+		lastIndex int64
+		blockNo   int
+	}
+	oldBlocks := make([]oldBlock, 0)
 	for rows.Next() {
-		if err := rows.Scan(&blockNo); err != nil {
+		var b oldBlock
+		if err := rows.Scan(&b.lastIndex, &b.blockNo); err != nil {
 			return err
 		}
-		if t, err := cdb.GetBlockTable(blockNo); err != nil {
+		oldBlocks = append(oldBlocks, b)
+	}
+	sort.Slice(oldBlocks, func(i, j int) bool { return oldBlocks[i].lastIndex < oldBlocks[j].lastIndex })
+
+	for _, b := range oldBlocks {
+		t, err := cdb.GetBlockTable(b.blockNo)
+		if err != nil {
 			return err
-		} else {
-			if err := t.Delete(nil); err != nil {
+		}
+		if cdb.onExpire != nil {
+			brows, err := t.SelectRows(nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := cdb.onExpire(brows); err != nil {
 				return err
 			}
 		}
+		if err := t.Delete(nil); err != nil {
+			return err
+		}
 	}
 
 	if err := cdb.statusTable.Delete(selectOldBlocks); err != nil {
@@ -267,10 +326,14 @@ func (cdb *CircuitDB) UpdateBlockStatus(completed bool) error {
 	}
 
 	if err := cdb.deleteOldBlocks(); err != nil {
-		return nil
+		return err
 	}
 
-	return nil
+	blockNos, err := cdb.getBlockNos(true)
+	if err != nil {
+		return err
+	}
+	return cdb.stats.save(blockNos)
 }
 
 func (cdb *CircuitDB) FlushOverwriteCurrentTable() error {
@@ -357,6 +420,26 @@ func (cdb *CircuitDB) SelectCompletedRows(conditionCheckFunc func([]string) bool
 	return cdb._selectRows(conditionCheckFunc, blockNos, columns, false)
 }
 
+// SelectWhere selects rows matching cond skipping blocks which can't have them
+func (cdb *CircuitDB) SelectWhere(cond *Cond,
+	blockNos []int, columns []string) (*circuitRows, error) {
+	if cdb.DataDir == "" {
+		return nil, nil
+	}
+	g := cdb.Groups[cdb.Name]
+	f, err := cond.Compile(g.columns, g.columnTypes)
+	if err != nil {
+		return nil, err
+	}
+	if blockNos == nil {
+		blockNos, err = cdb.getBlockNos(true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cdb._selectRows(f, cdb.stats.prune(cond, blockNos), columns, true)
+}
+
 func (cdb *CircuitDB) _selectRows(conditionCheckFunc func([]string) bool,
 	blockNos []int, columns []string, includeNonCompleted bool) (*circuitRows, error) {
 	var err error
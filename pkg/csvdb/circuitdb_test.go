@@ -292,3 +292,47 @@ func Test_CircuitDB_by_keepPeriod(t *testing.T) {
 	}
 
 }
+
+func Test_CircuitDB_reopen(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_CircuitDB_reopen")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	_initCircuitDB(dataDir, "testcirdb")
+
+	cols := []string{"itemid", "name", "count"}
+	cirdb, err := NewCircuitDB(dataDir, "testcirdb", cols, 3, 0, 0, 0, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for i := 0; i < 2; i++ {
+		if err := _insertRows(cirdb, cols, [][]interface{}{{"row001", "name001", "10"}}, 0, true); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	// opening the db again keeps the blocks it has not read registered
+	cirdb, err = NewCircuitDB(dataDir, "testcirdb", cols, 3, 0, 0, 0, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := cirdb.Groups["testcirdb"].save(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	g := new(TableGroup)
+	if err := g.load(dataDir + "/testcirdb/testcirdb.tbl.ini"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for blockNo := 0; blockNo < 2; blockNo++ {
+		if _, ok := g.tableDefs[cirdb.getBlockTableName(blockNo)]; !ok {
+			t.Errorf("block %d is not registered in %v", blockNo, g.tableDefs)
+			return
+		}
+	}
+}
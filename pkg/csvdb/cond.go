@@ -0,0 +1,286 @@
+package csvdb
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	condEq  = "eq"
+	condIn  = "in"
+	condGe  = "ge"
+	condLe  = "le"
+	condBw  = "between"
+	condAnd = "and"
+	condOr  = "or"
+)
+
+/*
+Cond is a predicate on named columns.
+Values are compared by the column types declared with SetColumnTypes(),
+columns without a type are compared as strings.
+
+	csvdb.And(csvdb.Eq("groupId", id), csvdb.Between("updated", from, to))
+
+A Cond is compiled to a conditionCheckFunc with Compile() or Table.Where(),
+and lets CircuitDB and BinDB skip blocks by their min/max statistics.
+*/
+type Cond struct {
+	op     string
+	col    string
+	values []string
+	conds  []*Cond
+}
+
+func newCond(op, col string, values ...interface{}) *Cond {
+	c := &Cond{op: op, col: col, values: make([]string, len(values))}
+	for i, v := range values {
+		c.values[i] = asString(v)
+	}
+	return c
+}
+
+// Eq matches rows whose col equals v
+func Eq(col string, v interface{}) *Cond {
+	return newCond(condEq, col, v)
+}
+
+// In matches rows whose col equals one of vs
+func In(col string, vs ...interface{}) *Cond {
+	return newCond(condIn, col, vs...)
+}
+
+// Ge matches rows whose col >= v
+func Ge(col string, v interface{}) *Cond {
+	return newCond(condGe, col, v)
+}
+
+// Le matches rows whose col <= v
+func Le(col string, v interface{}) *Cond {
+	return newCond(condLe, col, v)
+}
+
+// Between matches rows whose col is in [from, to]
+func Between(col string, from, to interface{}) *Cond {
+	return newCond(condBw, col, from, to)
+}
+
+// And matches rows matching all of conds
+func And(conds ...*Cond) *Cond {
+	return &Cond{op: condAnd, conds: conds}
+}
+
+// Or matches rows matching any of conds
+func Or(conds ...*Cond) *Cond {
+	return &Cond{op: condOr, conds: conds}
+}
+
+func isIntType(colType string) bool {
+	switch colType {
+	case "int", "int8", "int16", "int32", "int64", "bool",
+		"uint", "uint8", "uint16", "uint32", "uint64":
+		return true
+	}
+	return false
+}
+
+func isFloatType(colType string) bool {
+	return colType == "float32" || colType == "float64"
+}
+
+func checkColumnType(colType string) error {
+	if colType == "" || colType == "string" || isIntType(colType) || isFloatType(colType) {
+		return nil
+	}
+	return errors.Errorf("unknown column type %s", colType)
+}
+
+// canonical form of v so that equality is a string comparison
+func normalizeValue(v, colType string) (string, error) {
+	switch {
+	case colType == "bool":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return asString(b), nil
+	case isIntType(colType):
+		i, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return "", errors.Errorf("%s is not %s", v, colType)
+		}
+		return i.String(), nil
+	case isFloatType(colType):
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return asString(f), nil
+	}
+	return v, nil
+}
+
+// compareValues returns -1, 0 or 1 comparing a and b as colType.
+// values which can't be parsed are compared as strings
+func compareValues(a, b, colType string) int {
+	switch {
+	case isIntType(colType):
+		x, errx := strconv.ParseInt(a, 10, 64)
+		y, erry := strconv.ParseInt(b, 10, 64)
+		if errx == nil && erry == nil {
+			return cmpOrdered(x, y)
+		}
+	case isFloatType(colType):
+		x, errx := strconv.ParseFloat(a, 64)
+		y, erry := strconv.ParseFloat(b, 64)
+		if errx == nil && erry == nil {
+			return cmpOrdered(x, y)
+		}
+	}
+	return cmpOrdered(a, b)
+}
+
+func cmpOrdered[T int64 | float64 | string](x, y T) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	}
+	return 0
+}
+
+// Compile converts c to a conditionCheckFunc for rows of columns
+func (c *Cond) Compile(columns []string,
+	columnTypes map[string]string) (func([]string) bool, error) {
+	if c == nil {
+		return nil, nil
+	}
+	switch c.op {
+	case condAnd, condOr:
+		fs := make([]func([]string) bool, len(c.conds))
+		for i, sub := range c.conds {
+			f, err := sub.Compile(columns, columnTypes)
+			if err != nil {
+				return nil, err
+			}
+			fs[i] = f
+		}
+		if c.op == condAnd {
+			return func(v []string) bool {
+				for _, f := range fs {
+					if f != nil && !f(v) {
+						return false
+					}
+				}
+				return true
+			}, nil
+		}
+		return func(v []string) bool {
+			for _, f := range fs {
+				if f == nil || f(v) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	idx := -1
+	for i, col := range columns {
+		if col == c.col {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, errors.Errorf("col %s is not in the table", c.col)
+	}
+	colType := columnTypes[c.col]
+	if err := checkColumnType(colType); err != nil {
+		return nil, err
+	}
+	values := make([]string, len(c.values))
+	for i, v := range c.values {
+		nv, err := normalizeValue(v, colType)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = nv
+	}
+
+	switch c.op {
+	case condEq, condIn:
+		set := make(map[string]bool, len(values))
+		for _, v := range values {
+			set[v] = true
+		}
+		return func(v []string) bool {
+			return set[v[idx]]
+		}, nil
+	case condGe:
+		return func(v []string) bool {
+			return compareValues(v[idx], values[0], colType) >= 0
+		}, nil
+	case condLe:
+		return func(v []string) bool {
+			return compareValues(v[idx], values[0], colType) <= 0
+		}, nil
+	case condBw:
+		return func(v []string) bool {
+			return compareValues(v[idx], values[0], colType) >= 0 &&
+				compareValues(v[idx], values[1], colType) <= 0
+		}, nil
+	}
+	return nil, errors.Errorf("unknown condition %s", c.op)
+}
+
+// mayMatch returns false only if no row within stats can match c
+func (c *Cond) mayMatch(stats map[string]*colStats, columnTypes map[string]string) bool {
+	if c == nil || stats == nil {
+		return true
+	}
+	switch c.op {
+	case condAnd:
+		for _, sub := range c.conds {
+			if !sub.mayMatch(stats, columnTypes) {
+				return false
+			}
+		}
+		return true
+	case condOr:
+		for _, sub := range c.conds {
+			if sub.mayMatch(stats, columnTypes) {
+				return true
+			}
+		}
+		return len(c.conds) == 0
+	}
+
+	st, ok := stats[c.col]
+	if !ok {
+		return true
+	}
+	colType := columnTypes[c.col]
+	inRange := func(from, to string) bool {
+		return compareValues(st.Max, from, colType) >= 0 &&
+			compareValues(st.Min, to, colType) <= 0
+	}
+	switch c.op {
+	case condEq, condIn:
+		for _, v := range c.values {
+			if inRange(v, v) {
+				return true
+			}
+		}
+		return false
+	case condGe:
+		return compareValues(st.Max, c.values[0], colType) >= 0
+	case condLe:
+		return compareValues(st.Min, c.values[0], colType) <= 0
+	case condBw:
+		return inRange(c.values[0], c.values[1])
+	}
+	return true
+}
@@ -0,0 +1,238 @@
+package csvdb
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"testing"
+)
+
+func Test_Cond(t *testing.T) {
+	cols := []string{"name", "epoch", "score"}
+	colTypes := map[string]string{"epoch": "int64", "score": "float64"}
+	rows := [][]string{
+		{"a", "9", "0.5"},
+		{"b", "10", "1"},
+		{"c", "100", "1.5"},
+	}
+	_count := func(cond *Cond) int {
+		f, err := cond.Compile(cols, colTypes)
+		if err != nil {
+			t.Errorf("%v", err)
+			return -1
+		}
+		cnt := 0
+		for _, row := range rows {
+			if f == nil || f(row) {
+				cnt++
+			}
+		}
+		return cnt
+	}
+
+	cases := []struct {
+		title string
+		cond  *Cond
+		exp   int
+	}{
+		{"nil", nil, 3},
+		{"eq int", Eq("epoch", 10), 1},
+		{"eq float", Eq("score", "1.0"), 1},
+		{"in string", In("name", "a", "c", "z"), 2},
+		// "9" > "10" as strings
+		{"between int", Between("epoch", 9, 10), 2},
+		{"ge float", Ge("score", 1), 2},
+		{"le int", Le("epoch", 99), 2},
+		{"and", And(Ge("epoch", 10), Le("score", 1)), 1},
+		{"or", Or(Eq("name", "a"), Eq("epoch", 100)), 2},
+	}
+	for _, c := range cases {
+		if err := utils.GetGotExpErr(c.title, _count(c.cond), c.exp); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+
+	if _, err := Eq("nocol", 1).Compile(cols, colTypes); err == nil {
+		t.Errorf("unknown column must be an error")
+	}
+	if _, err := Eq("epoch", "x").Compile(cols, colTypes); err == nil {
+		t.Errorf("a value not matching the column type must be an error")
+	}
+}
+
+func _testSelectWhere(t *testing.T, storage string) {
+	dataDir, err := utils.InitTestDir("Test_SelectWhere_" + storage)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	cols := []string{"groupId", "epoch", "count"}
+	colTypes := map[string]string{"groupId": "int64", "epoch": "int64"}
+	s, err := NewStore(storage, dataDir, "teststore", cols, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.SetColumnTypes(colTypes); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// block n has epochs n*100 .. n*100+9
+	for blockNo := 0; blockNo < 5; blockNo++ {
+		rows := make([][]interface{}, 0)
+		for i := 0; i < 10; i++ {
+			rows = append(rows, []interface{}{i % 3, blockNo*100 + i, 1})
+		}
+		if err := _insertStoreRows(s, rows, int64(blockNo*100+9), true); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	// reopen to read the statistics from the disk
+	s, err = NewStore(storage, dataDir, "teststore", cols, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.SetColumnTypes(colTypes); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.LoadCircuitDBStatus(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	cond := And(Eq("groupId", 1), Between("epoch", 105, 250))
+	rows, err := s.SelectWhere(cond, nil, []string{"epoch"})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	epochs := make([]int64, 0)
+	for rows.Next() {
+		var epoch int64
+		if err := rows.Scan(&epoch); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		epochs = append(epochs, epoch)
+	}
+	if err := utils.GetGotExpErr("epochs", len(epochs), 4); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	var stats *blockStats
+	switch db := s.(type) {
+	case *csvStore:
+		stats = db.stats
+	case *BinDB:
+		stats = db.stats
+	}
+	if err := utils.GetGotExpErr("blocks to read", len(stats.prune(cond, []int{0, 1, 2, 3, 4})), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
+
+func Test_SelectWhere(t *testing.T) {
+	_testSelectWhere(t, CStorageCsv)
+	_testSelectWhere(t, CStorageBin)
+}
+
+// epochs of the rows of s matching cond
+func _selectEpochs(s Store, cond *Cond) ([]int64, error) {
+	rows, err := s.SelectWhere(cond, nil, []string{"epoch"})
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	epochs := make([]int64, 0)
+	for rows.Next() {
+		var epoch int64
+		if err := rows.Scan(&epoch); err != nil {
+			return nil, err
+		}
+		epochs = append(epochs, epoch)
+	}
+	return epochs, nil
+}
+
+func Test_SelectWhere_updated(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_SelectWhere_updated")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	cols := []string{"groupId", "epoch", "count"}
+	colTypes := map[string]string{"groupId": "int64", "epoch": "int64"}
+	s, err := NewStore(CStorageCsv, dataDir, "teststore", cols, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.SetColumnTypes(colTypes); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// block n has epochs n*100 .. n*100+9
+	for blockNo := 0; blockNo < 3; blockNo++ {
+		rows := make([][]interface{}, 0)
+		for i := 0; i < 10; i++ {
+			rows = append(rows, []interface{}{i % 3, blockNo*100 + i, 1})
+		}
+		if err := _insertStoreRows(s, rows, int64(blockNo*100+9), true); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	// beyond the max epoch recorded for block 0 by the inserts
+	cdb := s.(*csvStore).CircuitDB
+	tbl, err := cdb.GetBlockTable(0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := tbl.Update(func(v []string) bool { return v[1] == "5" },
+		map[string]interface{}{"epoch": 500}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := tbl.Upsert(func(v []string) bool { return v[1] == "600" },
+		map[string]interface{}{"groupId": 9, "epoch": 600, "count": 1}); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.UpdateBlockStatus(false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the statistics saved with the updates
+	s, err = NewStore(CStorageCsv, dataDir, "teststore", cols, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.SetColumnTypes(colTypes); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.LoadCircuitDBStatus(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for _, cond := range []*Cond{Ge("epoch", 400), Between("epoch", 450, 650)} {
+		epochs, err := _selectEpochs(s, cond)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := utils.GetGotExpErr("epochs", fmt.Sprint(epochs), "[500 600]"); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+}
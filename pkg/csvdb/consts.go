@@ -1,5 +1,7 @@
 package csvdb
 
+import "time"
+
 const (
 	CWriteModeAppend = "a"
 	CWriteModeWrite  = "w"
@@ -16,3 +18,21 @@ const (
 const (
 	cMaxBlockDitigs = 10
 )
+
+const (
+	cTxnSuffix         = ".txn"
+	cTxnManifest       = "manifest.json"
+	cTxnStatePending   = "pending"
+	cTxnStateCommitted = "committed"
+)
+
+const (
+	cBinBlockExt   = "bin"
+	cBinStatusFile = "status.json"
+	cStatsFile     = "blockstats.json"
+)
+
+const (
+	cLockFile          = ".lock"
+	cLockRetryInterval = 100 * time.Millisecond
+)
@@ -45,6 +45,11 @@ func (db *CsvDB) CreateGroup(groupName string,
 	if err != nil {
 		return nil, err
 	}
+	// keep the tables of the group loaded from its ini file
+	// so that saving the group does not unregister them
+	if loaded, ok := db.Groups[groupName]; ok {
+		g.tableDefs = loaded.tableDefs
+	}
 	db.Groups[groupName] = g
 	return g, nil
 }
@@ -158,3 +158,85 @@ func TestCsvDb(t *testing.T) {
 		return
 	}
 }
+
+func TestCsvDb_CreateGroupReopened(t *testing.T) {
+	rootDir, err := utils.InitTestDir("TestCsvDb_CreateGroupReopened")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	columns := []string{"id", "name"}
+	db, err := NewCsvDB(rootDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	g, err := db.CreateGroup("grp", columns, false, 1, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	tb, err := g.CreateTable("t1")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := tb.InsertRow(nil, 1, "user1"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := tb.Flush(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// creating the group again after reopening must not drop t1 from its ini file
+	db, err = NewCsvDB(rootDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	g, err = db.CreateGroup("grp", columns, false, 1, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	tb, err = g.CreateTable("t2")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := tb.InsertRow(nil, 2, "user2"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := tb.Flush(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	db, err = NewCsvDB(rootDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	g, err = db.GetGroup("grp")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for _, tableName := range []string{"t1", "t2"} {
+		if err := utils.GetGotExpErr(tableName+" exists", g.TableExists(tableName), true); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	tb, err = g.GetTable("t1")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("rows of t1", tb.Count(nil), 1); err != nil {
+		t.Errorf("%v", err)
+	}
+}
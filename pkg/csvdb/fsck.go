@@ -0,0 +1,454 @@
+package csvdb
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CSeverityError   = "ERROR"
+	CSeverityWarning = "WARN"
+)
+
+// Problem is an inconsistency found by Check* functions
+type Problem struct {
+	Severity string
+	Path     string
+	Message  string
+	repair   func() error
+}
+
+func newProblem(severity, path, format string, args ...interface{}) *Problem {
+	p := new(Problem)
+	p.Severity = severity
+	p.Path = path
+	p.Message = fmt.Sprintf(format, args...)
+	return p
+}
+
+// NewProblem creates a Problem which is repaired by calling repair. repair can be nil
+func NewProblem(severity, path, message string, repair func() error) *Problem {
+	p := newProblem(severity, path, "%s", message)
+	p.repair = repair
+	return p
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%-5s %s: %s", p.Severity, p.Path, p.Message)
+}
+
+// Repairable returns true if Repair() can fix the problem
+func (p *Problem) Repairable() bool {
+	return p.repair != nil
+}
+
+// Repair drops or rebuilds the broken part
+func (p *Problem) Repair() error {
+	if p.repair == nil {
+		return errors.Errorf("%s: not repairable", p.Path)
+	}
+	return p.repair()
+}
+
+// read all records of a table file.
+// rows are read until the first broken part and returned with the problems found
+func readTableFile(path string, ncols int) ([][]string, []*Problem, error) {
+	problems := make([]*Problem, 0)
+	fr, err := os.Open(path)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer fr.Close()
+
+	var r io.Reader = fr
+	ext := filepath.Ext(path)
+	if ext == ".gz" || ext == ".gzip" {
+		zr, err := gzip.NewReader(fr)
+		if err == io.EOF {
+			// empty file
+			return [][]string{}, problems, nil
+		}
+		if err != nil {
+			problems = append(problems, newProblem(CSeverityError, path, "broken gzip header: %v", err))
+			return [][]string{}, problems, nil
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	rows := make([][]string, 0)
+	badRows := 0
+	line := 0
+	for {
+		v, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err == io.ErrUnexpectedEOF || err == gzip.ErrChecksum {
+			problems = append(problems, newProblem(CSeverityError, path,
+				"truncated stream after %d rows", len(rows)))
+			break
+		}
+		if err != nil {
+			problems = append(problems, newProblem(CSeverityError, path,
+				"unreadable at row %d: %v", line, err))
+			break
+		}
+		if ncols > 0 && len(v) != ncols {
+			badRows++
+			continue
+		}
+		rows = append(rows, v)
+	}
+	if badRows > 0 {
+		problems = append(problems, newProblem(CSeverityError, path,
+			"%d rows do not have %d columns", badRows, ncols))
+	}
+	return rows, problems, nil
+}
+
+func rewriteTableFile(path string, rows [][]string) error {
+	w, err := newWriter(path, CWriteModeWrite)
+	if err != nil {
+		return err
+	}
+	defer w.close()
+	for _, row := range rows {
+		if err := w.write(row); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	w.flush()
+	return nil
+}
+
+// CheckTableGroup checks ini and table files of the group.
+func CheckTableGroup(g *TableGroup) ([]*Problem, error) {
+	problems := make([]*Problem, 0)
+	if len(g.columns) == 0 || (len(g.columns) == 1 && g.columns[0] == "") {
+		problems = append(problems, newProblem(CSeverityError, g.iniFile, "no columns defined"))
+		return problems, nil
+	}
+
+	tableNames := make([]string, 0, len(g.tableDefs))
+	for tableName := range g.tableDefs {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		path := g.getTablePath(tableName)
+		if !utils.PathExist(path) {
+			// tables are created on the first flush
+			continue
+		}
+		rows, tproblems, err := readTableFile(path, len(g.columns))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range tproblems {
+			rows := rows
+			path := path
+			p.repair = func() error {
+				return rewriteTableFile(path, rows)
+			}
+		}
+		problems = append(problems, tproblems...)
+	}
+	return problems, nil
+}
+
+// CheckCsvDB checks all table groups in baseDir
+func CheckCsvDB(baseDir string) ([]*Problem, error) {
+	problems := make([]*Problem, 0)
+	iniFiles, err := filepath.Glob(fmt.Sprintf("%s/*.%s", baseDir, cTblIniExt))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sort.Strings(iniFiles)
+	for _, iniFile := range iniFiles {
+		g := new(TableGroup)
+		if err := g.load(iniFile); err != nil {
+			problems = append(problems, newProblem(CSeverityError, iniFile, "broken ini file: %v", err))
+			continue
+		}
+		gproblems, err := CheckTableGroup(g)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, gproblems...)
+	}
+	return problems, nil
+}
+
+// CheckCircuitDB checks table files of the CircuitDB at rootDir/name
+// and that all blocks in CircuitDBStatus exist.
+func CheckCircuitDB(rootDir, name string) ([]*Problem, error) {
+	dataDir := fmt.Sprintf("%s/%s", rootDir, name)
+	if !utils.PathExist(dataDir) {
+		return []*Problem{}, nil
+	}
+	problems, err := CheckCsvDB(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	statusIni := fmt.Sprintf("%s/CircuitDBStatus.%s", dataDir, cTblIniExt)
+	blockIni := fmt.Sprintf("%s/%s.%s", dataDir, name, cTblIniExt)
+	if !utils.PathExist(statusIni) {
+		problems = append(problems, newProblem(CSeverityError, statusIni, "status table is missing"))
+		return problems, nil
+	}
+	if !utils.PathExist(blockIni) {
+		problems = append(problems, newProblem(CSeverityError, blockIni, "block table group is missing"))
+		return problems, nil
+	}
+	sg := new(TableGroup)
+	if err := sg.load(statusIni); err != nil {
+		return problems, nil
+	}
+	bg := new(TableGroup)
+	if err := bg.load(blockIni); err != nil {
+		return problems, nil
+	}
+
+	statusPath := sg.getTablePath("CircuitDBStatus")
+	if !utils.PathExist(statusPath) {
+		return problems, nil
+	}
+	statusRows, _, err := readTableFile(statusPath, len(CircuitColumns))
+	if err != nil {
+		return nil, err
+	}
+
+	goodRows := make([][]string, 0, len(statusRows))
+	registered := make(map[string]bool)
+	missing := make([]string, 0)
+	for _, row := range statusRows {
+		blockID := row[ColBlockId]
+		rowNo, _ := strconv.Atoi(row[ColRowNo])
+		path := bg.getTablePath(blockID)
+		if _, ok := bg.tableDefs[blockID]; !ok && rowNo > 0 {
+			missing = append(missing, blockID)
+			continue
+		}
+		if !utils.PathExist(path) && rowNo > 0 {
+			missing = append(missing, blockID)
+			continue
+		}
+		registered[blockID] = true
+		goodRows = append(goodRows, row)
+	}
+	if len(missing) > 0 {
+		p := newProblem(CSeverityError, statusPath,
+			"blocks %v are registered but their files do not exist", missing)
+		p.repair = func() error {
+			return rewriteTableFile(statusPath, goodRows)
+		}
+		problems = append(problems, p)
+	}
+
+	for blockID := range bg.tableDefs {
+		path := bg.getTablePath(blockID)
+		if registered[blockID] || !utils.PathExist(path) {
+			continue
+		}
+		// blocks deleted by the rotation are truncated, not removed
+		if rows, _, err := readTableFile(path, 0); err != nil || len(rows) == 0 {
+			continue
+		}
+		p := newProblem(CSeverityWarning, path, "block %s is not registered in CircuitDBStatus", blockID)
+		p.repair = func() error {
+			return errors.WithStack(os.Remove(path))
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// CheckCircuitDBColumn checks the values of column in all blocks of the CircuitDB at rootDir/name.
+// Rows where isValid returns false are reported and dropped by Repair()
+func CheckCircuitDBColumn(rootDir, name, column string,
+	isValid func(string) bool, message string) ([]*Problem, error) {
+	problems := make([]*Problem, 0)
+	blockIni := fmt.Sprintf("%s/%s/%s.%s", rootDir, name, name, cTblIniExt)
+	if !utils.PathExist(blockIni) {
+		return problems, nil
+	}
+	g := new(TableGroup)
+	if err := g.load(blockIni); err != nil {
+		return problems, nil
+	}
+	idx := -1
+	for i, col := range g.columns {
+		if col == column {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, errors.Errorf("column %s is not in %s", column, blockIni)
+	}
+
+	tableNames := make([]string, 0, len(g.tableDefs))
+	for tableName := range g.tableDefs {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	for _, tableName := range tableNames {
+		path := g.getTablePath(tableName)
+		if !utils.PathExist(path) {
+			continue
+		}
+		rows, _, err := readTableFile(path, len(g.columns))
+		if err != nil {
+			return nil, err
+		}
+		goodRows := make([][]string, 0, len(rows))
+		bad := make([]string, 0)
+		for _, row := range rows {
+			if isValid(row[idx]) {
+				goodRows = append(goodRows, row)
+			} else {
+				bad = append(bad, row[idx])
+			}
+		}
+		if len(bad) == 0 {
+			continue
+		}
+		if len(bad) > 5 {
+			bad = append(bad[:5], "...")
+		}
+		p := newProblem(CSeverityError, path, "%s: %v", message, bad)
+		p.repair = func() error {
+			return rewriteTableFile(path, goodRows)
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// CheckStore checks the store of storage at rootDir/name
+func CheckStore(storage, rootDir, name string) ([]*Problem, error) {
+	switch storage {
+	case "", CStorageCsv:
+		return CheckCircuitDB(rootDir, name)
+	case CStorageBin:
+		return CheckBinDB(rootDir, name)
+	}
+	return nil, errors.Errorf("unknown storage %s", storage)
+}
+
+// CheckStoreColumn checks the values of column like CheckCircuitDBColumn()
+func CheckStoreColumn(storage, rootDir, name string, columns []string, column string,
+	isValid func(string) bool, message string) ([]*Problem, error) {
+	switch storage {
+	case "", CStorageCsv:
+		return CheckCircuitDBColumn(rootDir, name, column, isValid, message)
+	case CStorageBin:
+		return CheckBinDBColumn(rootDir, name, columns, column, isValid, message)
+	}
+	return nil, errors.Errorf("unknown storage %s", storage)
+}
+
+// CheckBinDB checks block files of the BinDB at rootDir/name
+// and that all blocks in its status exist.
+func CheckBinDB(rootDir, name string) ([]*Problem, error) {
+	problems := make([]*Problem, 0)
+	bdb := &BinDB{DataDir: fmt.Sprintf("%s/%s", rootDir, name)}
+	if !utils.PathExist(bdb.DataDir) {
+		return problems, nil
+	}
+	statusPath := bdb.getStatusPath()
+	status, err := readBinStatus(statusPath)
+	if err != nil {
+		problems = append(problems, newProblem(CSeverityError, statusPath, "%v", err))
+		return problems, nil
+	}
+
+	goodStatus := make([]*BlockStatus, 0, len(status))
+	missing := make([]string, 0)
+	for _, st := range status {
+		path := bdb.getBlockPath(st.BlockNo)
+		if !utils.PathExist(path) && st.RowNo > 0 {
+			missing = append(missing, st.BlockID)
+			continue
+		}
+		goodStatus = append(goodStatus, st)
+
+		rows, err := readBinBlock(path)
+		if err == nil {
+			continue
+		}
+		p := newProblem(CSeverityError, path, "truncated after %d rows", len(rows))
+		p.repair = func() error {
+			return writeBinBlock(path, rows, CWriteModeWrite)
+		}
+		problems = append(problems, p)
+	}
+	if len(missing) > 0 {
+		p := newProblem(CSeverityError, statusPath,
+			"blocks %v are registered but their files do not exist", missing)
+		p.repair = func() error {
+			return writeBinStatus(statusPath, goodStatus)
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// CheckBinDBColumn checks the values of column in all blocks of the BinDB at rootDir/name.
+func CheckBinDBColumn(rootDir, name string, columns []string, column string,
+	isValid func(string) bool, message string) ([]*Problem, error) {
+	problems := make([]*Problem, 0)
+	idx := -1
+	for i, col := range columns {
+		if col == column {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return nil, errors.Errorf("column %s is not in %s", column, name)
+	}
+	bdb := &BinDB{DataDir: fmt.Sprintf("%s/%s", rootDir, name)}
+	status, err := readBinStatus(bdb.getStatusPath())
+	if err != nil {
+		return problems, nil
+	}
+	for _, st := range status {
+		path := bdb.getBlockPath(st.BlockNo)
+		rows, _ := readBinBlock(path)
+		goodRows := make([][]string, 0, len(rows))
+		bad := make([]string, 0)
+		for _, row := range rows {
+			if idx < len(row) && isValid(row[idx]) {
+				goodRows = append(goodRows, row)
+			} else if idx < len(row) {
+				bad = append(bad, row[idx])
+			}
+		}
+		if len(bad) == 0 {
+			continue
+		}
+		if len(bad) > 5 {
+			bad = append(bad[:5], "...")
+		}
+		p := newProblem(CSeverityError, path, "%s: %v", message, bad)
+		p.repair = func() error {
+			return writeBinBlock(path, goodRows, CWriteModeWrite)
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
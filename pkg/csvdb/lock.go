@@ -0,0 +1,99 @@
+package csvdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Lock is an advisory lock on a data directory.
+Writers take it exclusively and readers take it shared,
+so readers never see half flushed block files.
+The lock is shared by all users in the same process.
+*/
+type Lock struct {
+	dir       string
+	path      string
+	exclusive bool
+	refs      int
+	handle    lockHandle
+}
+
+var (
+	heldLocks   = make(map[string]*Lock)
+	heldLocksMu sync.Mutex
+)
+
+// LockDir locks dir. If another process holds a conflicting lock,
+// it retries until timeout and returns an error naming the holder
+func LockDir(dir string, exclusive bool, timeout time.Duration) (*Lock, error) {
+	dir = filepath.Clean(dir)
+	path := fmt.Sprintf("%s/%s", dir, cLockFile)
+	deadline := time.Now().Add(timeout)
+	for {
+		l, ok, err := tryLockDir(dir, path, exclusive)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return l, nil
+		}
+		// the other locks in the process are not held up while waiting
+		if !time.Now().Before(deadline) {
+			return nil, errors.Errorf("could not lock %s within %s: held by %s",
+				dir, timeout, lockHolders(path))
+		}
+		time.Sleep(cLockRetryInterval)
+	}
+}
+
+// lock dir once without waiting
+func tryLockDir(dir, path string, exclusive bool) (*Lock, bool, error) {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+
+	if l, ok := heldLocks[dir]; ok {
+		if exclusive && !l.exclusive {
+			// a reader in this process starts writing
+			ok, err := relock(l.handle, true)
+			if err != nil || !ok {
+				return nil, false, err
+			}
+			l.exclusive = true
+		}
+		l.refs++
+		return l, true, nil
+	}
+
+	h, ok, err := tryLock(path, exclusive)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	l := &Lock{dir: dir, path: path, exclusive: exclusive, refs: 1, handle: h}
+	heldLocks[dir] = l
+	return l, true, nil
+}
+
+// Unlock releases the lock when all users in the process have released it
+func (l *Lock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	l.refs--
+	if l.refs > 0 {
+		return nil
+	}
+	delete(heldLocks, l.dir)
+	return unlock(l.handle)
+}
+
+// IsExclusive returns true if the lock is for writing
+func (l *Lock) IsExclusive() bool {
+	return l.exclusive
+}
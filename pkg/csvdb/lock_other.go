@@ -0,0 +1,22 @@
+//go:build !unix
+
+package csvdb
+
+// file locking is not supported on this platform
+type lockHandle struct{}
+
+func tryLock(path string, exclusive bool) (lockHandle, bool, error) {
+	return lockHandle{}, true, nil
+}
+
+func relock(h lockHandle, exclusive bool) (bool, error) {
+	return true, nil
+}
+
+func unlock(h lockHandle) error {
+	return nil
+}
+
+func lockHolders(path string) string {
+	return "another process"
+}
@@ -0,0 +1,155 @@
+//go:build unix
+
+package csvdb
+
+import (
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_LockDir(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_LockDir")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// re-entrant in the same process
+	lock1, err := LockDir(dataDir, false, time.Second)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	lock2, err := LockDir(dataDir, true, time.Second)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("upgraded", lock1.IsExclusive(), true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// another open file conflicts like another process
+	lockPath := fmt.Sprintf("%s/%s", dataDir, cLockFile)
+	_, ok, err := tryLock(lockPath, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("locked while held", ok, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if !strings.Contains(lockHolders(lockPath), "exclusive") && lockHolders(lockPath) != "another process" {
+		t.Errorf("unexpected holder %s", lockHolders(lockPath))
+		return
+	}
+
+	lock2.Unlock()
+	if _, ok, _ := tryLock(lockPath, false); ok {
+		t.Errorf("released before all users unlocked")
+		return
+	}
+	lock1.Unlock()
+	h, ok, err := tryLock(lockPath, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("locked after release", ok, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// LockDir gives up after timeout
+	_, err = LockDir(dataDir, false, 200*time.Millisecond)
+	if err == nil {
+		t.Errorf("locked while another file holds the lock")
+		return
+	}
+	unlock(h)
+}
+
+func Test_LockDir_upgradeKeepsShared(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_LockDir_upgradeKeepsShared")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	lockPath := fmt.Sprintf("%s/%s", dataDir, cLockFile)
+	lock, err := LockDir(dataDir, false, time.Second)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer lock.Unlock()
+
+	// another reader makes the upgrade fail
+	h, ok, err := tryLock(lockPath, false)
+	if err != nil || !ok {
+		t.Errorf("another reader could not lock: %v", err)
+		return
+	}
+	if _, err := LockDir(dataDir, true, 200*time.Millisecond); err == nil {
+		t.Errorf("upgraded while another reader holds the lock")
+		return
+	}
+	if err := utils.GetGotExpErr("exclusive", lock.IsExclusive(), false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	unlock(h)
+
+	// the shared lock is still held
+	if h, ok, _ := tryLock(lockPath, true); ok {
+		unlock(h)
+		t.Errorf("the shared lock was dropped by the failed upgrade")
+	}
+}
+
+func Test_LockDir_waitDoesNotBlockOthers(t *testing.T) {
+	dirA, err := utils.InitTestDir("Test_LockDir_waitDoesNotBlockOthersA")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	dirB, err := utils.InitTestDir("Test_LockDir_waitDoesNotBlockOthersB")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	h, _, err := tryLock(fmt.Sprintf("%s/%s", dirA, cLockFile), true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer unlock(h)
+
+	waiting := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		close(waiting)
+		_, err := LockDir(dirA, false, time.Second)
+		done <- err
+	}()
+	<-waiting
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	lock, err := LockDir(dirB, true, time.Second)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	lock.Unlock()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("locking another dir waited %s for the one retrying", elapsed)
+	}
+	if err := <-done; err == nil {
+		t.Errorf("locked %s held by another file", dirA)
+	}
+}
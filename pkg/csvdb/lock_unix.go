@@ -0,0 +1,121 @@
+//go:build unix
+
+package csvdb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+type lockHandle *os.File
+
+func tryLock(path string, exclusive bool) (lockHandle, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, false, nil
+		}
+		return nil, false, errors.WithStack(err)
+	}
+	return f, true, nil
+}
+
+/*
+convert the lock held by h.
+flock may release the old lock before it fails to take the new one,
+so a shared lock failing to upgrade is taken again
+*/
+func relock(h lockHandle, exclusive bool) (bool, error) {
+	f := (*os.File)(h)
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		if err != syscall.EWOULDBLOCK {
+			return false, errors.WithStack(err)
+		}
+		if exclusive {
+			if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+				return false, errors.Errorf("lost the shared lock of %s while upgrading it: %v", f.Name(), err)
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func unlock(h lockHandle) error {
+	f := (*os.File)(h)
+	if f == nil {
+		return nil
+	}
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return errors.WithStack(f.Close())
+}
+
+// describe processes holding flock on path using /proc/locks (linux only)
+func lockHolders(path string) string {
+	unknown := "another process"
+	st, err := os.Stat(path)
+	if err != nil {
+		return unknown
+	}
+	sys, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return unknown
+	}
+	inode := strconv.FormatUint(uint64(sys.Ino), 10)
+
+	f, err := os.Open("/proc/locks")
+	if err != nil {
+		return unknown
+	}
+	defer f.Close()
+
+	// 1: FLOCK  ADVISORY  WRITE 1234 00:2e:5678 0 EOF
+	holders := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[1] != "FLOCK" {
+			continue
+		}
+		dev := strings.Split(fields[5], ":")
+		if len(dev) != 3 || dev[2] != inode {
+			continue
+		}
+		pid := fields[4]
+		mode := "shared"
+		if fields[3] == "WRITE" {
+			mode = "exclusive"
+		}
+		holders = append(holders, fmt.Sprintf("pid %s (%s, %s)", pid, processName(pid), mode))
+	}
+	if len(holders) == 0 {
+		return unknown
+	}
+	return strings.Join(holders, ", ")
+}
+
+func processName(pid string) string {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%s/cmdline", pid))
+	if err != nil || len(data) == 0 {
+		return "unknown"
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(data), "\x00", " "))
+}
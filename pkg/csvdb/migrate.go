@@ -0,0 +1,114 @@
+package csvdb
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// read block statuses and rows of all blocks from the store at rootDir/name
+func readStoreBlocks(storage, rootDir, name string, columns []string,
+	useGzip bool) ([]*BlockStatus, map[int][][]string, error) {
+	blocks := make(map[int][][]string)
+	switch storage {
+	case "", CStorageCsv:
+		cdb, err := NewCircuitDB(rootDir, name, columns, 0, 0, 0, 0, useGzip)
+		if err != nil {
+			return nil, nil, err
+		}
+		status := make([]*BlockStatus, 0)
+		rows, err := cdb.SelectFromStatusTable(nil, CircuitColumns)
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			st := new(BlockStatus)
+			if err := rows.Scan(&st.LastIndex, &st.BlockNo, &st.BlockID,
+				&st.RowNo, &st.LastEpoch, &st.Completed); err != nil {
+				return nil, nil, err
+			}
+			status = append(status, st)
+		}
+		for _, st := range status {
+			t, err := cdb.GetBlockTable(st.BlockNo)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !utils.PathExist(t.path) {
+				continue
+			}
+			brows, problems, err := readTableFile(t.path, len(columns))
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(problems) > 0 {
+				return nil, nil, errors.Errorf("%s. run fsck -repair first", problems[0])
+			}
+			blocks[st.BlockNo] = brows
+		}
+		return status, blocks, nil
+
+	case CStorageBin:
+		bdb, err := NewBinDB(rootDir, name, columns, 0, 0, 0, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, st := range bdb.status {
+			brows, err := readBinBlock(bdb.getBlockPath(st.BlockNo))
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "%s is broken. run fsck -repair first", bdb.getBlockPath(st.BlockNo))
+			}
+			blocks[st.BlockNo] = brows
+		}
+		return bdb.status, blocks, nil
+	}
+	return nil, nil, errors.Errorf("unknown storage %s", storage)
+}
+
+/*
+CopyStore copies all blocks and their statuses of the store at srcRoot/name
+to a new store of storage "to" at dstRoot/name.
+Block numbers, indexes and epochs are kept so the copy continues the rotation.
+*/
+func CopyStore(srcRoot, dstRoot, name string, columns []string,
+	from, to string, useGzip bool) error {
+	status, blocks, err := readStoreBlocks(from, srcRoot, name, columns, useGzip)
+	if err != nil {
+		return err
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].LastIndex < status[j].LastIndex })
+
+	switch to {
+	case "", CStorageCsv:
+		cdb, err := NewCircuitDB(dstRoot, name, columns, 0, 0, 0, 0, useGzip)
+		if err != nil {
+			return err
+		}
+		statusRows := make([][]string, 0, len(status))
+		for _, st := range status {
+			t, err := cdb.GetBlockTable(st.BlockNo)
+			if err != nil {
+				return err
+			}
+			if err := rewriteTableFile(t.path, blocks[st.BlockNo]); err != nil {
+				return err
+			}
+			statusRows = append(statusRows, st.statusRow())
+		}
+		return rewriteTableFile(cdb.statusTable.path, statusRows)
+
+	case CStorageBin:
+		bdb, err := NewBinDB(dstRoot, name, columns, 0, 0, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, st := range status {
+			if err := writeBinBlock(bdb.getBlockPath(st.BlockNo), blocks[st.BlockNo], CWriteModeWrite); err != nil {
+				return err
+			}
+		}
+		return writeBinStatus(bdb.getStatusPath(), status)
+	}
+	return errors.Errorf("unknown storage %s", to)
+}
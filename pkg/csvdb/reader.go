@@ -66,11 +66,12 @@ func (c *Reader) open() error {
 	var err error
 	mode := ""
 
-	if !utils.PathExist(c.filename) {
+	path := resolvePath(c.filename)
+	if !utils.PathExist(path) {
 		return nil
 	}
 
-	fr, err = os.Open(c.filename)
+	fr, err = os.Open(path)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -152,7 +153,7 @@ func (c *Reader) hasRows() bool {
 	if c.readBuff != nil && c.readBuff.pos >= 0 {
 		return true
 	}
-	if c.readBuff == nil && utils.PathExist(c.filename) {
+	if c.readBuff == nil && utils.PathExist(resolvePath(c.filename)) {
 		return true
 	}
 	return false
@@ -0,0 +1,121 @@
+package csvdb
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	CStorageCsv = "csv"
+	CStorageBin = "bin"
+)
+
+// RowScanner iterates rows selected from a Store
+type RowScanner interface {
+	Next() bool
+	Scan(args ...interface{}) error
+}
+
+/*
+Store is a table rotating its rows over blocks.
+Rows are inserted to the current block, NextBlock() moves to the next one
+and blocks older than keepPeriod are cleared after being passed to the expire handler.
+
+  - csv: CircuitDB. a CSV (or gzip) file per block
+  - bin: BinDB. a length prefixed binary file per block
+*/
+type Store interface {
+	SetMaxBlocks(maxBlocks int)
+	SetBlockSize(blockSize int)
+	SetColumnTypes(columnTypes map[string]string) error
+	SetExpireHandler(f func(rows RowScanner) error)
+	LoadCircuitDBStatus() error
+	CountFromStatusTable(conditionCheckFunc func([]string) bool) int
+	InsertRow(columns []string, row ...interface{}) error
+	FlushOverwriteCurrentTable() error
+	NextBlock(lastEpoch int64) error
+	UpdateBlockStatus(completed bool) error
+	SelectFromCurrentTable(conditionCheckFunc func([]string) bool,
+		colNames []string) (RowScanner, error)
+	SelectBlockRows(blockNo int, conditionCheckFunc func([]string) bool,
+		colNames []string) (RowScanner, error)
+	SelectRows(conditionCheckFunc func([]string) bool,
+		blockNos []int, columns []string) (RowScanner, error)
+	SelectCompletedRows(conditionCheckFunc func([]string) bool,
+		blockNos []int, columns []string) (RowScanner, error)
+	SelectWhere(cond *Cond, blockNos []int, columns []string) (RowScanner, error)
+}
+
+// NewStore opens the Store of storage at rootDir/name. storage "" means csv
+func NewStore(storage, rootDir, name string,
+	columns []string,
+	maxBlocks, blockSize int,
+	keepPeriod, unitSecs int64,
+	useGzip bool) (Store, error) {
+	switch storage {
+	case "", CStorageCsv:
+		cdb, err := NewCircuitDB(rootDir, name, columns,
+			maxBlocks, blockSize, keepPeriod, unitSecs, useGzip)
+		if err != nil {
+			return nil, err
+		}
+		return &csvStore{CircuitDB: cdb}, nil
+	case CStorageBin:
+		return NewBinDB(rootDir, name, columns,
+			maxBlocks, blockSize, keepPeriod, unitSecs)
+	}
+	return nil, errors.Errorf("unknown storage %s", storage)
+}
+
+// csvStore adapts CircuitDB to Store
+type csvStore struct {
+	*CircuitDB
+}
+
+func (s *csvStore) SelectFromCurrentTable(conditionCheckFunc func([]string) bool,
+	colNames []string) (RowScanner, error) {
+	rows, err := s.CircuitDB.SelectFromCurrentTable(conditionCheckFunc, colNames)
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *csvStore) SelectBlockRows(blockNo int, conditionCheckFunc func([]string) bool,
+	colNames []string) (RowScanner, error) {
+	t, err := s.GetBlockTable(blockNo)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := t.SelectRows(conditionCheckFunc, colNames)
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *csvStore) SelectRows(conditionCheckFunc func([]string) bool,
+	blockNos []int, columns []string) (RowScanner, error) {
+	rows, err := s.CircuitDB.SelectRows(conditionCheckFunc, blockNos, columns)
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *csvStore) SelectCompletedRows(conditionCheckFunc func([]string) bool,
+	blockNos []int, columns []string) (RowScanner, error) {
+	rows, err := s.CircuitDB.SelectCompletedRows(conditionCheckFunc, blockNos, columns)
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *csvStore) SelectWhere(cond *Cond,
+	blockNos []int, columns []string) (RowScanner, error) {
+	rows, err := s.CircuitDB.SelectWhere(cond, blockNos, columns)
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,201 @@
+package csvdb
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"strings"
+	"testing"
+)
+
+func _insertStoreRows(s Store, rows [][]interface{}, lastEpoch int64, goNextBlock bool) error {
+	for _, row := range rows {
+		if err := s.InsertRow(nil, row...); err != nil {
+			return err
+		}
+	}
+	if err := s.FlushOverwriteCurrentTable(); err != nil {
+		return err
+	}
+	if goNextBlock {
+		return s.NextBlock(lastEpoch)
+	}
+	return s.UpdateBlockStatus(false)
+}
+
+// sum of count by name
+func _sumStoreRows(rows RowScanner, err error) (map[string]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]int)
+	if rows == nil {
+		return sums, nil
+	}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
+		}
+		sums[name] += count
+	}
+	return sums, nil
+}
+
+func _testStore(t *testing.T, storage string) {
+	dataDir, err := utils.InitTestDir("Test_Store_" + storage)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	cols := []string{"itemid", "name", "count"}
+	selCols := []string{"name", "count"}
+
+	// 3 blocks rotate and blocks older than 2 days are cleared
+	s, err := NewStore(storage, dataDir, "teststore", cols, 3, 0, 2, 86400, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	blocks := [][][]interface{}{
+		{{"row001", "name001", 10}, {"row002", "name002", 20}},
+		{{"row101", "name001", 1}},
+		{{"row201", "name001", 100}, {"row202", "name002", 200}},
+	}
+	for i, rows := range blocks {
+		if err := _insertStoreRows(s, rows, int64(i)*86400, i < len(blocks)-1); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+
+	sums, err := _sumStoreRows(s.SelectRows(nil, nil, selCols))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("name001", sums["name001"], 111); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	sums, err = _sumStoreRows(s.SelectBlockRows(1, nil, selCols))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("block 1", sums["name001"], 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// reload from the disk. the current block is not completed
+	s, err = NewStore(storage, dataDir, "teststore", cols, 3, 0, 2, 86400, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := s.LoadCircuitDBStatus(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	sums, err = _sumStoreRows(s.SelectCompletedRows(nil, nil, selCols))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("completed name001", sums["name001"], 11); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// rotation reuses block 0
+	if err := s.NextBlock(3 * 86400); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := _insertStoreRows(s, [][]interface{}{{"row301", "name002", 1000}}, 3*86400, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	sums, err = _sumStoreRows(s.SelectBlockRows(0, nil, selCols))
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("block 0 name002", sums["name002"], 1000); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("blocks", s.CountFromStatusTable(nil), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
+
+func Test_Store(t *testing.T) {
+	_testStore(t, CStorageCsv)
+	_testStore(t, CStorageBin)
+}
+
+func Test_CopyStore(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_CopyStore")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	cols := []string{"itemid", "name", "count"}
+	selCols := []string{"name", "count"}
+	s, err := NewStore(CStorageCsv, dataDir+"/csv", "teststore", cols, 0, 0, 0, 0, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := _insertStoreRows(s, [][]interface{}{{"row001", "name001", 10}}, 1, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := _insertStoreRows(s, [][]interface{}{{"row101", "name001", "1,2"}}, 2, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// csv -> bin -> csv
+	if err := CopyStore(dataDir+"/csv", dataDir+"/bin", "teststore", cols, CStorageCsv, CStorageBin, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := CopyStore(dataDir+"/bin", dataDir+"/csv2", "teststore", cols, CStorageBin, CStorageCsv, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for _, c := range []struct{ storage, rootDir string }{
+		{CStorageBin, dataDir + "/bin"}, {CStorageCsv, dataDir + "/csv2"}} {
+		s, err := NewStore(c.storage, c.rootDir, "teststore", cols, 0, 0, 0, 0, true)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		if err := s.LoadCircuitDBStatus(); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		rows, err := s.SelectRows(nil, nil, selCols)
+		if err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		values := make([]string, 0)
+		for rows.Next() {
+			var name, count string
+			if err := rows.Scan(&name, &count); err != nil {
+				t.Errorf("%v", err)
+				return
+			}
+			values = append(values, name+"="+count)
+		}
+		if err := utils.GetGotExpErr(c.rootDir, strings.Join(values, " "), "name001=10 name001=1,2"); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+}
@@ -20,6 +20,7 @@ type Table struct {
 	path           string
 	iBuff          *insertBuff
 	reader         *Reader
+	onWrite        func(row []string) // called with each row inserted or updated
 }
 
 func newTable(groupName, tableName, path string,
@@ -142,6 +143,19 @@ func (t *Table) SelectRows(conditionCheckFunc func([]string) bool,
 		t.path, t.columns, colNames, t.reader)
 }
 
+// Where compiles cond to a conditionCheckFunc for Count, Sum, SelectRows, Update and Delete
+func (t *Table) Where(cond *Cond) (func([]string) bool, error) {
+	return cond.Compile(t.columns, t.columnTypes)
+}
+
+func (t *Table) SelectWhere(cond *Cond, colNames []string) (*Rows, error) {
+	f, err := t.Where(cond)
+	if err != nil {
+		return nil, err
+	}
+	return t.SelectRows(f, colNames)
+}
+
 func (t *Table) Select1Row(conditionCheckFunc func([]string) bool,
 	colNames []string, args ...interface{}) error {
 	//if !pathExist(t.path) {
@@ -187,11 +201,17 @@ func (t *Table) readRows(conditionCheckFunc func([]string) bool) ([][]string, er
 }
 
 func (t *Table) InsertRow(columns []string, args ...interface{}) error {
+	_, err := t.insertRow(columns, args...)
+	return err
+}
+
+// insertRow returns the inserted row
+func (t *Table) insertRow(columns []string, args ...interface{}) ([]string, error) {
 	if columns == nil && len(args) != len(t.columns) {
-		return errors.New("len of args do not match to table columns")
+		return nil, errors.New("len of args do not match to table columns")
 	}
 	if columns != nil && len(columns) != len(args) {
-		return errors.New("len of columns and args do not match")
+		return nil, errors.New("len of columns and args do not match")
 	}
 
 	row := make([]string, len(t.columns))
@@ -203,7 +223,7 @@ func (t *Table) InsertRow(columns []string, args ...interface{}) error {
 		for i, col := range columns {
 			j, ok := t.colMap[col]
 			if !ok {
-				return errors.New(fmt.Sprintf("column %s does not exist", col))
+				return nil, errors.New(fmt.Sprintf("column %s does not exist", col))
 			}
 			row[j] = asString(args[i])
 		}
@@ -212,14 +232,17 @@ func (t *Table) InsertRow(columns []string, args ...interface{}) error {
 	if t.iBuff.register(row) {
 		t.Flush()
 	}
+	if t.onWrite != nil {
+		t.onWrite(row)
+	}
 
 	if t.readBufferSize > 0 {
 		if err := t.reader.append(row); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return row, nil
 }
 
 func (t *Table) Flush() error {
@@ -371,6 +394,9 @@ func (t *Table) update(conditionCheckFunc func([]string) bool,
 				for col, updv := range updates {
 					v[t.colMap[col]] = asString(updv)
 				}
+				if t.onWrite != nil {
+					t.onWrite(v)
+				}
 				isUpdated = true
 			}
 			rows = append(rows, v)
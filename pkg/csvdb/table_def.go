@@ -1,9 +1,10 @@
 package csvdb
 
 type TableDef struct {
-	groupName string
-	tableName string
-	path      string
+	groupName   string
+	tableName   string
+	path        string
+	columnTypes map[string]string
 }
 
 func newTableDef(groupName, tableName, path string) *TableDef {
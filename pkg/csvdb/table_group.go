@@ -3,7 +3,9 @@ package csvdb
 import (
 	"fmt"
 	"goLogAnalyzer/pkg/utils"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -18,6 +20,7 @@ type TableGroup struct {
 	iniFile        string
 	tableDefs      map[string]*TableDef
 	columns        []string
+	columnTypes    map[string]string
 	useGzip        bool
 	bufferSize     int
 	readBufferSize int
@@ -89,6 +92,7 @@ func (g *TableGroup) load(iniFile string) error {
 	}
 	tableNames := make([]string, 0)
 	columns := make([]string, 0)
+	columnTypes := make(map[string]string)
 	useGzip := false
 	bufferSize := cDefaultBuffSize
 	readBufferSize := 0
@@ -102,6 +106,12 @@ func (g *TableGroup) load(iniFile string) error {
 			tableNames = strings.Split(tableNameStr, ",")
 		case "columns":
 			columns = strings.Split(k.MustString(""), ",")
+		case "columnTypes":
+			for _, colType := range strings.Split(k.MustString(""), ",") {
+				if col, typ, ok := strings.Cut(colType, ":"); ok {
+					columnTypes[col] = typ
+				}
+			}
 		case "useGzip":
 			useGzip = k.MustBool(false)
 		case "bufferSize":
@@ -112,10 +122,12 @@ func (g *TableGroup) load(iniFile string) error {
 	}
 
 	g.init(columns, useGzip, bufferSize, readBufferSize)
+	g.columnTypes = columnTypes
 	tableDefs := make(map[string]*TableDef, len(tableNames))
 	for _, tableName := range tableNames {
 		tableDefs[tableName] = newTableDef(g.groupName,
 			tableName, g.getTablePath(tableName))
+		tableDefs[tableName].columnTypes = columnTypes
 	}
 	g.tableDefs = tableDefs
 
@@ -134,13 +146,17 @@ func (g *TableGroup) save() error {
 		i++
 	}
 
-	file, err := os.OpenFile(g.iniFile, os.O_CREATE, 0640)
+	iniFile, err := StagedPath(g.iniFile)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(iniFile, os.O_CREATE, 0640)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 	defer file.Close()
 
-	cfg, err := ini.Load(g.iniFile)
+	cfg, err := ini.Load(iniFile)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -150,8 +166,17 @@ func (g *TableGroup) save() error {
 	cfg.Section("conf").Key("useGzip").SetValue(strconv.FormatBool(g.useGzip))
 	cfg.Section("conf").Key("bufferSize").SetValue(strconv.Itoa(g.bufferSize))
 	cfg.Section("conf").Key("readBufferSize").SetValue(strconv.Itoa(g.readBufferSize))
+	if len(g.columnTypes) > 0 {
+		colTypes := make([]string, 0, len(g.columnTypes))
+		for _, col := range g.columns {
+			if typ, ok := g.columnTypes[col]; ok {
+				colTypes = append(colTypes, col+":"+typ)
+			}
+		}
+		cfg.Section("conf").Key("columnTypes").SetValue(strings.Join(colTypes, ","))
+	}
 
-	if err := cfg.SaveTo(g.iniFile); err != nil {
+	if err := cfg.SaveTo(iniFile); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -163,6 +188,30 @@ func (g *TableGroup) save() error {
 	return nil
 }
 
+/*
+SetColumnTypes declares the types of columns.
+Types are int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+float32, float64, bool or string. Columns without a type are compared as strings.
+*/
+func (g *TableGroup) SetColumnTypes(columnTypes map[string]string) error {
+	for col, colType := range columnTypes {
+		if err := checkColumnType(colType); err != nil {
+			return err
+		}
+		if !slices.Contains(g.columns, col) {
+			return errors.Errorf("col %s is not in the table", col)
+		}
+	}
+	if maps.Equal(g.columnTypes, columnTypes) {
+		return nil
+	}
+	g.columnTypes = columnTypes
+	for _, td := range g.tableDefs {
+		td.columnTypes = columnTypes
+	}
+	return g.save()
+}
+
 func (g *TableGroup) DropTable(tableName string) error {
 	t, err := g.GetTable(tableName)
 	if err != nil {
@@ -192,10 +241,10 @@ func (g *TableGroup) TableExists(tableName string) bool {
 	if g == nil || g.iniFile == "" {
 		return false
 	}
-	if !utils.PathExist(g.getTablePath(tableName)) {
+	if !utils.PathExist(resolvePath(g.getTablePath(tableName))) {
 		return false
 	}
-	if !utils.PathExist(g.iniFile) {
+	if !utils.PathExist(resolvePath(g.iniFile)) {
 		return false
 	}
 	_, ok := g.tableDefs[tableName]
@@ -208,8 +257,13 @@ func (g *TableGroup) GetTable(tableName string) (*Table, error) {
 	}
 	if _, ok := g.tableDefs[tableName]; ok {
 		path := g.getTablePath(tableName)
-		return newTable(g.groupName, tableName,
+		t, err := newTable(g.groupName, tableName,
 			path, g.columns, g.useGzip, g.bufferSize, g.readBufferSize)
+		if err != nil {
+			return nil, err
+		}
+		t.columnTypes = g.columnTypes
+		return t, nil
 	} else {
 		return g.CreateTable(tableName)
 	}
@@ -225,6 +279,7 @@ func (g *TableGroup) CreateTable(tableName string) (*Table, error) {
 	if err != nil {
 		return nil, err
 	}
+	t.columnTypes = g.columnTypes
 
 	g.tableDefs[tableName] = t.TableDef
 	if err := t.Flush(); err != nil {
@@ -0,0 +1,396 @@
+package csvdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Txn makes a set of file writes under rootDir atomic.
+
+While a Txn is active, every file written by csvdb (block tables, status tables, ini files)
+and every path passed through StagedPath() is written to "<path>.txn" instead.
+Commit() then
+ 1. fsyncs all staged files
+ 2. writes the manifest with state "pending" and the list of staged files, fsynced
+ 3. renames the staged files to the final names
+ 4. rewrites the manifest with state "committed" and a new generation number
+
+RecoverTxn() must be called before opening the rootDir.
+A "pending" manifest means all staged files are complete, so the renames are finished (roll forward).
+Staged files without a pending manifest are from an incomplete commit and are removed (roll back).
+*/
+type Txn struct {
+	rootDir string
+	gen     int64
+	staged  map[string]bool // final paths having a staged file
+	mu      sync.Mutex
+}
+
+type txnManifest struct {
+	Generation int64    `json:"generation"`
+	State      string   `json:"state"`
+	Files      []string `json:"files"`
+}
+
+// TxnReport describes what RecoverTxn() did to the rootDir
+type TxnReport struct {
+	Generation  int64
+	RolledBack  []string
+	RolledAhead []string
+}
+
+var (
+	activeTxns   = make(map[string]*Txn)
+	activeTxnsMu sync.Mutex
+)
+
+func getManifestPath(rootDir string) string {
+	return fmt.Sprintf("%s/%s", rootDir, cTxnManifest)
+}
+
+func readManifest(rootDir string) (*txnManifest, error) {
+	m := new(txnManifest)
+	path := getManifestPath(rootDir)
+	if !utils.PathExist(path) {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrapf(err, "broken manifest %s", path)
+	}
+	return m, nil
+}
+
+func writeManifest(rootDir string, m *txnManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	path := getManifestPath(rootDir)
+	tmpPath := path + cTxnSuffix
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return errors.WithStack(err)
+	}
+	return syncDir(rootDir)
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Sync())
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	return errors.WithStack(f.Sync())
+}
+
+// TxnGeneration returns the generation of the last completed commit in rootDir
+func TxnGeneration(rootDir string) (int64, error) {
+	m, err := readManifest(rootDir)
+	if err != nil {
+		return 0, err
+	}
+	return m.Generation, nil
+}
+
+// BeginTxn starts a transaction covering all files under rootDir
+func BeginTxn(rootDir string) (*Txn, error) {
+	rootDir = filepath.Clean(rootDir)
+	activeTxnsMu.Lock()
+	defer activeTxnsMu.Unlock()
+	if _, ok := activeTxns[rootDir]; ok {
+		return nil, errors.Errorf("a transaction is already active on %s", rootDir)
+	}
+	m, err := readManifest(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.State == cTxnStatePending {
+		return nil, errors.Errorf("%s has an interrupted commit. recover it first", rootDir)
+	}
+	tx := new(Txn)
+	tx.rootDir = rootDir
+	tx.gen = m.Generation
+	tx.staged = make(map[string]bool)
+	activeTxns[rootDir] = tx
+	return tx, nil
+}
+
+// find the active transaction covering the path
+func getTxn(path string) *Txn {
+	activeTxnsMu.Lock()
+	defer activeTxnsMu.Unlock()
+	if len(activeTxns) == 0 {
+		return nil
+	}
+	path = filepath.Clean(path)
+	for rootDir, tx := range activeTxns {
+		if strings.HasPrefix(path, rootDir+string(filepath.Separator)) {
+			return tx
+		}
+	}
+	return nil
+}
+
+// StagedPath returns the path to write to instead of path.
+// If no transaction covers path, path itself is returned.
+// The current content of path is copied to the staged file
+// so that the caller can append to it.
+func StagedPath(path string) (string, error) {
+	tx := getTxn(path)
+	if tx == nil {
+		return path, nil
+	}
+	return tx.stage(path)
+}
+
+// returns the path to read from.
+// staged content is visible to readers in the same process.
+func resolvePath(path string) string {
+	tx := getTxn(path)
+	if tx == nil {
+		return path
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.staged[filepath.Clean(path)] {
+		return path + cTxnSuffix
+	}
+	return path
+}
+
+func (tx *Txn) stage(path string) (string, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	path = filepath.Clean(path)
+	tmpPath := path + cTxnSuffix
+	if tx.staged[path] {
+		return tmpPath, nil
+	}
+	if err := copyOrTouch(path, tmpPath); err != nil {
+		return "", err
+	}
+	tx.staged[path] = true
+	return tmpPath, nil
+}
+
+func copyOrTouch(src, dst string) error {
+	if err := utils.EnsureDir(filepath.Dir(dst)); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer out.Close()
+	if !utils.PathExist(src) {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer in.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (tx *Txn) stagedFiles() []string {
+	files := make([]string, 0, len(tx.staged))
+	for path := range tx.staged {
+		rel, err := filepath.Rel(tx.rootDir, path)
+		if err != nil {
+			rel = path
+		}
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (tx *Txn) end() {
+	activeTxnsMu.Lock()
+	delete(activeTxns, tx.rootDir)
+	activeTxnsMu.Unlock()
+}
+
+// fsync staged files and write the pending manifest.
+// after prepare() the commit can always be completed
+func (tx *Txn) prepare() (*txnManifest, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	for path := range tx.staged {
+		if err := syncFile(path + cTxnSuffix); err != nil {
+			return nil, err
+		}
+	}
+	m := &txnManifest{
+		Generation: tx.gen + 1,
+		State:      cTxnStatePending,
+		Files:      tx.stagedFiles(),
+	}
+	if err := writeManifest(tx.rootDir, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Commit makes all staged files visible at once
+func (tx *Txn) Commit() error {
+	defer tx.end()
+	m, err := tx.prepare()
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+	if _, err := applyManifest(tx.rootDir, m); err != nil {
+		return err
+	}
+	tx.gen = m.Generation
+	return nil
+}
+
+// Rollback discards all staged files
+func (tx *Txn) Rollback() {
+	defer tx.end()
+	tx.rollback()
+}
+
+func (tx *Txn) rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	for path := range tx.staged {
+		os.Remove(path + cTxnSuffix)
+	}
+	tx.staged = make(map[string]bool)
+}
+
+// rename staged files listed in the manifest and mark it committed
+func applyManifest(rootDir string, m *txnManifest) ([]string, error) {
+	applied := make([]string, 0, len(m.Files))
+	dirs := make(map[string]bool)
+	for _, rel := range m.Files {
+		path := filepath.Join(rootDir, rel)
+		tmpPath := path + cTxnSuffix
+		if !utils.PathExist(tmpPath) {
+			// renamed before the interruption
+			continue
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			return applied, errors.WithStack(err)
+		}
+		dirs[filepath.Dir(path)] = true
+		applied = append(applied, rel)
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return applied, err
+		}
+	}
+	m.State = cTxnStateCommitted
+	m.Files = nil
+	if err := writeManifest(rootDir, m); err != nil {
+		return applied, err
+	}
+	return applied, nil
+}
+
+// HasPendingTxn returns true if rootDir has a commit which was interrupted
+func HasPendingTxn(rootDir string) bool {
+	m, err := readManifest(rootDir)
+	if err != nil {
+		return true
+	}
+	return m.State == cTxnStatePending
+}
+
+// RecoverTxn completes or rolls back a commit interrupted in rootDir
+func RecoverTxn(rootDir string) (*TxnReport, error) {
+	rootDir = filepath.Clean(rootDir)
+	report := new(TxnReport)
+	if !utils.PathExist(rootDir) {
+		return report, nil
+	}
+	m, err := readManifest(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if m.State == cTxnStatePending {
+		applied, err := applyManifest(rootDir, m)
+		report.RolledAhead = applied
+		if err != nil {
+			return report, err
+		}
+	}
+	report.Generation = m.Generation
+
+	// staged files not in a pending manifest are from an incomplete commit
+	err = filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, cTxnSuffix) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return errors.WithStack(err)
+		}
+		rel, _ := filepath.Rel(rootDir, strings.TrimSuffix(path, cTxnSuffix))
+		report.RolledBack = append(report.RolledBack, rel)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// IsEmpty returns true if there was nothing to recover
+func (r *TxnReport) IsEmpty() bool {
+	return len(r.RolledBack) == 0 && len(r.RolledAhead) == 0
+}
+
+func (r *TxnReport) String() string {
+	if r.IsEmpty() {
+		return fmt.Sprintf("generation %d: clean", r.Generation)
+	}
+	s := fmt.Sprintf("generation %d:", r.Generation)
+	if len(r.RolledAhead) > 0 {
+		s += fmt.Sprintf(" completed an interrupted commit (%d files: %s)",
+			len(r.RolledAhead), strings.Join(r.RolledAhead, ","))
+	}
+	if len(r.RolledBack) > 0 {
+		s += fmt.Sprintf(" rolled back an incomplete commit (%d files: %s)",
+			len(r.RolledBack), strings.Join(r.RolledBack, ","))
+	}
+	return s
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package csvdb
+
+// directories can't be fsynced on this platform
+func syncDir(dir string) error {
+	return nil
+}
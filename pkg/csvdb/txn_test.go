@@ -0,0 +1,162 @@
+package csvdb
+
+import (
+	"goLogAnalyzer/pkg/utils"
+	"testing"
+)
+
+func _countRows(cirdb *CircuitDB) int {
+	return cirdb.CountAll(nil)
+}
+
+func Test_Txn_commit(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_Txn_commit")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	cols := []string{"itemid", "name", "count"}
+	cirdb, err := NewCircuitDB(dataDir, "txndb", cols, 3, 0, 0, 0, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	tx, err := BeginTxn(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	inRows := [][]interface{}{
+		{"row001", "name001", "10"},
+		{"row002", "name002", "20"},
+	}
+	if err := _insertRows(cirdb, cols, inRows, 0, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// staged rows are visible in the same process but not on the disk
+	if err := utils.GetGotExpErr("staged rows", _countRows(cirdb), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if utils.PathExist(cirdb.currTable.path) {
+		t.Errorf("%s must not exist before commit", cirdb.currTable.path)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if !utils.PathExist(cirdb.currTable.path) {
+		t.Errorf("%s must exist after commit", cirdb.currTable.path)
+		return
+	}
+	gen, err := TxnGeneration(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("generation", gen, int64(1)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
+
+func Test_Txn_recover(t *testing.T) {
+	dataDir, err := utils.InitTestDir("Test_Txn_recover")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	cols := []string{"itemid", "name", "count"}
+	cirdb, err := NewCircuitDB(dataDir, "txndb", cols, 3, 0, 0, 0, false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	inRows := [][]interface{}{
+		{"row001", "name001", "10"},
+	}
+	if err := _insertRows(cirdb, cols, inRows, 0, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// interrupted before the manifest was written => roll back
+	tx, err := BeginTxn(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	inRows = [][]interface{}{
+		{"row002", "name002", "20"},
+	}
+	if err := _insertRows(cirdb, cols, inRows, 0, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	tx.end()
+
+	report, err := RecoverTxn(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("rolled back", len(report.RolledBack) > 0, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("rows after roll back", _countRows(cirdb), 1); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// interrupted after the manifest was written => roll forward
+	tx, err = BeginTxn(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	inRows = [][]interface{}{
+		{"row003", "name003", "30"},
+	}
+	if err := _insertRows(cirdb, cols, inRows, 0, false); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if _, err := tx.prepare(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	tx.end()
+
+	if !HasPendingTxn(dataDir) {
+		t.Errorf("pending transaction expected")
+		return
+	}
+	if _, err := BeginTxn(dataDir); err == nil {
+		t.Errorf("BeginTxn must fail on a pending transaction")
+		return
+	}
+
+	report, err = RecoverTxn(dataDir)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("rolled ahead", len(report.RolledAhead) > 0, true); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("rows after roll forward", _countRows(cirdb), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if HasPendingTxn(dataDir) {
+		t.Errorf("no pending transaction expected")
+		return
+	}
+}
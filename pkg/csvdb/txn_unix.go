@@ -0,0 +1,28 @@
+//go:build unix
+
+package csvdb
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// syncDir makes the renames in dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		// some filesystems do not support fsync on directories
+		if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) ||
+			errors.Is(err, syscall.EOPNOTSUPP) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -32,7 +32,11 @@ func newWriter(path, writeMode string) (*Writer, error) {
 		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
 	}
 
-	fw, err := os.OpenFile(path, flags, 0644)
+	stagedPath, err := StagedPath(path)
+	if err != nil {
+		return nil, err
+	}
+	fw, err = os.OpenFile(stagedPath, flags, 0644)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -3,6 +3,7 @@ package filepointer
 import (
 	"goLogAnalyzer/pkg/utils"
 	"io"
+	"os"
 
 	"github.com/pkg/errors"
 )
@@ -10,6 +11,7 @@ import (
 type FilePointer struct {
 	files    []string
 	epochs   []int64
+	sizes    []int64
 	r        *reader
 	lastRow  int
 	pos      int
@@ -46,6 +48,16 @@ func NewFilePointer(pathRegex string,
 
 	fp.files = targetFiles
 	fp.epochs = targetEpochs
+	fp.sizes = make([]int64, len(targetFiles))
+	for i, f := range targetFiles {
+		// stdin has no size
+		if f == "" {
+			continue
+		}
+		if fi, err := os.Stat(f); err == nil {
+			fp.sizes[i] = fi.Size()
+		}
+	}
 	fp.lastRow = lastRow
 	fp.pos = 0
 	fp.IsEOF = false
@@ -57,6 +69,37 @@ func (fp *FilePointer) CurrFileEpoch() int64 {
 	return fp.epochs[fp.pos]
 }
 
+// CurrFileMtime returns the mtime of the file the current line was read from. 0 for stdin
+func (fp *FilePointer) CurrFileMtime() int64 {
+	return fp.epochs[fp.currPos]
+}
+
+// CurrFileName returns the path of the file the current line was read from. "" for stdin
+func (fp *FilePointer) CurrFileName() string {
+	return fp.files[fp.currPos]
+}
+
+// TotalBytes returns the size of the files to read. 0 for stdin
+func (fp *FilePointer) TotalBytes() int64 {
+	total := int64(0)
+	for _, size := range fp.sizes {
+		total += size
+	}
+	return total
+}
+
+// ReadBytes returns the bytes read from the files so far, including the rows skipped to resume
+func (fp *FilePointer) ReadBytes() int64 {
+	read := int64(0)
+	for i := 0; i < fp.pos && i < len(fp.sizes); i++ {
+		read += fp.sizes[i]
+	}
+	if fp.r != nil {
+		read += fp.r.offset()
+	}
+	return read
+}
+
 func (fp *FilePointer) Err() error {
 	return fp.currErr
 }
@@ -3,6 +3,7 @@ package filepointer
 import (
 	"fmt"
 	"goLogAnalyzer/pkg/utils"
+	"os"
 	"testing"
 	"time"
 )
@@ -104,3 +105,48 @@ func Test_countNFiles(t *testing.T) {
 		t.Error("count does not match")
 	}
 }
+
+func TestFilePointer_bytes(t *testing.T) {
+	testDir, err := utils.InitTestDir("TestFilePointer_bytes")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for i, name := range []string{"a.log", "b.log"} {
+		path := fmt.Sprintf("%s/%s", testDir, name)
+		if err := os.WriteFile(path, []byte("line1\nline2\n"), 0644); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+		mtime := time.Now().Add(time.Duration(i-2) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Errorf("%v", err)
+			return
+		}
+	}
+	fp, err := NewFilePointer(testDir+"/*.log", 0, 0)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("total bytes", fp.TotalBytes(), int64(24)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := fp.Open(); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	// the first file is buffered at once
+	fp.Next()
+	if err := utils.GetGotExpErr("read bytes in a.log", fp.ReadBytes(), int64(12)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	for fp.Next() {
+	}
+	if err := utils.GetGotExpErr("read bytes", fp.ReadBytes(), int64(24)); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+}
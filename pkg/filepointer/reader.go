@@ -12,6 +12,7 @@ import (
 
 type reader struct {
 	fd       *os.File
+	cr       *countingReader
 	zr       *gzip.Reader
 	reader   *bufio.Reader
 	rowNum   int
@@ -35,6 +36,7 @@ func newReader(filename string) (*reader, error) {
 
 	lr := new(reader)
 	lr.fd = fd
+	lr.cr = &countingReader{r: fd}
 
 	ext := filepath.Ext(filename)
 	if ext == ".gz" || ext == ".gzip" {
@@ -44,14 +46,14 @@ func newReader(filename string) (*reader, error) {
 	}
 
 	if lr.mode == "gzip" {
-		zr, err := gzip.NewReader(fd)
+		zr, err := gzip.NewReader(lr.cr)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 		lr.reader = bufio.NewReader(zr)
 		lr.zr = zr
 	} else {
-		lr.reader = bufio.NewReader(fd)
+		lr.reader = bufio.NewReader(lr.cr)
 	}
 	//lr.scanner.Split(bufio.ScanBytes)
 	lr.filename = filename
@@ -94,6 +96,11 @@ func (lr *reader) text() string {
 	return lr.currText
 }
 
+// bytes read from the file so far. compressed bytes for gzip files
+func (lr *reader) offset() int64 {
+	return lr.cr.n
+}
+
 func (lr *reader) close() {
 	if lr.mode == "gzip" {
 		if lr.zr != nil {
@@ -116,3 +123,15 @@ func (lr *reader) isOpen() bool {
 	}
 	return true
 }
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,165 @@
+package logan
+
+import (
+	core "goLogAnalyzer/internal/logan"
+	"time"
+)
+
+// defaults of the logan command
+const (
+	DefaultN                 = core.CDefaultN
+	DefaultKeepPeriod        = core.CDefaultKeepPeriod
+	DefaultLockTimeout       = core.CDefaultLockTimeout
+	DefaultLintSampleLines   = core.CDefaultLintSampleLines
+	DefaultDetectSampleLines = core.CDefaultDetectSampleLines
+)
+
+/*
+Config is the configuration of a dataDir, the same as the config file of the logan command.
+The zero values take the defaults. A dataDir keeps the config it was created with,
+so most of the values only matter to New on a new dataDir.
+*/
+type Config struct {
+	DataDir             string
+	LogPath             string
+	LogFormat           string
+	MsgFormats          []string
+	PatternKeyRegexes   []string
+	TimestampLayout     string
+	UseUtcTime          bool
+	Timezone            string
+	BlockSize           int
+	MaxBlocks           int
+	KeepPeriod          int64
+	UnitSecs            int64
+	ReorderWindow       int64
+	SearchRegex         []string
+	ExcludeRegex        []string
+	TermCountBorderRate float64
+	TermCountBorder     int
+	MinMatchRate        float64
+	Keywords            []string
+	KeyRegexes          []string
+	Ignorewords         []string
+	IgnoreRegexes       []string
+	CustomLogGroups     []string
+	Maskers             []string
+	Separators          string
+	IgnoreNumbers       bool
+	Storage             string
+	Retention           []RetentionTier
+	MergedFrom          []string // the dataDirs Merge merged into this one
+	SourceFrom          string
+	RejectFile          string
+	MemoryBudget        int // MB of the terms and groups in memory
+}
+
+// RetentionTier keeps the counts of Keep units of UnitSecs
+type RetentionTier = core.RetentionTier
+
+/*
+Options are how New and Open run the analyzer.
+LastFileEpoch makes New skip the log files modified before it.
+A TestMode analyzer registers the lines only in memory, to try a config with Parse.
+*/
+type Options struct {
+	ReadOnly      bool
+	TestMode      bool
+	LastFileEpoch int64
+	Debug         bool
+}
+
+// ConfigProblem is a problem of a config value found by LintConfig
+type ConfigProblem = core.ConfigProblem
+
+// DetectedLogFormat is the result of DetectLogFormat
+type DetectedLogFormat = core.DetectedLogFormat
+
+// LintConfig checks the values of conf, sampling sampleLines lines of conf.LogPath
+func LintConfig(conf *Config, sampleLines int) []ConfigProblem {
+	return core.LintConfig(conf.toAnalConfig(), sampleLines)
+}
+
+// DetectLogFormat samples the first sampleLines lines of logPath and returns the known log format matching the most
+func DetectLogFormat(logPath string, sampleLines int) (*DetectedLogFormat, error) {
+	return core.DetectLogFormat(logPath, sampleLines)
+}
+
+// SetLockTimeout sets how long New and Open wait for the other processes using the same dataDir
+func SetLockTimeout(timeout time.Duration) {
+	core.SetLockTimeout(timeout)
+}
+
+func (conf *Config) toAnalConfig() *core.AnalConfig {
+	return &core.AnalConfig{
+		DataDir:             conf.DataDir,
+		LogPath:             conf.LogPath,
+		LogFormat:           conf.LogFormat,
+		MsgFormats:          conf.MsgFormats,
+		PatternKeyRegexes:   conf.PatternKeyRegexes,
+		TimestampLayout:     conf.TimestampLayout,
+		UseUtcTime:          conf.UseUtcTime,
+		Timezone:            conf.Timezone,
+		BlockSize:           conf.BlockSize,
+		MaxBlocks:           conf.MaxBlocks,
+		KeepPeriod:          conf.KeepPeriod,
+		UnitSecs:            conf.UnitSecs,
+		ReorderWindow:       conf.ReorderWindow,
+		SearchRegex:         conf.SearchRegex,
+		ExludeRegex:         conf.ExcludeRegex,
+		TermCountBorderRate: conf.TermCountBorderRate,
+		TermCountBorder:     conf.TermCountBorder,
+		MinMatchRate:        conf.MinMatchRate,
+		Keywords:            conf.Keywords,
+		KeyRegexes:          conf.KeyRegexes,
+		Ignorewords:         conf.Ignorewords,
+		IgnoreRegexes:       conf.IgnoreRegexes,
+		CustomLogGroups:     conf.CustomLogGroups,
+		Maskers:             conf.Maskers,
+		Separators:          conf.Separators,
+		IgnoreNumbers:       conf.IgnoreNumbers,
+		Storage:             conf.Storage,
+		Retention:           conf.Retention,
+		MergedFrom:          conf.MergedFrom,
+		SourceFrom:          conf.SourceFrom,
+		RejectFile:          conf.RejectFile,
+		MemoryBudget:        conf.MemoryBudget,
+	}
+}
+
+func fromAnalConfig(ac *core.AnalConfig) Config {
+	return Config{
+		DataDir:             ac.DataDir,
+		LogPath:             ac.LogPath,
+		LogFormat:           ac.LogFormat,
+		MsgFormats:          ac.MsgFormats,
+		PatternKeyRegexes:   ac.PatternKeyRegexes,
+		TimestampLayout:     ac.TimestampLayout,
+		UseUtcTime:          ac.UseUtcTime,
+		Timezone:            ac.Timezone,
+		BlockSize:           ac.BlockSize,
+		MaxBlocks:           ac.MaxBlocks,
+		KeepPeriod:          ac.KeepPeriod,
+		UnitSecs:            ac.UnitSecs,
+		ReorderWindow:       ac.ReorderWindow,
+		SearchRegex:         ac.SearchRegex,
+		ExcludeRegex:        ac.ExludeRegex,
+		TermCountBorderRate: ac.TermCountBorderRate,
+		TermCountBorder:     ac.TermCountBorder,
+		MinMatchRate:        ac.MinMatchRate,
+		Keywords:            ac.Keywords,
+		KeyRegexes:          ac.KeyRegexes,
+		Ignorewords:         ac.Ignorewords,
+		IgnoreRegexes:       ac.IgnoreRegexes,
+		CustomLogGroups:     ac.CustomLogGroups,
+		Maskers:             ac.Maskers,
+		Separators:          ac.Separators,
+		IgnoreNumbers:       ac.IgnoreNumbers,
+		Storage:             ac.Storage,
+		Retention:           ac.Retention,
+		MergedFrom:          ac.MergedFrom,
+		SourceFrom:          ac.SourceFrom,
+		RejectFile:          ac.RejectFile,
+		MemoryBudget:        ac.MemoryBudget,
+	}
+}
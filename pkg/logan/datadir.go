@@ -0,0 +1,29 @@
+package logan
+
+import (
+	core "goLogAnalyzer/internal/logan"
+	"goLogAnalyzer/pkg/csvdb"
+)
+
+// Fsck checks the files of dataDir. Repair fixes the repairable problems
+func Fsck(dataDir string) ([]*csvdb.Problem, error) {
+	return core.Fsck(dataDir)
+}
+
+// Migrate converts the stores of dataDir to storage, csv or bin
+func Migrate(dataDir, storage string) error {
+	return core.Migrate(dataDir, storage)
+}
+
+/*
+Merge creates outDir with the groups and counts of dataDirs,
+counted by source with the base names of dataDirs.
+*/
+func Merge(outDir string, dataDirs []string) error {
+	return core.Merge(outDir, dataDirs)
+}
+
+// ImportModel creates dataDir from the model file at path written by ExportModel
+func ImportModel(path, dataDir string) error {
+	return core.ImportModel(path, dataDir)
+}
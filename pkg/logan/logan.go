@@ -0,0 +1,365 @@
+/*
+Package logan embeds the log analyzer of the logan command in other programs.
+
+An Analyzer groups the lines it is fed into log groups, the lines of the same pattern
+with the rare terms replaced with "*", and counts them along the time in a dataDir,
+or in memory without one:
+
+	a, err := logan.New(&logan.Config{DataDir: "/var/lib/logan/app",
+		LogFormat: `^(?P<timestamp>\S+ \S+) (?P<message>.+)$`, TimestampLayout: "2006-01-02 15:04:05"})
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	a.OnNewGroup(func(g logan.Group) { log.Printf("new log group %s", g.DisplayString) })
+	if err := a.FeedReader(ctx, r); err != nil {
+		return err
+	}
+	groups, err := a.Groups(logan.Query{Limit: 10})
+
+The methods of an Analyzer are not safe for concurrent use.
+*/
+package logan
+
+import (
+	"context"
+	core "goLogAnalyzer/internal/logan"
+	"io"
+	"time"
+)
+
+// FeedStats counts the lines of a log file of the last feed by what became of them
+type FeedStats = core.FeedStats
+
+// Group is a log group, the lines of a pattern
+type Group struct {
+	GroupId       int64
+	Count         int // in the range of the Query
+	Score         float64
+	DisplayString string // the pattern with the rare terms replaced with "*"
+	LastMessage   string
+	Created       time.Time      // of the first line in the current block
+	Updated       time.Time      // of the last line
+	Sources       map[string]int // counts by source. nil without SourceFrom
+}
+
+/*
+Query selects the groups of Groups.
+From and To limit the counts to [From, To), the zero time for no limit on that side.
+Limit is 10 if 0, and no limit if negative. The groups are in descending order of the counts
+unless Asc.
+*/
+type Query struct {
+	Limit        int
+	Search       string // regex the display strings have to match
+	Exclude      string // regex the display strings must not match
+	UpdatedSince time.Time
+	From         time.Time
+	To           time.Time
+	MinCount     int
+	MaxCount     int // 0 for no limit
+	Asc          bool
+}
+
+// Point is the count of a group in a bucket of the timeline
+type Point struct {
+	Time  time.Time // the start of the bucket
+	Width time.Duration
+	Count int
+}
+
+// History is the counts of a group along the timeline, without the empty buckets
+type History struct {
+	GroupId       int64
+	DisplayString string
+	Points        []Point
+}
+
+// PatternCount is how many times a pattern appeared for a pattern key, or a relation key
+type PatternCount struct {
+	Key   string
+	Start time.Time
+	Count int
+}
+
+// Pattern is a sequence of groups repeated in the lines of the same pattern key
+type Pattern struct {
+	GroupIds       []int64
+	DisplayStrings []string // "" for the groups no longer kept
+	Total          int
+	Counts         []PatternCount // in descending order of the counts
+}
+
+// pattern detection modes of Patterns
+const (
+	PatternsByFirstMatch = "firstMatch"
+	PatternsByRelations  = "relations"
+)
+
+// ParseResult is how Parse parsed and grouped a line
+type ParseResult struct {
+	Timestamp      time.Time // zero if the timestamp is not parsed
+	Message        string
+	GroupId        int64 // -1 for the lines filtered out or not matching LogFormat
+	DisplayString  string
+	PatternKey     string
+	PatternMatched bool
+	Tags           map[string]string
+}
+
+// Analyzer feeds the lines to the log groups of a dataDir and queries them
+type Analyzer struct {
+	a *core.Analyzer
+}
+
+/*
+New creates an analyzer of conf.DataDir, or loads it if it exists.
+Without DataDir, everything is kept in memory until Close.
+*/
+func New(conf *Config) (*Analyzer, error) {
+	return NewWith(conf, Options{})
+}
+
+// NewWith is New with opts
+func NewWith(conf *Config, opts Options) (*Analyzer, error) {
+	a, err := core.NewAnalyzer(conf.toAnalConfig(), opts.LastFileEpoch, opts.ReadOnly, opts.TestMode)
+	if err != nil {
+		return nil, err
+	}
+	return &Analyzer{a: a}, nil
+}
+
+/*
+Open loads the analyzer of dataDir with the config it was created with.
+A readOnly one only queries dataDir and shares it with the other readers.
+*/
+func Open(dataDir string, readOnly bool) (*Analyzer, error) {
+	a, err := core.LoadAnalyzer(dataDir, "", 0, 0, 0, nil, readOnly, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Analyzer{a: a}, nil
+}
+
+/*
+OpenWith loads the analyzer of conf.DataDir like Open, with some values of conf over the saved ones:
+LogPath, TermCountBorderRate, TermCountBorder and MinMatchRate if set, rebuilding the groups
+when the last three change, and CustomLogGroups, IgnoreNumbers, RejectFile and MemoryBudget.
+*/
+func OpenWith(conf *Config, opts Options) (*Analyzer, error) {
+	a, err := core.LoadAnalyzer(conf.DataDir, conf.LogPath, conf.TermCountBorderRate, conf.TermCountBorder,
+		conf.MinMatchRate, conf.CustomLogGroups, opts.ReadOnly, opts.Debug, opts.TestMode, conf.IgnoreNumbers)
+	if err != nil {
+		return nil, err
+	}
+	a.RejectFile = conf.RejectFile
+	if conf.MemoryBudget > 0 {
+		a.SetMemoryBudget(conf.MemoryBudget)
+	}
+	return &Analyzer{a: a}, nil
+}
+
+// Close releases dataDir
+func (x *Analyzer) Close() {
+	x.a.Close()
+}
+
+// Config returns the config the analyzer runs with
+func (x *Analyzer) Config() Config {
+	return fromAnalConfig(x.a.AnalConfig)
+}
+
+/*
+OnNewGroup makes the feeds call f with each group they create, as the first line of it is registered.
+f runs in the feed and the feed waits for it. nil stops it.
+*/
+func (x *Analyzer) OnNewGroup(f func(Group)) {
+	if f == nil {
+		x.a.OnNewLogGroup(nil)
+		return
+	}
+	x.a.OnNewLogGroup(func(g core.LogGroup) { f(toGroup(g)) })
+}
+
+/*
+Feed feeds the lines of Config.LogPath from where the last Feed ended.
+When ctx is done, it stops at the next line, commits the lines it has registered
+and returns ctx.Err(). The next Feed goes on from there.
+*/
+func (x *Analyzer) Feed(ctx context.Context) error {
+	return x.feed(ctx, func() error { return x.a.Feed(0) })
+}
+
+/*
+FeedReader feeds the lines of r until EOF, in batches of lines so that r is not kept in memory.
+When ctx is done, it stops at the next line, commits the lines it has registered
+and returns ctx.Err(), even while a Read of r is blocked. The rest of r is not fed,
+and the blocked Read is left to return in the background.
+*/
+func (x *Analyzer) FeedReader(ctx context.Context, r io.Reader) error {
+	return x.feed(ctx, func() error { return x.a.FeedReader(r) })
+}
+
+// FeedLines feeds the lines from lines until it is closed, like FeedReader
+func (x *Analyzer) FeedLines(ctx context.Context, lines <-chan string) error {
+	return x.feed(ctx, func() error { return x.a.FeedLines(lines) })
+}
+
+// run feed stopping it when ctx is done
+func (x *Analyzer) feed(ctx context.Context, feed func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			x.a.Stop()
+		case <-done:
+		}
+	}()
+	if err := feed(); err != nil {
+		return err
+	}
+	if x.a.Interrupted() {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// FeedStats returns the statistics by file of the lines of the last feed
+func (x *Analyzer) FeedStats() []FeedStats {
+	return x.a.FeedStats()
+}
+
+/*
+ShowProgress makes the feeds show how far they have read the logs on w, a terminal,
+or log it every few seconds if w is not a terminal. nil stops it.
+*/
+func (x *Analyzer) ShowProgress(w io.Writer) {
+	x.a.ShowProgress(w)
+}
+
+// Groups returns the groups selected by q
+func (x *Analyzer) Groups(q Query) ([]Group, error) {
+	lgs, err := x.a.LogGroups(core.LogGroupsQuery{
+		N:             q.Limit,
+		Search:        q.Search,
+		Exclude:       q.Exclude,
+		MinLastUpdate: toEpoch(q.UpdatedSince),
+		From:          toEpoch(q.From),
+		To:            toEpoch(q.To),
+		MinCount:      q.MinCount,
+		MaxCount:      q.MaxCount,
+		Asc:           q.Asc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]Group, len(lgs))
+	for i, lg := range lgs {
+		groups[i] = toGroup(lg)
+	}
+	return groups, nil
+}
+
+// History returns the counts of groupId in [from, to). the zero time for no limit on that side
+func (x *Analyzer) History(groupId int64, from, to time.Time) (*History, error) {
+	lgh, err := x.a.LogGroupHistory(groupId, toEpoch(from), toEpoch(to))
+	if err != nil {
+		return nil, err
+	}
+	h := &History{GroupId: lgh.GroupId, DisplayString: lgh.DisplayString,
+		Points: make([]Point, len(lgh.Points))}
+	for i, p := range lgh.Points {
+		h.Points[i] = Point{Time: time.Unix(p.Epoch, 0), Width: time.Duration(p.Secs) * time.Second, Count: p.Count}
+	}
+	return h, nil
+}
+
+/*
+Patterns returns the sequences of groups in the lines of the pattern keys of Config.PatternKeyRegexes
+repeated minCount times or more, in descending order of the totals.
+mode is PatternsByFirstMatch or PatternsByRelations.
+*/
+func (x *Analyzer) Patterns(minCount int, mode string) ([]Pattern, error) {
+	ps, err := x.a.Patterns(minCount, mode)
+	if err != nil {
+		return nil, err
+	}
+	patterns := make([]Pattern, len(ps))
+	for i, p := range ps {
+		patterns[i] = Pattern{GroupIds: p.GroupIds, DisplayStrings: p.DisplayStrings, Total: p.Total,
+			Counts: make([]PatternCount, len(p.Counts))}
+		for j, c := range p.Counts {
+			patterns[i].Counts[j] = PatternCount{Key: c.Key, Start: time.Unix(c.Start, 0), Count: c.Count}
+		}
+	}
+	return patterns, nil
+}
+
+// Parse registers line like the feeds and returns how it was parsed and grouped
+func (x *Analyzer) Parse(line string) (*ParseResult, error) {
+	p, err := x.a.ParseLine(line)
+	if err != nil {
+		return nil, err
+	}
+	return &ParseResult{
+		Timestamp:      fromEpoch(p.Epoch),
+		Message:        p.Message,
+		GroupId:        p.GroupId,
+		DisplayString:  p.DisplayString,
+		PatternKey:     p.PatternKey,
+		PatternMatched: p.PatternMatched,
+		Tags:           p.Tags,
+	}, nil
+}
+
+/*
+Classify writes the lines of r with their groups to w as NDJSON without registering them.
+Open the analyzer read only to classify the lines with the groups of a running feed.
+*/
+func (x *Analyzer) Classify(ctx context.Context, r io.Reader, w io.Writer) error {
+	return x.a.Classify(&ctxReader{ctx: ctx, r: r}, w)
+}
+
+func toGroup(lg core.LogGroup) Group {
+	return Group{
+		GroupId:       lg.GroupId,
+		Count:         lg.Count,
+		Score:         lg.Score,
+		DisplayString: lg.DisplayString,
+		LastMessage:   lg.LastMessage,
+		Created:       fromEpoch(lg.Created),
+		Updated:       fromEpoch(lg.Updated),
+		Sources:       lg.Sources,
+	}
+}
+
+func toEpoch(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromEpoch(epoch int64) time.Time {
+	if epoch <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(epoch, 0)
+}
+
+// a reader failing with ctx.Err() at the next Read once ctx is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
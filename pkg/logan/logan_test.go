@@ -0,0 +1,358 @@
+package logan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goLogAnalyzer/pkg/utils"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func _newTestConfig(testName string) (*Config, error) {
+	testDir, err := utils.InitTestDir(testName)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		DataDir:         testDir + "/data",
+		LogFormat:       `^(?P<timestamp>\d+-\d+-\d+T\d+:\d+:\d+)] (?P<message>.+)$`,
+		TimestampLayout: "2006-01-02T15:04:05",
+		UseUtcTime:      true,
+		UnitSecs:        3600 * 24,
+		TermCountBorder: 2,
+		MinMatchRate:    0.6,
+	}, nil
+}
+
+func _testLines() []string {
+	lines := make([]string, 0)
+	for day := 1; day <= 2; day++ {
+		for i := 0; i < 3; i++ {
+			lines = append(lines, fmt.Sprintf("2024-10-%02dT00:00:%02d] alpha apple", day, i))
+		}
+		lines = append(lines, fmt.Sprintf("2024-10-%02dT01:00:00] bravo banana", day))
+	}
+	return lines
+}
+
+func Test_Analyzer_FeedLines(t *testing.T) {
+	conf, err := _newTestConfig("Test_Analyzer_FeedLines")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := New(conf)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	created := make([]string, 0)
+	a.OnNewGroup(func(g Group) {
+		created = append(created, g.DisplayString)
+	})
+
+	lines := make(chan string)
+	go func() {
+		for _, line := range _testLines() {
+			lines <- line
+		}
+		close(lines)
+	}()
+	if err := a.FeedLines(context.Background(), lines); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("new groups", strings.Join(created, ","), "alpha apple,bravo banana"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	groups, err := a.Groups(Query{})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("groups", len(groups), 2); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	g := groups[0]
+	if err := utils.GetGotExpErr("top group", g.DisplayString, "alpha apple"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("count", g.Count, 6); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("last message", g.LastMessage, "2024-10-02T00:00:02] alpha apple"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	// the counts of the second day only
+	day2 := time.Date(2024, 10, 2, 0, 0, 0, 0, time.UTC)
+	groups, err = a.Groups(Query{From: day2, Search: "banana"})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(groups) != 1 || groups[0].Count != 1 {
+		t.Errorf("groups of banana from %v: %+v", day2, groups)
+		return
+	}
+
+	h, err := a.History(g.GroupId, time.Time{}, time.Time{})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	points := make([]string, len(h.Points))
+	for i, p := range h.Points {
+		points[i] = fmt.Sprintf("%s=%d", p.Time.UTC().Format("2006-01-02"), p.Count)
+	}
+	if err := utils.GetGotExpErr("history", strings.Join(points, ","), "2024-10-01=3,2024-10-02=3"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.Close()
+
+	// the groups are in dataDir
+	b, err := Open(conf.DataDir, true)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	groups, err = b.Groups(Query{Limit: -1})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("loaded groups", len(groups), 2); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_Analyzer_FeedReader(t *testing.T) {
+	// without dataDir in memory
+	a, err := New(&Config{
+		LogFormat:       `^(?P<timestamp>\d+-\d+-\d+T\d+:\d+:\d+)] (?P<message>.+)$`,
+		TimestampLayout: "2006-01-02T15:04:05",
+		UseUtcTime:      true,
+		TermCountBorder: 2,
+	})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := strings.NewReader(strings.Join(_testLines(), "\n") + "\n")
+	if err := a.FeedReader(ctx, r); !errors.Is(err, context.Canceled) {
+		t.Errorf("feed with a canceled context returned %v", err)
+		return
+	}
+	if err := a.FeedReader(context.Background(), r); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	groups, err := a.Groups(Query{})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	counts := make([]string, len(groups))
+	for i, g := range groups {
+		counts[i] = fmt.Sprintf("%s=%d", g.DisplayString, g.Count)
+	}
+	sort.Strings(counts)
+	if err := utils.GetGotExpErr("groups", strings.Join(counts, ","), "alpha apple=6,bravo banana=2"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	p, err := a.Parse("2024-10-03T00:00:00] alpha apple")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("timestamp", p.Timestamp.UTC().Format(time.RFC3339), "2024-10-03T00:00:00Z"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("parsed group", p.GroupId, groups[0].GroupId); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if err := utils.GetGotExpErr("message", p.Message, "alpha apple"); err != nil {
+		t.Errorf("%v", err)
+	}
+}
+
+func Test_Analyzer_FeedReader_blocked(t *testing.T) {
+	conf, err := _newTestConfig("Test_Analyzer_FeedReader_blocked")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := New(conf)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+
+	// the reader blocks after the lines of the first day until it is closed
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		pw.Write([]byte(strings.Join(_testLines()[:4], "\n") + "\n"))
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a.OnNewGroup(func(g Group) {
+		if g.DisplayString == "bravo banana" {
+			cancel()
+		}
+	})
+	errc := make(chan error, 1)
+	go func() { errc <- a.FeedReader(ctx, pr) }()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("feed returned %v", err)
+			return
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("feed did not return on a blocked Read")
+		return
+	}
+
+	// the lines before the cancel are committed
+	groups, err := a.Groups(Query{})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	counts := make([]string, len(groups))
+	for i, g := range groups {
+		counts[i] = fmt.Sprintf("%s=%d", g.DisplayString, g.Count)
+	}
+	sort.Strings(counts)
+	if err := utils.GetGotExpErr("groups", strings.Join(counts, ","), "alpha apple=3,bravo banana=1"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	stats := a.FeedStats()
+	if len(stats) != 1 || stats[0].File != "reader" || stats[0].Grouped != 4 {
+		t.Errorf("feed stats %+v", stats)
+	}
+}
+
+func Test_Config_ExcludeRegex(t *testing.T) {
+	conf, err := _newTestConfig("Test_Config_ExcludeRegex")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	conf.ExcludeRegex = []string{"banana"}
+	a, err := New(conf)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	r := strings.NewReader(strings.Join(_testLines(), "\n") + "\n")
+	if err := a.FeedReader(context.Background(), r); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a.Close()
+
+	// the saved config comes back with the lines excluded
+	b, err := OpenWith(&Config{DataDir: conf.DataDir}, Options{ReadOnly: true})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer b.Close()
+	if err := utils.GetGotExpErr("excludeRegex", fmt.Sprint(b.Config().ExcludeRegex), "[banana]"); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	groups, err := b.Groups(Query{Limit: -1})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(groups) != 1 || groups[0].DisplayString != "alpha apple" {
+		t.Errorf("groups excluding banana: %+v", groups)
+	}
+}
+
+func Test_Analyzer_WriteGroups(t *testing.T) {
+	conf, err := _newTestConfig("Test_Analyzer_WriteGroups")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	a, err := New(conf)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	defer a.Close()
+	r := strings.NewReader(strings.Join(_testLines(), "\n") + "\n")
+	if err := a.FeedReader(context.Background(), r); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	outDir := conf.DataDir + "/out"
+	if err := a.WriteGroups(outDir, Query{Search: "apple"}, true, ""); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	_, rows, err := utils.ReadCsv(outDir+"/logGroups.csv", ',', false)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(rows) != 1 || rows[0][1] != "6" || rows[0][3] != "alpha apple" {
+		t.Errorf("logGroups.csv: %v", rows)
+		return
+	}
+	if !utils.PathExist(outDir + "/history.csv") {
+		t.Errorf("no history.csv in %s", outDir)
+		return
+	}
+
+	// the search of the query does not filter the lines fed after it
+	if err := a.FeedLines(context.Background(), _lines("2024-10-03T00:00:00] bravo banana")); err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	groups, err := a.Groups(Query{Search: "banana"})
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if len(groups) != 1 || groups[0].Count != 3 {
+		t.Errorf("groups of banana: %+v", groups)
+	}
+}
+
+func _lines(lines ...string) <-chan string {
+	c := make(chan string, len(lines))
+	for _, line := range lines {
+		c <- line
+	}
+	close(c)
+	return c
+}
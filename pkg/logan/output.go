@@ -0,0 +1,78 @@
+package logan
+
+import (
+	core "goLogAnalyzer/internal/logan"
+	"time"
+)
+
+// the dimension of WriteGroups to break down the counts by
+const GroupBySource = core.CGroupBySource
+
+// formats of Export
+const (
+	ExportFormatSqlite  = core.CExportFormatSqlite
+	ExportFormatParquet = core.CExportFormatParquet
+)
+
+// formats and defaults of Check
+const (
+	CheckFormatJson      = core.CCheckFormatJson
+	CheckFormatJunit     = core.CCheckFormatJunit
+	DefaultMaxShareDiff  = core.CDefaultMaxShareDiff
+	DefaultCheckMinLines = core.CDefaultCheckMinLines
+)
+
+// CheckGroup is a group of the log checked by Check
+type CheckGroup = core.CheckGroup
+
+// CheckResult is the result of Check. Write writes it as CheckFormatJson or CheckFormatJunit
+type CheckResult = core.CheckResult
+
+// TUIOptions are the hooks of TUI to the outside of the dataDir
+type TUIOptions = core.TUIOptions
+
+/*
+WriteGroups writes the groups selected by q to logGroups.csv and logGroups_last.csv in outDir.
+history adds the counts of the groups along the timeline to history.csv and history_info.json,
+and by GroupBySource the counts by source to logGroups_by_source.csv.
+*/
+func (x *Analyzer) WriteGroups(outDir string, q Query, history bool, by string) error {
+	return x.a.OutputLogGroups(q.Limit, outDir, q.Search, q.Exclude, toEpoch(q.UpdatedSince),
+		toEpoch(q.From), toEpoch(q.To), q.MinCount, q.MaxCount, history, q.Asc, by)
+}
+
+/*
+Export writes the groups, their history, terms, pattern keys and pattern tags to tables in path,
+a database file for ExportFormatSqlite and a directory of files for ExportFormatParquet.
+*/
+func (x *Analyzer) Export(format, path string) error {
+	return x.a.Export(format, path)
+}
+
+// ExportModel writes the groups and terms to the model file at path for ImportModel
+func (x *Analyzer) ExportModel(path string) error {
+	return x.a.ExportModel(path)
+}
+
+/*
+Report writes an HTML report of the top n groups in [from, to) to path, with the anomalies
+over stdThreshold of the groups appearing minOccurrences times or more.
+*/
+func (x *Analyzer) Report(n int, path string, from, to time.Time, stdThreshold, minOccurrences float64) error {
+	return x.a.Report(n, path, toEpoch(from), toEpoch(to), stdThreshold, minOccurrences)
+}
+
+// TUI browses the groups on the terminal until the user quits
+func (x *Analyzer) TUI(opts TUIOptions) error {
+	return x.a.TUI(opts)
+}
+
+/*
+Check classifies the lines of logPath against the groups of the analyzer, the baseline fed with
+known-good logs, and reports the new groups and the groups whose share of lines moved more
+than maxShareDiff. Logs shorter than minLines are only checked for new groups.
+Open the baseline read only.
+*/
+func (x *Analyzer) Check(logPath string, maxShareDiff float64, minLines int) (*CheckResult, error) {
+	return x.a.Check(logPath, maxShareDiff, minLines)
+}
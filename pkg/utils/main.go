@@ -50,41 +50,148 @@ func Str2Timestamp(dateFormat, dateStr string) (time.Time, error) {
 }
 
 func Str2date(dateFormat, dateStr string) (time.Time, error) {
-	parsedDate, err := time.Parse(dateFormat, dateStr)
+	return Str2dateIn(dateFormat, dateStr, time.Local, time.Now(), time.Time{})
+}
+
+/*
+Str2dateIn parses dateStr in loc, or in the zone of dateStr when dateFormat has one.
+Missing parts are filled in from ref; a missing year is the one of ref,
+or the one before when the date of the line would be after ref.
+last is the time of the previous line of the same file, zero for the first one.
+A date more than half a year before last is taken as a year rollover (Dec to Jan) and moved to the next year.
+That happens when the lines go on past ref, like a file still written after its mtime was taken.
+Local times repeated when DST ends are the first of the two unless it is before last,
+and local times skipped when DST starts are read with the offset before the change.
+Sub-second precision is kept.
+*/
+func Str2dateIn(dateFormat, dateStr string, loc *time.Location, ref, last time.Time) (time.Time, error) {
+	// the wall clock as it is, even in the hour a DST change skips
+	parseLoc := time.UTC
+	if HasZoneLayout(dateFormat) {
+		parseLoc = loc
+	}
+	parsedDate, err := time.ParseInLocation(dateFormat, dateStr, parseLoc)
 	if err != nil {
 		return time.Time{}, err
 	}
-
-	now := time.Now()
+	// the zone of the line
+	if HasZoneLayout(dateFormat) {
+		loc = parsedDate.Location()
+	}
+	ref = ref.In(loc)
 
 	// Use parsed date components, but fill in missing parts with defaults
 	year := parsedDate.Year()
 	month := parsedDate.Month()
 	day := parsedDate.Day()
-
-	if month == 0 || (year == 0 && !strings.Contains(dateFormat, "01") && !strings.Contains(dateFormat, "Jan")) {
-		month = now.Month()
+	hour, minute, second := parsedDate.Hour(), parsedDate.Minute(), parsedDate.Second()
+	nsec := parsedDate.Nanosecond()
+	if year != 0 {
+		return resolveLocalTime(year, month, day, hour, minute, second, nsec, loc, last), nil
 	}
 
-	if day == 0 || (year == 0 && !strings.Contains(dateFormat, "2")) {
-		day = now.Day()
+	hasMonth := month != 0 && (strings.Contains(dateFormat, "01") || strings.Contains(dateFormat, "Jan"))
+	if !hasMonth {
+		month = ref.Month()
 	}
 
-	if year == 0 {
-		year = now.Year()
+	if day == 0 || !strings.Contains(dateFormat, "2") {
+		day = ref.Day()
 	}
 
-	// Adjust year if the parsed month is in the future compared to the current month
-	if month > now.Month() && year == now.Year() {
+	year = ref.Year()
+	finalDate := resolveLocalTime(year, month, day, hour, minute, second, nsec, loc, last)
+	// a date after ref is of the year before. Oct 25 in a file of Oct 19 is of last year
+	if hasMonth && finalDate.After(ref) {
 		year--
+		finalDate = resolveLocalTime(year, month, day, hour, minute, second, nsec, loc, last)
 	}
 
-	hour, minute, second := parsedDate.Hour(), parsedDate.Minute(), parsedDate.Second()
-
-	finalDate := time.Date(year, month, day, hour, minute, second, 0, time.Local)
+	if !last.IsZero() && finalDate.Before(last.AddDate(0, -6, 0)) {
+		finalDate = resolveLocalTime(year+1, month, day, hour, minute, second, nsec, loc, last)
+	}
 	return finalDate, nil
 }
 
+// HasZoneLayout tells if the time layout has a time zone name or offset
+func HasZoneLayout(layout string) bool {
+	for _, zone := range []string{"MST", "Z07", "-07", "-7"} {
+		if strings.Contains(layout, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLocalTime is time.Date with the choice for the local times DST repeats or skips
+func resolveLocalTime(year int, month time.Month, day, hour, minute, second, nsec int,
+	loc *time.Location, last time.Time) time.Time {
+	t := time.Date(year, month, day, hour, minute, second, nsec, loc)
+	_, before := t.Add(-12 * time.Hour).Zone()
+	_, after := t.Add(12 * time.Hour).Zone()
+	if before == after {
+		return t
+	}
+
+	// the wall clock read as UTC, minus each offset
+	wall := time.Date(year, month, day, hour, minute, second, nsec, time.UTC)
+	byBefore := wall.Add(-time.Duration(before) * time.Second)
+	byAfter := wall.Add(-time.Duration(after) * time.Second)
+	firstOk := sameWallClock(byBefore.In(loc), wall)
+	secondOk := sameWallClock(byAfter.In(loc), wall)
+	switch {
+	case firstOk && secondOk:
+		earlier, later := byBefore, byAfter
+		if later.Before(earlier) {
+			earlier, later = later, earlier
+		}
+		if earlier.Before(last) {
+			return later.In(loc)
+		}
+		return earlier.In(loc)
+	case firstOk:
+		return byBefore.In(loc)
+	case secondOk:
+		return byAfter.In(loc)
+	}
+	// skipped, so the offset before the change
+	return byBefore.In(loc)
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	wy, wmo, wd := wall.Date()
+	wh, wmi, ws := wall.Clock()
+	return y == wy && mo == wmo && d == wd && h == wh && mi == wmi && s == ws
+}
+
+// EpochStr2Time parses epoch seconds with an optional fraction, or epoch milliseconds
+func EpochStr2Time(s string, millis bool) (time.Time, error) {
+	if millis {
+		msecs, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(msecs).UTC(), nil
+	}
+	secs, frac, _ := strings.Cut(s, ".")
+	epoch, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	nsecs := int64(0)
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if nsecs, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(epoch, nsecs).UTC(), nil
+}
+
 // roundInt
 func roundInt(num float64) float64 {
 	t := math.Trunc(num)
@@ -743,6 +850,46 @@ func GetNdaysBefore(N int) int64 {
 	return epoch
 }
 
+/*
+ParseTimeArg converts a time given on the command line to an epoch.
+
+  - epoch seconds: 1727740800
+  - RFC3339: 2024-10-01T00:00:00Z
+  - date: 2024-10-01 in the local time
+  - duration relative to now: -2h, -30m, -7d
+*/
+func ParseTimeArg(s string, now time.Time) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if IsInt(s) {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.Unix(), nil
+	}
+
+	// time.ParseDuration does not know days
+	d := s
+	days := int64(0)
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.ParseInt(strings.TrimSuffix(s, "d"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %s", s)
+		}
+		days = n
+		d = "0s"
+	}
+	dur, err := time.ParseDuration(d)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s. epoch, RFC3339, yyyy-mm-dd or a duration like -2h", s)
+	}
+	return now.Add(dur).AddDate(0, 0, int(days)).Unix(), nil
+}
+
 func EpochToString(epoch int64) string {
 	// Convert epoch to time.Time
 	t := time.Unix(epoch, 0)
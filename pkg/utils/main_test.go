@@ -33,6 +33,15 @@ func Test_Replace(t *testing.T) {
 }
 
 func Test_Str2date(t *testing.T) {
+	// a date without the year is the last one until now
+	now := time.Now()
+	lastDate := func(month time.Month, day, hour, min, sec int) time.Time {
+		d := time.Date(now.Year(), month, day, hour, min, sec, 0, time.Local)
+		if d.After(now) {
+			d = d.AddDate(-1, 0, 0)
+		}
+		return d
+	}
 	tests := []struct {
 		title      string
 		format     string
@@ -44,7 +53,7 @@ func Test_Str2date(t *testing.T) {
 			title:    "Syslog format without year",
 			format:   "Jan  2 15:04:05",
 			input:    "Nov  1 03:13:26",
-			expected: time.Date(time.Now().Year(), 11, 1, 3, 13, 26, 0, time.Local),
+			expected: lastDate(11, 1, 3, 13, 26),
 		},
 		{
 			title:    "Time only",
@@ -56,13 +65,13 @@ func Test_Str2date(t *testing.T) {
 			title:    "Month-Day",
 			format:   "01-02",
 			input:    "12-25",
-			expected: time.Date(time.Now().Year()-1, 12, 25, 0, 0, 0, 0, time.Local),
+			expected: lastDate(12, 25, 0, 0, 0),
 		},
 		{
 			title:    "Month-Day Time",
 			format:   "01-02 15:04:05",
 			input:    "12-25 08:15:30",
-			expected: time.Date(time.Now().Year()-1, 12, 25, 8, 15, 30, 0, time.Local),
+			expected: lastDate(12, 25, 8, 15, 30),
 		},
 		{
 			title:    "Day only",
@@ -106,6 +115,67 @@ func Test_Str2date(t *testing.T) {
 	}
 }
 
+func Test_Str2dateIn(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	syslog := "Jan _2 15:04:05"
+	jan2 := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	dec31 := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+	tests := []struct {
+		title    string
+		format   string
+		input    string
+		loc      *time.Location
+		ref      time.Time
+		last     time.Time
+		expected time.Time
+	}{
+		{"December read in January", syslog, "Dec 31 23:59:59", time.UTC, jan2, time.Time{}, dec31},
+		{"January after December", syslog, "Jan  1 00:00:01", time.UTC, jan2, dec31,
+			time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{"rollover after the mtime", syslog, "Jan  1 00:00:01", time.UTC, dec31, dec31,
+			time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{"later day of the month of ref", syslog, "Oct 25 10:00:00", time.UTC,
+			time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC), time.Time{},
+			time.Date(2024, 10, 25, 10, 0, 0, 0, time.UTC)},
+		{"earlier day of the month of ref", syslog, "Oct 18 10:00:00", time.UTC,
+			time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC), time.Time{},
+			time.Date(2025, 10, 18, 10, 0, 0, 0, time.UTC)},
+		{"milliseconds", "Jan _2 15:04:05.000", "Mar  3 10:00:00.123", time.UTC, jan2, time.Time{},
+			time.Date(2024, 3, 3, 10, 0, 0, 123000000, time.UTC)},
+		{"timezone", "2006-01-02 15:04:05", "2024-03-03 10:00:00", tokyo, jan2, time.Time{},
+			time.Date(2024, 3, 3, 1, 0, 0, 0, time.UTC)},
+		{"zone of the line", "2006-01-02 15:04:05 -0700", "2024-03-03 10:00:00 +0100", tokyo, jan2, time.Time{},
+			time.Date(2024, 3, 3, 9, 0, 0, 0, time.UTC)},
+		{"first of repeated local times", "2006-01-02 15:04:05", "2024-11-03 01:30:00", ny, jan2, time.Time{},
+			time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC)},
+		{"second of repeated local times", "2006-01-02 15:04:05", "2024-11-03 01:30:00", ny, jan2,
+			time.Date(2024, 11, 3, 5, 59, 0, 0, time.UTC),
+			time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC)},
+		{"skipped local time", "2006-01-02 15:04:05", "2024-03-10 02:30:00", ny, jan2, time.Time{},
+			time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := Str2dateIn(test.format, test.input, test.loc, test.ref, test.last)
+		if err != nil {
+			t.Errorf("%s: %v", test.title, err)
+			return
+		}
+		if !got.Equal(test.expected) {
+			t.Errorf("%s got=%v expected=%v", test.title, got.UTC(), test.expected)
+			return
+		}
+	}
+}
+
 func Test_NextDivisibleByN(t *testing.T) {
 	tests := []struct {
 		i        int
@@ -126,3 +196,57 @@ func Test_NextDivisibleByN(t *testing.T) {
 		}
 	}
 }
+
+func Test_ParseTimeArg(t *testing.T) {
+	now := time.Date(2024, 10, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		s        string
+		expected int64
+	}{
+		{"", 0},
+		{"1727740800", 1727740800},
+		{"2024-10-01T00:00:00Z", 1727740800},
+		{"2024-10-01T09:00:00+09:00", 1727740800},
+		{"-2h", now.Unix() - 7200},
+		{"-1d", now.Unix() - 86400},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimeArg(tt.s, now)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		if err := GetGotExpErr(tt.s, got, tt.expected); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := ParseTimeArg("yesterday", now); err == nil {
+		t.Errorf("unknown time format must be an error")
+	}
+}
+
+func Test_EpochStr2Time(t *testing.T) {
+	tests := []struct {
+		s        string
+		millis   bool
+		expected int64
+	}{
+		{"1727740800", false, 1727740800000},
+		{"1727740800.25", false, 1727740800250},
+		{"1727740800250", true, 1727740800250},
+	}
+	for _, tt := range tests {
+		got, err := EpochStr2Time(tt.s, tt.millis)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		if err := GetGotExpErr(tt.s, got.UnixMilli(), tt.expected); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := EpochStr2Time("2024-10-01", false); err == nil {
+		t.Errorf("non numeric epoch must be an error")
+	}
+}